@@ -36,13 +36,25 @@ func main() {
 
 	application := app.New(ctx, cfg)
 	go application.GrpcApp.MustRun(ctx)
+	if application.HTTPApp != nil {
+		go application.HTTPApp.MustRun(ctx)
+	}
 	go application.Redpanda.Start(ctx)
+	go application.Outbox.Start(ctx)
+	go application.Calendar.Start(ctx)
+	go application.Syncer.Start(ctx)
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
 	<-stop
 
 	application.GrpcApp.Stop(ctx)
+	if application.HTTPApp != nil {
+		application.HTTPApp.Stop(ctx)
+	}
+	application.Outbox.Stop(ctx)
+	application.Syncer.Stop(ctx)
+	application.Calendar.Stop(ctx)
 	application.Redpanda.Stop(ctx)
 	log.Info(ctx, "application stopped")
 }
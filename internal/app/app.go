@@ -4,10 +4,14 @@ import (
 	"context"
 
 	"github.com/hesoyamTM/apphelper-schedule/internal/app/grpcapp"
+	"github.com/hesoyamTM/apphelper-schedule/internal/app/httpapp"
 	"github.com/hesoyamTM/apphelper-schedule/internal/clients"
 	"github.com/hesoyamTM/apphelper-schedule/internal/clients/redpanda"
 	"github.com/hesoyamTM/apphelper-schedule/internal/config"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/services/calendarsync"
 	"github.com/hesoyamTM/apphelper-schedule/internal/services/groups"
+	"github.com/hesoyamTM/apphelper-schedule/internal/services/outbox"
 	"github.com/hesoyamTM/apphelper-schedule/internal/services/schedule"
 	"github.com/hesoyamTM/apphelper-schedule/internal/storage/psql"
 	"github.com/hesoyamTM/apphelper-schedule/internal/storage/redis"
@@ -15,7 +19,11 @@ import (
 
 type App struct {
 	GrpcApp  grpcapp.App
+	HTTPApp  *httpapp.App
 	Redpanda *redpanda.RedPanda
+	Outbox   *outbox.Relay
+	Calendar *calendarsync.Worker
+	Syncer   *calendarsync.Syncer
 }
 
 func New(ctx context.Context, cfg *config.Config) *App {
@@ -29,14 +37,26 @@ func New(ctx context.Context, cfg *config.Config) *App {
 		panic(err)
 	}
 
-	groupService := groups.New(ctx, db, redpanda)
+	relay := outbox.NewRelay(db, redpanda, cfg.Outbox)
+
+	providers := map[models.CalendarProvider]schedule.CalendarService{
+		models.ProviderGoogle: clients.New(ctx, cfg.GoogleCalendar),
+		models.ProviderCalDAV: clients.NewCalDAV(cfg.CalDAV),
+	}
+	if cfg.OutlookCalendar.ClientId != "" {
+		providers[models.ProviderOutlook] = clients.NewOutlook(ctx, cfg.OutlookCalendar)
+	}
 
-	calendarService := clients.New(ctx, cfg.GoogleCalendar)
 	sessionStorage := redis.New(cfg.RedisSessionStorage)
 	stateStorage := redis.New(cfg.RedisStateStorage)
-	calendaerManager := schedule.NewCalendarManager(sessionStorage, stateStorage, calendarService, db, groupService, cfg.StateTTL)
+	calendaerManager := schedule.NewCalendarManager(sessionStorage, stateStorage, providers, db, db, cfg.StateTTL)
+
+	calendarWorker := calendarsync.NewWorker(db, calendaerManager, cfg.CalendarSync)
 
-	scheduleService := schedule.New(ctx, db, db, calendaerManager, redpanda)
+	scheduleService := schedule.New(ctx, db, db, calendaerManager, cfg.Schedule)
+	calendarSyncer := calendarsync.NewSyncer(db, calendaerManager, scheduleService, cfg.CalendarPoll)
+
+	groupService := groups.New(ctx, db, scheduleService)
 
 	grpcApp := grpcapp.New(
 		ctx,
@@ -46,8 +66,17 @@ func New(ctx context.Context, cfg *config.Config) *App {
 		groupService,
 	)
 
+	var httpApp *httpapp.App
+	if cfg.HTTP.Port != 0 {
+		httpApp = httpapp.New(ctx, cfg.HTTP.Host, cfg.HTTP.Port, scheduleService, scheduleService, scheduleService)
+	}
+
 	return &App{
 		GrpcApp:  *grpcApp,
+		HTTPApp:  httpApp,
 		Redpanda: redpanda,
+		Outbox:   relay,
+		Calendar: calendarWorker,
+		Syncer:   calendarSyncer,
 	}
 }
@@ -0,0 +1,59 @@
+package httpapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hesoyamTM/apphelper-schedule/internal/http/api"
+	"github.com/hesoyamTM/apphelper-schedule/internal/http/calendarhook"
+	"github.com/hesoyamTM/apphelper-schedule/internal/http/feed"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type App struct {
+	httpServer *http.Server
+}
+
+func New(
+	ctx context.Context,
+	host string,
+	port int,
+	feedServ feed.FeedService,
+	syncServ calendarhook.SyncService,
+	apiServ api.ScheduleService,
+) *App {
+	mux := http.NewServeMux()
+	feed.Register(ctx, mux, feedServ)
+	calendarhook.Register(ctx, mux, syncServ)
+	api.Register(ctx, mux, apiServ)
+
+	return &App{
+		httpServer: &http.Server{
+			Addr:    fmt.Sprintf("%s:%d", host, port),
+			Handler: mux,
+		},
+	}
+}
+
+func (a *App) MustRun(ctx context.Context) {
+	log := logger.GetLoggerFromCtx(ctx)
+
+	log.Info(ctx, "http server is running")
+
+	if err := a.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		panic(err)
+	}
+}
+
+func (a *App) Stop(ctx context.Context) {
+	log := logger.GetLoggerFromCtx(ctx)
+
+	log.Info(ctx, "http server is stopping")
+
+	if err := a.httpServer.Shutdown(ctx); err != nil {
+		log.Error(ctx, "failed to stop http server", zap.Error(err))
+	}
+}
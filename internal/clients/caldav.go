@@ -0,0 +1,334 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/lib/ical"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+)
+
+// CalDAV talks to a CalDAV server, like Nextcloud, with basic auth.
+// The token endpoint is the calendar home of the user, calendar ids are the URLs of the calendar collections
+// and event ids are the UIDs of the events, stored as <uid>.ics in their collection.
+type CalDAV struct {
+	client *http.Client
+}
+
+type CalDAVCfg struct {
+	Timeout time.Duration `env:"CALDAV_TIMEOUT" env-default:"10s" yaml:"timeout"`
+}
+
+func NewCalDAV(cfg CalDAVCfg) *CalDAV {
+	return &CalDAV{
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// LoginURL is empty, as CalDAV servers are connected with credentials instead of OAuth.
+func (c *CalDAV) LoginURL(ctx context.Context, state string) string {
+	return ""
+}
+
+func (c *CalDAV) GetTokenFromCode(ctx context.Context, authCode string) (models.Token, error) {
+	const op = "caldav.GetTokenFromCode"
+
+	return models.Token{}, fmt.Errorf("%s: %w", op, ErrUnsupported)
+}
+
+// RefreshToken fails, as credentials of basic auth cannot be refreshed.
+func (c *CalDAV) RefreshToken(ctx context.Context, tok models.Token) (models.Token, error) {
+	const op = "caldav.RefreshToken"
+
+	return models.Token{}, fmt.Errorf("%s: %w", op, ErrUnauthorized)
+}
+
+func (c *CalDAV) GetEvents(ctx context.Context, tok models.Token, minTime, maxTime time.Time) (*[]*models.CalendarEvent, error) {
+	const op = "caldav.GetEvents"
+
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:resourcetype/><d:displayname/></d:prop>
+</d:propfind>`
+
+	home, err := c.multistatus(ctx, tok, "PROPFIND", tok.Endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><c:calendar-data/></d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT">
+        <c:time-range start="%s" end="%s"/>
+      </c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`, minTime.UTC().Format("20060102T150405Z"), maxTime.UTC().Format("20060102T150405Z"))
+
+	events := make([]*models.CalendarEvent, 0)
+	for _, resp := range home.Responses {
+		if !resp.isCalendar() {
+			continue
+		}
+
+		calendarId, err := resolve(tok.Endpoint, resp.Href)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		objects, err := c.multistatus(ctx, tok, "REPORT", calendarId, query)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, obj := range objects.Responses {
+			for _, propstat := range obj.Propstats {
+				if propstat.Prop.CalendarData == "" {
+					continue
+				}
+
+				decoded, err := ical.Decode([]byte(propstat.Prop.CalendarData))
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", op, err)
+				}
+
+				for _, event := range decoded {
+					event.CalendarId = calendarId
+					events = append(events, event)
+				}
+			}
+		}
+	}
+
+	return &events, nil
+}
+
+func (c *CalDAV) CreateEvent(ctx context.Context, tok models.Token, start, end time.Time, title, calendarId string) (string, error) {
+	const op = "caldav.CreateEvent"
+
+	event := &models.CalendarEvent{
+		EventId: uuid.New().String(),
+		Title:   title,
+		Start:   start,
+		End:     end,
+	}
+
+	header := http.Header{"If-None-Match": {"*"}}
+	if err := c.putEvent(ctx, tok, calendarId, event, header); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return event.EventId, nil
+}
+
+func (c *CalDAV) UpdateEvent(ctx context.Context, tok models.Token, event *models.CalendarEvent) error {
+	const op = "caldav.UpdateEvent"
+
+	header := http.Header{"If-Match": {"*"}}
+	if err := c.putEvent(ctx, tok, event.CalendarId, event, header); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *CalDAV) DeleteEvent(ctx context.Context, tok models.Token, eventId, calendarId string) error {
+	const op = "caldav.DeleteEvent"
+
+	resp, err := c.do(ctx, tok, http.MethodDelete, eventURL(calendarId, eventId), nil, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+func (c *CalDAV) CreateCalendar(ctx context.Context, tok models.Token, title string) (*models.Calendar, error) {
+	const op = "caldav.CreateCalendar"
+
+	var name bytes.Buffer
+	if err := xml.EscapeText(&name, []byte(title)); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<c:mkcalendar xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:set><d:prop><d:displayname>` + name.String() + `</d:displayname></d:prop></d:set>
+</c:mkcalendar>`
+
+	calendarId := strings.TrimSuffix(tok.Endpoint, "/") + "/" + uuid.New().String() + "/"
+
+	header := http.Header{"Content-Type": {"application/xml; charset=utf-8"}}
+	resp, err := c.do(ctx, tok, "MKCALENDAR", calendarId, strings.NewReader(body), header)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	resp.Body.Close()
+
+	return &models.Calendar{
+		Title: title,
+		Id:    calendarId,
+	}, nil
+}
+
+// DeleteCalendar deletes the calendar collection together with its events.
+func (c *CalDAV) DeleteCalendar(ctx context.Context, tok models.Token, calendarId string) error {
+	const op = "caldav.DeleteCalendar"
+
+	resp, err := c.do(ctx, tok, http.MethodDelete, calendarId, nil, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// AccountId returns the username, CalDAV servers identify accounts by it.
+func (c *CalDAV) AccountId(ctx context.Context, tok models.Token) (string, error) {
+	return tok.Username, nil
+}
+
+// ShareCalendar is not supported, CalDAV servers manage sharing differently.
+func (c *CalDAV) ShareCalendar(ctx context.Context, tok models.Token, calendarId, accountId string) error {
+	const op = "caldav.ShareCalendar"
+
+	return fmt.Errorf("%s: %w", op, ErrUnsupported)
+}
+
+func (c *CalDAV) UnshareCalendar(ctx context.Context, tok models.Token, calendarId, accountId string) error {
+	const op = "caldav.UnshareCalendar"
+
+	return fmt.Errorf("%s: %w", op, ErrUnsupported)
+}
+
+func (c *CalDAV) SyncEvents(ctx context.Context, tok models.Token, calendarId, syncToken string) ([]*models.CalendarEvent, string, error) {
+	const op = "caldav.SyncEvents"
+
+	return nil, "", fmt.Errorf("%s: %w", op, ErrUnsupported)
+}
+
+func (c *CalDAV) WatchCalendar(ctx context.Context, tok models.Token, calendarId string, channel *models.CalendarChannel) error {
+	const op = "caldav.WatchCalendar"
+
+	return fmt.Errorf("%s: %w", op, ErrUnsupported)
+}
+
+func (c *CalDAV) putEvent(ctx context.Context, tok models.Token, calendarId string, event *models.CalendarEvent, header http.Header) error {
+	header.Set("Content-Type", "text/calendar; charset=utf-8")
+
+	body := bytes.NewReader(ical.EncodeIn([]*models.CalendarEvent{event}, eventLocation(event.Start)))
+	resp, err := c.do(ctx, tok, http.MethodPut, eventURL(calendarId, event.EventId), body, header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+func (c *CalDAV) multistatus(ctx context.Context, tok models.Token, method, target, body string) (*multistatus, error) {
+	header := http.Header{
+		"Content-Type": {"application/xml; charset=utf-8"},
+		"Depth":        {"1"},
+	}
+
+	resp, err := c.do(ctx, tok, method, target, strings.NewReader(body), header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, err
+	}
+
+	return &ms, nil
+}
+
+func (c *CalDAV) do(ctx context.Context, tok models.Token, method, target string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.SetBasicAuth(tok.Username, tok.AccessToken)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		resp.Body.Close()
+		return nil, ErrUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode >= 300:
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+func eventURL(calendarId, eventId string) string {
+	return strings.TrimSuffix(calendarId, "/") + "/" + url.PathEscape(eventId) + ".ics"
+}
+
+func resolve(base, ref string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+
+	return baseURL.ResolveReference(refURL).String(), nil
+}
+
+type multistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href      string `xml:"DAV: href"`
+	Propstats []struct {
+		Prop struct {
+			ResourceType struct {
+				Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+			} `xml:"DAV: resourcetype"`
+			DisplayName  string `xml:"DAV: displayname"`
+			CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+		} `xml:"DAV: prop"`
+	} `xml:"DAV: propstat"`
+}
+
+func (r *davResponse) isCalendar() bool {
+	for _, propstat := range r.Propstats {
+		if propstat.Prop.ResourceType.Calendar != nil {
+			return true
+		}
+	}
+
+	return false
+}
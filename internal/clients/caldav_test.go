@@ -0,0 +1,140 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCalDAV is an in-memory stand-in for a CalDAV server with a single calendar home at /home/.
+type fakeCalDAV struct {
+	mu        sync.Mutex
+	calendars map[string]bool
+	objects   map[string]string
+}
+
+func newFakeCalDAV() *fakeCalDAV {
+	return &fakeCalDAV{
+		calendars: make(map[string]bool),
+		objects:   make(map[string]string),
+	}
+}
+
+func (f *fakeCalDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if user, pass, ok := r.BasicAuth(); !ok || user != "student" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "MKCALENDAR":
+		f.calendars[r.URL.Path] = true
+		w.WriteHeader(http.StatusCreated)
+	case "PROPFIND":
+		var b strings.Builder
+		for path := range f.calendars {
+			fmt.Fprintf(&b, `<d:response><d:href>%s</d:href><d:propstat><d:prop>
+<d:resourcetype><d:collection/><c:calendar/></d:resourcetype></d:prop></d:propstat></d:response>`, path)
+		}
+		writeMultistatus(w, b.String())
+	case "REPORT":
+		var b strings.Builder
+		for path, data := range f.objects {
+			if strings.HasPrefix(path, r.URL.Path) {
+				fmt.Fprintf(&b, `<d:response><d:href>%s</d:href><d:propstat><d:prop>
+<c:calendar-data>%s</c:calendar-data></d:prop></d:propstat></d:response>`, path, data)
+			}
+		}
+		writeMultistatus(w, b.String())
+	case http.MethodPut:
+		_, exists := f.objects[r.URL.Path]
+		if (r.Header.Get("If-None-Match") == "*" && exists) || (r.Header.Get("If-Match") == "*" && !exists) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = string(data)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, ok := f.objects[r.URL.Path]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeMultistatus(w http.ResponseWriter, responses string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">%s</d:multistatus>`, responses)
+}
+
+func TestCalDAVEvents(t *testing.T) {
+	ctx := context.Background()
+
+	server := httptest.NewServer(newFakeCalDAV())
+	defer server.Close()
+
+	client := NewCalDAV(CalDAVCfg{Timeout: time.Second})
+	tok := models.Token{
+		Provider:    models.ProviderCalDAV,
+		Endpoint:    server.URL + "/home/",
+		Username:    "student",
+		AccessToken: "secret",
+	}
+
+	calend, err := client.CreateCalendar(ctx, tok, "Group & co")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(calend.Id, tok.Endpoint))
+
+	start := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	eventId, err := client.CreateEvent(ctx, tok, start, start.Add(time.Hour), "Math, algebra", calend.Id)
+	require.NoError(t, err)
+
+	events, err := client.GetEvents(ctx, tok, start.Add(-time.Hour), start.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, *events, 1)
+	require.Equal(t, eventId, (*events)[0].EventId)
+	require.Equal(t, calend.Id, (*events)[0].CalendarId)
+	require.Equal(t, "Math, algebra", (*events)[0].Title)
+	require.True(t, start.Equal((*events)[0].Start))
+
+	updated := &models.CalendarEvent{
+		EventId:    eventId,
+		CalendarId: calend.Id,
+		Title:      "Physics",
+		Start:      start.Add(time.Hour),
+		End:        start.Add(2 * time.Hour),
+	}
+	require.NoError(t, client.UpdateEvent(ctx, tok, updated))
+
+	events, err = client.GetEvents(ctx, tok, start.Add(-time.Hour), start.Add(3*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, *events, 1)
+	require.Equal(t, "Physics", (*events)[0].Title)
+	require.True(t, updated.End.Equal((*events)[0].End))
+
+	require.NoError(t, client.DeleteEvent(ctx, tok, eventId, calend.Id))
+	require.ErrorIs(t, client.DeleteEvent(ctx, tok, eventId, calend.Id), ErrNotFound)
+
+	tok.AccessToken = "wrong"
+	_, err = client.GetEvents(ctx, tok, start, start.Add(time.Hour))
+	require.ErrorIs(t, err, ErrUnauthorized)
+}
@@ -9,6 +9,9 @@ import (
 var (
 	ErrUnauthorized = errors.New("unauthorized")
 	ErrNotFound     = errors.New("not found")
+	ErrUnsupported  = errors.New("not supported by the calendar provider")
+	// ErrSyncTokenExpired asks for a full sync of the calendar
+	ErrSyncTokenExpired = errors.New("sync token expired")
 
 	ErrClosedChannel = errors.New("closed channel")
 )
@@ -21,6 +24,8 @@ func HandleGoogleAPIError(err error) error {
 			return ErrUnauthorized
 		case 404:
 			return ErrNotFound
+		case 410:
+			return ErrSyncTokenExpired
 		}
 	}
 
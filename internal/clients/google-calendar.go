@@ -58,6 +58,7 @@ func (g *GoogleCalendar) GetTokenFromCode(ctx context.Context, authCode string)
 	}
 
 	return models.Token{
+		Provider:     models.ProviderGoogle,
 		AccessToken:  tok.AccessToken,
 		RefreshToken: tok.RefreshToken,
 	}, nil
@@ -118,31 +119,65 @@ func (g *GoogleCalendar) GetEvents(ctx context.Context, tok models.Token, minTim
 	return &events, nil
 }
 
-func (g *GoogleCalendar) CreateEvent(ctx context.Context, tok models.Token, start, end time.Time, eventId, calendarId string) error {
+func (g *GoogleCalendar) CreateEvent(ctx context.Context, tok models.Token, start, end time.Time, title, calendarId string) (string, error) {
 	const op = "google-calendar.CreateEvent"
 
 	srv, err := g.serviceFromToken(ctx, tok)
 	if err != nil {
 		err = HandleGoogleAPIError(err)
 
-		return fmt.Errorf("%s: failed to get token: %w", op, err)
+		return "", fmt.Errorf("%s: failed to get token: %w", op, err)
 	}
 
 	event := calendar.Event{
-		Summary: eventId,
+		Summary: title,
 		Start: &calendar.EventDateTime{
 			DateTime: start.Format(time.RFC3339),
+			TimeZone: eventLocation(start).String(),
 		},
 		End: &calendar.EventDateTime{
 			DateTime: end.Format(time.RFC3339),
+			TimeZone: eventLocation(end).String(),
+		},
+	}
+
+	created, err := srv.Events.Insert(calendarId, &event).Do()
+	if err != nil {
+		err = HandleGoogleAPIError(err)
+
+		return "", fmt.Errorf("%s: failed to create event: %w", op, err)
+	}
+
+	return created.Id, nil
+}
+
+func (g *GoogleCalendar) UpdateEvent(ctx context.Context, tok models.Token, event *models.CalendarEvent) error {
+	const op = "google-calendar.UpdateEvent"
+
+	srv, err := g.serviceFromToken(ctx, tok)
+	if err != nil {
+		err = HandleGoogleAPIError(err)
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	patch := calendar.Event{
+		Summary: event.Title,
+		Start: &calendar.EventDateTime{
+			DateTime: event.Start.Format(time.RFC3339),
+			TimeZone: eventLocation(event.Start).String(),
+		},
+		End: &calendar.EventDateTime{
+			DateTime: event.End.Format(time.RFC3339),
+			TimeZone: eventLocation(event.End).String(),
 		},
 	}
 
-	_, err = srv.Events.Insert(calendarId, &event).Do()
+	_, err = srv.Events.Patch(event.CalendarId, event.EventId, &patch).Do()
 	if err != nil {
 		err = HandleGoogleAPIError(err)
 
-		return fmt.Errorf("%s: failed to create event: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
@@ -192,6 +227,169 @@ func (g *GoogleCalendar) CreateCalendar(ctx context.Context, tok models.Token, t
 	}, nil
 }
 
+func (g *GoogleCalendar) DeleteCalendar(ctx context.Context, tok models.Token, calendarId string) error {
+	const op = "google-calendar.DeleteCalendar"
+
+	srv, err := g.serviceFromToken(ctx, tok)
+	if err != nil {
+		err = HandleGoogleAPIError(err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := srv.Calendars.Delete(calendarId).Do(); err != nil {
+		err = HandleGoogleAPIError(err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AccountId returns the email of the account, which is the id of its primary calendar.
+func (g *GoogleCalendar) AccountId(ctx context.Context, tok models.Token) (string, error) {
+	const op = "google-calendar.AccountId"
+
+	srv, err := g.serviceFromToken(ctx, tok)
+	if err != nil {
+		err = HandleGoogleAPIError(err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	primary, err := srv.Calendars.Get("primary").Do()
+	if err != nil {
+		err = HandleGoogleAPIError(err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return primary.Id, nil
+}
+
+// ShareCalendar lets the account read the calendar.
+func (g *GoogleCalendar) ShareCalendar(ctx context.Context, tok models.Token, calendarId, accountId string) error {
+	const op = "google-calendar.ShareCalendar"
+
+	srv, err := g.serviceFromToken(ctx, tok)
+	if err != nil {
+		err = HandleGoogleAPIError(err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rule := &calendar.AclRule{
+		Role: "reader",
+		Scope: &calendar.AclRuleScope{
+			Type:  "user",
+			Value: accountId,
+		},
+	}
+	if _, err := srv.Acl.Insert(calendarId, rule).Do(); err != nil {
+		err = HandleGoogleAPIError(err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (g *GoogleCalendar) UnshareCalendar(ctx context.Context, tok models.Token, calendarId, accountId string) error {
+	const op = "google-calendar.UnshareCalendar"
+
+	srv, err := g.serviceFromToken(ctx, tok)
+	if err != nil {
+		err = HandleGoogleAPIError(err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := srv.Acl.Delete(calendarId, "user:"+accountId).Do(); err != nil {
+		err = HandleGoogleAPIError(err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SyncEvents returns the events of the calendar changed since syncToken, or all of them if it is empty,
+// and the token of the next sync. Deleted events are returned too.
+func (g *GoogleCalendar) SyncEvents(ctx context.Context, tok models.Token, calendarId, syncToken string) ([]*models.CalendarEvent, string, error) {
+	const op = "google-calendar.SyncEvents"
+
+	srv, err := g.serviceFromToken(ctx, tok)
+	if err != nil {
+		err = HandleGoogleAPIError(err)
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	events := make([]*models.CalendarEvent, 0)
+	pageToken := ""
+	for {
+		call := srv.Events.List(calendarId).ShowDeleted(true).MaxResults(maxResults)
+		if syncToken != "" {
+			call = call.SyncToken(syncToken)
+		}
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		page, err := call.Do()
+		if err != nil {
+			err = HandleGoogleAPIError(err)
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, item := range page.Items {
+			event := &models.CalendarEvent{
+				EventId:    item.Id,
+				CalendarId: calendarId,
+				Title:      item.Summary,
+				Deleted:    item.Status == "cancelled",
+			}
+
+			// deleted events and all-day events carry no times
+			if !event.Deleted && item.Start != nil && item.Start.DateTime != "" && item.End != nil {
+				event.Start, err = time.Parse(time.RFC3339, item.Start.DateTime)
+				if err != nil {
+					return nil, "", fmt.Errorf("%s: %w", op, err)
+				}
+				event.End, err = time.Parse(time.RFC3339, item.End.DateTime)
+				if err != nil {
+					return nil, "", fmt.Errorf("%s: %w", op, err)
+				}
+			}
+
+			events = append(events, event)
+		}
+
+		if page.NextPageToken == "" {
+			return events, page.NextSyncToken, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// WatchCalendar subscribes channel to the changes of the events of the calendar
+// and sets its resource id and expiration.
+func (g *GoogleCalendar) WatchCalendar(ctx context.Context, tok models.Token, calendarId string, channel *models.CalendarChannel) error {
+	const op = "google-calendar.WatchCalendar"
+
+	srv, err := g.serviceFromToken(ctx, tok)
+	if err != nil {
+		err = HandleGoogleAPIError(err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	watched, err := srv.Events.Watch(calendarId, &calendar.Channel{
+		Id:      channel.Id.String(),
+		Type:    "web_hook",
+		Address: channel.Address,
+	}).Do()
+	if err != nil {
+		err = HandleGoogleAPIError(err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	channel.ResourceId = watched.ResourceId
+	channel.ExpiresAt = time.UnixMilli(watched.Expiration)
+
+	return nil
+}
+
 func (g *GoogleCalendar) serviceFromToken(ctx context.Context, tok models.Token) (*calendar.Service, error) {
 	const op = "google-calendar.ServiceFromToken"
 
@@ -221,6 +419,7 @@ func (g *GoogleCalendar) RefreshToken(ctx context.Context, tok models.Token) (mo
 	}
 
 	return models.Token{
+		Provider:     models.ProviderGoogle,
 		AccessToken:  newToken.AccessToken,
 		RefreshToken: newToken.RefreshToken,
 	}, nil
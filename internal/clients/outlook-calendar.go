@@ -0,0 +1,340 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/microsoft"
+)
+
+const (
+	graphURL = "https://graph.microsoft.com/v1.0"
+	// Graph returns date-times of the requested time zone without offset
+	graphTimeFormat = "2006-01-02T15:04:05.9999999"
+)
+
+// OutlookCalendar talks to Outlook calendars through Microsoft Graph.
+type OutlookCalendar struct {
+	oauthConfig *oauth2.Config
+	baseURL     string
+}
+
+type OutlookCalendarCfg struct {
+	ClientId     string `env:"OUTLOOK_CALENDAR_CLIENT_ID" yaml:"client-id"`
+	ClientSecret string `env:"OUTLOOK_CALENDAR_CLIENT_SECRET" yaml:"client-secret"`
+	RedirectURL  string `env:"OUTLOOK_CALENDAR_REDIRECT_URL" yaml:"redirect-url"`
+	Tenant       string `env:"OUTLOOK_CALENDAR_TENANT" env-default:"common" yaml:"tenant"`
+}
+
+func NewOutlook(ctx context.Context, cfg OutlookCalendarCfg) *OutlookCalendar {
+	oauthCfg := &oauth2.Config{
+		ClientID:     cfg.ClientId,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       []string{"offline_access", "Calendars.ReadWrite"},
+		Endpoint:     microsoft.AzureADEndpoint(cfg.Tenant),
+	}
+
+	return &OutlookCalendar{
+		oauthConfig: oauthCfg,
+		baseURL:     graphURL,
+	}
+}
+
+func (o *OutlookCalendar) LoginURL(ctx context.Context, state string) string {
+	return o.oauthConfig.AuthCodeURL(state)
+}
+
+func (o *OutlookCalendar) GetTokenFromCode(ctx context.Context, authCode string) (models.Token, error) {
+	const op = "outlook-calendar.GetTokenFromCode"
+
+	tok, err := o.oauthConfig.Exchange(ctx, authCode)
+	if err != nil {
+		return models.Token{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return models.Token{
+		Provider:     models.ProviderOutlook,
+		AccessToken:  tok.AccessToken,
+		RefreshToken: tok.RefreshToken,
+		Expiry:       tok.Expiry,
+	}, nil
+}
+
+func (o *OutlookCalendar) RefreshToken(ctx context.Context, tok models.Token) (models.Token, error) {
+	const op = "outlook-calendar.RefreshToken"
+
+	newToken, err := o.oauthConfig.TokenSource(ctx, &oauth2.Token{RefreshToken: tok.RefreshToken}).Token()
+	if err != nil {
+		return models.Token{}, fmt.Errorf("%s: %w", op, ErrUnauthorized)
+	}
+
+	return models.Token{
+		Provider:     models.ProviderOutlook,
+		AccessToken:  newToken.AccessToken,
+		RefreshToken: newToken.RefreshToken,
+		Expiry:       newToken.Expiry,
+	}, nil
+}
+
+func (o *OutlookCalendar) GetEvents(ctx context.Context, tok models.Token, minTime, maxTime time.Time) (*[]*models.CalendarEvent, error) {
+	const op = "outlook-calendar.GetEvents"
+
+	var calendars struct {
+		Value []struct {
+			Id string `json:"id"`
+		} `json:"value"`
+	}
+	if err := o.do(ctx, tok, http.MethodGet, "/me/calendars", nil, &calendars); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := url.Values{
+		"startDateTime": {minTime.UTC().Format(time.RFC3339)},
+		"endDateTime":   {maxTime.UTC().Format(time.RFC3339)},
+		"$top":          {fmt.Sprint(maxResults)},
+	}
+
+	events := make([]*models.CalendarEvent, 0)
+	for _, calend := range calendars.Value {
+		var view struct {
+			Value []graphEvent `json:"value"`
+		}
+		path := "/me/calendars/" + url.PathEscape(calend.Id) + "/calendarView?" + query.Encode()
+		if err := o.do(ctx, tok, http.MethodGet, path, nil, &view); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, event := range view.Value {
+			st, err := time.Parse(graphTimeFormat, event.Start.DateTime)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			end, err := time.Parse(graphTimeFormat, event.End.DateTime)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+
+			events = append(events, &models.CalendarEvent{
+				Title:      event.Subject,
+				EventId:    event.Id,
+				CalendarId: calend.Id,
+				Start:      st,
+				End:        end,
+			})
+		}
+	}
+
+	return &events, nil
+}
+
+func (o *OutlookCalendar) CreateEvent(ctx context.Context, tok models.Token, start, end time.Time, title, calendarId string) (string, error) {
+	const op = "outlook-calendar.CreateEvent"
+
+	var created graphEvent
+	path := "/me/calendars/" + url.PathEscape(calendarId) + "/events"
+	if err := o.do(ctx, tok, http.MethodPost, path, newGraphEvent(title, start, end), &created); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return created.Id, nil
+}
+
+func (o *OutlookCalendar) UpdateEvent(ctx context.Context, tok models.Token, event *models.CalendarEvent) error {
+	const op = "outlook-calendar.UpdateEvent"
+
+	path := "/me/events/" + url.PathEscape(event.EventId)
+	if err := o.do(ctx, tok, http.MethodPatch, path, newGraphEvent(event.Title, event.Start, event.End), nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (o *OutlookCalendar) DeleteEvent(ctx context.Context, tok models.Token, eventId, calendarId string) error {
+	const op = "outlook-calendar.DeleteEvent"
+
+	if err := o.do(ctx, tok, http.MethodDelete, "/me/events/"+url.PathEscape(eventId), nil, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (o *OutlookCalendar) CreateCalendar(ctx context.Context, tok models.Token, title string) (*models.Calendar, error) {
+	const op = "outlook-calendar.CreateCalendar"
+
+	var created struct {
+		Id string `json:"id"`
+	}
+	if err := o.do(ctx, tok, http.MethodPost, "/me/calendars", map[string]string{"name": title}, &created); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &models.Calendar{
+		Title: title,
+		Id:    created.Id,
+	}, nil
+}
+
+func (o *OutlookCalendar) DeleteCalendar(ctx context.Context, tok models.Token, calendarId string) error {
+	const op = "outlook-calendar.DeleteCalendar"
+
+	if err := o.do(ctx, tok, http.MethodDelete, "/me/calendars/"+url.PathEscape(calendarId), nil, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AccountId returns the email of the owner of the default calendar of the account.
+func (o *OutlookCalendar) AccountId(ctx context.Context, tok models.Token) (string, error) {
+	const op = "outlook-calendar.AccountId"
+
+	var calend struct {
+		Owner struct {
+			Address string `json:"address"`
+		} `json:"owner"`
+	}
+	if err := o.do(ctx, tok, http.MethodGet, "/me/calendar", nil, &calend); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return calend.Owner.Address, nil
+}
+
+// ShareCalendar lets the account read the calendar.
+func (o *OutlookCalendar) ShareCalendar(ctx context.Context, tok models.Token, calendarId, accountId string) error {
+	const op = "outlook-calendar.ShareCalendar"
+
+	permission := map[string]any{
+		"emailAddress": map[string]string{"address": accountId},
+		"role":         "read",
+	}
+	path := "/me/calendars/" + url.PathEscape(calendarId) + "/calendarPermissions"
+	if err := o.do(ctx, tok, http.MethodPost, path, permission, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (o *OutlookCalendar) UnshareCalendar(ctx context.Context, tok models.Token, calendarId, accountId string) error {
+	const op = "outlook-calendar.UnshareCalendar"
+
+	var permissions struct {
+		Value []struct {
+			Id           string `json:"id"`
+			EmailAddress struct {
+				Address string `json:"address"`
+			} `json:"emailAddress"`
+		} `json:"value"`
+	}
+	path := "/me/calendars/" + url.PathEscape(calendarId) + "/calendarPermissions"
+	if err := o.do(ctx, tok, http.MethodGet, path, nil, &permissions); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, permission := range permissions.Value {
+		if !strings.EqualFold(permission.EmailAddress.Address, accountId) {
+			continue
+		}
+
+		if err := o.do(ctx, tok, http.MethodDelete, path+"/"+url.PathEscape(permission.Id), nil, nil); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%s: %w", op, ErrNotFound)
+}
+
+func (o *OutlookCalendar) SyncEvents(ctx context.Context, tok models.Token, calendarId, syncToken string) ([]*models.CalendarEvent, string, error) {
+	const op = "outlook-calendar.SyncEvents"
+
+	return nil, "", fmt.Errorf("%s: %w", op, ErrUnsupported)
+}
+
+func (o *OutlookCalendar) WatchCalendar(ctx context.Context, tok models.Token, calendarId string, channel *models.CalendarChannel) error {
+	const op = "outlook-calendar.WatchCalendar"
+
+	return fmt.Errorf("%s: %w", op, ErrUnsupported)
+}
+
+// do sends in as JSON to the Graph API and decodes the response into out, if set.
+func (o *OutlookCalendar) do(ctx context.Context, tok models.Token, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, o.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", `outlook.timezone="UTC"`)
+
+	client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: tok.AccessToken}))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type graphDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type graphEvent struct {
+	Id      string        `json:"id,omitempty"`
+	Subject string        `json:"subject"`
+	Start   graphDateTime `json:"start"`
+	End     graphDateTime `json:"end"`
+}
+
+func newGraphEvent(title string, start, end time.Time) *graphEvent {
+	return &graphEvent{
+		Subject: title,
+		Start:   newGraphDateTime(start),
+		End:     newGraphDateTime(end),
+	}
+}
+
+// newGraphDateTime writes t as wall clock time of its IANA zone, which Graph accepts next to Windows zones.
+func newGraphDateTime(t time.Time) graphDateTime {
+	loc := eventLocation(t)
+
+	return graphDateTime{DateTime: t.In(loc).Format(graphTimeFormat), TimeZone: loc.String()}
+}
@@ -1,5 +1,7 @@
 package redpanda
 
+import "github.com/hesoyamTM/apphelper-schedule/internal/models"
+
 type GroupAddedEvent struct {
 	GroupId   string `json:"group_id"`
 	GroupName string `json:"group_name"`
@@ -7,3 +9,60 @@ type GroupAddedEvent struct {
 	StudentId string `json:"student_id"`
 	Link      string `json:"link"`
 }
+
+// GroupRemovedEvent is emitted when a student leaves the group or is removed from it.
+// RemovedBy is the student if they left.
+type GroupRemovedEvent struct {
+	GroupId   string `json:"group_id"`
+	GroupName string `json:"group_name"`
+	TrainerId string `json:"trainer_id"`
+	StudentId string `json:"student_id"`
+	RemovedBy string `json:"removed_by"`
+	Status    string `json:"status"`
+}
+
+// GroupDeletedEvent is emitted when the owner deletes a group. StudentIds are its students at the time,
+// CancelledSchedules the number of lessons cancelled with it.
+type GroupDeletedEvent struct {
+	GroupId            string   `json:"group_id"`
+	GroupName          string   `json:"group_name"`
+	TrainerId          string   `json:"trainer_id"`
+	StudentIds         []string `json:"student_ids"`
+	CancelledSchedules int      `json:"cancelled_schedules"`
+}
+
+// GroupJoinRequestedEvent is emitted when a student asks to join a group which requires approval.
+type GroupJoinRequestedEvent struct {
+	RequestId string `json:"request_id"`
+	GroupId   string `json:"group_id"`
+	GroupName string `json:"group_name"`
+	TrainerId string `json:"trainer_id"`
+	StudentId string `json:"student_id"`
+}
+
+// GroupJoinDecidedEvent is emitted when the staff of a group approves or rejects a join request.
+type GroupJoinDecidedEvent struct {
+	RequestId string `json:"request_id"`
+	GroupId   string `json:"group_id"`
+	GroupName string `json:"group_name"`
+	TrainerId string `json:"trainer_id"`
+	StudentId string `json:"student_id"`
+	Status    string `json:"status"`
+	DecidedBy string `json:"decided_by"`
+}
+
+// GroupRoleChangedEvent is emitted when the owner of a group grants or revokes a staff role.
+// Role is student or empty if the role was revoked.
+type GroupRoleChangedEvent struct {
+	GroupId      string `json:"group_id"`
+	GroupName    string `json:"group_name"`
+	UserId       string `json:"user_id"`
+	Role         string `json:"role"`
+	PreviousRole string `json:"previous_role"`
+	ChangedBy    string `json:"changed_by"`
+}
+
+type ScheduleUpdatedEvent struct {
+	Before *models.Schedule `json:"before"`
+	After  *models.Schedule `json:"after"`
+}
@@ -1,80 +1,134 @@
 package redpanda
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 
-	"github.com/IBM/sarama"
-	"github.com/hesoyamTM/apphelper-schedule/internal/clients"
 	"github.com/hesoyamTM/apphelper-schedule/internal/models"
 )
 
-func (r *RedPanda) ScheduleCreatedEvent(ctx context.Context, schedule *models.Schedule) error {
-	const op = "redpanda.RedPanda.ScheduleCreateEvent"
+func ScheduleCreatedMessage(schedule *models.Schedule) (*models.OutboxMessage, error) {
+	const op = "redpanda.ScheduleCreatedMessage"
 
-	value, err := json.Marshal(schedule)
+	msg, err := newMessage(scheduleCreatedTopic, schedule.Id.String(), schedule)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	message := &sarama.ProducerMessage{
-		Topic: scheduleCreatedTopic,
-		Value: sarama.ByteEncoder(value),
+	return msg, nil
+}
+
+func ScheduleUpdatedMessage(event *ScheduleUpdatedEvent) (*models.OutboxMessage, error) {
+	const op = "redpanda.ScheduleUpdatedMessage"
+
+	msg, err := newMessage(scheduleUpdatedTopic, event.After.Id.String(), event)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return msg, nil
+}
+
+// ScheduleCancelledMessage carries the cancelled schedule with the reason and the user who cancelled it.
+func ScheduleCancelledMessage(schedule *models.Schedule) (*models.OutboxMessage, error) {
+	const op = "redpanda.ScheduleCancelledMessage"
+
+	msg, err := newMessage(scheduleCancelledTopic, schedule.Id.String(), schedule)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	select {
-	case r.messageChan <- message:
-		return nil
-	case <-r.stopChan:
-		return fmt.Errorf("%s: %w", op, clients.ErrClosedChannel)
-	default:
-		return fmt.Errorf("%s: %w", op, clients.ErrClosedChannel)
+	return msg, nil
+}
+
+func GroupAddedMessage(event *GroupAddedEvent) (*models.OutboxMessage, error) {
+	const op = "redpanda.GroupAddedMessage"
+
+	msg, err := newMessage(groupAddedTopic, event.GroupId, event)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return msg, nil
+}
+
+func GroupRemovedMessage(event *GroupRemovedEvent) (*models.OutboxMessage, error) {
+	const op = "redpanda.GroupRemovedMessage"
+
+	msg, err := newMessage(groupRemovedTopic, event.GroupId, event)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+
+	return msg, nil
 }
 
-func (r *RedPanda) ScheduleUpdatedEvent(ctx context.Context, schedule *models.Schedule) error {
-	const op = "redpanda.RedPanda.ScheduleUpdatedEvent"
+func GroupDeletedMessage(event *GroupDeletedEvent) (*models.OutboxMessage, error) {
+	const op = "redpanda.GroupDeletedMessage"
 
-	value, err := json.Marshal(schedule)
+	msg, err := newMessage(groupDeletedTopic, event.GroupId, event)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	message := &sarama.ProducerMessage{
-		Topic: scheduleUpdatedTopic,
-		Value: sarama.ByteEncoder(value),
+	return msg, nil
+}
+
+func GroupJoinRequestedMessage(event *GroupJoinRequestedEvent) (*models.OutboxMessage, error) {
+	const op = "redpanda.GroupJoinRequestedMessage"
+
+	msg, err := newMessage(groupJoinRequestedTopic, event.GroupId, event)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	select {
-	case r.messageChan <- message:
-		return nil
-	case <-r.stopChan:
-		return fmt.Errorf("%s: %w", op, clients.ErrClosedChannel)
-	default:
-		return fmt.Errorf("%s: %w", op, clients.ErrClosedChannel)
+	return msg, nil
+}
+
+func GroupJoinDecidedMessage(event *GroupJoinDecidedEvent) (*models.OutboxMessage, error) {
+	const op = "redpanda.GroupJoinDecidedMessage"
+
+	msg, err := newMessage(groupJoinDecidedTopic, event.GroupId, event)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+
+	return msg, nil
 }
 
-func (r *RedPanda) GroupAddedEvent(ctx context.Context, group *GroupAddedEvent) error {
-	const op = "redpanda.RedPanda.GroupAddedEvent"
+func GroupRoleChangedMessage(event *GroupRoleChangedEvent) (*models.OutboxMessage, error) {
+	const op = "redpanda.GroupRoleChangedMessage"
 
-	value, err := json.Marshal(group)
+	msg, err := newMessage(groupRoleChangedTopic, event.GroupId, event)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	message := &sarama.ProducerMessage{
-		Topic: groupAddedTopic,
-		Value: sarama.ByteEncoder(value),
+	return msg, nil
+}
+
+func AttendanceMarkedMessage(attendance *models.Attendance) (*models.OutboxMessage, error) {
+	const op = "redpanda.AttendanceMarkedMessage"
+
+	msg, err := newMessage(attendanceMarkedTopic, attendance.ScheduleId.String(), attendance)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	select {
-	case r.messageChan <- message:
-		return nil
-	case <-r.stopChan:
-		return fmt.Errorf("%s: %w", op, clients.ErrClosedChannel)
-	default:
-		return fmt.Errorf("%s: %w", op, clients.ErrClosedChannel)
+	return msg, nil
+}
+
+// newMessage keys the message by the schedule or the group it is about, so that the events of one schedule
+// or group land in the same partition and are consumed in order.
+func newMessage(topic, key string, event any) (*models.OutboxMessage, error) {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
 	}
+
+	return &models.OutboxMessage{
+		Topic:   topic,
+		Key:     key,
+		Payload: value,
+	}, nil
 }
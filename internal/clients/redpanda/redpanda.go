@@ -7,14 +7,23 @@ import (
 
 	"github.com/IBM/sarama"
 	"github.com/hesoyamTM/apphelper-notification/pkg/redpanda"
+	"github.com/hesoyamTM/apphelper-schedule/internal/clients"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
 	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
 	"go.uber.org/zap"
 )
 
 const (
-	scheduleCreatedTopic = "schedule.schedule.created"
-	scheduleUpdatedTopic = "schedule.schedule.updated"
-	groupAddedTopic      = "group.group.added"
+	scheduleCreatedTopic    = "schedule.schedule.created"
+	scheduleUpdatedTopic    = "schedule.schedule.updated"
+	scheduleCancelledTopic  = "schedule.schedule.cancelled"
+	groupAddedTopic         = "group.group.added"
+	groupRemovedTopic       = "group.group.removed"
+	groupDeletedTopic       = "group.group.deleted"
+	groupJoinRequestedTopic = "group.join.requested"
+	groupJoinDecidedTopic   = "group.join.decided"
+	groupRoleChangedTopic   = "group.role.changed"
+	attendanceMarkedTopic   = "attendance.marked"
 )
 
 type RedPanda struct {
@@ -27,14 +36,18 @@ func NewRedPanda(ctx context.Context, cfg redpanda.RedpandaConfig) (*RedPanda, e
 	const op = "redpanda.NewRedPanda"
 
 	redpandaCfg := redpanda.NewSaramaConfig(cfg)
+	// Publish waits for the acknowledgement of every message
+	redpandaCfg.Producer.Return.Successes = true
+	redpandaCfg.Producer.Return.Errors = true
 	producer, err := redpanda.NewSaramaAsyncProducer(redpandaCfg, cfg.Brokers)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return &RedPanda{
-		producer: *producer,
-		stopChan: make(chan struct{}),
+		producer:    *producer,
+		messageChan: make(chan *sarama.ProducerMessage),
+		stopChan:    make(chan struct{}),
 	}, nil
 }
 
@@ -45,8 +58,9 @@ func (r *RedPanda) Start(ctx context.Context) error {
 	var enqueued, successes, failures int32
 
 	go func() {
-		for range r.producer.Successes() {
+		for message := range r.producer.Successes() {
 			atomic.AddInt32(&successes, 1)
+			ack(message, nil)
 		}
 	}()
 
@@ -54,11 +68,10 @@ func (r *RedPanda) Start(ctx context.Context) error {
 		for err := range r.producer.Errors() {
 			atomic.AddInt32(&failures, 1)
 			log.Error(ctx, "failed to send message to redpanda", zap.Error(err))
+			ack(err.Msg, err.Err)
 		}
 	}()
 
-	r.messageChan = make(chan *sarama.ProducerMessage)
-
 	for {
 		select {
 		case message := <-r.messageChan:
@@ -74,6 +87,41 @@ func (r *RedPanda) Start(ctx context.Context) error {
 	}
 }
 
+// Publish sends a message and waits until the broker acknowledges it.
+func (r *RedPanda) Publish(ctx context.Context, msg *models.OutboxMessage) error {
+	const op = "redpanda.RedPanda.Publish"
+
+	done := make(chan error, 1)
+	message := &sarama.ProducerMessage{
+		Topic:    msg.Topic,
+		Value:    sarama.ByteEncoder(msg.Payload),
+		Metadata: done,
+	}
+	if msg.Key != "" {
+		message.Key = sarama.StringEncoder(msg.Key)
+	}
+
+	select {
+	case r.messageChan <- message:
+	case <-r.stopChan:
+		return fmt.Errorf("%s: %w", op, clients.ErrClosedChannel)
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	case <-r.stopChan:
+		return fmt.Errorf("%s: %w", op, clients.ErrClosedChannel)
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+}
+
 func (r *RedPanda) Stop(ctx context.Context) error {
 	const op = "redpanda.RedPanda.Stop"
 
@@ -88,3 +136,10 @@ func (r *RedPanda) Stop(ctx context.Context) error {
 
 	return nil
 }
+
+// ack reports the delivery result to the publisher waiting for it
+func ack(message *sarama.ProducerMessage, err error) {
+	if done, ok := message.Metadata.(chan error); ok {
+		done <- err
+	}
+}
@@ -0,0 +1,13 @@
+package clients
+
+import "time"
+
+// eventLocation returns the zone events starting at t are created in. The local zone has no IANA name,
+// so UTC is used instead.
+func eventLocation(t time.Time) *time.Location {
+	if t.Location() == time.Local {
+		return time.UTC
+	}
+
+	return t.Location()
+}
@@ -9,6 +9,9 @@ import (
 
 	"github.com/hesoyamTM/apphelper-notification/pkg/redpanda"
 	"github.com/hesoyamTM/apphelper-schedule/internal/clients"
+	"github.com/hesoyamTM/apphelper-schedule/internal/services/calendarsync"
+	"github.com/hesoyamTM/apphelper-schedule/internal/services/outbox"
+	"github.com/hesoyamTM/apphelper-schedule/internal/services/schedule"
 	"github.com/hesoyamTM/apphelper-schedule/internal/storage/psql"
 	"github.com/hesoyamTM/apphelper-schedule/internal/storage/redis"
 	"github.com/hesoyamTM/apphelper-sso/pkg/observability"
@@ -20,13 +23,20 @@ type Config struct {
 	Env      string        `yaml:"env" env-required:"true" env:"ENV"`
 	StateTTL time.Duration `yaml:"state-ttl" env-required:"true" env:"STATE_TTL"`
 
-	Grpc                GRPC                      `yaml:"grpc"`
-	Psql                psql.PsqlConfig           `yaml:"psql"`
-	RedisSessionStorage redis.RedisConfig         `yaml:"redis-session-storage"`
-	RedisStateStorage   redis.RedisConfig         `yaml:"redis-state-storage"`
-	GoogleCalendar      clients.GoogleCalendarCfg `yaml:"google-calendar"`
-	Redpanda            redpanda.RedpandaConfig   `yaml:"redpanda"`
-	Observability       observability.OtelConfig  `yaml:"observability"`
+	Grpc                GRPC                       `yaml:"grpc"`
+	Schedule            schedule.Config            `yaml:"schedule"`
+	HTTP                HTTP                       `yaml:"http"`
+	Psql                psql.PsqlConfig            `yaml:"psql"`
+	RedisSessionStorage redis.RedisConfig          `yaml:"redis-session-storage"`
+	RedisStateStorage   redis.RedisConfig          `yaml:"redis-state-storage"`
+	GoogleCalendar      clients.GoogleCalendarCfg  `yaml:"google-calendar"`
+	OutlookCalendar     clients.OutlookCalendarCfg `yaml:"outlook-calendar"`
+	CalDAV              clients.CalDAVCfg          `yaml:"caldav"`
+	Redpanda            redpanda.RedpandaConfig    `yaml:"redpanda"`
+	Outbox              outbox.RelayConfig         `yaml:"outbox"`
+	CalendarSync        calendarsync.WorkerConfig  `yaml:"calendar-sync"`
+	CalendarPoll        calendarsync.SyncerConfig  `yaml:"calendar-poll"`
+	Observability       observability.OtelConfig   `yaml:"observability"`
 }
 
 type GRPC struct {
@@ -34,6 +44,12 @@ type GRPC struct {
 	Port int    `yaml:"port" env-required:"true" env:"GRPC_PORT"`
 }
 
+// HTTP serves the iCalendar feeds and receives calendar push notifications. The server is not started without a port.
+type HTTP struct {
+	Host string `yaml:"host" env-default:"0.0.0.0" env:"HTTP_HOST"`
+	Port int    `yaml:"port" env:"HTTP_PORT"`
+}
+
 func fetchConfigPath() string {
 	var cfgPath string
 
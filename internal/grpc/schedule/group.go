@@ -2,10 +2,12 @@ package schedule
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
 	schedulev1 "github.com/hesoyamTM/apphelper-protos/gen/go/schedule"
 	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/services/groups"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -15,6 +17,7 @@ type GroupService interface {
 	CreateGroup(ctx context.Context, trainerId uuid.UUID, name string) error
 	GetGroups(ctx context.Context, trainerId, studentId uuid.UUID) ([]*models.Group, error)
 	DeleteGroup(ctx context.Context, groupId, trainerId uuid.UUID) error
+	GetGroup(ctx context.Context, groupId uuid.UUID) (*models.Group, error)
 }
 
 func (s *serverAPI) AddToGroup(ctx context.Context, req *schedulev1.AddToGroupRequest) (*schedulev1.Empty, error) {
@@ -33,6 +36,18 @@ func (s *serverAPI) AddToGroup(ctx context.Context, req *schedulev1.AddToGroupRe
 	}
 
 	if err := s.group.AddToGroup(ctx, userId, link); err != nil {
+		if errors.Is(err, groups.ErrInvalidInvitation) {
+			return nil, status.Error(codes.NotFound, "invitation not found")
+		}
+		if errors.Is(err, groups.ErrAlreadyMember) {
+			return nil, status.Error(codes.AlreadyExists, "already a member of the group")
+		}
+		if errors.Is(err, groups.ErrJoinRequestPending) {
+			return nil, status.Error(codes.AlreadyExists, "join request is already pending")
+		}
+		if errors.Is(err, groups.ErrGroupFull) {
+			return nil, status.Error(codes.ResourceExhausted, "group is full")
+		}
 		// TODO: error
 
 		return nil, status.Error(codes.Internal, "internal error")
@@ -113,7 +128,17 @@ func (s *serverAPI) DeleteGroup(ctx context.Context, req *schedulev1.DeleteGroup
 		return nil, status.Error(codes.InvalidArgument, "validation error")
 	}
 
+	if err := CheckIdPermission(ctx, trainerId); err != nil {
+		return nil, err
+	}
+	if err := s.CheckGroupRole(ctx, groupId, trainerId, models.RoleOwner); err != nil {
+		return nil, err
+	}
+
 	if err := s.group.DeleteGroup(ctx, groupId, trainerId); err != nil {
+		if errors.Is(err, groups.ErrGroupNotFound) {
+			return nil, status.Error(codes.NotFound, "group not found")
+		}
 		// TODO: error
 
 		return nil, status.Error(codes.Internal, "internal error")
@@ -2,10 +2,12 @@ package schedule
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
 	schedulev1 "github.com/hesoyamTM/apphelper-protos/gen/go/schedule"
 	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/services/schedule"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
@@ -15,9 +17,9 @@ import (
 type ScheduleService interface {
 	CreateSchedule(ctx context.Context, sched *models.Schedule) error
 	CreateScheduleForGroup(ctx context.Context, groupId uuid.UUID, sched *models.Schedule) error
-	GetSchedules(ctx context.Context, groupId, trainerId, studentId uuid.UUID) ([]*models.Schedule, error)
-	DeleteSchedule(ctx context.Context, groupId, trainerId uuid.UUID) error
-	LoginURL(ctx context.Context, userID uuid.UUID) string
+	GetSchedules(ctx context.Context, filter models.ScheduleFilter, pageToken string) ([]*models.Schedule, string, error)
+	DeleteSchedule(ctx context.Context, scheduleId, trainerId uuid.UUID) error
+	LoginURL(ctx context.Context, userID uuid.UUID, provider models.CalendarProvider) string
 	Authorize(ctx context.Context, userId uuid.UUID, authcode, state string) error
 	IsAuthorized(ctx context.Context, userId uuid.UUID) bool
 }
@@ -38,6 +40,11 @@ func (s *serverAPI) CreateSchedule(ctx context.Context, req *schedulev1.CreateSc
 	if err := CheckIdPermission(ctx, trainerId); err != nil {
 		return nil, err
 	}
+	if groupId != uuid.Nil {
+		if err := s.CheckGroupRole(ctx, groupId, trainerId, models.RoleOwner, models.RoleCoTrainer); err != nil {
+			return nil, err
+		}
+	}
 
 	sched := &models.Schedule{
 		GroupId:   groupId,
@@ -45,10 +52,19 @@ func (s *serverAPI) CreateSchedule(ctx context.Context, req *schedulev1.CreateSc
 		TrainerId: trainerId,
 		StudentId: studentId,
 		Start:     req.Start.AsTime(),
-		End:       req.End.AsTime(),
+	}
+	// without an end a lesson of a group lasts the default duration of the group
+	if req.End != nil {
+		sched.End = req.End.AsTime()
 	}
 
 	if err := s.schedule.CreateSchedule(ctx, sched); err != nil {
+		if errors.Is(err, schedule.ErrScheduleConflict) {
+			return nil, status.Error(codes.AlreadyExists, "schedule conflict")
+		}
+		if errors.Is(err, schedule.ErrInvalidTime) {
+			return nil, status.Error(codes.InvalidArgument, "validation error")
+		}
 		// TODO: error
 
 		return nil, status.Error(codes.Internal, "internal error")
@@ -69,15 +85,27 @@ func (s *serverAPI) CreateScheduleForGroup(ctx context.Context, req *schedulev1.
 	if err := CheckIdPermission(ctx, trainerId); err != nil {
 		return nil, err
 	}
+	if err := s.CheckGroupRole(ctx, groupId, trainerId, models.RoleOwner, models.RoleCoTrainer); err != nil {
+		return nil, err
+	}
 	sched := &models.Schedule{
 		GroupId:   groupId,
 		Title:     req.GetTitle(),
 		TrainerId: trainerId,
 		Start:     req.Start.AsTime(),
-		End:       req.End.AsTime(),
+	}
+	// without an end the lesson lasts the default duration of the group
+	if req.End != nil {
+		sched.End = req.End.AsTime()
 	}
 
 	if err := s.schedule.CreateScheduleForGroup(ctx, groupId, sched); err != nil {
+		if errors.Is(err, schedule.ErrScheduleConflict) {
+			return nil, status.Error(codes.AlreadyExists, "schedule conflict")
+		}
+		if errors.Is(err, schedule.ErrInvalidTime) {
+			return nil, status.Error(codes.InvalidArgument, "validation error")
+		}
 		// TODO: error
 
 		return nil, status.Error(codes.Internal, "internal error")
@@ -85,6 +113,9 @@ func (s *serverAPI) CreateScheduleForGroup(ctx context.Context, req *schedulev1.
 	return nil, nil
 }
 
+// GetSchedule lists the schedules of the trainer or the student. GetSchedulesRequest of apphelper-protos v0.1.5
+// has no time window, limit or page token, so the listing is not paged here; the paged one is served over HTTP,
+// see api.registerSchedules.
 func (s *serverAPI) GetSchedule(ctx context.Context, req *schedulev1.GetSchedulesRequest) (*schedulev1.GetSchedulesResponse, error) {
 	trainerId, err := uuid.Parse(req.GetTrainerId())
 	if err != nil {
@@ -103,7 +134,11 @@ func (s *serverAPI) GetSchedule(ctx context.Context, req *schedulev1.GetSchedule
 		return nil, err
 	}
 
-	schedules, err := s.schedule.GetSchedules(ctx, groupId, trainerId, studentId)
+	schedules, _, err := s.schedule.GetSchedules(ctx, models.ScheduleFilter{
+		TrainerId: trainerId,
+		StudentId: studentId,
+		GroupId:   groupId,
+	}, "")
 	if err != nil {
 		// TODO: error
 
@@ -140,7 +175,17 @@ func (s *serverAPI) DeleteSchedule(ctx context.Context, req *schedulev1.DeleteSc
 		return nil, status.Error(codes.InvalidArgument, "validation error")
 	}
 
+	if err := CheckIdPermission(ctx, trainerId); err != nil {
+		return nil, err
+	}
+
 	if err := s.schedule.DeleteSchedule(ctx, scheduleId, trainerId); err != nil {
+		if errors.Is(err, schedule.ErrScheduleNotFound) {
+			return nil, status.Error(codes.NotFound, "schedule not found")
+		}
+		if errors.Is(err, schedule.ErrScheduleCancelled) {
+			return nil, status.Error(codes.FailedPrecondition, "schedule is already cancelled")
+		}
 		// TODO: error
 
 		return nil, status.Error(codes.Internal, "internal error")
@@ -162,8 +207,13 @@ func (s *serverAPI) GetLoginLink(ctx context.Context, req *schedulev1.Empty) (*s
 		return nil, status.Error(codes.InvalidArgument, "validation error")
 	}
 
+	provider := models.ProviderGoogle
+	if p, ok := md["provider"]; ok && len(p) > 0 {
+		provider = models.CalendarProvider(p[0])
+	}
+
 	return &schedulev1.GetLoginLinkResponse{
-		LoginLink: s.schedule.LoginURL(ctx, userId),
+		LoginLink: s.schedule.LoginURL(ctx, userId, provider),
 	}, nil
 }
 
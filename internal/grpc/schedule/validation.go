@@ -6,6 +6,7 @@ import (
 	"slices"
 
 	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
 	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -46,3 +47,23 @@ func CheckIdPermission(ctx context.Context, ids ...uuid.UUID) error {
 	log.Error(ctx, fmt.Sprintf("%s: permission denied", op))
 	return status.Error(codes.PermissionDenied, "permission denied")
 }
+
+// CheckGroupRole checks that the user has one of roles in the group.
+func (s *serverAPI) CheckGroupRole(ctx context.Context, groupId, userId uuid.UUID, roles ...models.GroupRole) error {
+	const op = "schedule.CheckGroupRole"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	group, err := s.group.GetGroup(ctx, groupId)
+	if err != nil {
+		// TODO: error
+
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	if group.HasRole(userId, roles...) {
+		return nil
+	}
+
+	log.Error(ctx, fmt.Sprintf("%s: permission denied", op))
+	return status.Error(codes.PermissionDenied, "permission denied")
+}
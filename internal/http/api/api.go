@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/lib/recurrence"
+	"github.com/hesoyamTM/apphelper-schedule/internal/services/schedule"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// userHeader carries the id of the authenticated user, set by the gateway like the uid metadata of gRPC calls
+const userHeader = "Uid"
+
+var errValidation = errors.New("validation error")
+
+// ScheduleService serves the operations whose requests apphelper-protos does not describe yet.
+type ScheduleService interface {
+	SchedulesService
+	ImportService
+	AvailabilityService
+	FreeBusyService
+	AttendanceService
+}
+
+// Register serves the JSON API of the schedules at /api. Every request is made on behalf of the user
+// in the uid header.
+func Register(ctx context.Context, mux *http.ServeMux, scheduleService ScheduleService) {
+	registerSchedules(ctx, mux, scheduleService)
+	registerImport(ctx, mux, scheduleService)
+	registerAvailability(ctx, mux, scheduleService)
+	registerFreeBusy(ctx, mux, scheduleService)
+	registerAttendance(ctx, mux, scheduleService)
+}
+
+// handler runs handle for the authenticated user and writes its result as JSON, or its error with the status
+// matching it.
+func handler(ctx context.Context, handle func(r *http.Request, userId uuid.UUID) (any, error)) http.HandlerFunc {
+	log := logger.GetLoggerFromCtx(ctx)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := uuid.Parse(r.Header.Get(userHeader))
+		if err != nil {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+
+		resp, err := handle(r, userId)
+		if err != nil {
+			code, msg := errorStatus(err)
+			if code == http.StatusInternalServerError {
+				log.Error(ctx, "failed to handle request", zap.String("path", r.URL.Path), zap.Error(err))
+			}
+
+			http.Error(w, msg, code)
+			return
+		}
+
+		if resp == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Error(ctx, "failed to write response", zap.Error(err))
+		}
+	}
+}
+
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, errValidation), errors.Is(err, schedule.ErrInvalidCursor), errors.Is(err, schedule.ErrInvalidTime),
+		errors.Is(err, schedule.ErrInvalidImport), errors.Is(err, schedule.ErrInvalidAvailability),
+		errors.Is(err, recurrence.ErrInvalidRule), errors.Is(err, schedule.ErrInvalidAttendance),
+		errors.Is(err, schedule.ErrNotAnOccurrence):
+		return http.StatusBadRequest, "validation error"
+	case errors.Is(err, schedule.ErrUnauthorized):
+		return http.StatusForbidden, "permission denied"
+	case errors.Is(err, storage.ErrGroupNotFound):
+		return http.StatusNotFound, "group not found"
+	case errors.Is(err, schedule.ErrAvailabilityNotFound):
+		return http.StatusNotFound, "availability not found"
+	case errors.Is(err, schedule.ErrScheduleNotFound):
+		return http.StatusNotFound, "schedule not found"
+	case errors.Is(err, schedule.ErrSeriesNotFound):
+		return http.StatusNotFound, "series not found"
+	case errors.Is(err, schedule.ErrScheduleCancelled):
+		return http.StatusConflict, "schedule is cancelled"
+	case errors.Is(err, schedule.ErrScheduleConflict):
+		return http.StatusConflict, "schedule conflict"
+	case errors.Is(err, schedule.ErrSlotUnavailable):
+		return http.StatusConflict, "slot is not available"
+	default:
+		return http.StatusInternalServerError, "internal error"
+	}
+}
+
+func parseId(value string) (uuid.UUID, error) {
+	if value == "" {
+		return uuid.Nil, nil
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, errValidation
+	}
+
+	return id, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errValidation
+	}
+
+	return t, nil
+}
+
+func parseInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, errValidation
+	}
+
+	return n, nil
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errValidation
+	}
+
+	return d, nil
+}
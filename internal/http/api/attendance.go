@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+)
+
+type AttendanceService interface {
+	MarkAttendance(ctx context.Context, trainerId, scheduleId uuid.UUID, status models.AttendanceStatus, note string) (*models.Attendance, error)
+	MarkOccurrenceAttendance(ctx context.Context, trainerId, seriesId uuid.UUID, recurrenceId time.Time, status models.AttendanceStatus, note string) (*models.Attendance, error)
+	GetAttendance(ctx context.Context, userId uuid.UUID, filter models.AttendanceFilter) ([]*models.Attendance, error)
+}
+
+type markRequest struct {
+	Status models.AttendanceStatus `json:"status"`
+	Note   string                  `json:"note"`
+}
+
+type attendanceResponse struct {
+	Attendance []*models.Attendance `json:"attendance"`
+}
+
+// registerAttendance serves the attendance of the lessons:
+//
+//	PUT /api/schedules/{schedule_id}/attendance                           marks the lesson
+//	PUT /api/series/{series_id}/occurrences/{recurrence_id}/attendance    marks the occurrence starting at recurrence_id
+//	GET /api/attendance                                                   lists it, ?student_id=&group_id=&from=&to=
+//
+// Marks are made by the staff of the lesson; students list their own attendance and the staff the one of their group.
+func registerAttendance(ctx context.Context, mux *http.ServeMux, attendanceService AttendanceService) {
+	mux.HandleFunc("PUT /api/schedules/{schedule_id}/attendance", handler(ctx, func(r *http.Request, userId uuid.UUID) (any, error) {
+		scheduleId, err := parseId(r.PathValue("schedule_id"))
+		if err != nil {
+			return nil, err
+		}
+
+		var req markRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, errValidation
+		}
+
+		return attendanceService.MarkAttendance(r.Context(), userId, scheduleId, req.Status, req.Note)
+	}))
+
+	mux.HandleFunc("PUT /api/series/{series_id}/occurrences/{recurrence_id}/attendance", handler(ctx, func(r *http.Request, userId uuid.UUID) (any, error) {
+		seriesId, err := parseId(r.PathValue("series_id"))
+		if err != nil {
+			return nil, err
+		}
+		recurrenceId, err := parseTime(r.PathValue("recurrence_id"))
+		if err != nil {
+			return nil, err
+		}
+
+		var req markRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, errValidation
+		}
+
+		return attendanceService.MarkOccurrenceAttendance(r.Context(), userId, seriesId, recurrenceId, req.Status, req.Note)
+	}))
+
+	mux.HandleFunc("GET /api/attendance", handler(ctx, func(r *http.Request, userId uuid.UUID) (any, error) {
+		query := r.URL.Query()
+
+		var filter models.AttendanceFilter
+		var err error
+
+		if filter.StudentId, err = parseId(query.Get("student_id")); err != nil {
+			return nil, err
+		}
+		if filter.GroupId, err = parseId(query.Get("group_id")); err != nil {
+			return nil, err
+		}
+		if filter.From, err = parseTime(query.Get("from")); err != nil {
+			return nil, err
+		}
+		if filter.To, err = parseTime(query.Get("to")); err != nil {
+			return nil, err
+		}
+
+		attendance, err := attendanceService.GetAttendance(r.Context(), userId, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		return &attendanceResponse{Attendance: attendance}, nil
+	}))
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+)
+
+type AvailabilityService interface {
+	CreateAvailability(ctx context.Context, av *models.Availability) error
+	GetAvailability(ctx context.Context, trainerId uuid.UUID) ([]*models.Availability, error)
+	DeleteAvailability(ctx context.Context, availabilityId, trainerId uuid.UUID) error
+	GetFreeSlots(ctx context.Context, userId, trainerId uuid.UUID, from, to time.Time) ([]*models.Slot, error)
+	BookSlot(ctx context.Context, studentId, availabilityId uuid.UUID, start time.Time) (*models.Schedule, error)
+}
+
+// availability is the JSON form of models.Availability, with durations such as "45m".
+type availability struct {
+	Id           uuid.UUID         `json:"id"`
+	TrainerId    uuid.UUID         `json:"trainer_id"`
+	GroupId      uuid.UUID         `json:"group_id"`
+	Title        string            `json:"title"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	SlotLength   string            `json:"slot_length"`
+	BufferBefore string            `json:"buffer_before"`
+	BufferAfter  string            `json:"buffer_after"`
+	Recurrence   models.Recurrence `json:"recurrence"`
+}
+
+type availabilityResponse struct {
+	Availability []*availability `json:"availability"`
+}
+
+type slotsResponse struct {
+	Slots []*models.Slot `json:"slots"`
+}
+
+type bookingRequest struct {
+	Start time.Time `json:"start"`
+}
+
+// registerAvailability serves the availability windows of the user and the booking of their slots:
+//
+//	POST /api/availability                    publishes a window of the user in one of their groups
+//	GET /api/availability                     lists the windows of the user
+//	DELETE /api/availability/{id}             withdraws a window of the user
+//	GET /api/trainers/{trainer_id}/slots      lists the free slots of the trainer in the groups of the user, ?from=&to=
+//	POST /api/availability/{id}/bookings      books the slot starting at start for the user
+func registerAvailability(ctx context.Context, mux *http.ServeMux, availabilityService AvailabilityService) {
+	mux.HandleFunc("POST /api/availability", handler(ctx, func(r *http.Request, userId uuid.UUID) (any, error) {
+		var req availability
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, errValidation
+		}
+
+		av := &models.Availability{
+			TrainerId:  userId,
+			GroupId:    req.GroupId,
+			Title:      req.Title,
+			Start:      req.Start,
+			End:        req.End,
+			Recurrence: req.Recurrence,
+		}
+
+		var err error
+		if av.SlotLength, err = parseDuration(req.SlotLength); err != nil {
+			return nil, err
+		}
+		if av.BufferBefore, err = parseDuration(req.BufferBefore); err != nil {
+			return nil, err
+		}
+		if av.BufferAfter, err = parseDuration(req.BufferAfter); err != nil {
+			return nil, err
+		}
+
+		if err := availabilityService.CreateAvailability(r.Context(), av); err != nil {
+			return nil, err
+		}
+
+		return availabilityJSON(av), nil
+	}))
+
+	mux.HandleFunc("GET /api/availability", handler(ctx, func(r *http.Request, userId uuid.UUID) (any, error) {
+		windows, err := availabilityService.GetAvailability(r.Context(), userId)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &availabilityResponse{Availability: make([]*availability, len(windows))}
+		for i, av := range windows {
+			resp.Availability[i] = availabilityJSON(av)
+		}
+
+		return resp, nil
+	}))
+
+	mux.HandleFunc("DELETE /api/availability/{availability_id}", handler(ctx, func(r *http.Request, userId uuid.UUID) (any, error) {
+		availabilityId, err := parseId(r.PathValue("availability_id"))
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, availabilityService.DeleteAvailability(r.Context(), availabilityId, userId)
+	}))
+
+	mux.HandleFunc("GET /api/trainers/{trainer_id}/slots", handler(ctx, func(r *http.Request, userId uuid.UUID) (any, error) {
+		query := r.URL.Query()
+
+		trainerId, err := parseId(r.PathValue("trainer_id"))
+		if err != nil {
+			return nil, err
+		}
+		from, err := parseTime(query.Get("from"))
+		if err != nil {
+			return nil, err
+		}
+		to, err := parseTime(query.Get("to"))
+		if err != nil {
+			return nil, err
+		}
+		if from.IsZero() || !to.After(from) {
+			return nil, errValidation
+		}
+
+		slots, err := availabilityService.GetFreeSlots(r.Context(), userId, trainerId, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		return &slotsResponse{Slots: slots}, nil
+	}))
+
+	mux.HandleFunc("POST /api/availability/{availability_id}/bookings", handler(ctx, func(r *http.Request, userId uuid.UUID) (any, error) {
+		availabilityId, err := parseId(r.PathValue("availability_id"))
+		if err != nil {
+			return nil, err
+		}
+
+		var req bookingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Start.IsZero() {
+			return nil, errValidation
+		}
+
+		return availabilityService.BookSlot(r.Context(), userId, availabilityId, req.Start)
+	}))
+}
+
+func availabilityJSON(av *models.Availability) *availability {
+	return &availability{
+		Id:           av.Id,
+		TrainerId:    av.TrainerId,
+		GroupId:      av.GroupId,
+		Title:        av.Title,
+		Start:        av.Start,
+		End:          av.End,
+		SlotLength:   av.SlotLength.String(),
+		BufferBefore: av.BufferBefore.String(),
+		BufferAfter:  av.BufferAfter.String(),
+		Recurrence:   av.Recurrence,
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+)
+
+type FreeBusyService interface {
+	GetFreeBusy(ctx context.Context, userId, groupId, trainerId uuid.UUID, studentIds []uuid.UUID, from, to time.Time, duration time.Duration) (*models.FreeBusy, error)
+}
+
+// registerFreeBusy serves the busy and the common free time of a trainer and students of the group to its staff:
+// GET /api/groups/{group_id}/freebusy?trainer_id=&student_id=&from=&to=&duration=.
+// student_id may be repeated, all students of the group are included without it.
+func registerFreeBusy(ctx context.Context, mux *http.ServeMux, freeBusyService FreeBusyService) {
+	mux.HandleFunc("GET /api/groups/{group_id}/freebusy", handler(ctx, func(r *http.Request, userId uuid.UUID) (any, error) {
+		query := r.URL.Query()
+
+		groupId, err := parseId(r.PathValue("group_id"))
+		if err != nil {
+			return nil, err
+		}
+		trainerId, err := parseId(query.Get("trainer_id"))
+		if err != nil {
+			return nil, err
+		}
+		if trainerId == uuid.Nil {
+			trainerId = userId
+		}
+
+		studentIds := make([]uuid.UUID, 0, len(query["student_id"]))
+		for _, value := range query["student_id"] {
+			studentId, err := parseId(value)
+			if err != nil {
+				return nil, err
+			}
+			studentIds = append(studentIds, studentId)
+		}
+
+		from, err := parseTime(query.Get("from"))
+		if err != nil {
+			return nil, err
+		}
+		to, err := parseTime(query.Get("to"))
+		if err != nil {
+			return nil, err
+		}
+		duration, err := parseDuration(query.Get("duration"))
+		if err != nil {
+			return nil, err
+		}
+
+		return freeBusyService.GetFreeBusy(r.Context(), userId, groupId, trainerId, studentIds, from, to, duration)
+	}))
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/services/schedule"
+)
+
+// maxImportSize limits the iCalendar files accepted by the import
+const maxImportSize = 1 << 20
+
+type ImportService interface {
+	ImportSchedules(ctx context.Context, trainerId, groupId uuid.UUID, data []byte, dryRun bool) (*models.ImportResult, error)
+}
+
+// registerImport serves the import of an iCalendar file, sent as the request body, into lessons of the group
+// taught by the user: POST /api/groups/{group_id}/import?dry_run=true.
+// Entries overlapping existing lessons are returned uncommitted rather than as an error, so that they can be shown.
+func registerImport(ctx context.Context, mux *http.ServeMux, importService ImportService) {
+	mux.HandleFunc("POST /api/groups/{group_id}/import", handler(ctx, func(r *http.Request, userId uuid.UUID) (any, error) {
+		groupId, err := parseId(r.PathValue("group_id"))
+		if err != nil {
+			return nil, err
+		}
+
+		dryRun := false
+		if value := r.URL.Query().Get("dry_run"); value != "" {
+			if dryRun, err = strconv.ParseBool(value); err != nil {
+				return nil, errValidation
+			}
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxImportSize))
+		if err != nil {
+			return nil, errValidation
+		}
+
+		result, err := importService.ImportSchedules(r.Context(), userId, groupId, data, dryRun)
+		if err != nil && !(errors.Is(err, schedule.ErrScheduleConflict) && result != nil) {
+			return nil, err
+		}
+
+		return result, nil
+	}))
+}
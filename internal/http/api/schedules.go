@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/services/schedule"
+)
+
+type SchedulesService interface {
+	GetSchedules(ctx context.Context, filter models.ScheduleFilter, pageToken string) ([]*models.Schedule, string, error)
+}
+
+type schedulesResponse struct {
+	Schedules     []*models.Schedule `json:"schedules"`
+	NextPageToken string             `json:"next_page_token"`
+}
+
+// registerSchedules serves the schedules of the trainer or the student, one of whom must be the user,
+// a page at a time: GET /api/schedules?trainer_id=&student_id=&group_id=&from=&to=&search=&limit=&page_token=.
+// from and to are RFC 3339 times.
+func registerSchedules(ctx context.Context, mux *http.ServeMux, scheduleService SchedulesService) {
+	mux.HandleFunc("GET /api/schedules", handler(ctx, func(r *http.Request, userId uuid.UUID) (any, error) {
+		query := r.URL.Query()
+
+		var filter models.ScheduleFilter
+		var err error
+
+		if filter.TrainerId, err = parseId(query.Get("trainer_id")); err != nil {
+			return nil, err
+		}
+		if filter.StudentId, err = parseId(query.Get("student_id")); err != nil {
+			return nil, err
+		}
+		if filter.GroupId, err = parseId(query.Get("group_id")); err != nil {
+			return nil, err
+		}
+		if filter.From, err = parseTime(query.Get("from")); err != nil {
+			return nil, err
+		}
+		if filter.To, err = parseTime(query.Get("to")); err != nil {
+			return nil, err
+		}
+		if filter.Limit, err = parseInt(query.Get("limit")); err != nil {
+			return nil, err
+		}
+		filter.Search = query.Get("search")
+
+		if userId != filter.TrainerId && userId != filter.StudentId {
+			return nil, schedule.ErrUnauthorized
+		}
+
+		schedules, next, err := scheduleService.GetSchedules(r.Context(), filter, query.Get("page_token"))
+		if err != nil {
+			return nil, err
+		}
+
+		return &schedulesResponse{Schedules: schedules, NextPageToken: next}, nil
+	}))
+}
@@ -0,0 +1,50 @@
+package calendarhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/services/schedule"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type SyncService interface {
+	RequestCalendarSync(ctx context.Context, channelId uuid.UUID, resourceId string) error
+}
+
+// Register receives the push notifications of Google Calendar at /calendar/notifications
+// and makes the changed calendars due for a sync.
+func Register(ctx context.Context, mux *http.ServeMux, syncService SyncService) {
+	log := logger.GetLoggerFromCtx(ctx)
+
+	mux.HandleFunc("POST /calendar/notifications", func(w http.ResponseWriter, r *http.Request) {
+		// the first notification of a channel only confirms it
+		if r.Header.Get("X-Goog-Resource-State") == "sync" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		channelId, err := uuid.Parse(r.Header.Get("X-Goog-Channel-ID"))
+		if err != nil {
+			http.Error(w, "validation error", http.StatusBadRequest)
+			return
+		}
+
+		if err := syncService.RequestCalendarSync(r.Context(), channelId, r.Header.Get("X-Goog-Resource-ID")); err != nil {
+			// a channel replaced by a newer one or of a deleted calendar
+			if errors.Is(err, schedule.ErrCalendarNotFound) {
+				http.Error(w, "channel not found", http.StatusNotFound)
+				return
+			}
+
+			log.Error(ctx, "failed to request calendar sync", zap.Error(err))
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,66 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/services/schedule"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type FeedService interface {
+	ExportFeed(ctx context.Context, token string, groupId uuid.UUID, loc *time.Location) ([]byte, error)
+}
+
+// Register serves the iCalendar feeds at /feeds/{token}.ics. The optional query parameters
+// group and tz narrow the feed down to a group and render it in an IANA time zone.
+func Register(ctx context.Context, mux *http.ServeMux, feedService FeedService) {
+	log := logger.GetLoggerFromCtx(ctx)
+
+	mux.HandleFunc("GET /feeds/{token}", func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimSuffix(r.PathValue("token"), ".ics")
+
+		groupId := uuid.Nil
+		if group := r.URL.Query().Get("group"); group != "" {
+			id, err := uuid.Parse(group)
+			if err != nil {
+				http.Error(w, "validation error", http.StatusBadRequest)
+				return
+			}
+			groupId = id
+		}
+
+		var loc *time.Location
+		if tz := r.URL.Query().Get("tz"); tz != "" {
+			l, err := time.LoadLocation(tz)
+			if err != nil {
+				http.Error(w, "validation error", http.StatusBadRequest)
+				return
+			}
+			loc = l
+		}
+
+		data, err := feedService.ExportFeed(r.Context(), token, groupId, loc)
+		if err != nil {
+			switch {
+			case errors.Is(err, schedule.ErrFeedNotFound):
+				http.Error(w, "feed not found", http.StatusNotFound)
+			case errors.Is(err, schedule.ErrUnauthorized):
+				http.Error(w, "permission denied", http.StatusForbidden)
+			default:
+				log.Error(ctx, "failed to export feed", zap.Error(err))
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Cache-Control", "private, max-age=300")
+		w.Write(data)
+	})
+}
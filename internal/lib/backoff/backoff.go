@@ -0,0 +1,16 @@
+package backoff
+
+import "time"
+
+// Exponential doubles min with every failed attempt up to max.
+func Exponential(min, max time.Duration, attempts int) time.Duration {
+	delay := min
+	for range attempts {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+
+	return delay
+}
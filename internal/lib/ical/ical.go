@@ -0,0 +1,461 @@
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+)
+
+const (
+	prodId     = "-//apphelper//schedule//EN"
+	utcFormat  = "20060102T150405Z"
+	timeFormat = "20060102T150405"
+	dateFormat = "20060102"
+
+	// RFC 5545 3.1: lines SHOULD NOT be longer than 75 octets
+	maxLineLength = 75
+	// bounds the observances of a VTIMEZONE, two offset changes a year cover decades
+	maxTransitions = 100
+)
+
+var (
+	ErrInvalidCalendar = errors.New("invalid icalendar data")
+	ErrUnsupportedRule = errors.New("unsupported recurrence rule")
+)
+
+// Encode writes events as an iCalendar object with UTC times. The event id is used as UID.
+func Encode(events []*models.CalendarEvent) []byte {
+	return EncodeIn(events, time.UTC)
+}
+
+// EncodeIn writes events as an iCalendar object with local times of loc, described by a VTIMEZONE.
+// The event id is used as UID.
+func EncodeIn(events []*models.CalendarEvent, loc *time.Location) []byte {
+	var b bytes.Buffer
+
+	stamp := time.Now().UTC().Format(utcFormat)
+	utc := loc == nil || loc == time.UTC
+
+	writeLine(&b, "BEGIN:VCALENDAR")
+	writeLine(&b, "VERSION:2.0")
+	writeLine(&b, "PRODID:"+prodId)
+	if !utc {
+		writeTimezone(&b, loc, events)
+	}
+	for _, event := range events {
+		writeLine(&b, "BEGIN:VEVENT")
+		writeLine(&b, "UID:"+escapeText(event.EventId))
+		writeLine(&b, "DTSTAMP:"+stamp)
+		if utc {
+			writeLine(&b, "DTSTART:"+event.Start.UTC().Format(utcFormat))
+			writeLine(&b, "DTEND:"+event.End.UTC().Format(utcFormat))
+		} else {
+			writeLine(&b, "DTSTART;TZID="+loc.String()+":"+event.Start.In(loc).Format(timeFormat))
+			writeLine(&b, "DTEND;TZID="+loc.String()+":"+event.End.In(loc).Format(timeFormat))
+		}
+		writeLine(&b, "SUMMARY:"+escapeText(event.Title))
+		writeLine(&b, "END:VEVENT")
+	}
+	writeLine(&b, "END:VCALENDAR")
+
+	return b.Bytes()
+}
+
+// Decode reads the events of an iCalendar object. The UID is returned as event id.
+// Events without DTEND and DURATION end when they start, all-day events end a day later.
+// RRULE and EXDATE are read into the recurrence of the event; rules beyond FREQ, INTERVAL,
+// BYDAY, COUNT and UNTIL fail with ErrUnsupportedRule.
+func Decode(data []byte) ([]*models.CalendarEvent, error) {
+	lines, err := unfold(data)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*models.CalendarEvent, 0)
+
+	var event *models.CalendarEvent
+	var duration time.Duration
+	var allDay bool
+	// depth of the components nested into the current event, like VALARM
+	var depth int
+
+	for _, line := range lines {
+		name, params, value, err := parseLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case name == "BEGIN" && event == nil:
+			if strings.EqualFold(value, "VEVENT") {
+				event = &models.CalendarEvent{}
+				duration = 0
+				allDay = false
+			}
+		case name == "BEGIN":
+			depth++
+		case name == "END" && event != nil && depth > 0:
+			depth--
+		case name == "END" && event != nil:
+			if event.Start.IsZero() {
+				return nil, ErrInvalidCalendar
+			}
+			// EXDATE without RRULE
+			if event.Recurrence != nil && event.Recurrence.Frequency == "" {
+				event.Recurrence = nil
+			}
+			if event.End.IsZero() {
+				switch {
+				case duration > 0:
+					event.End = event.Start.Add(duration)
+				case allDay:
+					event.End = event.Start.AddDate(0, 0, 1)
+				default:
+					event.End = event.Start
+				}
+			}
+
+			events = append(events, event)
+			event = nil
+		case event == nil || depth > 0:
+		case name == "UID":
+			event.EventId = unescapeText(value)
+		case name == "SUMMARY":
+			event.Title = unescapeText(value)
+		case name == "DTSTART":
+			event.Start, err = parseTime(value, params)
+			if err != nil {
+				return nil, err
+			}
+			allDay = strings.EqualFold(params["VALUE"], "DATE") || len(value) == len(dateFormat)
+		case name == "DTEND":
+			event.End, err = parseTime(value, params)
+			if err != nil {
+				return nil, err
+			}
+		case name == "DURATION":
+			duration, err = parseDuration(value)
+			if err != nil {
+				return nil, err
+			}
+		case name == "RRULE":
+			rule, err := parseRule(value)
+			if err != nil {
+				return nil, err
+			}
+			if event.Recurrence != nil {
+				rule.Exceptions = event.Recurrence.Exceptions
+			}
+			event.Recurrence = rule
+		case name == "EXDATE":
+			if event.Recurrence == nil {
+				event.Recurrence = &models.Recurrence{}
+			}
+			for _, v := range strings.Split(value, ",") {
+				t, err := parseTime(v, params)
+				if err != nil {
+					return nil, err
+				}
+				event.Recurrence.Exceptions = append(event.Recurrence.Exceptions, t)
+			}
+		case name == "RECURRENCE-ID":
+			event.RecurrenceId, err = parseTime(value, params)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if event != nil {
+		return nil, ErrInvalidCalendar
+	}
+
+	return events, nil
+}
+
+func writeLine(b *bytes.Buffer, line string) {
+	length := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if length+size > maxLineLength {
+			b.WriteString("\r\n ")
+			length = 1
+		}
+
+		b.WriteRune(r)
+		length += size
+	}
+
+	b.WriteString("\r\n")
+}
+
+// writeTimezone describes loc with one observance per offset change between the first and last event.
+func writeTimezone(b *bytes.Buffer, loc *time.Location, events []*models.CalendarEvent) {
+	from, to := time.Now(), time.Now()
+	for i, event := range events {
+		if i == 0 || event.Start.Before(from) {
+			from = event.Start
+		}
+		if i == 0 || event.End.After(to) {
+			to = event.End
+		}
+	}
+
+	writeLine(b, "BEGIN:VTIMEZONE")
+	writeLine(b, "TZID:"+loc.String())
+
+	t := from.In(loc)
+	for range maxTransitions {
+		zoneStart, zoneEnd := t.ZoneBounds()
+		name, offset := t.Zone()
+
+		prevOffset := offset
+		onset := "19700101T000000"
+		if !zoneStart.IsZero() {
+			_, prevOffset = zoneStart.Add(-time.Second).Zone()
+			onset = zoneStart.In(time.FixedZone("", prevOffset)).Format(timeFormat)
+		}
+
+		kind := "STANDARD"
+		if t.IsDST() {
+			kind = "DAYLIGHT"
+		}
+
+		writeLine(b, "BEGIN:"+kind)
+		writeLine(b, "DTSTART:"+onset)
+		writeLine(b, "TZOFFSETFROM:"+formatOffset(prevOffset))
+		writeLine(b, "TZOFFSETTO:"+formatOffset(offset))
+		writeLine(b, "TZNAME:"+escapeText(name))
+		writeLine(b, "END:"+kind)
+
+		if zoneEnd.IsZero() || zoneEnd.After(to) {
+			break
+		}
+		t = zoneEnd.In(loc)
+	}
+
+	writeLine(b, "END:VTIMEZONE")
+}
+
+func formatOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+
+	return fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset%3600/60)
+}
+
+func unfold(data []byte) ([]string, error) {
+	lines := make([]string, 0)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// parseLine splits a content line into its upper-cased name, parameters and value.
+func parseLine(line string) (string, map[string]string, string, error) {
+	quoted := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		}
+		if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", ErrInvalidCalendar
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+
+	return strings.ToUpper(parts[0]), params, line[colon+1:], nil
+}
+
+func parseTime(value string, params map[string]string) (time.Time, error) {
+	loc := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		l, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, ErrInvalidCalendar
+		}
+		loc = l
+	}
+
+	var t time.Time
+	var err error
+
+	switch {
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse(utcFormat, value)
+	case len(value) == len(dateFormat):
+		t, err = time.ParseInLocation(dateFormat, value, loc)
+	default:
+		t, err = time.ParseInLocation(timeFormat, value, loc)
+	}
+	if err != nil {
+		return time.Time{}, ErrInvalidCalendar
+	}
+
+	return t, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// parseRule parses the RRULE subset of models.Recurrence, like FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10.
+func parseRule(value string) (*models.Recurrence, error) {
+	rule := &models.Recurrence{}
+
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, ErrInvalidCalendar
+		}
+
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			switch strings.ToUpper(val) {
+			case "DAILY":
+				rule.Frequency = models.FrequencyDaily
+			case "WEEKLY":
+				rule.Frequency = models.FrequencyWeekly
+			case "MONTHLY":
+				rule.Frequency = models.FrequencyMonthly
+			default:
+				return nil, ErrUnsupportedRule
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(val)
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(val)
+		case "UNTIL":
+			rule.Until, err = parseTime(val, nil)
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				weekday, ok := weekdays[strings.ToUpper(day)]
+				if !ok {
+					// positions like 1MO are not supported
+					return nil, ErrUnsupportedRule
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "WKST":
+		default:
+			return nil, ErrUnsupportedRule
+		}
+		if err != nil {
+			return nil, ErrInvalidCalendar
+		}
+	}
+
+	if rule.Frequency == "" {
+		return nil, ErrInvalidCalendar
+	}
+
+	return rule, nil
+}
+
+// parseDuration parses the RFC 5545 duration of an event, like PT1H30M or P1D.
+func parseDuration(value string) (time.Duration, error) {
+	value = strings.TrimPrefix(value, "+")
+	if !strings.HasPrefix(value, "P") {
+		return 0, ErrInvalidCalendar
+	}
+
+	var duration time.Duration
+	inTime := false
+	num := ""
+
+	for _, r := range value[1:] {
+		switch {
+		case r >= '0' && r <= '9':
+			num += string(r)
+			continue
+		case r == 'T':
+			inTime = true
+			continue
+		}
+
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, ErrInvalidCalendar
+		}
+		num = ""
+
+		switch {
+		case r == 'W' && !inTime:
+			duration += time.Duration(n) * 7 * 24 * time.Hour
+		case r == 'D' && !inTime:
+			duration += time.Duration(n) * 24 * time.Hour
+		case r == 'H' && inTime:
+			duration += time.Duration(n) * time.Hour
+		case r == 'M' && inTime:
+			duration += time.Duration(n) * time.Minute
+		case r == 'S' && inTime:
+			duration += time.Duration(n) * time.Second
+		default:
+			return 0, ErrInvalidCalendar
+		}
+	}
+	if num != "" {
+		return 0, ErrInvalidCalendar
+	}
+
+	return duration, nil
+}
+
+var (
+	textEscaper   = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func unescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
@@ -0,0 +1,132 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	start := time.Date(2025, time.March, 24, 9, 0, 0, 0, time.UTC)
+	events := []*models.CalendarEvent{
+		{EventId: "lesson-1", Title: "Math; algebra, part 1\nbring a calculator", Start: start, End: start.Add(time.Hour)},
+		// after the DST change in Berlin
+		{EventId: "lesson-2", Title: strings.Repeat("ä", 60), Start: start.AddDate(0, 0, 7), End: start.AddDate(0, 0, 7).Add(90 * time.Minute)},
+	}
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+	}{
+		{name: "utc", loc: time.UTC},
+		{name: "time zone", loc: berlin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := EncodeIn(events, tt.loc)
+
+			for _, line := range strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n") {
+				require.LessOrEqual(t, len(line), maxLineLength)
+			}
+			if tt.loc != time.UTC {
+				require.Contains(t, string(data), "TZID:Europe/Berlin")
+			}
+
+			decoded, err := Decode(data)
+			require.NoError(t, err)
+			require.Len(t, decoded, len(events))
+
+			for i, event := range events {
+				require.Equal(t, event.EventId, decoded[i].EventId)
+				require.Equal(t, event.Title, decoded[i].Title)
+				require.True(t, event.Start.Equal(decoded[i].Start), "start: want %v, got %v", event.Start, decoded[i].Start)
+				require.True(t, event.End.Equal(decoded[i].End), "end: want %v, got %v", event.End, decoded[i].End)
+				require.Nil(t, decoded[i].Recurrence)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:weekly",
+		"SUMMARY:Weekly",
+		"DTSTART;TZID=Europe/Berlin:20250303T100000",
+		"DURATION:PT1H30M",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10",
+		"EXDATE;TZID=Europe/Berlin:20250310T100000,20250312T100000",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT15M",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:weekly",
+		"RECURRENCE-ID;TZID=Europe/Berlin:20250305T100000",
+		"DTSTART:20250305T120000Z",
+		"DTEND:20250305T130000Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:all-day",
+		"DTSTART;VALUE=DATE:20250401",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := Decode([]byte(data))
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	weekly := events[0]
+	require.Equal(t, "Weekly", weekly.Title)
+	require.True(t, time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC).Equal(weekly.Start))
+	require.Equal(t, 90*time.Minute, weekly.End.Sub(weekly.Start))
+	require.NotNil(t, weekly.Recurrence)
+	require.Equal(t, models.FrequencyWeekly, weekly.Recurrence.Frequency)
+	require.Equal(t, []time.Weekday{time.Monday, time.Wednesday}, weekly.Recurrence.ByDay)
+	require.Equal(t, 10, weekly.Recurrence.Count)
+	require.Len(t, weekly.Recurrence.Exceptions, 2)
+	require.True(t, time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC).Equal(weekly.Recurrence.Exceptions[0]))
+
+	moved := events[1]
+	require.True(t, time.Date(2025, time.March, 5, 9, 0, 0, 0, time.UTC).Equal(moved.RecurrenceId))
+	require.True(t, time.Date(2025, time.March, 5, 12, 0, 0, 0, time.UTC).Equal(moved.Start))
+
+	allDay := events[2]
+	require.Equal(t, 24*time.Hour, allDay.End.Sub(allDay.Start))
+}
+
+func TestDecodeErrors(t *testing.T) {
+	event := func(lines ...string) []byte {
+		return []byte(strings.Join(append(append([]string{"BEGIN:VCALENDAR", "BEGIN:VEVENT", "UID:1"}, lines...), "END:VEVENT", "END:VCALENDAR"), "\r\n"))
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "no start", data: event("SUMMARY:test"), want: ErrInvalidCalendar},
+		{name: "invalid time", data: event("DTSTART:2025-03-03"), want: ErrInvalidCalendar},
+		{name: "unknown time zone", data: event("DTSTART;TZID=Mars/Olympus:20250303T100000"), want: ErrInvalidCalendar},
+		{name: "yearly rule", data: event("DTSTART:20250303T100000Z", "RRULE:FREQ=YEARLY"), want: ErrUnsupportedRule},
+		{name: "positional by day", data: event("DTSTART:20250303T100000Z", "RRULE:FREQ=MONTHLY;BYDAY=1MO"), want: ErrUnsupportedRule},
+		{name: "unterminated event", data: []byte("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:20250303T100000Z\r\n"), want: ErrInvalidCalendar},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.data)
+			require.ErrorIs(t, err, tt.want)
+		})
+	}
+}
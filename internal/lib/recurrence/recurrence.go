@@ -0,0 +1,132 @@
+package recurrence
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+)
+
+// maxPeriods bounds expansion of open-ended rules
+const maxPeriods = 10000
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+func Validate(rule models.Recurrence) error {
+	switch rule.Frequency {
+	case models.FrequencyDaily, models.FrequencyWeekly, models.FrequencyMonthly:
+	default:
+		return ErrInvalidRule
+	}
+
+	if rule.Interval < 0 || rule.Count < 0 {
+		return ErrInvalidRule
+	}
+	// RFC 5545: COUNT and UNTIL MUST NOT occur in the same rule
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return ErrInvalidRule
+	}
+	for _, day := range rule.ByDay {
+		if day < time.Sunday || day > time.Saturday {
+			return ErrInvalidRule
+		}
+	}
+
+	return nil
+}
+
+// Expand returns the occurrence start times of rule anchored at start that fall into [from, to].
+// A zero from or to leaves that side of the window open.
+func Expand(rule models.Recurrence, start, from, to time.Time) []time.Time {
+	interval := rule.Interval
+	if interval == 0 {
+		interval = 1
+	}
+
+	occurrences := make([]time.Time, 0)
+	generated := 0
+
+	for period := 0; period < maxPeriods; period++ {
+		for _, candidate := range candidates(rule, start, period*interval) {
+			if candidate.Before(start) {
+				continue
+			}
+			if !rule.Until.IsZero() && candidate.After(rule.Until) {
+				return occurrences
+			}
+			if rule.Count > 0 && generated >= rule.Count {
+				return occurrences
+			}
+			if !to.IsZero() && candidate.After(to) {
+				return occurrences
+			}
+
+			generated++
+
+			if !from.IsZero() && candidate.Before(from) {
+				continue
+			}
+			if slices.ContainsFunc(rule.Exceptions, candidate.Equal) {
+				continue
+			}
+
+			occurrences = append(occurrences, candidate)
+		}
+	}
+
+	return occurrences
+}
+
+// IsOccurrence reports whether t is one of the occurrences of rule anchored at start.
+func IsOccurrence(rule models.Recurrence, start, t time.Time) bool {
+	return len(Expand(rule, start, t, t)) == 1
+}
+
+// candidates returns the sorted occurrence candidates of the period shifted by offset units of the rule frequency.
+// Wall clock time of start is kept, so occurrences do not drift across DST changes.
+func candidates(rule models.Recurrence, start time.Time, offset int) []time.Time {
+	y, m, d := start.Date()
+	hh, mm, ss := start.Clock()
+	loc := start.Location()
+
+	switch rule.Frequency {
+	case models.FrequencyDaily:
+		day := time.Date(y, m, d+offset, hh, mm, ss, start.Nanosecond(), loc)
+		if len(rule.ByDay) > 0 && !slices.Contains(rule.ByDay, day.Weekday()) {
+			return nil
+		}
+		return []time.Time{day}
+
+	case models.FrequencyWeekly:
+		days := rule.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+
+		// weeks start on Monday (RFC 5545 default WKST)
+		weekStart := d - daysFromMonday(start.Weekday()) + 7*offset
+
+		res := make([]time.Time, 0, len(days))
+		for _, day := range days {
+			res = append(res, time.Date(y, m, weekStart+daysFromMonday(day), hh, mm, ss, start.Nanosecond(), loc))
+		}
+		slices.SortFunc(res, func(a, b time.Time) int { return a.Compare(b) })
+
+		return slices.CompactFunc(res, time.Time.Equal)
+
+	case models.FrequencyMonthly:
+		month := time.Date(y, m+time.Month(offset), d, hh, mm, ss, start.Nanosecond(), loc)
+		// months without the day of start are skipped, as in RFC 5545
+		if month.Day() != d {
+			return nil
+		}
+		return []time.Time{month}
+	}
+
+	return nil
+}
+
+func daysFromMonday(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
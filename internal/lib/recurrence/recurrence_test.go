@@ -0,0 +1,151 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpand(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	monday := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		rule  models.Recurrence
+		start time.Time
+		from  time.Time
+		to    time.Time
+		want  []time.Time
+	}{
+		{
+			name:  "weekly by day with count",
+			rule:  models.Recurrence{Frequency: models.FrequencyWeekly, ByDay: []time.Weekday{time.Wednesday, time.Monday}, Count: 5},
+			start: monday,
+			want: []time.Time{
+				monday, monday.AddDate(0, 0, 2), monday.AddDate(0, 0, 7), monday.AddDate(0, 0, 9), monday.AddDate(0, 0, 14),
+			},
+		},
+		{
+			name:  "by day before the start is skipped",
+			rule:  models.Recurrence{Frequency: models.FrequencyWeekly, ByDay: []time.Weekday{time.Monday, time.Friday}, Count: 3},
+			start: monday.AddDate(0, 0, 2), // Wednesday
+			want:  []time.Time{monday.AddDate(0, 0, 4), monday.AddDate(0, 0, 7), monday.AddDate(0, 0, 11)},
+		},
+		{
+			name:  "daily until is inclusive",
+			rule:  models.Recurrence{Frequency: models.FrequencyDaily, Until: monday.AddDate(0, 0, 2)},
+			start: monday,
+			want:  []time.Time{monday, monday.AddDate(0, 0, 1), monday.AddDate(0, 0, 2)},
+		},
+		{
+			name:  "interval",
+			rule:  models.Recurrence{Frequency: models.FrequencyWeekly, Interval: 2, Count: 3},
+			start: monday,
+			want:  []time.Time{monday, monday.AddDate(0, 0, 14), monday.AddDate(0, 0, 28)},
+		},
+		{
+			name:  "monthly on the 31st skips shorter months",
+			rule:  models.Recurrence{Frequency: models.FrequencyMonthly, Count: 4},
+			start: time.Date(2025, time.January, 31, 10, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2025, time.January, 31, 10, 0, 0, 0, time.UTC),
+				time.Date(2025, time.March, 31, 10, 0, 0, 0, time.UTC),
+				time.Date(2025, time.May, 31, 10, 0, 0, 0, time.UTC),
+				time.Date(2025, time.July, 31, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "wall clock is kept when DST starts",
+			rule:  models.Recurrence{Frequency: models.FrequencyWeekly, Count: 2},
+			start: time.Date(2025, time.March, 24, 10, 0, 0, 0, berlin),
+			want:  []time.Time{time.Date(2025, time.March, 24, 9, 0, 0, 0, time.UTC), time.Date(2025, time.March, 31, 8, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:  "wall clock is kept when DST ends",
+			rule:  models.Recurrence{Frequency: models.FrequencyDaily, Count: 2},
+			start: time.Date(2025, time.October, 25, 10, 0, 0, 0, berlin),
+			want:  []time.Time{time.Date(2025, time.October, 25, 8, 0, 0, 0, time.UTC), time.Date(2025, time.October, 26, 9, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:  "exceptions count towards count",
+			rule:  models.Recurrence{Frequency: models.FrequencyDaily, Count: 3, Exceptions: []time.Time{monday.AddDate(0, 0, 1)}},
+			start: monday,
+			want:  []time.Time{monday, monday.AddDate(0, 0, 2)},
+		},
+		{
+			name:  "window",
+			rule:  models.Recurrence{Frequency: models.FrequencyDaily, Count: 10},
+			start: monday,
+			from:  monday.AddDate(0, 0, 8),
+			to:    monday.AddDate(0, 0, 20),
+			want:  []time.Time{monday.AddDate(0, 0, 8), monday.AddDate(0, 0, 9)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Expand(tt.rule, tt.start, tt.from, tt.to)
+
+			require.Len(t, got, len(tt.want))
+			for i := range tt.want {
+				require.True(t, tt.want[i].Equal(got[i]), "occurrence %d: want %v, got %v", i, tt.want[i], got[i])
+			}
+		})
+	}
+}
+
+func TestExpandOpenEnded(t *testing.T) {
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+	// a rule without count and until stops after maxPeriods periods
+	daily := Expand(models.Recurrence{Frequency: models.FrequencyDaily}, start, time.Time{}, time.Time{})
+	require.Len(t, daily, maxPeriods)
+	require.True(t, start.AddDate(0, 0, maxPeriods-1).Equal(daily[len(daily)-1]))
+
+	weekly := Expand(models.Recurrence{Frequency: models.FrequencyWeekly, ByDay: []time.Weekday{time.Monday, time.Thursday}}, start, time.Time{}, time.Time{})
+	require.Len(t, weekly, 2*maxPeriods)
+}
+
+func TestIsOccurrence(t *testing.T) {
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+	rule := models.Recurrence{Frequency: models.FrequencyWeekly, Count: 3, Exceptions: []time.Time{start.AddDate(0, 0, 7)}}
+
+	require.True(t, IsOccurrence(rule, start, start))
+	require.True(t, IsOccurrence(rule, start, start.AddDate(0, 0, 14)))
+	require.False(t, IsOccurrence(rule, start, start.AddDate(0, 0, 7)))
+	require.False(t, IsOccurrence(rule, start, start.AddDate(0, 0, 21)))
+	require.False(t, IsOccurrence(rule, start, start.Add(time.Hour)))
+}
+
+func TestValidate(t *testing.T) {
+	until := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		rule    models.Recurrence
+		wantErr bool
+	}{
+		{name: "weekly", rule: models.Recurrence{Frequency: models.FrequencyWeekly, ByDay: []time.Weekday{time.Monday}}},
+		{name: "until", rule: models.Recurrence{Frequency: models.FrequencyDaily, Until: until}},
+		{name: "unknown frequency", rule: models.Recurrence{Frequency: "yearly"}, wantErr: true},
+		{name: "negative interval", rule: models.Recurrence{Frequency: models.FrequencyDaily, Interval: -1}, wantErr: true},
+		{name: "count and until", rule: models.Recurrence{Frequency: models.FrequencyDaily, Count: 2, Until: until}, wantErr: true},
+		{name: "invalid day", rule: models.Recurrence{Frequency: models.FrequencyWeekly, ByDay: []time.Weekday{7}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.rule)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidRule)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AttendanceStatus string
+
+const (
+	AttendanceScheduled AttendanceStatus = "scheduled"
+	AttendanceAttended  AttendanceStatus = "attended"
+	AttendanceAbsent    AttendanceStatus = "absent"
+	AttendanceExcused   AttendanceStatus = "excused"
+	AttendanceLate      AttendanceStatus = "late"
+)
+
+// Attendance is the state of a student at a lesson. Lessons without a mark are scheduled.
+type Attendance struct {
+	ScheduleId uuid.UUID        `json:"schedule_id"`
+	StudentId  uuid.UUID        `json:"student_id"`
+	TrainerId  uuid.UUID        `json:"trainer_id"`
+	GroupId    uuid.UUID        `json:"group_id"`
+	Title      string           `json:"title"`
+	Start      time.Time        `json:"start"`
+	End        time.Time        `json:"end"`
+	Status     AttendanceStatus `json:"status"`
+	Note       string           `json:"note"`
+	MarkedAt   time.Time        `json:"marked_at"`
+}
+
+// AttendanceFilter selects the attendance of a student or of a group between From and To.
+type AttendanceFilter struct {
+	StudentId uuid.UUID
+	GroupId   uuid.UUID
+	From      time.Time
+	To        time.Time
+}
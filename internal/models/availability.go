@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Availability is a window in which students of the group can book lessons with the trainer themselves.
+// Start and End are the first window, repeated by Recurrence unless its frequency is empty.
+type Availability struct {
+	Id           uuid.UUID     `json:"id"`
+	TrainerId    uuid.UUID     `json:"trainer_id"`
+	GroupId      uuid.UUID     `json:"group_id"`
+	Title        string        `json:"title"`
+	Start        time.Time     `json:"start"`
+	End          time.Time     `json:"end"`
+	SlotLength   time.Duration `json:"slot_length"`
+	BufferBefore time.Duration `json:"buffer_before"`
+	BufferAfter  time.Duration `json:"buffer_after"`
+	Recurrence   Recurrence    `json:"recurrence"`
+}
+
+// Slot is a bookable lesson of an availability window.
+type Slot struct {
+	AvailabilityId uuid.UUID `json:"availability_id"`
+	GroupId        uuid.UUID `json:"group_id"`
+	Title          string    `json:"title"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+}
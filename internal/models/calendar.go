@@ -1,16 +1,56 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Calendar struct {
 	Title string
 	Id    string
 }
 
+// GroupCalendar is the calendar of a group in the account of one of its members.
+type GroupCalendar struct {
+	UserId     uuid.UUID
+	GroupId    uuid.UUID
+	CalendarId string
+
+	// SyncToken marks the state of the calendar seen by the last sync, empty before the first one
+	SyncToken string
+	Channel   CalendarChannel
+}
+
+// CalendarChannel is a subscription to push notifications about changes of a calendar,
+// delivered to Address until ExpiresAt.
+type CalendarChannel struct {
+	Id         uuid.UUID
+	ResourceId string
+	Address    string
+	ExpiresAt  time.Time
+}
+
 type CalendarEvent struct {
 	EventId    string
 	CalendarId string
 	Title      string
 	Start      time.Time
 	End        time.Time
+
+	// Recurrence is the rule of a recurring event and RecurrenceId the occurrence replaced
+	// by a modified instance, as read from iCalendar.
+	Recurrence   *Recurrence
+	RecurrenceId time.Time
+
+	// Deleted is set for events removed from the calendar, as reported by a sync
+	Deleted bool
+}
+
+// ScheduleEvent links a schedule to the calendar event of one of its participants.
+type ScheduleEvent struct {
+	ScheduleId uuid.UUID
+	UserId     uuid.UUID
+	CalendarId string
+	EventId    string
 }
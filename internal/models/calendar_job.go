@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CalendarOperation string
+
+const (
+	CalendarOperationCreate CalendarOperation = "create"
+	CalendarOperationUpdate CalendarOperation = "update"
+	CalendarOperationRemove CalendarOperation = "remove"
+	// CalendarOperationDeleteCalendar deletes the calendar Event.CalendarId with all its events
+	CalendarOperationDeleteCalendar CalendarOperation = "delete_cal"
+	// CalendarOperationShare gives the user access to the group calendar of the trainer, unshare takes it back
+	CalendarOperationShare   CalendarOperation = "share"
+	CalendarOperationUnshare CalendarOperation = "unshare"
+)
+
+type CalendarJobStatus string
+
+const (
+	CalendarJobPending CalendarJobStatus = "pending"
+	// CalendarJobDead marks a job which ran out of attempts. It is kept until retried.
+	CalendarJobDead CalendarJobStatus = "dead"
+)
+
+// CalendarJob is a change of the calendar of a user, applied in the background by the calendar sync worker.
+// Create links the new event to all ScheduleIds, update and remove apply to the event of the first one.
+type CalendarJob struct {
+	Id            int64
+	UserId        uuid.UUID
+	GroupId       uuid.UUID
+	ScheduleIds   []uuid.UUID
+	Operation     CalendarOperation
+	Event         CalendarEvent
+	Status        CalendarJobStatus
+	Attempts      int
+	LastError     string
+	CreatedAt     time.Time
+	NextAttemptAt time.Time
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// UserBusy lists the merged busy intervals of a user from their lessons and connected calendar.
+type UserBusy struct {
+	UserId uuid.UUID  `json:"user_id"`
+	Busy   []Interval `json:"busy"`
+}
+
+// FreeBusy is the availability of a set of users: their busy intervals and the intervals free for all of them.
+type FreeBusy struct {
+	Users []*UserBusy `json:"users"`
+	Free  []Interval  `json:"free"`
+}
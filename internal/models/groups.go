@@ -1,11 +1,154 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type GroupRole string
+
+const (
+	RoleOwner GroupRole = "owner"
+	// co-trainers manage the schedules of the group like its owner
+	RoleCoTrainer GroupRole = "co-trainer"
+	// assistants see the schedules of the group and mark attendance
+	RoleAssistant GroupRole = "assistant"
+	RoleStudent   GroupRole = "student"
+)
+
+// CalendarMode is how the lessons of a group reach the calendars of its students.
+type CalendarMode string
+
+const (
+	// every student gets a copy of the events in a calendar of their own account
+	CalendarModeCopy CalendarMode = "copy"
+	// students are given access to the group calendar of the trainer
+	CalendarModeShared CalendarMode = "shared"
+)
 
 type Group struct {
 	Id        uuid.UUID
 	Name      string
 	TrainerId uuid.UUID
 	Students  []uuid.UUID
-	Link      string
+	// Link is the token of the default invitation, only shown to the staff of the group
+	Link string
+	// InvitationId is the id of the default invitation
+	InvitationId uuid.UUID
+	// RequiresApproval makes joining with an invitation a request the staff decides on
+	RequiresApproval bool
+
+	Description string
+	// Capacity limits the number of students, zero is unlimited
+	Capacity int
+	// DefaultDuration is the length of lessons created for the group without an end
+	DefaultDuration time.Duration
+	Location        string
+	// ArchivedAt is set while the group is archived
+	ArchivedAt   time.Time
+	CalendarMode CalendarMode
+
+	// Staff are the co-trainers and assistants of the group
+	Staff []*GroupMember
+}
+
+type GroupMember struct {
+	UserId uuid.UUID `json:"user_id"`
+	Role   GroupRole `json:"role"`
+}
+
+type MembershipStatus string
+
+const (
+	MembershipActive  MembershipStatus = "active"
+	MembershipLeft    MembershipStatus = "left"
+	MembershipRemoved MembershipStatus = "removed"
+)
+
+// Membership is a stay of a student in a group. A student who leaves and rejoins has one per stay.
+type Membership struct {
+	Id       uuid.UUID
+	GroupId  uuid.UUID
+	UserId   uuid.UUID
+	Status   MembershipStatus
+	JoinedAt time.Time
+	// LeftAt is zero while the membership is active
+	LeftAt time.Time
+	// InvitationId is the invitation the student joined through, if any
+	InvitationId uuid.UUID
+}
+
+// Invitation lets students join a group with its token.
+type Invitation struct {
+	Id        uuid.UUID
+	GroupId   uuid.UUID
+	CreatedBy uuid.UUID
+	// StudentId restricts the invitation to a single student if set
+	StudentId uuid.UUID
+	// MaxUses of zero is unlimited
+	MaxUses   int
+	Uses      int
+	ExpiresAt time.Time
+	RevokedAt time.Time
+	CreatedAt time.Time
+
+	// Token is only known when the invitation is issued, just its hash is stored. The token of the default
+	// invitation is kept as the link of the group.
+	Token     string
+	TokenHash string
+}
+
+// Role returns the role of the user in the group, or an empty role if the user does not belong to it.
+func (g *Group) Role(userId uuid.UUID) GroupRole {
+	if userId == g.TrainerId {
+		return RoleOwner
+	}
+	for _, member := range g.Staff {
+		if member.UserId == userId {
+			return member.Role
+		}
+	}
+	if slices.Contains(g.Students, userId) {
+		return RoleStudent
+	}
+
+	return ""
+}
+
+func (g *Group) HasRole(userId uuid.UUID, roles ...GroupRole) bool {
+	return slices.Contains(roles, g.Role(userId))
+}
+
+// Trainers returns the owner and the co-trainers of the group.
+func (g *Group) Trainers() []uuid.UUID {
+	trainers := []uuid.UUID{g.TrainerId}
+	for _, member := range g.Staff {
+		if member.Role == RoleCoTrainer {
+			trainers = append(trainers, member.UserId)
+		}
+	}
+
+	return trainers
+}
+
+type JoinRequestStatus string
+
+const (
+	JoinRequestPending  JoinRequestStatus = "pending"
+	JoinRequestApproved JoinRequestStatus = "approved"
+	JoinRequestRejected JoinRequestStatus = "rejected"
+)
+
+// JoinRequest is a request of a student to join a group which requires approval.
+type JoinRequest struct {
+	Id           uuid.UUID
+	GroupId      uuid.UUID
+	StudentId    uuid.UUID
+	InvitationId uuid.UUID
+	Status       JoinRequestStatus
+	RequestedAt  time.Time
+	DecidedAt    time.Time
+	DecidedBy    uuid.UUID
 }
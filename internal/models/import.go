@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ImportEntry is an event of an imported iCalendar file and the existing or imported lessons it overlaps.
+type ImportEntry struct {
+	Uid        string      `json:"uid"`
+	Title      string      `json:"title"`
+	Start      time.Time   `json:"start"`
+	End        time.Time   `json:"end"`
+	Recurrence *Recurrence `json:"recurrence"`
+	Conflicts  []*Conflict `json:"conflicts"`
+}
+
+type ImportResult struct {
+	Entries   []*ImportEntry `json:"entries"`
+	Committed bool           `json:"committed"`
+}
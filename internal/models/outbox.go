@@ -0,0 +1,10 @@
+package models
+
+// OutboxMessage is an event stored together with the change it describes and relayed to Redpanda later.
+type OutboxMessage struct {
+	Id       int64
+	Topic    string
+	Key      string
+	Payload  []byte
+	Attempts int
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Frequency string
+
+const (
+	FrequencyDaily   Frequency = "daily"
+	FrequencyWeekly  Frequency = "weekly"
+	FrequencyMonthly Frequency = "monthly"
+)
+
+// EditScope selects which occurrences of a series an edit or cancellation applies to.
+type EditScope string
+
+const (
+	ScopeOccurrence EditScope = "occurrence"
+	ScopeFollowing  EditScope = "following"
+	ScopeSeries     EditScope = "series"
+)
+
+// Recurrence is a subset of the RFC 5545 RRULE: FREQ, INTERVAL, BYDAY, COUNT, UNTIL plus EXDATE exceptions.
+type Recurrence struct {
+	Frequency  Frequency      `json:"frequency"`
+	Interval   int            `json:"interval"`
+	ByDay      []time.Weekday `json:"by_day"`
+	Count      int            `json:"count"`
+	Until      time.Time      `json:"until"`
+	Exceptions []time.Time    `json:"exceptions"`
+}
+
+type ScheduleSeries struct {
+	Id         uuid.UUID  `json:"id"`
+	Start      time.Time  `json:"start"`
+	End        time.Time  `json:"end"`
+	GroupName  string     `json:"group_name"`
+	Title      string     `json:"title"`
+	GroupId    uuid.UUID  `json:"group_id"`
+	StudentId  uuid.UUID  `json:"student_id"`
+	TrainerId  uuid.UUID  `json:"trainer_id"`
+	Recurrence Recurrence `json:"recurrence"`
+
+	// LessonId is shared by the series of a group lesson, which is stored as one series per student
+	LessonId uuid.UUID `json:"lesson_id"`
+
+	// TimeZone is the IANA zone whose wall clock time the occurrences keep, UTC if empty
+	TimeZone string `json:"time_zone"`
+}
@@ -15,4 +15,40 @@ type Schedule struct {
 	GroupId   uuid.UUID `json:"group_id"`
 	StudentId uuid.UUID `json:"student_id"`
 	TrainerId uuid.UUID `json:"trainer_id"`
+
+	SeriesId     uuid.UUID `json:"series_id"`
+	RecurrenceId time.Time `json:"recurrence_id"`
+
+	// LessonId is shared by the schedules of a group lesson, which is stored as one schedule per student,
+	// and is kept when the lesson moves
+	LessonId uuid.UUID `json:"lesson_id"`
+
+	// CancelledAt is zero unless the schedule was cancelled by CancelledBy
+	CancelledAt  time.Time `json:"cancelled_at"`
+	CancelledBy  uuid.UUID `json:"cancelled_by"`
+	CancelReason string    `json:"cancel_reason"`
+}
+
+// Conflict is an existing schedule of UserId overlapping a requested one.
+type Conflict struct {
+	UserId   uuid.UUID `json:"user_id"`
+	Schedule *Schedule `json:"schedule"`
+}
+
+// ScheduleFilter narrows down schedules. Zero fields are not applied.
+type ScheduleFilter struct {
+	TrainerId uuid.UUID
+	StudentId uuid.UUID
+	GroupId   uuid.UUID
+	From      time.Time
+	To        time.Time
+	Search    string
+	After     *ScheduleCursor
+	Limit     int
+}
+
+// ScheduleCursor points at the last schedule of a page in (start, id) order.
+type ScheduleCursor struct {
+	Start time.Time
+	Id    uuid.UUID
 }
@@ -2,8 +2,21 @@ package models
 
 import "time"
 
+type CalendarProvider string
+
+const (
+	ProviderGoogle  CalendarProvider = "google"
+	ProviderOutlook CalendarProvider = "outlook"
+	ProviderCalDAV  CalendarProvider = "caldav"
+)
+
 type Token struct {
+	Provider     CalendarProvider
 	AccessToken  string
 	RefreshToken string
 	Expiry       time.Time
+
+	// Endpoint and Username are set for providers using basic auth, AccessToken holds the password then
+	Endpoint string
+	Username string
 }
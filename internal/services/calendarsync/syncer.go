@@ -0,0 +1,141 @@
+package calendarsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hesoyamTM/apphelper-schedule/internal/clients"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type SyncStorage interface {
+	ClaimCalendarSyncs(ctx context.Context, limit int, interval time.Duration) ([]*models.GroupCalendar, error)
+}
+
+type CalendarWatcher interface {
+	WatchCalendar(ctx context.Context, calend *models.GroupCalendar, address string) error
+}
+
+type SyncService interface {
+	SyncCalendar(ctx context.Context, calend *models.GroupCalendar) error
+}
+
+type SyncerConfig struct {
+	// every calendar is synced at least this often, push notifications make it sooner
+	Interval     time.Duration `yaml:"interval" env-default:"5m" env:"CALENDAR_POLL_INTERVAL"`
+	PollInterval time.Duration `yaml:"poll-interval" env-default:"5s" env:"CALENDAR_POLL_POLL_INTERVAL"`
+	BatchSize    int           `yaml:"batch-size" env-default:"20" env:"CALENDAR_POLL_BATCH_SIZE"`
+	// WebhookURL receives push notifications about the changes of the calendars, none are requested without it
+	WebhookURL  string        `yaml:"webhook-url" env:"CALENDAR_POLL_WEBHOOK_URL"`
+	RenewBefore time.Duration `yaml:"renew-before" env-default:"1h" env:"CALENDAR_POLL_RENEW_BEFORE"`
+}
+
+// Syncer brings the changes made directly in the calendars of trainers back into the schedules.
+// Calendars are synced every Interval, or on the next poll after a push notification about them.
+type Syncer struct {
+	db       SyncStorage
+	watcher  CalendarWatcher
+	service  SyncService
+	cfg      SyncerConfig
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewSyncer(db SyncStorage, watcher CalendarWatcher, service SyncService, cfg SyncerConfig) *Syncer {
+	return &Syncer{
+		db:       db,
+		watcher:  watcher,
+		service:  service,
+		cfg:      cfg,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start polls for due calendars until Stop is called.
+func (s *Syncer) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(ctx)
+	}()
+
+	<-s.stopChan
+	cancel()
+	s.wg.Wait()
+}
+
+func (s *Syncer) Stop(ctx context.Context) {
+	log := logger.GetLoggerFromCtx(ctx)
+
+	close(s.stopChan)
+	s.wg.Wait()
+
+	log.Info(ctx, "stopped calendar syncer")
+}
+
+func (s *Syncer) run(ctx context.Context) {
+	log := logger.GetLoggerFromCtx(ctx)
+
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := s.process(ctx)
+			if err != nil {
+				log.Error(ctx, "failed to sync calendars", zap.Error(err))
+			}
+			if err != nil || n < s.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// process syncs one batch of due calendars and returns its size. A failed calendar is synced
+// again after Interval.
+func (s *Syncer) process(ctx context.Context) (int, error) {
+	const op = "calendarsync.Syncer.process"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	calendars, err := s.db.ClaimCalendarSyncs(ctx, s.cfg.BatchSize, s.cfg.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, calend := range calendars {
+		if err := s.service.SyncCalendar(ctx, calend); err != nil && !isUnsynced(err) {
+			log.Error(ctx, "failed to sync calendar", zap.String("calendar_id", calend.CalendarId), zap.Error(err))
+		}
+
+		if s.cfg.WebhookURL == "" || calend.Channel.ExpiresAt.After(time.Now().Add(s.cfg.RenewBefore)) {
+			continue
+		}
+		if err := s.watcher.WatchCalendar(ctx, calend, s.cfg.WebhookURL); err != nil && !isUnsynced(err) {
+			log.Error(ctx, "failed to watch calendar", zap.String("calendar_id", calend.CalendarId), zap.Error(err))
+		}
+	}
+
+	return len(calendars), nil
+}
+
+// isUnsynced reports whether the calendar cannot be synced at all: the trainer has
+// disconnected the calendar or its provider does not support syncing.
+func isUnsynced(err error) bool {
+	return errors.Is(err, storage.ErrSessionNotFound) || errors.Is(err, clients.ErrUnsupported)
+}
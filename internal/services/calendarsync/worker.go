@@ -0,0 +1,195 @@
+package calendarsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/clients"
+	"github.com/hesoyamTM/apphelper-schedule/internal/lib/backoff"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type JobStorage interface {
+	ClaimCalendarJobs(ctx context.Context, limit int, lease time.Duration) ([]*models.CalendarJob, error)
+	DeleteCalendarJob(ctx context.Context, id int64) error
+	MarkCalendarJobFailed(ctx context.Context, id int64, nextAttempt time.Time, reason string) error
+	MarkCalendarJobDead(ctx context.Context, id int64, reason string) error
+}
+
+type CalendarManager interface {
+	CreateEvent(ctx context.Context, userId, groupId uuid.UUID, event *models.CalendarEvent, scheduleIds ...uuid.UUID) error
+	UpdateEvent(ctx context.Context, userId, scheduleId uuid.UUID, updated *models.CalendarEvent) error
+	RemoveEvent(ctx context.Context, userId, scheduleId uuid.UUID) error
+	DeleteCalendar(ctx context.Context, userId uuid.UUID, calendarId string) error
+	ShareCalendar(ctx context.Context, memberId, groupId uuid.UUID) error
+	UnshareCalendar(ctx context.Context, memberId, groupId uuid.UUID) error
+}
+
+type WorkerConfig struct {
+	Workers      int           `yaml:"workers" env-default:"4" env:"CALENDAR_SYNC_WORKERS"`
+	PollInterval time.Duration `yaml:"poll-interval" env-default:"1s" env:"CALENDAR_SYNC_POLL_INTERVAL"`
+	BatchSize    int           `yaml:"batch-size" env-default:"20" env:"CALENDAR_SYNC_BATCH_SIZE"`
+	Lease        time.Duration `yaml:"lease" env-default:"1m" env:"CALENDAR_SYNC_LEASE"`
+	MinBackoff   time.Duration `yaml:"min-backoff" env-default:"5s" env:"CALENDAR_SYNC_MIN_BACKOFF"`
+	MaxBackoff   time.Duration `yaml:"max-backoff" env-default:"30m" env:"CALENDAR_SYNC_MAX_BACKOFF"`
+	MaxAttempts  int           `yaml:"max-attempts" env-default:"10" env:"CALENDAR_SYNC_MAX_ATTEMPTS"`
+}
+
+// Worker applies the queued calendar jobs. Failed jobs are retried with exponential backoff
+// and dead-lettered after MaxAttempts.
+type Worker struct {
+	db              JobStorage
+	calendarManager CalendarManager
+	cfg             WorkerConfig
+	stopChan        chan struct{}
+	wg              sync.WaitGroup
+}
+
+func NewWorker(db JobStorage, calendarManager CalendarManager, cfg WorkerConfig) *Worker {
+	return &Worker{
+		db:              db,
+		calendarManager: calendarManager,
+		cfg:             cfg,
+		stopChan:        make(chan struct{}),
+	}
+}
+
+// Start runs the worker pool until Stop is called.
+func (w *Worker) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for range max(w.cfg.Workers, 1) {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.run(ctx)
+		}()
+	}
+
+	<-w.stopChan
+	cancel()
+	w.wg.Wait()
+}
+
+func (w *Worker) Stop(ctx context.Context) {
+	log := logger.GetLoggerFromCtx(ctx)
+
+	close(w.stopChan)
+	w.wg.Wait()
+
+	log.Info(ctx, "stopped calendar sync worker")
+}
+
+func (w *Worker) run(ctx context.Context) {
+	log := logger.GetLoggerFromCtx(ctx)
+
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := w.process(ctx)
+			if err != nil {
+				log.Error(ctx, "failed to process calendar jobs", zap.Error(err))
+			}
+			// a full batch means more jobs may be waiting
+			if err != nil || n < w.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// process applies one batch of due jobs and returns its size.
+func (w *Worker) process(ctx context.Context) (int, error) {
+	const op = "calendarsync.Worker.process"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	jobs, err := w.db.ClaimCalendarJobs(ctx, w.cfg.BatchSize, w.cfg.Lease)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, job := range jobs {
+		err := w.apply(ctx, job)
+		if err == nil || isPermanent(err) {
+			if err != nil {
+				log.Info(ctx, "dropped calendar job", zap.Int64("id", job.Id), zap.Error(err))
+			}
+
+			if err := w.db.DeleteCalendarJob(ctx, job.Id); err != nil {
+				return len(jobs), fmt.Errorf("%s: %w", op, err)
+			}
+			continue
+		}
+
+		log.Error(ctx, "failed to apply calendar job", zap.Int64("id", job.Id), zap.Error(err))
+
+		if ctx.Err() != nil {
+			// the lease expires and the job is claimed again
+			return len(jobs), nil
+		}
+
+		if job.Attempts+1 >= w.cfg.MaxAttempts {
+			if err := w.db.MarkCalendarJobDead(ctx, job.Id, err.Error()); err != nil {
+				return len(jobs), fmt.Errorf("%s: %w", op, err)
+			}
+			continue
+		}
+
+		nextAttempt := time.Now().Add(backoff.Exponential(w.cfg.MinBackoff, w.cfg.MaxBackoff, job.Attempts))
+		if err := w.db.MarkCalendarJobFailed(ctx, job.Id, nextAttempt, err.Error()); err != nil {
+			return len(jobs), fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return len(jobs), nil
+}
+
+func (w *Worker) apply(ctx context.Context, job *models.CalendarJob) error {
+	switch job.Operation {
+	case models.CalendarOperationDeleteCalendar:
+		return w.calendarManager.DeleteCalendar(ctx, job.UserId, job.Event.CalendarId)
+	case models.CalendarOperationShare:
+		return w.calendarManager.ShareCalendar(ctx, job.UserId, job.GroupId)
+	case models.CalendarOperationUnshare:
+		return w.calendarManager.UnshareCalendar(ctx, job.UserId, job.GroupId)
+	}
+
+	if len(job.ScheduleIds) == 0 {
+		return nil
+	}
+
+	switch job.Operation {
+	case models.CalendarOperationCreate:
+		return w.calendarManager.CreateEvent(ctx, job.UserId, job.GroupId, &job.Event, job.ScheduleIds...)
+	case models.CalendarOperationUpdate:
+		return w.calendarManager.UpdateEvent(ctx, job.UserId, job.ScheduleIds[0], &job.Event)
+	case models.CalendarOperationRemove:
+		return w.calendarManager.RemoveEvent(ctx, job.UserId, job.ScheduleIds[0])
+	default:
+		return fmt.Errorf("unknown calendar operation %q", job.Operation)
+	}
+}
+
+// isPermanent reports whether retrying cannot help: the user has not connected a calendar,
+// the event to change is not linked to the schedule, the group of the event was deleted
+// or the calendar provider does not support the change.
+func isPermanent(err error) bool {
+	return errors.Is(err, storage.ErrSessionNotFound) || errors.Is(err, storage.ErrEventNotFound) ||
+		errors.Is(err, storage.ErrGroupNotFound) || errors.Is(err, clients.ErrUnsupported)
+}
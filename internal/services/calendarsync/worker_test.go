@@ -0,0 +1,89 @@
+package calendarsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerProcess(t *testing.T) {
+	MockJobStorage := &MockJobStorage{}
+	MockCalendarManager := &MockCalendarManager{}
+
+	applied := &models.CalendarJob{Id: 1, UserId: uuid.New(), ScheduleIds: []uuid.UUID{uuid.New()}, Operation: models.CalendarOperationCreate}
+	disconnected := &models.CalendarJob{Id: 2, UserId: uuid.New(), ScheduleIds: []uuid.UUID{uuid.New()}, Operation: models.CalendarOperationRemove}
+	failed := &models.CalendarJob{Id: 3, UserId: uuid.New(), ScheduleIds: []uuid.UUID{uuid.New()}, Operation: models.CalendarOperationUpdate, Attempts: 2}
+	exhausted := &models.CalendarJob{Id: 4, UserId: uuid.New(), GroupId: uuid.New(), Operation: models.CalendarOperationShare, Attempts: 4}
+
+	cfg := WorkerConfig{
+		BatchSize:   10,
+		Lease:       time.Minute,
+		MinBackoff:  time.Second,
+		MaxBackoff:  time.Minute,
+		MaxAttempts: 5,
+	}
+
+	MockJobStorage.On("ClaimCalendarJobs", mock.Anything, cfg.BatchSize, cfg.Lease).Return([]*models.CalendarJob{applied, disconnected, failed, exhausted}, nil)
+	MockJobStorage.On("DeleteCalendarJob", mock.Anything, mock.Anything).Return(nil)
+	MockJobStorage.On("MarkCalendarJobFailed", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockJobStorage.On("MarkCalendarJobDead", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockCalendarManager.On("CreateEvent", mock.Anything, applied.UserId, mock.Anything, mock.Anything, applied.ScheduleIds).Return(nil)
+	MockCalendarManager.On("RemoveEvent", mock.Anything, disconnected.UserId, disconnected.ScheduleIds[0]).Return(storage.ErrSessionNotFound)
+	MockCalendarManager.On("UpdateEvent", mock.Anything, failed.UserId, failed.ScheduleIds[0], mock.Anything).Return(errors.New("rate limited"))
+	MockCalendarManager.On("ShareCalendar", mock.Anything, exhausted.UserId, exhausted.GroupId).Return(errors.New("rate limited"))
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	w := NewWorker(MockJobStorage, MockCalendarManager, cfg)
+
+	start := time.Now()
+	n, err := w.process(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+
+	// applied jobs and the ones which cannot succeed are dropped
+	MockJobStorage.AssertCalled(t, "DeleteCalendarJob", ctx, applied.Id)
+	MockJobStorage.AssertCalled(t, "DeleteCalendarJob", ctx, disconnected.Id)
+	MockJobStorage.AssertNumberOfCalls(t, "DeleteCalendarJob", 2)
+
+	// the others are retried with a backoff until they run out of attempts
+	MockJobStorage.AssertCalled(t, "MarkCalendarJobFailed", ctx, failed.Id, mock.MatchedBy(func(nextAttempt time.Time) bool {
+		return !nextAttempt.Before(start.Add(4*time.Second)) && nextAttempt.Before(time.Now().Add(5*time.Second))
+	}), "rate limited")
+	MockJobStorage.AssertNumberOfCalls(t, "MarkCalendarJobFailed", 1)
+	MockJobStorage.AssertCalled(t, "MarkCalendarJobDead", ctx, exhausted.Id, "rate limited")
+}
+
+func TestWorkerProcessSkipsUnlinkedJobs(t *testing.T) {
+	MockJobStorage := &MockJobStorage{}
+	MockCalendarManager := &MockCalendarManager{}
+
+	// the schedule of the event was never stored
+	job := &models.CalendarJob{Id: 1, UserId: uuid.New(), Operation: models.CalendarOperationUpdate}
+
+	MockJobStorage.On("ClaimCalendarJobs", mock.Anything, mock.Anything, mock.Anything).Return([]*models.CalendarJob{job}, nil)
+	MockJobStorage.On("DeleteCalendarJob", mock.Anything, mock.Anything).Return(nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	w := NewWorker(MockJobStorage, MockCalendarManager, WorkerConfig{BatchSize: 10, MaxAttempts: 5})
+
+	_, err = w.process(ctx)
+	require.NoError(t, err)
+	MockJobStorage.AssertCalled(t, "DeleteCalendarJob", ctx, job.Id)
+	MockCalendarManager.AssertNotCalled(t, "UpdateEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
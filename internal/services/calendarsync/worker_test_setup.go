@@ -0,0 +1,68 @@
+package calendarsync
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockJobStorage struct {
+	mock.Mock
+}
+
+func (m *MockJobStorage) ClaimCalendarJobs(ctx context.Context, limit int, lease time.Duration) ([]*models.CalendarJob, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]*models.CalendarJob), args.Error(1)
+}
+
+func (m *MockJobStorage) DeleteCalendarJob(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockJobStorage) MarkCalendarJobFailed(ctx context.Context, id int64, nextAttempt time.Time, reason string) error {
+	args := m.Called(ctx, id, nextAttempt, reason)
+	return args.Error(0)
+}
+
+func (m *MockJobStorage) MarkCalendarJobDead(ctx context.Context, id int64, reason string) error {
+	args := m.Called(ctx, id, reason)
+	return args.Error(0)
+}
+
+type MockCalendarManager struct {
+	mock.Mock
+}
+
+func (m *MockCalendarManager) CreateEvent(ctx context.Context, userId, groupId uuid.UUID, event *models.CalendarEvent, scheduleIds ...uuid.UUID) error {
+	args := m.Called(ctx, userId, groupId, event, scheduleIds)
+	return args.Error(0)
+}
+
+func (m *MockCalendarManager) UpdateEvent(ctx context.Context, userId, scheduleId uuid.UUID, updated *models.CalendarEvent) error {
+	args := m.Called(ctx, userId, scheduleId, updated)
+	return args.Error(0)
+}
+
+func (m *MockCalendarManager) RemoveEvent(ctx context.Context, userId, scheduleId uuid.UUID) error {
+	args := m.Called(ctx, userId, scheduleId)
+	return args.Error(0)
+}
+
+func (m *MockCalendarManager) DeleteCalendar(ctx context.Context, userId uuid.UUID, calendarId string) error {
+	args := m.Called(ctx, userId, calendarId)
+	return args.Error(0)
+}
+
+func (m *MockCalendarManager) ShareCalendar(ctx context.Context, memberId, groupId uuid.UUID) error {
+	args := m.Called(ctx, memberId, groupId)
+	return args.Error(0)
+}
+
+func (m *MockCalendarManager) UnshareCalendar(ctx context.Context, memberId, groupId uuid.UUID) error {
+	args := m.Called(ctx, memberId, groupId)
+	return args.Error(0)
+}
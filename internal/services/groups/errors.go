@@ -0,0 +1,19 @@
+package groups
+
+import "errors"
+
+var (
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrGroupNotFound   = errors.New("group not found")
+	ErrMemberNotFound  = errors.New("member not found")
+	ErrInvalidRole     = errors.New("invalid role")
+	ErrAlreadyMember   = errors.New("already a member of the group")
+	ErrGroupFull       = errors.New("group is full")
+	ErrInvalidSettings = errors.New("invalid group settings")
+
+	ErrInvalidInvitation  = errors.New("invalid invitation")
+	ErrInvitationNotFound = errors.New("invitation not found")
+
+	ErrJoinRequestPending  = errors.New("join request is already pending")
+	ErrJoinRequestNotFound = errors.New("join request not found")
+)
@@ -2,77 +2,156 @@ package groups
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"time"
 
-	"github.com/brianvoe/gofakeit"
 	"github.com/google/uuid"
 	"github.com/hesoyamTM/apphelper-schedule/internal/clients/redpanda"
 	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
 	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
 	"go.uber.org/zap"
 )
 
 type GroupStorage interface {
-	CreateGroup(ctx context.Context, name, link string, trainerId uuid.UUID) error
-	AddToGroup(ctx context.Context, studentId uuid.UUID, link string) (*models.Group, error)
+	CreateGroup(ctx context.Context, name string, trainerId uuid.UUID, invitation *models.Invitation) error
+	AddToGroup(ctx context.Context, studentId uuid.UUID, tokenHash string, newChanges func(*models.Group, *models.JoinRequest) ([]*models.CalendarJob, []*models.OutboxMessage, error)) (*models.Group, *models.JoinRequest, error)
 	ProvideGroups(ctx context.Context, trainerId, studentId uuid.UUID) ([]*models.Group, error)
 	ProvideGroup(ctx context.Context, groupId uuid.UUID) (*models.Group, error)
-	DeleteGroup(ctx context.Context, groupId uuid.UUID, trainerId uuid.UUID) error
+	DeleteGroup(ctx context.Context, groupId, trainerId uuid.UUID, reason string, newChanges func([]*models.Schedule, []*models.GroupCalendar) ([]*models.CalendarJob, []*models.OutboxMessage, error)) error
+	UpdateGroup(ctx context.Context, group *models.Group) error
+	SetGroupArchived(ctx context.Context, groupId uuid.UUID, archived bool) error
+	SetCalendarMode(ctx context.Context, groupId uuid.UUID, mode models.CalendarMode) error
+
+	SetGroupRole(ctx context.Context, groupId, userId uuid.UUID, role models.GroupRole, messages []*models.OutboxMessage) error
+	DeleteGroupRole(ctx context.Context, groupId, userId uuid.UUID, messages []*models.OutboxMessage) error
+
+	ProvideGroupMembers(ctx context.Context, groupId uuid.UUID, history bool) ([]*models.Membership, error)
+	RemoveGroupMember(
+		ctx context.Context,
+		groupId, userId, removedBy uuid.UUID,
+		status models.MembershipStatus,
+		reason string,
+		truncated, deleted []*models.ScheduleSeries,
+		newChanges func([]*models.Schedule) ([]*models.CalendarJob, []*models.OutboxMessage, error),
+	) error
+
+	CreateInvitation(ctx context.Context, invitation *models.Invitation) error
+	ProvideInvitations(ctx context.Context, groupId uuid.UUID) ([]*models.Invitation, error)
+	ProvideInvitation(ctx context.Context, invitationId uuid.UUID) (*models.Invitation, error)
+	RevokeInvitation(ctx context.Context, groupId, invitationId uuid.UUID) error
+	RotateInvitation(ctx context.Context, invitation, next *models.Invitation, isDefault bool) error
+
+	SetRequiresApproval(ctx context.Context, groupId uuid.UUID, required bool) error
+	ProvideJoinRequests(ctx context.Context, groupId uuid.UUID) ([]*models.JoinRequest, error)
+	ProvideJoinRequest(ctx context.Context, requestId uuid.UUID) (*models.JoinRequest, error)
+	DecideJoinRequest(ctx context.Context, request *models.JoinRequest, jobs []*models.CalendarJob, messages []*models.OutboxMessage) error
 }
 
-type RedPanda interface {
-	GroupAddedEvent(ctx context.Context, group *redpanda.GroupAddedEvent) error
+// ScheduleService ends the series of students who leave a group and gives the students of groups
+// in the shared calendar mode access to the group calendar.
+type ScheduleService interface {
+	MemberSeries(ctx context.Context, groupId, studentId uuid.UUID, at time.Time) ([]*models.ScheduleSeries, []*models.ScheduleSeries, error)
+	ShareGroupCalendar(ctx context.Context, groupId uuid.UUID, memberIds ...uuid.UUID)
+	UnshareGroupCalendar(ctx context.Context, groupId uuid.UUID, memberIds ...uuid.UUID)
 }
 
+// staffRoles are the roles of the staff of a group
+var staffRoles = []models.GroupRole{models.RoleOwner, models.RoleCoTrainer, models.RoleAssistant}
+
 type Groups struct {
-	db       GroupStorage
-	redpanda RedPanda
+	db        GroupStorage
+	schedules ScheduleService
 }
 
-func New(ctx context.Context, db GroupStorage, redpanda RedPanda) *Groups {
+func New(ctx context.Context, db GroupStorage, schedules ScheduleService) *Groups {
 	return &Groups{
-		db:       db,
-		redpanda: redpanda,
+		db:        db,
+		schedules: schedules,
 	}
 }
 
+// AddToGroup adds the student to the group of the invitation with the token, see CreateInvitation.
+// If the group requires approval, the student asks to join it instead, see ApproveJoinRequest.
+// In the shared calendar mode the access to the group calendar is given in the same transaction.
 func (g *Groups) AddToGroup(ctx context.Context, studentId uuid.UUID, link string) error {
 	const op = "groups.AddToGroup"
 	log := logger.GetLoggerFromCtx(ctx)
 
-	group, err := g.db.AddToGroup(ctx, studentId, link)
+	_, _, err := g.db.AddToGroup(ctx, studentId, hashInvitationToken(link), func(group *models.Group, request *models.JoinRequest) ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		if request != nil {
+			msg, err := redpanda.GroupJoinRequestedMessage(&redpanda.GroupJoinRequestedEvent{
+				RequestId: request.Id.String(),
+				GroupId:   group.Id.String(),
+				GroupName: group.Name,
+				TrainerId: group.TrainerId.String(),
+				StudentId: studentId.String(),
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return nil, []*models.OutboxMessage{msg}, nil
+		}
+
+		msg, err := groupAddedMessage(group, studentId)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return shareCalendarJobs(group, studentId), []*models.OutboxMessage{msg}, nil
+	})
 	if err != nil {
-		log.Error(ctx, "failed to create schedule", zap.Error(err))
-
+		log.Error(ctx, "failed to add to group", zap.Error(err))
+
+		if errors.Is(err, storage.ErrMemberExists) {
+			return fmt.Errorf("%s: %w", op, ErrAlreadyMember)
+		}
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrInvalidInvitation)
+		}
+		if errors.Is(err, storage.ErrJoinRequestExists) {
+			return fmt.Errorf("%s: %w", op, ErrJoinRequestPending)
+		}
+		if errors.Is(err, storage.ErrGroupFull) {
+			return fmt.Errorf("%s: %w", op, ErrGroupFull)
+		}
 		// TODO: error
 
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := g.redpanda.GroupAddedEvent(ctx, &redpanda.GroupAddedEvent{
-		GroupId:   group.Id.String(),
-		GroupName: group.Name,
-		TrainerId: group.TrainerId.String(),
-		StudentId: studentId.String(),
-		Link:      link,
-	}); err != nil {
-		log.Error(ctx, "failed to create schedule", zap.Error(err))
-
-		// TODO: error
+	return nil
+}
 
-		return fmt.Errorf("%s: %w", op, err)
+// shareCalendarJobs gives the student who joined the group access to the group calendar
+// in the shared calendar mode.
+func shareCalendarJobs(group *models.Group, studentId uuid.UUID) []*models.CalendarJob {
+	if group.CalendarMode != models.CalendarModeShared {
+		return nil
 	}
 
-	return nil
+	return []*models.CalendarJob{{
+		UserId:    studentId,
+		GroupId:   group.Id,
+		Operation: models.CalendarOperationShare,
+	}}
 }
 
+// CreateGroup creates the group of the trainer with an unlimited default invitation, whose token is kept
+// as the link of the group for its staff, see GetGroups.
 func (g *Groups) CreateGroup(ctx context.Context, trainerId uuid.UUID, name string) error {
 	const op = "groups.CreateGroup"
 	log := logger.GetLoggerFromCtx(ctx)
 
-	link := gofakeit.Password(true, true, false, false, false, 20)
+	invitation, err := newInvitation(&models.Invitation{CreatedBy: trainerId})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
-	if err := g.db.CreateGroup(ctx, name, link, trainerId); err != nil {
+	if err := g.db.CreateGroup(ctx, name, trainerId, invitation); err != nil {
 		log.Error(ctx, "failed to create schedule", zap.Error(err))
 
 		// TODO: error
@@ -83,6 +162,8 @@ func (g *Groups) CreateGroup(ctx context.Context, trainerId uuid.UUID, name stri
 	return nil
 }
 
+// GetGroups returns the groups of the trainer, of the student or the ones they share. The link of a group
+// is left out of the groups listed for a student, unless the student is on its staff too.
 func (g *Groups) GetGroups(ctx context.Context, trainerId, studentId uuid.UUID) ([]*models.Group, error) {
 	const op = "groups.GetGroups"
 	log := logger.GetLoggerFromCtx(ctx)
@@ -95,18 +176,40 @@ func (g *Groups) GetGroups(ctx context.Context, trainerId, studentId uuid.UUID)
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if studentId != uuid.Nil {
+		for _, group := range groups {
+			if !group.HasRole(studentId, staffRoles...) {
+				group.Link = ""
+			}
+		}
+	}
+
 	return groups, nil
 }
 
+// DeleteGroup deletes the group with everything in it. Only the owner can delete it.
+// Lessons which have not started yet are cancelled and published as CancelSchedule does, and their events
+// are removed from the calendars of the participants. The calendars of the group are deleted from the accounts
+// of its members.
 func (g *Groups) DeleteGroup(ctx context.Context, groupId, trainerId uuid.UUID) error {
 	const op = "groups.DeleteGroup"
 	log := logger.GetLoggerFromCtx(ctx)
 
-	if err := g.db.DeleteGroup(ctx, groupId, trainerId); err != nil {
-		log.Error(ctx, "failed to delete group", zap.Error(err))
+	group, err := g.ownedGroup(ctx, groupId, trainerId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
-		// TODO: error
+	newChanges := func(cancelled []*models.Schedule, calendars []*models.GroupCalendar) ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		return groupDeletedChanges(group, cancelled, calendars)
+	}
 
+	if err := g.db.DeleteGroup(ctx, groupId, trainerId, groupDeletedReason, newChanges); err != nil {
+		log.Error(ctx, "failed to delete group", zap.Error(err))
+
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -128,3 +231,323 @@ func (g *Groups) GetGroup(ctx context.Context, groupId uuid.UUID) (*models.Group
 
 	return group, nil
 }
+
+// RemoveFromGroup removes the student from the group on behalf of its owner or a co-trainer,
+// see removeMember.
+func (g *Groups) RemoveFromGroup(ctx context.Context, groupId, trainerId, studentId uuid.UUID) error {
+	const op = "groups.RemoveFromGroup"
+
+	group, err := g.managedGroup(ctx, groupId, trainerId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := g.removeMember(ctx, group, studentId, trainerId, models.MembershipRemoved); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// LeaveGroup removes the student from the group on their own behalf, see removeMember.
+func (g *Groups) LeaveGroup(ctx context.Context, groupId, studentId uuid.UUID) error {
+	const op = "groups.LeaveGroup"
+
+	group, err := g.provideGroup(ctx, groupId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := g.removeMember(ctx, group, studentId, studentId, models.MembershipLeft); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// removeMember ends the membership of the student with status and publishes group.group.removed. In the same
+// transaction the student's upcoming lessons in the group are cancelled and removed from the calendars as
+// CancelSchedule does, the student's series in the group are ended and, in the shared calendar mode,
+// the access to the group calendar is taken back.
+func (g *Groups) removeMember(ctx context.Context, group *models.Group, studentId, userId uuid.UUID, status models.MembershipStatus) error {
+	const op = "groups.removeMember"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	truncated, deleted, err := g.schedules.MemberSeries(ctx, group.Id, studentId, time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	reason := "removed from the group"
+	if status == models.MembershipLeft {
+		reason = "left the group"
+	}
+
+	newChanges := func(cancelled []*models.Schedule) ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		jobs, messages, err := cancelledChanges(group, cancelled)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if group.CalendarMode == models.CalendarModeShared {
+			jobs = append(jobs, &models.CalendarJob{
+				UserId:    studentId,
+				GroupId:   group.Id,
+				Operation: models.CalendarOperationUnshare,
+			})
+		}
+
+		msg, err := redpanda.GroupRemovedMessage(&redpanda.GroupRemovedEvent{
+			GroupId:   group.Id.String(),
+			GroupName: group.Name,
+			TrainerId: group.TrainerId.String(),
+			StudentId: studentId.String(),
+			RemovedBy: userId.String(),
+			Status:    string(status),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return jobs, append(messages, msg), nil
+	}
+
+	err = g.db.RemoveGroupMember(ctx, group.Id, studentId, userId, status, reason, truncated, deleted, newChanges)
+	if err != nil {
+		log.Error(ctx, "failed to remove group member", zap.Error(err))
+
+		if errors.Is(err, storage.ErrMemberNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrMemberNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetMembers returns the students of the group in joining order, with the ones who left or were removed
+// if history is set. Only the staff of the group can list them.
+func (g *Groups) GetMembers(ctx context.Context, groupId, userId uuid.UUID, history bool) ([]*models.Membership, error) {
+	const op = "groups.GetMembers"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	group, err := g.provideGroup(ctx, groupId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !group.HasRole(userId, staffRoles...) {
+		return nil, fmt.Errorf("%s: %w", op, ErrUnauthorized)
+	}
+
+	members, err := g.db.ProvideGroupMembers(ctx, groupId, history)
+	if err != nil {
+		log.Error(ctx, "failed to provide group members", zap.Error(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return members, nil
+}
+
+// SetMemberRole makes the user a co-trainer or an assistant of the group. Only the owner can change roles.
+func (g *Groups) SetMemberRole(ctx context.Context, groupId, ownerId, userId uuid.UUID, role models.GroupRole) error {
+	const op = "groups.SetMemberRole"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if role != models.RoleCoTrainer && role != models.RoleAssistant {
+		return fmt.Errorf("%s: %w", op, ErrInvalidRole)
+	}
+
+	group, err := g.ownedGroup(ctx, groupId, ownerId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if userId == group.TrainerId {
+		return fmt.Errorf("%s: %w", op, ErrInvalidRole)
+	}
+
+	msg, err := roleChangedMessage(group, ownerId, userId, role)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := g.db.SetGroupRole(ctx, groupId, userId, role, []*models.OutboxMessage{msg}); err != nil {
+		log.Error(ctx, "failed to set group role", zap.Error(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeMemberRole takes the co-trainer or assistant role of the user away. Students of the group stay students.
+func (g *Groups) RevokeMemberRole(ctx context.Context, groupId, ownerId, userId uuid.UUID) error {
+	const op = "groups.RevokeMemberRole"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	group, err := g.ownedGroup(ctx, groupId, ownerId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	msg, err := roleChangedMessage(group, ownerId, userId, "")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := g.db.DeleteGroupRole(ctx, groupId, userId, []*models.OutboxMessage{msg}); err != nil {
+		log.Error(ctx, "failed to delete group role", zap.Error(err))
+
+		if errors.Is(err, storage.ErrMemberNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrMemberNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// managedGroup returns the group if the user is its owner or a co-trainer.
+func (g *Groups) managedGroup(ctx context.Context, groupId, userId uuid.UUID) (*models.Group, error) {
+	const op = "groups.managedGroup"
+
+	group, err := g.provideGroup(ctx, groupId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !group.HasRole(userId, models.RoleOwner, models.RoleCoTrainer) {
+		return nil, fmt.Errorf("%s: %w", op, ErrUnauthorized)
+	}
+
+	return group, nil
+}
+
+// ownedGroup returns the group if ownerId owns it.
+func (g *Groups) ownedGroup(ctx context.Context, groupId, ownerId uuid.UUID) (*models.Group, error) {
+	const op = "groups.ownedGroup"
+
+	group, err := g.provideGroup(ctx, groupId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if group.TrainerId != ownerId {
+		return nil, fmt.Errorf("%s: %w", op, ErrUnauthorized)
+	}
+
+	return group, nil
+}
+
+func (g *Groups) provideGroup(ctx context.Context, groupId uuid.UUID) (*models.Group, error) {
+	const op = "groups.provideGroup"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	group, err := g.db.ProvideGroup(ctx, groupId)
+	if err != nil {
+		log.Error(ctx, "failed to provide group", zap.Error(err))
+
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return group, nil
+}
+
+func groupAddedMessage(group *models.Group, studentId uuid.UUID) (*models.OutboxMessage, error) {
+	return redpanda.GroupAddedMessage(&redpanda.GroupAddedEvent{
+		GroupId:   group.Id.String(),
+		GroupName: group.Name,
+		TrainerId: group.TrainerId.String(),
+		StudentId: studentId.String(),
+		Link:      group.Link,
+	})
+}
+
+const groupDeletedReason = "group deleted"
+
+// groupDeletedChanges builds the cancellation of every lesson in cancelled, the removal of their calendar events,
+// the deletion of the group calendars and the group deleted event.
+func groupDeletedChanges(group *models.Group, cancelled []*models.Schedule, calendars []*models.GroupCalendar) ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+	jobs, messages, err := cancelledChanges(group, cancelled)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, calend := range calendars {
+		jobs = append(jobs, &models.CalendarJob{
+			UserId:    calend.UserId,
+			GroupId:   group.Id,
+			Operation: models.CalendarOperationDeleteCalendar,
+			Event:     models.CalendarEvent{CalendarId: calend.CalendarId},
+		})
+	}
+
+	studentIds := make([]string, len(group.Students))
+	for i, studentId := range group.Students {
+		studentIds[i] = studentId.String()
+	}
+
+	msg, err := redpanda.GroupDeletedMessage(&redpanda.GroupDeletedEvent{
+		GroupId:            group.Id.String(),
+		GroupName:          group.Name,
+		TrainerId:          group.TrainerId.String(),
+		StudentIds:         studentIds,
+		CancelledSchedules: len(cancelled),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	messages = append(messages, msg)
+
+	return jobs, messages, nil
+}
+
+// cancelledChanges builds the cancellation of every lesson in cancelled and the removal of their calendar events.
+func cancelledChanges(group *models.Group, cancelled []*models.Schedule) ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+	jobs := make([]*models.CalendarJob, 0, 2*len(cancelled))
+	messages := make([]*models.OutboxMessage, 0, len(cancelled)+1)
+
+	for _, sched := range cancelled {
+		sched.GroupName = group.Name
+
+		msg, err := redpanda.ScheduleCancelledMessage(sched)
+		if err != nil {
+			return nil, nil, err
+		}
+		messages = append(messages, msg)
+
+		for _, userId := range []uuid.UUID{sched.TrainerId, sched.StudentId} {
+			if userId == uuid.Nil {
+				continue
+			}
+
+			jobs = append(jobs, &models.CalendarJob{
+				UserId:      userId,
+				GroupId:     group.Id,
+				ScheduleIds: []uuid.UUID{sched.Id},
+				Operation:   models.CalendarOperationRemove,
+			})
+		}
+	}
+
+	return jobs, messages, nil
+}
+
+// roleChangedMessage builds the role changed event of the user. If the user keeps no staff role,
+// the new role is the one left without it: student or none.
+func roleChangedMessage(group *models.Group, ownerId, userId uuid.UUID, role models.GroupRole) (*models.OutboxMessage, error) {
+	previous := group.Role(userId)
+	if role == "" && slices.Contains(group.Students, userId) {
+		role = models.RoleStudent
+	}
+
+	return redpanda.GroupRoleChangedMessage(&redpanda.GroupRoleChangedEvent{
+		GroupId:      group.Id.String(),
+		GroupName:    group.Name,
+		UserId:       userId.String(),
+		Role:         string(role),
+		PreviousRole: string(previous),
+		ChangedBy:    ownerId.String(),
+	})
+}
@@ -0,0 +1,463 @@
+package groups
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestGroups(t *testing.T) (context.Context, *Groups, *MockGroupStorage, *MockScheduleService) {
+	MockGroupStorage := &MockGroupStorage{}
+	MockScheduleService := &MockScheduleService{}
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	return ctx, New(ctx, MockGroupStorage, MockScheduleService), MockGroupStorage, MockScheduleService
+}
+
+func topics(messages []*models.OutboxMessage) []string {
+	result := make([]string, len(messages))
+	for i, msg := range messages {
+		result[i] = msg.Topic
+	}
+
+	return result
+}
+
+func TestRemoveFromGroup(t *testing.T) {
+	ctx, g, MockGroupStorage, MockScheduleService := newTestGroups(t)
+
+	trainerId := uuid.New()
+	studentId := uuid.New()
+	group := &models.Group{
+		Id:           uuid.New(),
+		Name:         "group",
+		TrainerId:    trainerId,
+		Students:     []uuid.UUID{studentId},
+		CalendarMode: models.CalendarModeShared,
+	}
+
+	truncated := []*models.ScheduleSeries{{Id: uuid.New(), GroupId: group.Id, TrainerId: trainerId, StudentId: studentId}}
+	deleted := []*models.ScheduleSeries{{Id: uuid.New(), GroupId: group.Id, TrainerId: trainerId, StudentId: studentId}}
+	cancelled := []*models.Schedule{{Id: uuid.New(), GroupId: group.Id, TrainerId: trainerId, StudentId: studentId}}
+
+	var jobs []*models.CalendarJob
+	var messages []*models.OutboxMessage
+
+	MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+	MockScheduleService.On("MemberSeries", mock.Anything, group.Id, studentId, mock.Anything).Return(truncated, deleted, nil)
+	MockGroupStorage.On("RemoveGroupMember", mock.Anything, group.Id, studentId, trainerId, models.MembershipRemoved, "removed from the group", truncated, deleted, mock.Anything).
+		Run(func(args mock.Arguments) {
+			newChanges := args.Get(8).(func([]*models.Schedule) ([]*models.CalendarJob, []*models.OutboxMessage, error))
+
+			var err error
+			jobs, messages, err = newChanges(cancelled)
+			require.NoError(t, err)
+		}).
+		Return(nil)
+
+	require.NoError(t, g.RemoveFromGroup(ctx, group.Id, trainerId, studentId))
+
+	// the changes are stored with the membership, nothing is queued on its own
+	MockScheduleService.AssertNotCalled(t, "UnshareGroupCalendar", mock.Anything, mock.Anything, mock.Anything)
+
+	require.Equal(t, []string{"schedule.schedule.cancelled", "group.group.removed"}, topics(messages))
+	require.Equal(t, "group", cancelled[0].GroupName)
+
+	require.Len(t, jobs, 3)
+	for i, userId := range []uuid.UUID{trainerId, studentId} {
+		require.Equal(t, userId, jobs[i].UserId)
+		require.Equal(t, models.CalendarOperationRemove, jobs[i].Operation)
+		require.Equal(t, []uuid.UUID{cancelled[0].Id}, jobs[i].ScheduleIds)
+	}
+	require.Equal(t, studentId, jobs[2].UserId)
+	require.Equal(t, models.CalendarOperationUnshare, jobs[2].Operation)
+}
+
+func TestLeaveGroup(t *testing.T) {
+	ctx, g, MockGroupStorage, MockScheduleService := newTestGroups(t)
+
+	studentId := uuid.New()
+	group := &models.Group{Id: uuid.New(), TrainerId: uuid.New(), Students: []uuid.UUID{studentId}}
+
+	none := []*models.ScheduleSeries{}
+
+	MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+	MockScheduleService.On("MemberSeries", mock.Anything, group.Id, mock.Anything, mock.Anything).Return(none, none, nil)
+	MockGroupStorage.On("RemoveGroupMember", mock.Anything, group.Id, studentId, studentId, models.MembershipLeft, "left the group", none, none, mock.Anything).
+		Run(func(args mock.Arguments) {
+			newChanges := args.Get(8).(func([]*models.Schedule) ([]*models.CalendarJob, []*models.OutboxMessage, error))
+
+			// the group calendar is not shared with its students, there is no access to take back
+			jobs, messages, err := newChanges(nil)
+			require.NoError(t, err)
+			require.Empty(t, jobs)
+			require.Equal(t, []string{"group.group.removed"}, topics(messages))
+		}).
+		Return(nil).Once()
+
+	require.NoError(t, g.LeaveGroup(ctx, group.Id, studentId))
+
+	MockGroupStorage.On("RemoveGroupMember", mock.Anything, group.Id, studentId, studentId, models.MembershipLeft, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(storage.ErrMemberNotFound)
+
+	require.ErrorIs(t, g.LeaveGroup(ctx, group.Id, studentId), ErrMemberNotFound)
+}
+
+func TestGetGroupsHidesLink(t *testing.T) {
+	ctx, g, MockGroupStorage, _ := newTestGroups(t)
+
+	trainerId := uuid.New()
+	studentId := uuid.New()
+	newGroup := func(staff ...*models.GroupMember) *models.Group {
+		return &models.Group{Id: uuid.New(), TrainerId: trainerId, Students: []uuid.UUID{studentId}, Link: "token", Staff: staff}
+	}
+
+	joined := newGroup()
+	assisted := newGroup(&models.GroupMember{UserId: studentId, Role: models.RoleAssistant})
+	MockGroupStorage.On("ProvideGroups", mock.Anything, uuid.Nil, studentId).Return([]*models.Group{joined, assisted}, nil)
+
+	groups, err := g.GetGroups(ctx, uuid.Nil, studentId)
+	require.NoError(t, err)
+	require.Empty(t, groups[0].Link)
+	require.Equal(t, "token", groups[1].Link)
+
+	owned := newGroup()
+	MockGroupStorage.On("ProvideGroups", mock.Anything, trainerId, uuid.Nil).Return([]*models.Group{owned}, nil)
+
+	groups, err = g.GetGroups(ctx, trainerId, uuid.Nil)
+	require.NoError(t, err)
+	require.Equal(t, "token", groups[0].Link)
+}
+
+func TestCreateInvitation(t *testing.T) {
+	ctx, g, MockGroupStorage, _ := newTestGroups(t)
+
+	trainerId := uuid.New()
+	assistantId := uuid.New()
+	group := &models.Group{Id: uuid.New(), TrainerId: trainerId, Staff: []*models.GroupMember{{UserId: assistantId, Role: models.RoleAssistant}}}
+
+	MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+	MockGroupStorage.On("CreateInvitation", mock.Anything, mock.Anything).Return(nil)
+
+	_, err := g.CreateInvitation(ctx, group.Id, trainerId, &models.Invitation{MaxUses: -1})
+	require.ErrorIs(t, err, ErrInvalidInvitation)
+	_, err = g.CreateInvitation(ctx, group.Id, trainerId, &models.Invitation{ExpiresAt: time.Now().Add(-time.Minute)})
+	require.ErrorIs(t, err, ErrInvalidInvitation)
+	_, err = g.CreateInvitation(ctx, group.Id, assistantId, &models.Invitation{})
+	require.ErrorIs(t, err, ErrUnauthorized)
+	MockGroupStorage.AssertNotCalled(t, "CreateInvitation", mock.Anything, mock.Anything)
+
+	expiresAt := time.Now().Add(24 * time.Hour)
+	invitation, err := g.CreateInvitation(ctx, group.Id, trainerId, &models.Invitation{MaxUses: 5, ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	require.Equal(t, group.Id, invitation.GroupId)
+	require.Equal(t, 5, invitation.MaxUses)
+	require.Equal(t, expiresAt, invitation.ExpiresAt)
+	require.NotEmpty(t, invitation.Token)
+	require.Equal(t, hashInvitationToken(invitation.Token), invitation.TokenHash)
+}
+
+func TestRevokeInvitation(t *testing.T) {
+	ctx, g, MockGroupStorage, _ := newTestGroups(t)
+
+	trainerId := uuid.New()
+	group := &models.Group{Id: uuid.New(), TrainerId: trainerId}
+	invitationId := uuid.New()
+
+	MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+	MockGroupStorage.On("RevokeInvitation", mock.Anything, group.Id, invitationId).Return(nil).Once()
+	MockGroupStorage.On("RevokeInvitation", mock.Anything, group.Id, invitationId).Return(storage.ErrInvitationNotFound)
+
+	require.ErrorIs(t, g.RevokeInvitation(ctx, group.Id, uuid.New(), invitationId), ErrUnauthorized)
+	require.NoError(t, g.RevokeInvitation(ctx, group.Id, trainerId, invitationId))
+	require.ErrorIs(t, g.RevokeInvitation(ctx, group.Id, trainerId, invitationId), ErrInvitationNotFound)
+}
+
+func TestRotateInvitation(t *testing.T) {
+	ctx, g, MockGroupStorage, _ := newTestGroups(t)
+
+	trainerId := uuid.New()
+	group := &models.Group{Id: uuid.New(), TrainerId: trainerId, InvitationId: uuid.New()}
+
+	byDefault := &models.Invitation{Id: group.InvitationId, GroupId: group.Id}
+	limited := &models.Invitation{Id: uuid.New(), GroupId: group.Id, MaxUses: 3, ExpiresAt: time.Now().Add(time.Hour)}
+	revoked := &models.Invitation{Id: uuid.New(), GroupId: group.Id, RevokedAt: time.Now()}
+	foreign := &models.Invitation{Id: uuid.New(), GroupId: uuid.New()}
+
+	MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+	for _, invitation := range []*models.Invitation{byDefault, limited, revoked, foreign} {
+		MockGroupStorage.On("ProvideInvitation", mock.Anything, invitation.Id).Return(invitation, nil)
+	}
+	MockGroupStorage.On("RotateInvitation", mock.Anything, byDefault, mock.Anything, true).Return(nil)
+	MockGroupStorage.On("RotateInvitation", mock.Anything, limited, mock.Anything, false).Return(nil)
+
+	next, err := g.RotateInvitation(ctx, group.Id, trainerId, byDefault.Id)
+	require.NoError(t, err)
+	require.NotEmpty(t, next.Token)
+
+	// the limits are kept, the uses start over
+	next, err = g.RotateInvitation(ctx, group.Id, trainerId, limited.Id)
+	require.NoError(t, err)
+	require.Equal(t, limited.MaxUses, next.MaxUses)
+	require.Equal(t, limited.ExpiresAt, next.ExpiresAt)
+	require.Zero(t, next.Uses)
+
+	_, err = g.RotateInvitation(ctx, group.Id, trainerId, revoked.Id)
+	require.ErrorIs(t, err, ErrInvitationNotFound)
+	_, err = g.RotateInvitation(ctx, group.Id, trainerId, foreign.Id)
+	require.ErrorIs(t, err, ErrInvitationNotFound)
+
+	MockGroupStorage.AssertNumberOfCalls(t, "RotateInvitation", 2)
+}
+
+// joined runs the newChanges callback of AddToGroup with group and request and records its jobs and messages.
+func joined(t *testing.T, group *models.Group, request *models.JoinRequest, jobs *[]*models.CalendarJob, messages *[]*models.OutboxMessage) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		newChanges := args.Get(3).(func(*models.Group, *models.JoinRequest) ([]*models.CalendarJob, []*models.OutboxMessage, error))
+
+		var err error
+		*jobs, *messages, err = newChanges(group, request)
+		require.NoError(t, err)
+	}
+}
+
+func TestAddToGroup(t *testing.T) {
+	ctx, g, MockGroupStorage, MockScheduleService := newTestGroups(t)
+
+	studentId := uuid.New()
+	group := &models.Group{Id: uuid.New(), TrainerId: uuid.New(), CalendarMode: models.CalendarModeShared}
+	link := "token"
+
+	var jobs []*models.CalendarJob
+	var messages []*models.OutboxMessage
+
+	MockGroupStorage.On("AddToGroup", mock.Anything, studentId, hashInvitationToken(link), mock.Anything).
+		Run(joined(t, group, nil, &jobs, &messages)).
+		Return(group, nil, nil)
+
+	require.NoError(t, g.AddToGroup(ctx, studentId, link))
+	require.Equal(t, []string{"group.group.added"}, topics(messages))
+
+	// the access to the group calendar is given with the membership, not after it
+	require.Equal(t, []*models.CalendarJob{{UserId: studentId, GroupId: group.Id, Operation: models.CalendarOperationShare}}, jobs)
+	MockScheduleService.AssertNotCalled(t, "ShareGroupCalendar", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAddToGroupErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "already a member", err: storage.ErrMemberExists, want: ErrAlreadyMember},
+		{name: "full", err: storage.ErrGroupFull, want: ErrGroupFull},
+		{name: "unknown invitation", err: storage.ErrInvitationNotFound, want: ErrInvalidInvitation},
+		{name: "request pending", err: storage.ErrJoinRequestExists, want: ErrJoinRequestPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, g, MockGroupStorage, MockScheduleService := newTestGroups(t)
+
+			MockGroupStorage.On("AddToGroup", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil, tt.err)
+
+			require.ErrorIs(t, g.AddToGroup(ctx, uuid.New(), "token"), tt.want)
+			MockScheduleService.AssertNotCalled(t, "ShareGroupCalendar", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestAddToGroupRequestsApproval(t *testing.T) {
+	ctx, g, MockGroupStorage, MockScheduleService := newTestGroups(t)
+
+	studentId := uuid.New()
+	group := &models.Group{Id: uuid.New(), TrainerId: uuid.New(), RequiresApproval: true, CalendarMode: models.CalendarModeShared}
+	request := &models.JoinRequest{Id: uuid.New(), GroupId: group.Id, StudentId: studentId, Status: models.JoinRequestPending}
+
+	var jobs []*models.CalendarJob
+	var messages []*models.OutboxMessage
+
+	MockGroupStorage.On("AddToGroup", mock.Anything, studentId, mock.Anything, mock.Anything).
+		Run(joined(t, group, request, &jobs, &messages)).
+		Return(group, request, nil)
+
+	require.NoError(t, g.AddToGroup(ctx, studentId, "token"))
+	require.Equal(t, []string{"group.join.requested"}, topics(messages))
+
+	// the student gets access to the group calendar once the request is approved
+	require.Empty(t, jobs)
+	MockScheduleService.AssertNotCalled(t, "ShareGroupCalendar", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDecideJoinRequest(t *testing.T) {
+	trainerId := uuid.New()
+	assistantId := uuid.New()
+	studentId := uuid.New()
+
+	tests := []struct {
+		name   string
+		decide func(g *Groups, ctx context.Context, groupId, userId, requestId uuid.UUID) error
+		status models.JoinRequestStatus
+		topics []string
+		shared bool
+	}{
+		{
+			name:   "approve",
+			decide: (*Groups).ApproveJoinRequest,
+			status: models.JoinRequestApproved,
+			topics: []string{"group.join.decided", "group.group.added"},
+			shared: true,
+		},
+		{
+			name:   "reject",
+			decide: (*Groups).RejectJoinRequest,
+			status: models.JoinRequestRejected,
+			topics: []string{"group.join.decided"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, g, MockGroupStorage, MockScheduleService := newTestGroups(t)
+
+			group := &models.Group{
+				Id:           uuid.New(),
+				TrainerId:    trainerId,
+				CalendarMode: models.CalendarModeShared,
+				Staff:        []*models.GroupMember{{UserId: assistantId, Role: models.RoleAssistant}},
+			}
+			request := &models.JoinRequest{Id: uuid.New(), GroupId: group.Id, StudentId: studentId, Status: models.JoinRequestPending}
+			decided := &models.JoinRequest{Id: uuid.New(), GroupId: group.Id, StudentId: studentId, Status: models.JoinRequestRejected}
+			foreign := &models.JoinRequest{Id: uuid.New(), GroupId: uuid.New(), StudentId: studentId, Status: models.JoinRequestPending}
+
+			var jobs []*models.CalendarJob
+			var messages []*models.OutboxMessage
+
+			MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+			for _, r := range []*models.JoinRequest{request, decided, foreign} {
+				MockGroupStorage.On("ProvideJoinRequest", mock.Anything, r.Id).Return(r, nil)
+			}
+			MockGroupStorage.On("DecideJoinRequest", mock.Anything, request, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) {
+					jobs = args.Get(2).([]*models.CalendarJob)
+					messages = args.Get(3).([]*models.OutboxMessage)
+				}).
+				Return(nil)
+
+			// assistants do not decide on requests, nor are the decided ones and the ones of other groups found
+			require.ErrorIs(t, tt.decide(g, ctx, group.Id, assistantId, request.Id), ErrUnauthorized)
+			require.ErrorIs(t, tt.decide(g, ctx, group.Id, trainerId, decided.Id), ErrJoinRequestNotFound)
+			require.ErrorIs(t, tt.decide(g, ctx, group.Id, trainerId, foreign.Id), ErrJoinRequestNotFound)
+
+			require.NoError(t, tt.decide(g, ctx, group.Id, trainerId, request.Id))
+			require.Equal(t, tt.status, request.Status)
+			require.Equal(t, trainerId, request.DecidedBy)
+			require.Equal(t, tt.topics, topics(messages))
+
+			if tt.shared {
+				require.Equal(t, []*models.CalendarJob{{UserId: studentId, GroupId: group.Id, Operation: models.CalendarOperationShare}}, jobs)
+			} else {
+				require.Empty(t, jobs)
+			}
+			MockScheduleService.AssertNotCalled(t, "ShareGroupCalendar", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestApproveJoinRequestGroupFull(t *testing.T) {
+	ctx, g, MockGroupStorage, MockScheduleService := newTestGroups(t)
+
+	trainerId := uuid.New()
+	group := &models.Group{Id: uuid.New(), TrainerId: trainerId, Capacity: 1, CalendarMode: models.CalendarModeShared}
+	request := &models.JoinRequest{Id: uuid.New(), GroupId: group.Id, StudentId: uuid.New(), Status: models.JoinRequestPending}
+
+	MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+	MockGroupStorage.On("ProvideJoinRequest", mock.Anything, request.Id).Return(request, nil)
+	MockGroupStorage.On("DecideJoinRequest", mock.Anything, request, mock.Anything, mock.Anything).Return(storage.ErrGroupFull)
+
+	require.ErrorIs(t, g.ApproveJoinRequest(ctx, group.Id, trainerId, request.Id), ErrGroupFull)
+	MockScheduleService.AssertNotCalled(t, "ShareGroupCalendar", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeleteGroup(t *testing.T) {
+	ctx, g, MockGroupStorage, _ := newTestGroups(t)
+
+	trainerId := uuid.New()
+	coTrainerId := uuid.New()
+	students := []uuid.UUID{uuid.New(), uuid.New()}
+	group := &models.Group{
+		Id:        uuid.New(),
+		Name:      "group",
+		TrainerId: trainerId,
+		Students:  students,
+		Staff:     []*models.GroupMember{{UserId: coTrainerId, Role: models.RoleCoTrainer}},
+	}
+
+	// a group lesson of both students
+	cancelled := make([]*models.Schedule, len(students))
+	for i, studentId := range students {
+		cancelled[i] = &models.Schedule{Id: uuid.New(), GroupId: group.Id, TrainerId: trainerId, StudentId: studentId}
+	}
+	calendars := []*models.GroupCalendar{
+		{UserId: trainerId, GroupId: group.Id, CalendarId: "trainer"},
+		{UserId: students[0], GroupId: group.Id, CalendarId: "student"},
+	}
+
+	var jobs []*models.CalendarJob
+	var messages []*models.OutboxMessage
+
+	MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+	MockGroupStorage.On("DeleteGroup", mock.Anything, group.Id, trainerId, groupDeletedReason, mock.Anything).
+		Run(func(args mock.Arguments) {
+			newChanges := args.Get(4).(func([]*models.Schedule, []*models.GroupCalendar) ([]*models.CalendarJob, []*models.OutboxMessage, error))
+
+			var err error
+			jobs, messages, err = newChanges(cancelled, calendars)
+			require.NoError(t, err)
+		}).
+		Return(nil).Once()
+
+	// only the owner deletes the group
+	require.ErrorIs(t, g.DeleteGroup(ctx, group.Id, coTrainerId), ErrUnauthorized)
+
+	require.NoError(t, g.DeleteGroup(ctx, group.Id, trainerId))
+
+	require.Equal(t, []string{"schedule.schedule.cancelled", "schedule.schedule.cancelled", "group.group.deleted"}, topics(messages))
+
+	removed := make(map[uuid.UUID][]uuid.UUID)
+	deleted := make([]string, 0)
+	for _, job := range jobs {
+		require.Equal(t, group.Id, job.GroupId)
+
+		switch job.Operation {
+		case models.CalendarOperationRemove:
+			removed[job.UserId] = append(removed[job.UserId], job.ScheduleIds...)
+		case models.CalendarOperationDeleteCalendar:
+			deleted = append(deleted, job.Event.CalendarId)
+		default:
+			t.Fatalf("unexpected calendar job %s", job.Operation)
+		}
+	}
+
+	// the trainer has one event for the lesson, linked to the rows of both students
+	require.Equal(t, []uuid.UUID{cancelled[0].Id, cancelled[1].Id}, removed[trainerId])
+	for i, studentId := range students {
+		require.Equal(t, []uuid.UUID{cancelled[i].Id}, removed[studentId])
+	}
+	require.Equal(t, []string{"trainer", "student"}, deleted)
+
+	MockGroupStorage.On("DeleteGroup", mock.Anything, group.Id, trainerId, mock.Anything, mock.Anything).Return(storage.ErrGroupNotFound)
+
+	require.ErrorIs(t, g.DeleteGroup(ctx, group.Id, trainerId), ErrGroupNotFound)
+}
@@ -0,0 +1,148 @@
+package groups
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockGroupStorage struct {
+	mock.Mock
+}
+
+func (m *MockGroupStorage) CreateGroup(ctx context.Context, name string, trainerId uuid.UUID, invitation *models.Invitation) error {
+	args := m.Called(ctx, name, trainerId, invitation)
+	return args.Error(0)
+}
+
+func (m *MockGroupStorage) AddToGroup(ctx context.Context, studentId uuid.UUID, tokenHash string, newChanges func(*models.Group, *models.JoinRequest) ([]*models.CalendarJob, []*models.OutboxMessage, error)) (*models.Group, *models.JoinRequest, error) {
+	args := m.Called(ctx, studentId, tokenHash, newChanges)
+	group, _ := args.Get(0).(*models.Group)
+	request, _ := args.Get(1).(*models.JoinRequest)
+	return group, request, args.Error(2)
+}
+
+func (m *MockGroupStorage) ProvideGroups(ctx context.Context, trainerId, studentId uuid.UUID) ([]*models.Group, error) {
+	args := m.Called(ctx, trainerId, studentId)
+	return args.Get(0).([]*models.Group), args.Error(1)
+}
+
+func (m *MockGroupStorage) ProvideGroup(ctx context.Context, groupId uuid.UUID) (*models.Group, error) {
+	args := m.Called(ctx, groupId)
+	group, _ := args.Get(0).(*models.Group)
+	return group, args.Error(1)
+}
+
+func (m *MockGroupStorage) DeleteGroup(ctx context.Context, groupId, trainerId uuid.UUID, reason string, newChanges func([]*models.Schedule, []*models.GroupCalendar) ([]*models.CalendarJob, []*models.OutboxMessage, error)) error {
+	args := m.Called(ctx, groupId, trainerId, reason, newChanges)
+	return args.Error(0)
+}
+
+func (m *MockGroupStorage) UpdateGroup(ctx context.Context, group *models.Group) error {
+	args := m.Called(ctx, group)
+	return args.Error(0)
+}
+
+func (m *MockGroupStorage) SetGroupArchived(ctx context.Context, groupId uuid.UUID, archived bool) error {
+	args := m.Called(ctx, groupId, archived)
+	return args.Error(0)
+}
+
+func (m *MockGroupStorage) SetCalendarMode(ctx context.Context, groupId uuid.UUID, mode models.CalendarMode) error {
+	args := m.Called(ctx, groupId, mode)
+	return args.Error(0)
+}
+
+func (m *MockGroupStorage) SetGroupRole(ctx context.Context, groupId, userId uuid.UUID, role models.GroupRole, messages []*models.OutboxMessage) error {
+	args := m.Called(ctx, groupId, userId, role, messages)
+	return args.Error(0)
+}
+
+func (m *MockGroupStorage) DeleteGroupRole(ctx context.Context, groupId, userId uuid.UUID, messages []*models.OutboxMessage) error {
+	args := m.Called(ctx, groupId, userId, messages)
+	return args.Error(0)
+}
+
+func (m *MockGroupStorage) ProvideGroupMembers(ctx context.Context, groupId uuid.UUID, history bool) ([]*models.Membership, error) {
+	args := m.Called(ctx, groupId, history)
+	return args.Get(0).([]*models.Membership), args.Error(1)
+}
+
+func (m *MockGroupStorage) RemoveGroupMember(
+	ctx context.Context,
+	groupId, userId, removedBy uuid.UUID,
+	status models.MembershipStatus,
+	reason string,
+	truncated, deleted []*models.ScheduleSeries,
+	newChanges func([]*models.Schedule) ([]*models.CalendarJob, []*models.OutboxMessage, error),
+) error {
+	args := m.Called(ctx, groupId, userId, removedBy, status, reason, truncated, deleted, newChanges)
+	return args.Error(0)
+}
+
+func (m *MockGroupStorage) CreateInvitation(ctx context.Context, invitation *models.Invitation) error {
+	args := m.Called(ctx, invitation)
+	return args.Error(0)
+}
+
+func (m *MockGroupStorage) ProvideInvitations(ctx context.Context, groupId uuid.UUID) ([]*models.Invitation, error) {
+	args := m.Called(ctx, groupId)
+	return args.Get(0).([]*models.Invitation), args.Error(1)
+}
+
+func (m *MockGroupStorage) ProvideInvitation(ctx context.Context, invitationId uuid.UUID) (*models.Invitation, error) {
+	args := m.Called(ctx, invitationId)
+	invitation, _ := args.Get(0).(*models.Invitation)
+	return invitation, args.Error(1)
+}
+
+func (m *MockGroupStorage) RevokeInvitation(ctx context.Context, groupId, invitationId uuid.UUID) error {
+	args := m.Called(ctx, groupId, invitationId)
+	return args.Error(0)
+}
+
+func (m *MockGroupStorage) RotateInvitation(ctx context.Context, invitation, next *models.Invitation, isDefault bool) error {
+	args := m.Called(ctx, invitation, next, isDefault)
+	return args.Error(0)
+}
+
+func (m *MockGroupStorage) SetRequiresApproval(ctx context.Context, groupId uuid.UUID, required bool) error {
+	args := m.Called(ctx, groupId, required)
+	return args.Error(0)
+}
+
+func (m *MockGroupStorage) ProvideJoinRequests(ctx context.Context, groupId uuid.UUID) ([]*models.JoinRequest, error) {
+	args := m.Called(ctx, groupId)
+	return args.Get(0).([]*models.JoinRequest), args.Error(1)
+}
+
+func (m *MockGroupStorage) ProvideJoinRequest(ctx context.Context, requestId uuid.UUID) (*models.JoinRequest, error) {
+	args := m.Called(ctx, requestId)
+	request, _ := args.Get(0).(*models.JoinRequest)
+	return request, args.Error(1)
+}
+
+func (m *MockGroupStorage) DecideJoinRequest(ctx context.Context, request *models.JoinRequest, jobs []*models.CalendarJob, messages []*models.OutboxMessage) error {
+	args := m.Called(ctx, request, jobs, messages)
+	return args.Error(0)
+}
+
+type MockScheduleService struct {
+	mock.Mock
+}
+
+func (m *MockScheduleService) MemberSeries(ctx context.Context, groupId, studentId uuid.UUID, at time.Time) ([]*models.ScheduleSeries, []*models.ScheduleSeries, error) {
+	args := m.Called(ctx, groupId, studentId, at)
+	return args.Get(0).([]*models.ScheduleSeries), args.Get(1).([]*models.ScheduleSeries), args.Error(2)
+}
+
+func (m *MockScheduleService) ShareGroupCalendar(ctx context.Context, groupId uuid.UUID, memberIds ...uuid.UUID) {
+	m.Called(ctx, groupId, memberIds)
+}
+
+func (m *MockScheduleService) UnshareGroupCalendar(ctx context.Context, groupId uuid.UUID, memberIds ...uuid.UUID) {
+	m.Called(ctx, groupId, memberIds)
+}
@@ -0,0 +1,165 @@
+package groups
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// random bytes of an invitation token
+const invitationTokenSize = 32
+
+// CreateInvitation issues an invitation to the group on behalf of its owner or a co-trainer.
+// opts may limit it to a single student, a number of uses and an expiry date.
+// The token of the returned invitation cannot be shown again, only its hash is stored. The default invitation
+// of the group is the exception, its token is the link of the group, see CreateGroup.
+func (g *Groups) CreateInvitation(ctx context.Context, groupId, userId uuid.UUID, opts *models.Invitation) (*models.Invitation, error) {
+	const op = "groups.CreateInvitation"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if opts.MaxUses < 0 || !opts.ExpiresAt.IsZero() && !opts.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidInvitation)
+	}
+
+	if _, err := g.managedGroup(ctx, groupId, userId); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	invitation, err := newInvitation(&models.Invitation{
+		GroupId:   groupId,
+		CreatedBy: userId,
+		StudentId: opts.StudentId,
+		MaxUses:   opts.MaxUses,
+		ExpiresAt: opts.ExpiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := g.db.CreateInvitation(ctx, invitation); err != nil {
+		log.Error(ctx, "failed to create invitation", zap.Error(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invitation, nil
+}
+
+// GetInvitations returns the invitations of the group, revoked ones included, to its owner and co-trainers.
+func (g *Groups) GetInvitations(ctx context.Context, groupId, userId uuid.UUID) ([]*models.Invitation, error) {
+	const op = "groups.GetInvitations"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if _, err := g.managedGroup(ctx, groupId, userId); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	invitations, err := g.db.ProvideInvitations(ctx, groupId)
+	if err != nil {
+		log.Error(ctx, "failed to provide invitations", zap.Error(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invitations, nil
+}
+
+// RevokeInvitation stops the invitation from being used. Students who joined through it stay.
+func (g *Groups) RevokeInvitation(ctx context.Context, groupId, userId, invitationId uuid.UUID) error {
+	const op = "groups.RevokeInvitation"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if _, err := g.managedGroup(ctx, groupId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := g.db.RevokeInvitation(ctx, groupId, invitationId); err != nil {
+		log.Error(ctx, "failed to revoke invitation", zap.Error(err))
+
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrInvitationNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RotateInvitation revokes the invitation and issues a new token with the same limits and a fresh use count.
+// Rotating the default invitation of the group replaces the link of the group.
+func (g *Groups) RotateInvitation(ctx context.Context, groupId, userId, invitationId uuid.UUID) (*models.Invitation, error) {
+	const op = "groups.RotateInvitation"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	group, err := g.managedGroup(ctx, groupId, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	invitation, err := g.db.ProvideInvitation(ctx, invitationId)
+	if err != nil {
+		log.Error(ctx, "failed to provide invitation", zap.Error(err))
+
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvitationNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if invitation.GroupId != groupId || !invitation.RevokedAt.IsZero() {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvitationNotFound)
+	}
+
+	next, err := newInvitation(&models.Invitation{
+		GroupId:   groupId,
+		CreatedBy: userId,
+		StudentId: invitation.StudentId,
+		MaxUses:   invitation.MaxUses,
+		ExpiresAt: invitation.ExpiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	isDefault := invitation.Id == group.InvitationId
+
+	if err := g.db.RotateInvitation(ctx, invitation, next, isDefault); err != nil {
+		log.Error(ctx, "failed to rotate invitation", zap.Error(err))
+
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvitationNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return next, nil
+}
+
+// newInvitation sets a random token and its hash on invitation.
+func newInvitation(invitation *models.Invitation) (*models.Invitation, error) {
+	raw := make([]byte, invitationTokenSize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+
+	invitation.Token = base64.RawURLEncoding.EncodeToString(raw)
+	invitation.TokenHash = hashInvitationToken(invitation.Token)
+
+	return invitation, nil
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
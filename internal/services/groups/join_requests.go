@@ -0,0 +1,146 @@
+package groups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/clients/redpanda"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// SetJoinApproval turns the approval of join requests of the group on or off. Only the owner can change it.
+// Requests pending when it is turned off stay pending.
+func (g *Groups) SetJoinApproval(ctx context.Context, groupId, ownerId uuid.UUID, required bool) error {
+	const op = "groups.SetJoinApproval"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if _, err := g.ownedGroup(ctx, groupId, ownerId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := g.db.SetRequiresApproval(ctx, groupId, required); err != nil {
+		log.Error(ctx, "failed to set requires approval", zap.Error(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetJoinRequests returns the pending join requests of the group to its owner and co-trainers, oldest first.
+func (g *Groups) GetJoinRequests(ctx context.Context, groupId, userId uuid.UUID) ([]*models.JoinRequest, error) {
+	const op = "groups.GetJoinRequests"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if _, err := g.managedGroup(ctx, groupId, userId); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	requests, err := g.db.ProvideJoinRequests(ctx, groupId)
+	if err != nil {
+		log.Error(ctx, "failed to provide join requests", zap.Error(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return requests, nil
+}
+
+// ApproveJoinRequest adds the student of the pending request to the group, see decideJoinRequest.
+func (g *Groups) ApproveJoinRequest(ctx context.Context, groupId, userId, requestId uuid.UUID) error {
+	const op = "groups.ApproveJoinRequest"
+
+	if err := g.decideJoinRequest(ctx, groupId, userId, requestId, models.JoinRequestApproved); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RejectJoinRequest turns the pending request down, see decideJoinRequest.
+func (g *Groups) RejectJoinRequest(ctx context.Context, groupId, userId, requestId uuid.UUID) error {
+	const op = "groups.RejectJoinRequest"
+
+	if err := g.decideJoinRequest(ctx, groupId, userId, requestId, models.JoinRequestRejected); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// decideJoinRequest stores the decision of the owner or a co-trainer on the pending request and publishes
+// group.join.decided, and group.group.added if the student is added. In the shared calendar mode the added
+// student is given access to the group calendar in the same transaction.
+func (g *Groups) decideJoinRequest(ctx context.Context, groupId, userId, requestId uuid.UUID, status models.JoinRequestStatus) error {
+	const op = "groups.decideJoinRequest"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	group, err := g.managedGroup(ctx, groupId, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	request, err := g.db.ProvideJoinRequest(ctx, requestId)
+	if err != nil {
+		log.Error(ctx, "failed to provide join request", zap.Error(err))
+
+		if errors.Is(err, storage.ErrJoinRequestNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrJoinRequestNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if request.GroupId != groupId || request.Status != models.JoinRequestPending {
+		return fmt.Errorf("%s: %w", op, ErrJoinRequestNotFound)
+	}
+
+	request.Status = status
+	request.DecidedAt = time.Now()
+	request.DecidedBy = userId
+
+	msg, err := redpanda.GroupJoinDecidedMessage(&redpanda.GroupJoinDecidedEvent{
+		RequestId: request.Id.String(),
+		GroupId:   group.Id.String(),
+		GroupName: group.Name,
+		TrainerId: group.TrainerId.String(),
+		StudentId: request.StudentId.String(),
+		Status:    string(status),
+		DecidedBy: userId.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	messages := []*models.OutboxMessage{msg}
+
+	var jobs []*models.CalendarJob
+	if status == models.JoinRequestApproved {
+		msg, err := groupAddedMessage(group, request.StudentId)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		messages = append(messages, msg)
+		jobs = shareCalendarJobs(group, request.StudentId)
+	}
+
+	if err := g.db.DecideJoinRequest(ctx, request, jobs, messages); err != nil {
+		log.Error(ctx, "failed to decide join request", zap.Error(err))
+
+		if errors.Is(err, storage.ErrJoinRequestNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrJoinRequestNotFound)
+		}
+		if errors.Is(err, storage.ErrMemberExists) {
+			return fmt.Errorf("%s: %w", op, ErrAlreadyMember)
+		}
+		if errors.Is(err, storage.ErrGroupFull) {
+			return fmt.Errorf("%s: %w", op, ErrGroupFull)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package groups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	maxNameLength     = 50
+	maxLocationLength = 255
+)
+
+// UpdateGroup replaces the name, description, capacity, default lesson duration and location of the group
+// with the ones of settings. The owner and co-trainers can change them.
+// Lowering the capacity below the number of students only stops new students from joining.
+func (g *Groups) UpdateGroup(ctx context.Context, groupId, userId uuid.UUID, settings *models.Group) error {
+	const op = "groups.UpdateGroup"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if err := validateSettings(settings); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	group, err := g.managedGroup(ctx, groupId, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	group.Name = settings.Name
+	group.Description = settings.Description
+	group.Capacity = settings.Capacity
+	group.DefaultDuration = settings.DefaultDuration
+	group.Location = settings.Location
+
+	if err := g.db.UpdateGroup(ctx, group); err != nil {
+		log.Error(ctx, "failed to update group", zap.Error(err))
+
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ArchiveGroup hides the group from GetGroups and stops students from joining it. Only the owner can archive it.
+func (g *Groups) ArchiveGroup(ctx context.Context, groupId, ownerId uuid.UUID) error {
+	const op = "groups.ArchiveGroup"
+
+	if err := g.setArchived(ctx, groupId, ownerId, true); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UnarchiveGroup brings the archived group back. Only the owner can unarchive it.
+func (g *Groups) UnarchiveGroup(ctx context.Context, groupId, ownerId uuid.UUID) error {
+	const op = "groups.UnarchiveGroup"
+
+	if err := g.setArchived(ctx, groupId, ownerId, false); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetCalendarMode chooses how the lessons of the group reach the calendars of its students. Only the owner
+// can change it. Switching to the shared mode gives the current students access to the group calendar
+// of the owner, switching back takes it away. Events of earlier lessons are left as they are.
+func (g *Groups) SetCalendarMode(ctx context.Context, groupId, ownerId uuid.UUID, mode models.CalendarMode) error {
+	const op = "groups.SetCalendarMode"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if mode != models.CalendarModeCopy && mode != models.CalendarModeShared {
+		return fmt.Errorf("%s: %w", op, ErrInvalidSettings)
+	}
+
+	group, err := g.ownedGroup(ctx, groupId, ownerId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if group.CalendarMode == mode {
+		return nil
+	}
+
+	if err := g.db.SetCalendarMode(ctx, groupId, mode); err != nil {
+		log.Error(ctx, "failed to set calendar mode", zap.Error(err))
+
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if mode == models.CalendarModeShared {
+		g.schedules.ShareGroupCalendar(ctx, groupId, group.Students...)
+	} else {
+		g.schedules.UnshareGroupCalendar(ctx, groupId, group.Students...)
+	}
+
+	return nil
+}
+
+func (g *Groups) setArchived(ctx context.Context, groupId, ownerId uuid.UUID, archived bool) error {
+	const op = "groups.setArchived"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if _, err := g.ownedGroup(ctx, groupId, ownerId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := g.db.SetGroupArchived(ctx, groupId, archived); err != nil {
+		log.Error(ctx, "failed to set group archived", zap.Error(err))
+
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func validateSettings(settings *models.Group) error {
+	if settings.Name == "" || utf8.RuneCountInString(settings.Name) > maxNameLength {
+		return ErrInvalidSettings
+	}
+	if utf8.RuneCountInString(settings.Location) > maxLocationLength {
+		return ErrInvalidSettings
+	}
+	if settings.Capacity < 0 || settings.DefaultDuration < 0 {
+		return ErrInvalidSettings
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hesoyamTM/apphelper-schedule/internal/lib/backoff"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type OutboxStorage interface {
+	ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxMessage, error)
+	MarkOutboxDelivered(ctx context.Context, id int64) error
+	MarkOutboxFailed(ctx context.Context, id int64, nextAttempt time.Time, reason string) error
+	DeleteDeliveredOutboxMessages(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, msg *models.OutboxMessage) error
+}
+
+type RelayConfig struct {
+	PollInterval time.Duration `yaml:"poll-interval" env-default:"1s" env:"OUTBOX_POLL_INTERVAL"`
+	BatchSize    int           `yaml:"batch-size" env-default:"100" env:"OUTBOX_BATCH_SIZE"`
+	Lease        time.Duration `yaml:"lease" env-default:"30s" env:"OUTBOX_LEASE"`
+	MinBackoff   time.Duration `yaml:"min-backoff" env-default:"1s" env:"OUTBOX_MIN_BACKOFF"`
+	MaxBackoff   time.Duration `yaml:"max-backoff" env-default:"5m" env:"OUTBOX_MAX_BACKOFF"`
+	// delivered messages are kept for Retention and deleted every PurgeInterval
+	Retention     time.Duration `yaml:"retention" env-default:"168h" env:"OUTBOX_RETENTION"`
+	PurgeInterval time.Duration `yaml:"purge-interval" env-default:"1h" env:"OUTBOX_PURGE_INTERVAL"`
+}
+
+// Relay publishes the messages stored in the outbox and marks them as delivered.
+// A message is only marked after the broker acknowledged it, so it is delivered at least once.
+type Relay struct {
+	db        OutboxStorage
+	publisher Publisher
+	cfg       RelayConfig
+	stopChan  chan struct{}
+	doneChan  chan struct{}
+}
+
+func NewRelay(db OutboxStorage, publisher Publisher, cfg RelayConfig) *Relay {
+	return &Relay{
+		db:        db,
+		publisher: publisher,
+		cfg:       cfg,
+		stopChan:  make(chan struct{}),
+		doneChan:  make(chan struct{}),
+	}
+}
+
+func (r *Relay) Start(ctx context.Context) {
+	log := logger.GetLoggerFromCtx(ctx)
+	defer close(r.doneChan)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-r.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	purgeTicker := time.NewTicker(r.cfg.PurgeInterval)
+	defer purgeTicker.Stop()
+
+	for {
+		for {
+			n, err := r.relay(ctx)
+			if err != nil {
+				log.Error(ctx, "failed to relay outbox messages", zap.Error(err))
+			}
+			// a full batch means more messages may be waiting
+			if err != nil || n < r.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-purgeTicker.C:
+			if err := r.purge(ctx); err != nil {
+				log.Error(ctx, "failed to purge outbox messages", zap.Error(err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Relay) Stop(ctx context.Context) {
+	log := logger.GetLoggerFromCtx(ctx)
+
+	close(r.stopChan)
+	<-r.doneChan
+
+	log.Info(ctx, "stopped outbox relay")
+}
+
+// relay publishes one batch of pending messages and returns its size.
+func (r *Relay) relay(ctx context.Context) (int, error) {
+	const op = "outbox.Relay.relay"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	messages, err := r.db.ClaimOutboxMessages(ctx, r.cfg.BatchSize, r.cfg.Lease)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, msg := range messages {
+		if err := r.publisher.Publish(ctx, msg); err != nil {
+			log.Error(ctx, "failed to publish outbox message", zap.Int64("id", msg.Id), zap.Error(err))
+
+			if ctx.Err() != nil {
+				// the lease expires and the message is claimed again
+				return len(messages), nil
+			}
+
+			nextAttempt := time.Now().Add(backoff.Exponential(r.cfg.MinBackoff, r.cfg.MaxBackoff, msg.Attempts))
+			if err := r.db.MarkOutboxFailed(ctx, msg.Id, nextAttempt, err.Error()); err != nil {
+				return len(messages), fmt.Errorf("%s: %w", op, err)
+			}
+			continue
+		}
+
+		if err := r.db.MarkOutboxDelivered(ctx, msg.Id); err != nil {
+			return len(messages), fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return len(messages), nil
+}
+
+// purge deletes the messages delivered longer than the retention ago.
+func (r *Relay) purge(ctx context.Context) error {
+	const op = "outbox.Relay.purge"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	n, err := r.db.DeleteDeliveredOutboxMessages(ctx, r.cfg.Retention)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n > 0 {
+		log.Info(ctx, "purged outbox messages", zap.Int64("count", n))
+	}
+
+	return nil
+}
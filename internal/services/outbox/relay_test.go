@@ -0,0 +1,92 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelay(t *testing.T) {
+	MockOutboxStorage := &MockOutboxStorage{}
+	MockPublisher := &MockPublisher{}
+
+	delivered := &models.OutboxMessage{Id: 1, Topic: "schedule.schedule.created"}
+	failed := &models.OutboxMessage{Id: 2, Topic: "schedule.schedule.created", Attempts: 2}
+
+	cfg := RelayConfig{
+		BatchSize:  10,
+		Lease:      time.Minute,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+	}
+
+	MockOutboxStorage.On("ClaimOutboxMessages", mock.Anything, cfg.BatchSize, cfg.Lease).Return([]*models.OutboxMessage{delivered, failed}, nil)
+	MockOutboxStorage.On("MarkOutboxDelivered", mock.Anything, mock.Anything).Return(nil)
+	MockOutboxStorage.On("MarkOutboxFailed", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockPublisher.On("Publish", mock.Anything, delivered).Return(nil)
+	MockPublisher.On("Publish", mock.Anything, failed).Return(errors.New("broker unavailable"))
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	r := NewRelay(MockOutboxStorage, MockPublisher, cfg)
+
+	start := time.Now()
+	n, err := r.relay(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	// only acknowledged messages are delivered, the others are retried with a backoff
+	MockOutboxStorage.AssertCalled(t, "MarkOutboxDelivered", ctx, delivered.Id)
+	MockOutboxStorage.AssertNotCalled(t, "MarkOutboxDelivered", ctx, failed.Id)
+	MockOutboxStorage.AssertCalled(t, "MarkOutboxFailed", ctx, failed.Id, mock.MatchedBy(func(nextAttempt time.Time) bool {
+		return !nextAttempt.Before(start.Add(4*time.Second)) && nextAttempt.Before(time.Now().Add(5*time.Second))
+	}), "broker unavailable")
+}
+
+func TestRelayClaimFailure(t *testing.T) {
+	MockOutboxStorage := &MockOutboxStorage{}
+	MockPublisher := &MockPublisher{}
+
+	MockOutboxStorage.On("ClaimOutboxMessages", mock.Anything, mock.Anything, mock.Anything).Return([]*models.OutboxMessage{}, errors.New("connection refused"))
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	r := NewRelay(MockOutboxStorage, MockPublisher, RelayConfig{BatchSize: 10})
+
+	_, err = r.relay(ctx)
+	require.Error(t, err)
+	MockPublisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+}
+
+func TestRelayPurge(t *testing.T) {
+	MockOutboxStorage := &MockOutboxStorage{}
+	MockPublisher := &MockPublisher{}
+
+	cfg := RelayConfig{Retention: 24 * time.Hour}
+
+	MockOutboxStorage.On("DeleteDeliveredOutboxMessages", mock.Anything, cfg.Retention).Return(int64(3), nil).Once()
+	MockOutboxStorage.On("DeleteDeliveredOutboxMessages", mock.Anything, cfg.Retention).Return(int64(0), errors.New("connection refused")).Once()
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	r := NewRelay(MockOutboxStorage, MockPublisher, cfg)
+
+	require.NoError(t, r.purge(ctx))
+	require.Error(t, r.purge(ctx))
+	MockOutboxStorage.AssertNumberOfCalls(t, "DeleteDeliveredOutboxMessages", 2)
+}
@@ -0,0 +1,42 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOutboxStorage struct {
+	mock.Mock
+}
+
+func (m *MockOutboxStorage) ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxMessage, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]*models.OutboxMessage), args.Error(1)
+}
+
+func (m *MockOutboxStorage) MarkOutboxDelivered(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockOutboxStorage) MarkOutboxFailed(ctx context.Context, id int64, nextAttempt time.Time, reason string) error {
+	args := m.Called(ctx, id, nextAttempt, reason)
+	return args.Error(0)
+}
+
+func (m *MockOutboxStorage) DeleteDeliveredOutboxMessages(ctx context.Context, retention time.Duration) (int64, error) {
+	args := m.Called(ctx, retention)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) Publish(ctx context.Context, msg *models.OutboxMessage) error {
+	args := m.Called(ctx, msg)
+	return args.Error(0)
+}
@@ -0,0 +1,206 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/clients/redpanda"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// MarkAttendance records the attendance of the student of the schedule and publishes attendance.marked.
+// Besides the trainer, the owner, co-trainers and assistants of the group can mark it.
+// Marking a lesson as scheduled resets it.
+func (s *Schedule) MarkAttendance(ctx context.Context, trainerId, scheduleId uuid.UUID, status models.AttendanceStatus, note string) (*models.Attendance, error) {
+	const op = "schedule.MarkAttendance"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	sched, err := s.db.ProvideSchedule(ctx, scheduleId)
+	if err != nil {
+		log.Error(ctx, "failed to provide schedule", zap.Error(err))
+
+		if errors.Is(err, storage.ErrScheduleNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrScheduleNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	ok, err := s.canManage(ctx, sched.GroupId, sched.TrainerId, trainerId, models.RoleOwner, models.RoleCoTrainer, models.RoleAssistant)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, ErrScheduleNotFound)
+	}
+
+	attendance, err := s.markAttendance(ctx, sched, status, note)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attendance, nil
+}
+
+// MarkOccurrenceAttendance records the attendance at the occurrence of the series starting at recurrenceId.
+func (s *Schedule) MarkOccurrenceAttendance(ctx context.Context, trainerId, seriesId uuid.UUID, recurrenceId time.Time, status models.AttendanceStatus, note string) (*models.Attendance, error) {
+	const op = "schedule.MarkOccurrenceAttendance"
+
+	lesson, err := s.provideOccurrenceLesson(ctx, trainerId, seriesId, recurrenceId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	attendance, err := s.markAttendance(ctx, occurrenceOf(lesson[0], recurrenceId), status, note)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attendance, nil
+}
+
+func (s *Schedule) markAttendance(ctx context.Context, sched *models.Schedule, status models.AttendanceStatus, note string) (*models.Attendance, error) {
+	const op = "schedule.markAttendance"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if !validAttendance(status) {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidAttendance)
+	}
+	// the trainer's schedule of a group without students
+	if sched.StudentId == uuid.Nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidAttendance)
+	}
+	if !sched.CancelledAt.IsZero() {
+		return nil, fmt.Errorf("%s: %w", op, ErrScheduleCancelled)
+	}
+
+	attendance := &models.Attendance{
+		ScheduleId: sched.Id,
+		StudentId:  sched.StudentId,
+		TrainerId:  sched.TrainerId,
+		GroupId:    sched.GroupId,
+		Title:      sched.Title,
+		Start:      sched.Start,
+		End:        sched.End,
+		Status:     status,
+		Note:       note,
+		MarkedAt:   time.Now(),
+	}
+
+	msg, err := redpanda.AttendanceMarkedMessage(attendance)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.db.SetAttendance(ctx, attendance, []*models.OutboxMessage{msg}); err != nil {
+		log.Error(ctx, "failed to set attendance", zap.Error(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attendance, nil
+}
+
+// GetAttendance returns the attendance of the student, or of all students of the group, at the lessons
+// between filter.From and filter.To, ordered by start date. Unmarked lessons are scheduled; marks of
+// lessons deleted since are kept.
+// Students see their own attendance; the attendance of others is shown to the owner, co-trainers and assistants
+// of the group, which filter has to name.
+func (s *Schedule) GetAttendance(ctx context.Context, userId uuid.UUID, filter models.AttendanceFilter) ([]*models.Attendance, error) {
+	const op = "schedule.GetAttendance"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if filter.StudentId == uuid.Nil && filter.GroupId == uuid.Nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidAttendance)
+	}
+
+	var group *models.Group
+	if filter.GroupId != uuid.Nil {
+		var err error
+		group, err = s.gDB.ProvideGroup(ctx, filter.GroupId)
+		if err != nil {
+			log.Error(ctx, "failed to fetch group", zap.Error(err))
+
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if filter.StudentId != userId {
+		if group == nil || !group.HasRole(userId, models.RoleOwner, models.RoleCoTrainer, models.RoleAssistant) {
+			return nil, fmt.Errorf("%s: %w", op, ErrUnauthorized)
+		}
+	}
+
+	scheduleFilters := []models.ScheduleFilter{
+		{StudentId: filter.StudentId, GroupId: filter.GroupId, From: filter.From, To: filter.To},
+	}
+	if filter.StudentId == uuid.Nil {
+		scheduleFilters = scheduleFilters[:0]
+		for _, trainerId := range group.Trainers() {
+			scheduleFilters = append(scheduleFilters, models.ScheduleFilter{TrainerId: trainerId, GroupId: filter.GroupId, From: filter.From, To: filter.To})
+		}
+	}
+
+	schedules := make([]*models.Schedule, 0)
+	for _, scheduleFilter := range scheduleFilters {
+		scheds, _, err := s.GetSchedules(ctx, scheduleFilter, "")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		schedules = append(schedules, scheds...)
+	}
+
+	marks, err := s.db.ProvideAttendance(ctx, filter)
+	if err != nil {
+		log.Error(ctx, "failed to provide attendance", zap.Error(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	type key struct{ scheduleId, studentId uuid.UUID }
+	marked := make(map[key]bool, len(marks))
+	result := make([]*models.Attendance, 0, len(schedules)+len(marks))
+	for _, mark := range marks {
+		marked[key{mark.ScheduleId, mark.StudentId}] = true
+		result = append(result, mark)
+	}
+
+	for _, sched := range schedules {
+		if sched.StudentId == uuid.Nil || marked[key{sched.Id, sched.StudentId}] {
+			continue
+		}
+
+		result = append(result, &models.Attendance{
+			ScheduleId: sched.Id,
+			StudentId:  sched.StudentId,
+			TrainerId:  sched.TrainerId,
+			GroupId:    sched.GroupId,
+			Title:      sched.Title,
+			Start:      sched.Start,
+			End:        sched.End,
+			Status:     models.AttendanceScheduled,
+		})
+	}
+
+	slices.SortFunc(result, func(a, b *models.Attendance) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	return result, nil
+}
+
+func validAttendance(status models.AttendanceStatus) bool {
+	switch status {
+	case models.AttendanceScheduled, models.AttendanceAttended, models.AttendanceAbsent,
+		models.AttendanceExcused, models.AttendanceLate:
+		return true
+	}
+
+	return false
+}
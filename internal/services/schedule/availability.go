@@ -0,0 +1,237 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/lib/recurrence"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// CreateAvailability publishes a window of the trainer in which students of the group can book lessons.
+func (s *Schedule) CreateAvailability(ctx context.Context, av *models.Availability) error {
+	const op = "schedule.CreateAvailability"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if av.SlotLength <= 0 || av.BufferBefore < 0 || av.BufferAfter < 0 || av.End.Sub(av.Start) < av.SlotLength {
+		return fmt.Errorf("%s: %w", op, ErrInvalidAvailability)
+	}
+	if av.Recurrence.Frequency != "" {
+		if err := recurrence.Validate(av.Recurrence); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	group, err := s.gDB.ProvideGroup(ctx, av.GroupId)
+	if err != nil {
+		log.Error(ctx, "failed to fetch group", zap.Error(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !group.HasRole(av.TrainerId, managerRoles...) {
+		return fmt.Errorf("%s: %w", op, ErrUnauthorized)
+	}
+
+	if err := s.db.CreateAvailability(ctx, av); err != nil {
+		log.Error(ctx, "failed to create availability", zap.Error(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Schedule) GetAvailability(ctx context.Context, trainerId uuid.UUID) ([]*models.Availability, error) {
+	const op = "schedule.GetAvailability"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	windows, err := s.db.ProvideTrainerAvailability(ctx, trainerId)
+	if err != nil {
+		log.Error(ctx, "failed to provide availability", zap.Error(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return windows, nil
+}
+
+// DeleteAvailability withdraws the window. Lessons booked in it are kept.
+func (s *Schedule) DeleteAvailability(ctx context.Context, availabilityId, trainerId uuid.UUID) error {
+	const op = "schedule.DeleteAvailability"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if err := s.db.DeleteAvailability(ctx, availabilityId, trainerId); err != nil {
+		log.Error(ctx, "failed to delete availability", zap.Error(err))
+
+		if errors.Is(err, storage.ErrAvailabilityNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrAvailabilityNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetFreeSlots returns the slots of the trainer's windows between from and to which, together with
+// their buffers, do not overlap any lesson of the trainer. Users other than the trainer only see the windows
+// of the groups they belong to.
+func (s *Schedule) GetFreeSlots(ctx context.Context, userId, trainerId uuid.UUID, from, to time.Time) ([]*models.Slot, error) {
+	const op = "schedule.GetFreeSlots"
+
+	windows, err := s.GetAvailability(ctx, trainerId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	members := make(map[uuid.UUID]bool)
+	slots := make([]*models.Slot, 0)
+	for _, av := range windows {
+		if userId != trainerId {
+			member, ok := members[av.GroupId]
+			if !ok {
+				if member, err = s.isMember(ctx, av.GroupId, userId); err != nil {
+					return nil, fmt.Errorf("%s: %w", op, err)
+				}
+				members[av.GroupId] = member
+			}
+			if !member {
+				continue
+			}
+		}
+
+		free, err := s.freeSlots(ctx, av, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		slots = append(slots, free...)
+	}
+
+	slices.SortFunc(slots, func(a, b *models.Slot) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	return slots, nil
+}
+
+// BookSlot books the slot of the window starting at start for the student, who must belong to the group
+// of the window. The lesson is created as CreateSchedule does, with its conflict checks, calendar events
+// and created event.
+func (s *Schedule) BookSlot(ctx context.Context, studentId, availabilityId uuid.UUID, start time.Time) (*models.Schedule, error) {
+	const op = "schedule.BookSlot"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	av, err := s.db.ProvideAvailability(ctx, availabilityId)
+	if err != nil {
+		log.Error(ctx, "failed to provide availability", zap.Error(err))
+
+		if errors.Is(err, storage.ErrAvailabilityNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrAvailabilityNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	group, err := s.gDB.ProvideGroup(ctx, av.GroupId)
+	if err != nil {
+		log.Error(ctx, "failed to fetch group", zap.Error(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !slices.Contains(group.Students, studentId) {
+		return nil, fmt.Errorf("%s: %w", op, ErrUnauthorized)
+	}
+
+	free, err := s.freeSlots(ctx, av, start, start.Add(av.SlotLength))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !slices.ContainsFunc(free, func(slot *models.Slot) bool { return slot.Start.Equal(start) }) {
+		return nil, fmt.Errorf("%s: %w", op, ErrSlotUnavailable)
+	}
+
+	sched := &models.Schedule{
+		Title:     av.Title,
+		Start:     start,
+		End:       start.Add(av.SlotLength),
+		GroupId:   group.Id,
+		GroupName: group.Name,
+		StudentId: studentId,
+		TrainerId: av.TrainerId,
+	}
+
+	if err := s.CreateSchedule(ctx, sched); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sched, nil
+}
+
+// freeSlots cuts the windows of av overlapping [from, to) into slots, separated by the buffers,
+// and drops the slots which overlap a lesson of the trainer including the buffers.
+func (s *Schedule) freeSlots(ctx context.Context, av *models.Availability, from, to time.Time) ([]*models.Slot, error) {
+	const op = "schedule.freeSlots"
+
+	busy, err := s.findConflicts(ctx, []uuid.UUID{av.TrainerId}, from.Add(-av.BufferAfter), to.Add(av.BufferBefore))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// recurring windows keep the wall clock time of the trainer
+	loc := s.userLocation(ctx, av.TrainerId)
+
+	slots := make([]*models.Slot, 0)
+	for _, window := range availabilityWindows(av, loc, from, to) {
+		step := av.BufferBefore + av.SlotLength + av.BufferAfter
+
+		for start := window.Start.Add(av.BufferBefore); !start.Add(av.SlotLength).After(window.End); start = start.Add(step) {
+			end := start.Add(av.SlotLength)
+			if start.Before(from) || end.After(to) {
+				continue
+			}
+
+			blocked := slices.ContainsFunc(busy, func(conflict *models.Conflict) bool {
+				return conflict.Schedule.Start.Before(end.Add(av.BufferAfter)) &&
+					start.Add(-av.BufferBefore).Before(conflict.Schedule.End)
+			})
+			if blocked {
+				continue
+			}
+
+			slots = append(slots, &models.Slot{
+				AvailabilityId: av.Id,
+				GroupId:        av.GroupId,
+				Title:          av.Title,
+				Start:          start,
+				End:            end,
+			})
+		}
+	}
+
+	return slots, nil
+}
+
+// availabilityWindows returns the windows of av overlapping [from, to), recurring in loc.
+func availabilityWindows(av *models.Availability, loc *time.Location, from, to time.Time) []*models.Schedule {
+	if av.Recurrence.Frequency == "" {
+		if av.Start.Before(to) && from.Before(av.End) {
+			return []*models.Schedule{{Start: av.Start, End: av.End}}
+		}
+		return nil
+	}
+
+	return expandSeries(&models.ScheduleSeries{
+		Start:      av.Start,
+		End:        av.End,
+		Recurrence: av.Recurrence,
+		TimeZone:   loc.String(),
+	}, from, to)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,9 +17,19 @@ type CalendarService interface {
 	LoginURL(ctx context.Context, state string) string
 	GetTokenFromCode(ctx context.Context, authCode string) (models.Token, error)
 	GetEvents(ctx context.Context, tok models.Token, minTime, maxTime time.Time) (*[]*models.CalendarEvent, error)
-	CreateEvent(ctx context.Context, tok models.Token, start, end time.Time, eventId, calendarId string) error
+	CreateEvent(ctx context.Context, tok models.Token, start, end time.Time, title, calendarId string) (string, error)
+	UpdateEvent(ctx context.Context, tok models.Token, event *models.CalendarEvent) error
 	DeleteEvent(ctx context.Context, tok models.Token, eventId, calendarId string) error
 	CreateCalendar(ctx context.Context, tok models.Token, title string) (*models.Calendar, error)
+	DeleteCalendar(ctx context.Context, tok models.Token, calendarId string) error
+	// AccountId identifies the account of tok for sharing calendars with it, usually by its email
+	AccountId(ctx context.Context, tok models.Token) (string, error)
+	ShareCalendar(ctx context.Context, tok models.Token, calendarId, accountId string) error
+	UnshareCalendar(ctx context.Context, tok models.Token, calendarId, accountId string) error
+	// SyncEvents returns the events changed since syncToken, all of them if it is empty, and the next token.
+	// An expired syncToken fails with clients.ErrSyncTokenExpired.
+	SyncEvents(ctx context.Context, tok models.Token, calendarId, syncToken string) ([]*models.CalendarEvent, string, error)
+	WatchCalendar(ctx context.Context, tok models.Token, calendarId string, channel *models.CalendarChannel) error
 	RefreshToken(ctx context.Context, tok models.Token) (models.Token, error)
 }
 
@@ -35,50 +46,61 @@ type StateStorage interface {
 }
 
 type CalendarStorage interface {
-	CreateCalendar(ctx context.Context, groupId uuid.UUID, calendarId string) error
-	DeleteCalendar(ctx context.Context, groupId uuid.UUID) error
-	ProvideCalendar(ctx context.Context, groupId uuid.UUID) (string, error)
-}
+	CreateCalendar(ctx context.Context, userId, groupId uuid.UUID, calendarId string) error
+	DeleteCalendar(ctx context.Context, userId, groupId uuid.UUID) error
+	ProvideCalendar(ctx context.Context, userId, groupId uuid.UUID) (string, error)
+	SetCalendarChannel(ctx context.Context, userId, groupId uuid.UUID, channel *models.CalendarChannel) error
+
+	CreateScheduleEvents(ctx context.Context, events []*models.ScheduleEvent) error
+	ProvideScheduleEvent(ctx context.Context, scheduleId, userId uuid.UUID) (*models.ScheduleEvent, error)
+	DeleteScheduleEvent(ctx context.Context, scheduleId, userId uuid.UUID) error
+	CountScheduleEvents(ctx context.Context, calendarId, eventId string) (int, error)
 
-type GroupService interface {
-	GetGroup(ctx context.Context, groupId uuid.UUID) (*models.Group, error)
+	ProvideUserTimeZone(ctx context.Context, userId uuid.UUID) (string, error)
 }
 
 type CalendarManager struct {
 	sessionStorage  SessionStorage
 	stateStorage    StateStorage
-	calendarService CalendarService
+	providers       map[models.CalendarProvider]CalendarService
 	calendarStorage CalendarStorage
-	groupService    GroupService
+	groupStorage    GroupStorage
 	stateTTL        time.Duration
 }
 
 func NewCalendarManager(
 	sessionStorage SessionStorage,
 	stateStorage StateStorage,
-	calendarService CalendarService,
+	providers map[models.CalendarProvider]CalendarService,
 	calendarStorage CalendarStorage,
-	groupService GroupService,
+	groupStorage GroupStorage,
 	stateTTL time.Duration,
 ) *CalendarManager {
 	return &CalendarManager{
 		sessionStorage:  sessionStorage,
 		stateStorage:    stateStorage,
-		calendarService: calendarService,
+		providers:       providers,
 		calendarStorage: calendarStorage,
-		groupService:    groupService,
+		groupStorage:    groupStorage,
 		stateTTL:        stateTTL,
 	}
 }
 
-func (c *CalendarManager) LoginURL(ctx context.Context, userID uuid.UUID) string {
-	state := uuid.New().String()
+// LoginURL returns the OAuth login link of the provider. The provider is kept in the state,
+// so that Authorize exchanges the code with the same provider.
+func (c *CalendarManager) LoginURL(ctx context.Context, userID uuid.UUID, provider models.CalendarProvider) string {
+	svc, err := c.service(provider)
+	if err != nil {
+		return ""
+	}
+
+	state := string(provider) + ":" + uuid.New().String()
 
 	if err := c.stateStorage.SetState(ctx, userID, state, c.stateTTL); err != nil {
 		return ""
 	}
 
-	return c.calendarService.LoginURL(ctx, state)
+	return svc.LoginURL(ctx, state)
 }
 
 func (c *CalendarManager) Authorize(ctx context.Context, userId uuid.UUID, authcode, state string) error {
@@ -92,14 +114,24 @@ func (c *CalendarManager) Authorize(ctx context.Context, userId uuid.UUID, authc
 		return fmt.Errorf("%s: %w", op, clients.ErrUnauthorized)
 	}
 
-	tok, err := c.calendarService.GetTokenFromCode(ctx, authcode)
+	provider, _, ok := strings.Cut(state, ":")
+	if !ok {
+		return fmt.Errorf("%s: %w", op, clients.ErrUnauthorized)
+	}
+
+	svc, err := c.service(models.CalendarProvider(provider))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tok, err := svc.GetTokenFromCode(ctx, authcode)
 	if err != nil {
 		if errors.Is(err, clients.ErrUnauthorized) {
 			_, err = c.refreshToken(ctx, userId, tok)
 			if err != nil {
 				return fmt.Errorf("%s: %w", op, err)
 			}
-			_, err = c.calendarService.GetTokenFromCode(ctx, authcode)
+			_, err = svc.GetTokenFromCode(ctx, authcode)
 			if err != nil {
 				return fmt.Errorf("%s: %w", op, err)
 			}
@@ -118,6 +150,28 @@ func (c *CalendarManager) Authorize(ctx context.Context, userId uuid.UUID, authc
 	return nil
 }
 
+// Connect stores credentials which are not issued through OAuth, like those of a CalDAV server,
+// after checking them against the provider.
+func (c *CalendarManager) Connect(ctx context.Context, userId uuid.UUID, tok models.Token) error {
+	const op = "calendar.Connect"
+
+	svc, err := c.service(tok.Provider)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	if _, err := svc.GetEvents(ctx, tok, now, now.Add(time.Hour)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := c.sessionStorage.SetSession(ctx, userId, tok, 0); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
 func (c *CalendarManager) IsAuthorized(ctx context.Context, userId uuid.UUID) bool {
 	_, err := c.sessionStorage.ProvideSession(ctx, userId)
 	if err != nil {
@@ -131,7 +185,10 @@ func (c *CalendarManager) IsAuthorized(ctx context.Context, userId uuid.UUID) bo
 	return true
 }
 
-func (c *CalendarManager) CreateEvent(ctx context.Context, userId, groupId uuid.UUID, event *models.CalendarEvent) error {
+// CreateEvent adds event to the group calendar of the user and links it to the schedules,
+// so that later changes of the schedules reach the event. Every member has a calendar of the group
+// in their own account, created with their first event.
+func (c *CalendarManager) CreateEvent(ctx context.Context, userId, groupId uuid.UUID, event *models.CalendarEvent, scheduleIds ...uuid.UUID) error {
 	const op = "calendar.CreateEvent"
 
 	tok, err := c.sessionStorage.ProvideSession(ctx, userId)
@@ -141,7 +198,12 @@ func (c *CalendarManager) CreateEvent(ctx context.Context, userId, groupId uuid.
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	calendarId, err := c.calendarStorage.ProvideCalendar(ctx, groupId)
+	svc, err := c.service(tok.Provider)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	calendarId, err := c.calendarStorage.ProvideCalendar(ctx, userId, groupId)
 	if err != nil {
 		if errors.Is(err, storage.ErrCalendarNotFound) {
 			calend, err := c.createCalendar(ctx, userId, groupId, tok)
@@ -154,31 +216,49 @@ func (c *CalendarManager) CreateEvent(ctx context.Context, userId, groupId uuid.
 		}
 	}
 
-	if err := c.calendarService.CreateEvent(ctx, tok, event.Start, event.End, event.Title, calendarId); err != nil {
+	start, end := c.inUserZone(ctx, userId, event)
+
+	eventId, err := svc.CreateEvent(ctx, tok, start, end, event.Title, calendarId)
+	if err != nil {
 		if errors.Is(err, clients.ErrUnauthorized) {
 			tok, err = c.refreshToken(ctx, userId, tok)
 			if err != nil {
 				return fmt.Errorf("%s: %w", op, err)
 			}
-			if err := c.calendarService.CreateEvent(ctx, tok, event.Start, event.End, event.Title, calendarId); err != nil {
+			eventId, err = svc.CreateEvent(ctx, tok, start, end, event.Title, calendarId)
+			if err != nil {
 				return fmt.Errorf("%s: %w", op, err)
 			}
-			return nil
-		}
-		if errors.Is(err, clients.ErrNotFound) {
+		} else if errors.Is(err, clients.ErrNotFound) {
 			calend, err := c.createCalendar(ctx, userId, groupId, tok)
 			if err != nil {
 				return fmt.Errorf("%s: %w", op, err)
 			}
+			calendarId = calend.Id
 
-			if err = c.calendarService.CreateEvent(ctx, tok, event.Start, event.End, event.Title, calend.Id); err != nil && !errors.Is(err, clients.ErrNotFound) {
+			eventId, err = svc.CreateEvent(ctx, tok, start, end, event.Title, calendarId)
+			if err != nil {
 				return fmt.Errorf("%s: %w", op, err)
 			}
-			return nil
+		} else {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	links := make([]*models.ScheduleEvent, len(scheduleIds))
+	for i, scheduleId := range scheduleIds {
+		links[i] = &models.ScheduleEvent{
+			ScheduleId: scheduleId,
+			UserId:     userId,
+			CalendarId: calendarId,
+			EventId:    eventId,
 		}
+	}
 
+	if err := c.calendarStorage.CreateScheduleEvents(ctx, links); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+
 	return nil
 }
 
@@ -192,14 +272,19 @@ func (c *CalendarManager) GetEvents(ctx context.Context, userId uuid.UUID, minTi
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	events, err := c.calendarService.GetEvents(ctx, tok, minTime, maxTime)
+	svc, err := c.service(tok.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	events, err := svc.GetEvents(ctx, tok, minTime, maxTime)
 	if err != nil {
 		if errors.Is(err, clients.ErrUnauthorized) {
 			tok, err = c.refreshToken(ctx, userId, tok)
 			if err != nil {
 				return nil, fmt.Errorf("%s: %w", op, err)
 			}
-			events, err = c.calendarService.GetEvents(ctx, tok, minTime, maxTime)
+			events, err = svc.GetEvents(ctx, tok, minTime, maxTime)
 			if err != nil {
 				return nil, fmt.Errorf("%s: %w", op, err)
 			}
@@ -216,29 +301,331 @@ func (c *CalendarManager) GetEvents(ctx context.Context, userId uuid.UUID, minTi
 func (c *CalendarManager) DeleteEvent(ctx context.Context, userId uuid.UUID, group_id uuid.UUID, eventId string) error {
 	const op = "calendar.DeleteEvent"
 
-	tok, err := c.sessionStorage.ProvideSession(ctx, userId)
+	calendarId, err := c.calendarStorage.ProvideCalendar(ctx, userId, group_id)
 	if err != nil {
 		// TODO: error
 
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	calendarId, err := c.calendarStorage.ProvideCalendar(ctx, group_id)
+	if err := c.deleteEvent(ctx, userId, calendarId, eventId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UpdateEvent moves the calendar event of the user linked to the schedule to the title and time of updated.
+func (c *CalendarManager) UpdateEvent(ctx context.Context, userId, scheduleId uuid.UUID, updated *models.CalendarEvent) error {
+	const op = "calendar.UpdateEvent"
+
+	link, err := c.calendarStorage.ProvideScheduleEvent(ctx, scheduleId, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tok, err := c.sessionStorage.ProvideSession(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	svc, err := c.service(tok.Provider)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	start, end := c.inUserZone(ctx, userId, updated)
+
+	event := &models.CalendarEvent{
+		EventId:    link.EventId,
+		CalendarId: link.CalendarId,
+		Title:      updated.Title,
+		Start:      start,
+		End:        end,
+	}
+
+	if err := svc.UpdateEvent(ctx, tok, event); err != nil {
+		if errors.Is(err, clients.ErrUnauthorized) {
+			tok, err = c.refreshToken(ctx, userId, tok)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			if err := svc.UpdateEvent(ctx, tok, event); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			return nil
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveEvent deletes the calendar event of the user linked to the schedule.
+// The trainer's event of a group lesson is shared by the schedules of all students,
+// so it is only deleted together with the last of them.
+func (c *CalendarManager) RemoveEvent(ctx context.Context, userId, scheduleId uuid.UUID) error {
+	const op = "calendar.RemoveEvent"
+
+	link, err := c.calendarStorage.ProvideScheduleEvent(ctx, scheduleId, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	count, err := c.calendarStorage.CountScheduleEvents(ctx, link.CalendarId, link.EventId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if count <= 1 {
+		if err := c.deleteEvent(ctx, userId, link.CalendarId, link.EventId); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := c.calendarStorage.DeleteScheduleEvent(ctx, scheduleId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteCalendar deletes the calendar of the user together with its events. A calendar which is already gone
+// counts as deleted.
+func (c *CalendarManager) DeleteCalendar(ctx context.Context, userId uuid.UUID, calendarId string) error {
+	const op = "calendar.DeleteCalendar"
+
+	tok, err := c.sessionStorage.ProvideSession(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	svc, err := c.service(tok.Provider)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := svc.DeleteCalendar(ctx, tok, calendarId); err != nil {
+		if errors.Is(err, clients.ErrUnauthorized) {
+			tok, err = c.refreshToken(ctx, userId, tok)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			if err := svc.DeleteCalendar(ctx, tok, calendarId); err != nil && !errors.Is(err, clients.ErrNotFound) {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			return nil
+		}
+		if errors.Is(err, clients.ErrNotFound) {
+			return nil
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ChangedEvents returns the events of the calendar changed since its last sync and the sync token to store
+// once they are applied. Without a token, or when it has expired, all events are listed and full is set.
+func (c *CalendarManager) ChangedEvents(ctx context.Context, calend *models.GroupCalendar) (events []*models.CalendarEvent, syncToken string, full bool, err error) {
+	const op = "calendar.ChangedEvents"
+
+	tok, err := c.sessionStorage.ProvideSession(ctx, calend.UserId)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	syncToken = calend.SyncToken
+	for {
+		full = syncToken == ""
+
+		err = c.withRefresh(ctx, calend.UserId, tok, func(svc CalendarService, tok models.Token) error {
+			events, syncToken, err = svc.SyncEvents(ctx, tok, calend.CalendarId, syncToken)
+			return err
+		})
+		if errors.Is(err, clients.ErrSyncTokenExpired) && !full {
+			syncToken = ""
+			continue
+		}
+		if err != nil {
+			return nil, "", false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return events, syncToken, full, nil
+	}
+}
+
+// WatchCalendar subscribes address to push notifications about the changes of the calendar,
+// replacing its previous channel, which expires on its own.
+func (c *CalendarManager) WatchCalendar(ctx context.Context, calend *models.GroupCalendar, address string) error {
+	const op = "calendar.WatchCalendar"
+
+	tok, err := c.sessionStorage.ProvideSession(ctx, calend.UserId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	channel := &models.CalendarChannel{
+		Id:      uuid.New(),
+		Address: address,
+	}
+
+	err = c.withRefresh(ctx, calend.UserId, tok, func(svc CalendarService, tok models.Token) error {
+		return svc.WatchCalendar(ctx, tok, calend.CalendarId, channel)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := c.calendarStorage.SetCalendarChannel(ctx, calend.UserId, calend.GroupId, channel); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	calend.Channel = *channel
+
+	return nil
+}
+
+// ShareCalendar gives the account of the member access to the group calendar of the trainer,
+// creating the calendar if the trainer has none yet.
+func (c *CalendarManager) ShareCalendar(ctx context.Context, memberId, groupId uuid.UUID) error {
+	const op = "calendar.ShareCalendar"
+
+	group, err := c.groupStorage.ProvideGroup(ctx, groupId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	accountId, err := c.accountId(ctx, memberId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tok, err := c.sessionStorage.ProvideSession(ctx, group.TrainerId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	calendarId, err := c.calendarStorage.ProvideCalendar(ctx, group.TrainerId, groupId)
+	if err != nil {
+		if !errors.Is(err, storage.ErrCalendarNotFound) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		calend, err := c.createCalendar(ctx, group.TrainerId, groupId, tok)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		calendarId = calend.Id
+	}
+
+	err = c.withRefresh(ctx, group.TrainerId, tok, func(svc CalendarService, tok models.Token) error {
+		return svc.ShareCalendar(ctx, tok, calendarId, accountId)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UnshareCalendar takes the access to the group calendar of the trainer back from the account of the member.
+func (c *CalendarManager) UnshareCalendar(ctx context.Context, memberId, groupId uuid.UUID) error {
+	const op = "calendar.UnshareCalendar"
+
+	group, err := c.groupStorage.ProvideGroup(ctx, groupId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	calendarId, err := c.calendarStorage.ProvideCalendar(ctx, group.TrainerId, groupId)
+	if err != nil {
+		if errors.Is(err, storage.ErrCalendarNotFound) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	accountId, err := c.accountId(ctx, memberId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tok, err := c.sessionStorage.ProvideSession(ctx, group.TrainerId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = c.withRefresh(ctx, group.TrainerId, tok, func(svc CalendarService, tok models.Token) error {
+		return svc.UnshareCalendar(ctx, tok, calendarId, accountId)
+	})
+	if err != nil && !errors.Is(err, clients.ErrNotFound) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *CalendarManager) accountId(ctx context.Context, userId uuid.UUID) (string, error) {
+	tok, err := c.sessionStorage.ProvideSession(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+
+	var accountId string
+	err = c.withRefresh(ctx, userId, tok, func(svc CalendarService, tok models.Token) error {
+		accountId, err = svc.AccountId(ctx, tok)
+		return err
+	})
+
+	return accountId, err
+}
+
+// withRefresh calls fn with the provider of tok, once more with a refreshed token if tok has expired.
+func (c *CalendarManager) withRefresh(ctx context.Context, userId uuid.UUID, tok models.Token, fn func(CalendarService, models.Token) error) error {
+	svc, err := c.service(tok.Provider)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(svc, tok); !errors.Is(err, clients.ErrUnauthorized) {
+		return err
+	}
+
+	tok, err = c.refreshToken(ctx, userId, tok)
+	if err != nil {
+		return err
+	}
+
+	return fn(svc, tok)
+}
+
+func (c *CalendarManager) deleteEvent(ctx context.Context, userId uuid.UUID, calendarId, eventId string) error {
+	const op = "calendar.deleteEvent"
+
+	tok, err := c.sessionStorage.ProvideSession(ctx, userId)
 	if err != nil {
 		// TODO: error
 
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := c.calendarService.DeleteEvent(ctx, tok, eventId, calendarId); err != nil {
+	svc, err := c.service(tok.Provider)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := svc.DeleteEvent(ctx, tok, eventId, calendarId); err != nil {
 		if errors.Is(err, clients.ErrUnauthorized) {
 			tok, err = c.refreshToken(ctx, userId, tok)
 			if err != nil {
 				return fmt.Errorf("%s: %w", op, err)
 			}
-			if err := c.calendarService.DeleteEvent(ctx, tok, eventId, calendarId); err != nil {
+			if err := svc.DeleteEvent(ctx, tok, eventId, calendarId); err != nil && !errors.Is(err, clients.ErrNotFound) {
 				return fmt.Errorf("%s: %w", op, err)
 			}
+			return nil
 		}
 		if errors.Is(err, clients.ErrNotFound) {
 			return nil
@@ -254,19 +641,24 @@ func (c *CalendarManager) DeleteEvent(ctx context.Context, userId uuid.UUID, gro
 func (c *CalendarManager) createCalendar(ctx context.Context, userId, groupId uuid.UUID, tok models.Token) (*models.Calendar, error) {
 	const op = "calendar.createCalendar"
 
-	group, err := c.groupService.GetGroup(ctx, groupId)
+	svc, err := c.service(tok.Provider)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	calend, err := c.calendarService.CreateCalendar(ctx, tok, group.Name)
+	group, err := c.groupStorage.ProvideGroup(ctx, groupId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	calend, err := svc.CreateCalendar(ctx, tok, group.Name)
 	if err != nil {
 		if errors.Is(err, clients.ErrUnauthorized) {
 			tok, err = c.refreshToken(ctx, userId, tok)
 			if err != nil {
 				return nil, fmt.Errorf("%s: %w", op, err)
 			}
-			calend, err = c.calendarService.CreateCalendar(ctx, tok, group.Name)
+			calend, err = svc.CreateCalendar(ctx, tok, group.Name)
 			if err != nil {
 				return nil, fmt.Errorf("%s: %w", op, err)
 			}
@@ -275,7 +667,7 @@ func (c *CalendarManager) createCalendar(ctx context.Context, userId, groupId uu
 		}
 	}
 
-	if err := c.calendarStorage.CreateCalendar(ctx, groupId, calend.Id); err != nil {
+	if err := c.calendarStorage.CreateCalendar(ctx, userId, groupId, calend.Id); err != nil {
 		// TODO: error
 
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -287,7 +679,12 @@ func (c *CalendarManager) createCalendar(ctx context.Context, userId, groupId uu
 func (c *CalendarManager) refreshToken(ctx context.Context, userId uuid.UUID, tok models.Token) (models.Token, error) {
 	const op = "calendar.RefreshToken"
 
-	newTok, err := c.calendarService.RefreshToken(ctx, tok)
+	svc, err := c.service(tok.Provider)
+	if err != nil {
+		return models.Token{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	newTok, err := svc.RefreshToken(ctx, tok)
 	if err != nil {
 		if errors.Is(err, clients.ErrUnauthorized) {
 			// if err := c.sessionStorage.DeleteSession(ctx, userId); err != nil {
@@ -305,3 +702,22 @@ func (c *CalendarManager) refreshToken(ctx context.Context, userId uuid.UUID, to
 
 	return newTok, nil
 }
+
+func (c *CalendarManager) service(provider models.CalendarProvider) (CalendarService, error) {
+	svc, ok := c.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	return svc, nil
+}
+
+// inUserZone returns the times of event in the time zone of the user, which the calendar shows them in.
+func (c *CalendarManager) inUserZone(ctx context.Context, userId uuid.UUID, event *models.CalendarEvent) (time.Time, time.Time) {
+	loc := time.UTC
+	if timeZone, err := c.calendarStorage.ProvideUserTimeZone(ctx, userId); err == nil {
+		loc = loadLocation(timeZone)
+	}
+
+	return event.Start.In(loc), event.End.In(loc)
+}
@@ -0,0 +1,137 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// GetCalendarJobs returns the calendar changes of the user which are not applied yet,
+// including the dead-lettered ones.
+func (s *Schedule) GetCalendarJobs(ctx context.Context, userId uuid.UUID) ([]*models.CalendarJob, error) {
+	const op = "schedule.GetCalendarJobs"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	jobs, err := s.db.ProvideCalendarJobs(ctx, userId)
+	if err != nil {
+		log.Error(ctx, "failed to provide calendar jobs", zap.Error(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return jobs, nil
+}
+
+// RetryCalendarJobs requeues the dead-lettered calendar changes of the user and returns their number.
+func (s *Schedule) RetryCalendarJobs(ctx context.Context, userId uuid.UUID) (int, error) {
+	const op = "schedule.RetryCalendarJobs"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	n, err := s.db.RetryCalendarJobs(ctx, userId)
+	if err != nil {
+		log.Error(ctx, "failed to retry calendar jobs", zap.Error(err))
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// ShareGroupCalendar queues giving the members access to the group calendar of the trainer,
+// for groups in the shared calendar mode.
+func (s *Schedule) ShareGroupCalendar(ctx context.Context, groupId uuid.UUID, memberIds ...uuid.UUID) {
+	s.syncCalendars(ctx, calendarAccessJobs(groupId, models.CalendarOperationShare, memberIds)...)
+}
+
+// UnshareGroupCalendar queues taking the access to the group calendar of the trainer back from the members.
+func (s *Schedule) UnshareGroupCalendar(ctx context.Context, groupId uuid.UUID, memberIds ...uuid.UUID) {
+	s.syncCalendars(ctx, calendarAccessJobs(groupId, models.CalendarOperationUnshare, memberIds)...)
+}
+
+func calendarAccessJobs(groupId uuid.UUID, operation models.CalendarOperation, memberIds []uuid.UUID) []*models.CalendarJob {
+	jobs := make([]*models.CalendarJob, len(memberIds))
+	for i, memberId := range memberIds {
+		jobs[i] = &models.CalendarJob{
+			UserId:    memberId,
+			GroupId:   groupId,
+			Operation: operation,
+		}
+	}
+
+	return jobs
+}
+
+// syncCalendars queues the calendar changes for the sync worker.
+func (s *Schedule) syncCalendars(ctx context.Context, jobs ...*models.CalendarJob) {
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if err := s.db.CreateCalendarJobs(ctx, jobs); err != nil {
+		log.Error(ctx, "failed to queue calendar jobs", zap.Error(err))
+
+		// TODO: error
+	}
+}
+
+func createEventJob(userId, groupId uuid.UUID, event models.CalendarEvent, scheduleIds ...uuid.UUID) *models.CalendarJob {
+	return &models.CalendarJob{
+		UserId:      userId,
+		GroupId:     groupId,
+		ScheduleIds: scheduleIds,
+		Operation:   models.CalendarOperationCreate,
+		Event:       event,
+	}
+}
+
+// groupEventJobs creates the calendar events of a group lesson stored as scheds.
+// The trainer has a single event for the lesson, linked to the schedule of every student.
+// In the shared calendar mode students see the event of the trainer and get no copy.
+func groupEventJobs(group *models.Group, sched *models.Schedule, scheds []*models.Schedule) []*models.CalendarJob {
+	event := models.CalendarEvent{
+		Title: sched.Title,
+		Start: sched.Start,
+		End:   sched.End,
+	}
+
+	scheduleIds := make([]uuid.UUID, len(scheds))
+	for i := range scheds {
+		scheduleIds[i] = scheds[i].Id
+	}
+
+	jobs := []*models.CalendarJob{createEventJob(sched.TrainerId, group.Id, event, scheduleIds...)}
+	if group.CalendarMode == models.CalendarModeShared {
+		return jobs
+	}
+
+	for _, studentSched := range scheds {
+		if studentSched.StudentId == uuid.Nil {
+			continue
+		}
+
+		jobs = append(jobs, createEventJob(studentSched.StudentId, group.Id, event, studentSched.Id))
+	}
+
+	return jobs
+}
+
+func updateEventJob(userId uuid.UUID, sched *models.Schedule, event models.CalendarEvent) *models.CalendarJob {
+	return &models.CalendarJob{
+		UserId:      userId,
+		GroupId:     sched.GroupId,
+		ScheduleIds: []uuid.UUID{sched.Id},
+		Operation:   models.CalendarOperationUpdate,
+		Event:       event,
+	}
+}
+
+func removeEventJob(userId uuid.UUID, sched *models.Schedule) *models.CalendarJob {
+	return &models.CalendarJob{
+		UserId:      userId,
+		GroupId:     sched.GroupId,
+		ScheduleIds: []uuid.UUID{sched.Id},
+		Operation:   models.CalendarOperationRemove,
+	}
+}
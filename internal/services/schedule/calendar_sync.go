@@ -0,0 +1,199 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/clients/redpanda"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// calendarDeletedReason is the cancel reason of lessons whose events were deleted in the calendar
+const calendarDeletedReason = "deleted from the calendar"
+
+// SyncCalendar applies the changes made directly in the group calendar of a trainer to the lessons
+// linked to its events: a moved event moves the lessons, a deleted one cancels them.
+// Moves conflicting with other lessons of the participants are reverted in the calendar.
+// The first sync of a calendar only records its state, so that older edits are not replayed.
+func (s *Schedule) SyncCalendar(ctx context.Context, calend *models.GroupCalendar) error {
+	const op = "schedule.SyncCalendar"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	events, syncToken, full, err := s.calendarManager.ChangedEvents(ctx, calend)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !full {
+		for _, event := range events {
+			if err := s.applyCalendarChange(ctx, calend, event); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	if err := s.db.SetCalendarSyncToken(ctx, calend.UserId, calend.GroupId, syncToken); err != nil {
+		log.Error(ctx, "failed to set calendar sync token", zap.Error(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RequestCalendarSync makes the calendar watched by the push notification channel due for a sync.
+func (s *Schedule) RequestCalendarSync(ctx context.Context, channelId uuid.UUID, resourceId string) error {
+	const op = "schedule.RequestCalendarSync"
+
+	if err := s.db.RequestCalendarSync(ctx, channelId, resourceId); err != nil {
+		if errors.Is(err, storage.ErrCalendarNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrCalendarNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// applyCalendarChange applies the change of one event to the upcoming lessons linked to it.
+// Events of a group lesson are linked to the schedule of every student, which move together.
+func (s *Schedule) applyCalendarChange(ctx context.Context, calend *models.GroupCalendar, event *models.CalendarEvent) error {
+	const op = "schedule.applyCalendarChange"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	linked, err := s.db.ProvideEventSchedules(ctx, calend.UserId, calend.CalendarId, event.EventId)
+	if err != nil {
+		log.Error(ctx, "failed to provide event schedules", zap.Error(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	scheds := make([]*models.Schedule, 0, len(linked))
+	for _, sched := range linked {
+		if !sched.CancelledAt.IsZero() || !sched.Start.After(time.Now()) {
+			continue
+		}
+
+		ok, err := s.canManage(ctx, sched.GroupId, sched.TrainerId, calend.UserId, managerRoles...)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if ok {
+			scheds = append(scheds, sched)
+		}
+	}
+	if len(scheds) == 0 {
+		return nil
+	}
+
+	if event.Deleted {
+		for _, sched := range scheds {
+			err := s.cancelSchedule(ctx, sched, calend.UserId, calendarDeletedReason)
+			if err != nil && !errors.Is(err, ErrScheduleCancelled) {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		return nil
+	}
+
+	if event.Start.Equal(scheds[0].Start) && event.End.Equal(scheds[0].End) {
+		return nil
+	}
+
+	if err := s.moveSchedules(ctx, calend.UserId, scheds, event.Start, event.End); err != nil {
+		if !errors.Is(err, ErrScheduleConflict) && !errors.Is(err, ErrInvalidTime) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info(ctx, "reverted calendar event", zap.String("event_id", event.EventId), zap.Error(err))
+
+		s.syncCalendars(ctx, updateEventJob(calend.UserId, scheds[0], models.CalendarEvent{
+			Title: scheds[0].Title,
+			Start: scheds[0].Start,
+			End:   scheds[0].End,
+		}))
+	}
+
+	return nil
+}
+
+// moveSchedules moves the lessons of one event to [start, end) on behalf of userId in a single transaction,
+// publishing schedule.schedule.updated for each of them and updating the events of the other participants.
+func (s *Schedule) moveSchedules(ctx context.Context, userId uuid.UUID, scheds []*models.Schedule, start, end time.Time) error {
+	const op = "schedule.moveSchedules"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if !end.After(start) {
+		return fmt.Errorf("%s: %w", op, ErrInvalidTime)
+	}
+
+	userIds := make([]uuid.UUID, 0, len(scheds)+1)
+	scheduleIds := make([]uuid.UUID, 0, len(scheds))
+	for _, sched := range scheds {
+		userIds = append(userIds, participants(sched)...)
+		scheduleIds = append(scheduleIds, sched.Id)
+	}
+	slices.SortFunc(userIds, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+
+	conflicts, err := s.findConflicts(ctx, slices.Compact(userIds), start, end)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, conflict := range conflicts {
+		if !slices.Contains(scheduleIds, conflict.Schedule.Id) {
+			return fmt.Errorf("%s: %w", op, ErrScheduleConflict)
+		}
+	}
+
+	moved := make([]*models.Schedule, 0, len(scheds))
+	jobs := make([]*models.CalendarJob, 0, len(scheds)+1)
+	messages := make([]*models.OutboxMessage, 0, len(scheds))
+	for _, before := range scheds {
+		after := *before
+		after.Start = start
+		after.End = end
+		moved = append(moved, &after)
+
+		msg, err := redpanda.ScheduleUpdatedMessage(&redpanda.ScheduleUpdatedEvent{
+			Before: before,
+			After:  &after,
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		messages = append(messages, msg)
+
+		event := models.CalendarEvent{
+			Title: after.Title,
+			Start: after.Start,
+			End:   after.End,
+		}
+
+		// the trainer has one event for all students of the lesson
+		if after.TrainerId != userId && after.Id == scheds[0].Id {
+			jobs = append(jobs, updateEventJob(after.TrainerId, &after, event))
+		}
+		if after.StudentId != uuid.Nil && after.StudentId != userId {
+			jobs = append(jobs, updateEventJob(after.StudentId, &after, event))
+		}
+	}
+
+	if err := s.db.MoveSchedules(ctx, moved, jobs, messages); err != nil {
+		log.Error(ctx, "failed to move schedules", zap.Error(err))
+
+		if errors.Is(err, storage.ErrScheduleConflict) {
+			return fmt.Errorf("%s: %w", op, ErrScheduleConflict)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
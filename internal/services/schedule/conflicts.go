@@ -0,0 +1,171 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// seriesConflictWindow bounds the conflict check of recurring lessons, which may never end
+const seriesConflictWindow = 365 * 24 * time.Hour
+
+// CheckScheduleConflicts reports the existing schedules of the trainer and the student overlapping sched
+// without creating anything.
+func (s *Schedule) CheckScheduleConflicts(ctx context.Context, sched *models.Schedule) ([]*models.Conflict, error) {
+	const op = "schedule.CheckScheduleConflicts"
+
+	conflicts, err := s.findConflicts(ctx, participants(sched), sched.Start, sched.End)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return conflicts, nil
+}
+
+// CheckGroupScheduleConflicts reports the existing schedules of the trainer and every student of the group
+// overlapping sched without creating anything.
+func (s *Schedule) CheckGroupScheduleConflicts(ctx context.Context, groupId uuid.UUID, sched *models.Schedule) ([]*models.Conflict, error) {
+	const op = "schedule.CheckGroupScheduleConflicts"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	group, err := s.gDB.ProvideGroup(ctx, groupId)
+	if err != nil {
+		log.Error(ctx, "failed to fetch group", zap.Error(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	conflicts, err := s.findConflicts(ctx, append([]uuid.UUID{sched.TrainerId}, group.Students...), sched.Start, sched.End)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return conflicts, nil
+}
+
+// findConflicts collects single schedules and series occurrences of userIds overlapping [start, end).
+func (s *Schedule) findConflicts(ctx context.Context, userIds []uuid.UUID, start, end time.Time) ([]*models.Conflict, error) {
+	const op = "schedule.findConflicts"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	conflicts, err := s.db.ProvideConflicts(ctx, userIds, start, end)
+	if err != nil {
+		log.Error(ctx, "failed to provide conflicts", zap.Error(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, userId := range userIds {
+		series, err := s.db.ProvideScheduleSeries(ctx, userId, uuid.Nil)
+		if err != nil {
+			log.Error(ctx, "failed to get schedule series", zap.Error(err))
+
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		studentSeries, err := s.db.ProvideScheduleSeries(ctx, uuid.Nil, userId)
+		if err != nil {
+			log.Error(ctx, "failed to get schedule series", zap.Error(err))
+
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, ser := range append(series, studentSeries...) {
+			for _, occurrence := range expandSeries(ser, start, end) {
+				if !occurrence.Start.Before(end) {
+					continue
+				}
+
+				conflicts = append(conflicts, &models.Conflict{
+					UserId:   userId,
+					Schedule: occurrence,
+				})
+			}
+		}
+	}
+
+	return conflicts, nil
+}
+
+// checkOccurrences fails with ErrScheduleConflict if one of occurrences, which may come from several series,
+// overlaps a lesson of its participants. The series ignoreIds and the lessons detached from them are not counted.
+func (s *Schedule) checkOccurrences(ctx context.Context, occurrences []*models.Schedule, ignoreIds ...uuid.UUID) error {
+	const op = "schedule.checkOccurrences"
+
+	if len(occurrences) == 0 {
+		return nil
+	}
+
+	userIds := make([]uuid.UUID, 0, 2)
+	from, to := occurrences[0].Start, occurrences[0].End
+	for _, occurrence := range occurrences {
+		for _, userId := range participants(occurrence) {
+			if !slices.Contains(userIds, userId) {
+				userIds = append(userIds, userId)
+			}
+		}
+		if occurrence.Start.Before(from) {
+			from = occurrence.Start
+		}
+		if occurrence.End.After(to) {
+			to = occurrence.End
+		}
+	}
+
+	conflicts, err := s.findConflicts(ctx, userIds, from, to)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, conflict := range conflicts {
+		if conflict.Schedule.SeriesId != uuid.Nil && slices.Contains(ignoreIds, conflict.Schedule.SeriesId) {
+			continue
+		}
+
+		for _, occurrence := range occurrences {
+			if slices.Contains(participants(occurrence), conflict.UserId) &&
+				conflict.Schedule.Start.Before(occurrence.End) && occurrence.Start.Before(conflict.Schedule.End) {
+				return fmt.Errorf("%s: %w", op, ErrScheduleConflict)
+			}
+		}
+	}
+
+	return nil
+}
+
+// groupSchedules builds a schedule per student of the group from sched.
+// A group without students still gets the trainer's schedule.
+func groupSchedules(group *models.Group, sched *models.Schedule) []*models.Schedule {
+	if len(group.Students) == 0 {
+		return []*models.Schedule{sched}
+	}
+
+	scheds := make([]*models.Schedule, 0, len(group.Students))
+	for _, student := range group.Students {
+		studentSched := *sched
+		studentSched.StudentId = student
+
+		scheds = append(scheds, &studentSched)
+	}
+
+	return scheds
+}
+
+// sameLesson reports whether a and b are schedules of the same group lesson, stored once per student.
+func sameLesson(a, b *models.Schedule) bool {
+	return a.GroupId == b.GroupId && a.TrainerId == b.TrainerId && a.Start.Equal(b.Start) && a.End.Equal(b.End)
+}
+
+func participants(sched *models.Schedule) []uuid.UUID {
+	if sched.StudentId == uuid.Nil {
+		return []uuid.UUID{sched.TrainerId}
+	}
+
+	return slices.Compact([]uuid.UUID{sched.TrainerId, sched.StudentId})
+}
@@ -0,0 +1,52 @@
+package schedule
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+)
+
+const maxPageSize = 500
+
+// encodeCursor turns a cursor into an opaque page token
+func encodeCursor(cursor *models.ScheduleCursor) string {
+	raw := cursor.Start.UTC().Format(time.RFC3339Nano) + "|" + cursor.Id.String()
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(token string) (*models.ScheduleCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	start, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &models.ScheduleCursor{}
+
+	if cursor.Start, err = time.Parse(time.RFC3339Nano, start); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	if cursor.Id, err = uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	return cursor, nil
+}
+
+// compareCursor orders a schedule against a cursor by start date, then by id
+func compareCursor(sched *models.Schedule, cursor *models.ScheduleCursor) int {
+	if c := sched.Start.Compare(cursor.Start); c != 0 {
+		return c
+	}
+
+	return strings.Compare(sched.Id.String(), cursor.Id.String())
+}
@@ -5,4 +5,23 @@ import "errors"
 var (
 	ErrUnauthorized     = errors.New("unauthorized")
 	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrSeriesNotFound   = errors.New("series not found")
+	ErrInvalidScope     = errors.New("invalid edit scope")
+	ErrNotAnOccurrence  = errors.New("time is not an occurrence of the series")
+	ErrScheduleConflict = errors.New("schedule overlaps an existing one")
+	ErrInvalidCursor    = errors.New("invalid page token")
+	ErrInvalidTime      = errors.New("schedule must end after it starts")
+	ErrUnknownProvider  = errors.New("unknown calendar provider")
+	ErrCalendarNotFound = errors.New("calendar not found")
+	ErrFeedNotFound     = errors.New("feed not found")
+	ErrInvalidImport    = errors.New("invalid icalendar file")
+
+	ErrInvalidAvailability  = errors.New("invalid availability window")
+	ErrAvailabilityNotFound = errors.New("availability not found")
+	ErrSlotUnavailable      = errors.New("slot is not available")
+	ErrInvalidAttendance    = errors.New("invalid attendance")
+
+	ErrScheduleCancelled = errors.New("schedule is cancelled")
+	ErrTooLateToCancel   = errors.New("schedule starts within the cancellation notice")
+	ErrInvalidTimeZone   = errors.New("invalid time zone")
 )
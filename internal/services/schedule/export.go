@@ -0,0 +1,168 @@
+package schedule
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/lib/ical"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// window of a subscribed feed around now
+	feedPast   = 30 * 24 * time.Hour
+	feedFuture = 365 * 24 * time.Hour
+
+	feedTokenSize = 32
+	uidDomain     = "apphelper-schedule"
+)
+
+// ExportSchedules renders the lessons of the user, as trainer and as student, between from and to as iCalendar.
+// If groupId is set, the lessons of the group are rendered instead, provided the user belongs to it:
+// the lessons of all of its trainers for its staff, and only their own lessons for its students.
+// Times are written in loc, or in the time zone of the user if loc is nil.
+func (s *Schedule) ExportSchedules(ctx context.Context, userId, groupId uuid.UUID, from, to time.Time, loc *time.Location) ([]byte, error) {
+	const op = "schedule.ExportSchedules"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	filters := []models.ScheduleFilter{
+		{TrainerId: userId, From: from, To: to},
+		{StudentId: userId, From: from, To: to},
+	}
+
+	if groupId != uuid.Nil {
+		group, err := s.gDB.ProvideGroup(ctx, groupId)
+		if err != nil {
+			log.Error(ctx, "failed to fetch group", zap.Error(err))
+
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		switch group.Role(userId) {
+		case "":
+			return nil, fmt.Errorf("%s: %w", op, ErrUnauthorized)
+		case models.RoleStudent:
+			filters = []models.ScheduleFilter{{StudentId: userId, GroupId: groupId, From: from, To: to}}
+		default:
+			filters = filters[:0]
+			for _, trainerId := range group.Trainers() {
+				filters = append(filters, models.ScheduleFilter{TrainerId: trainerId, GroupId: groupId, From: from, To: to})
+			}
+		}
+	}
+
+	schedules := make([]*models.Schedule, 0)
+	for _, filter := range filters {
+		scheds, _, err := s.GetSchedules(ctx, filter, "")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		schedules = append(schedules, scheds...)
+	}
+
+	if loc == nil {
+		loc = s.userLocation(ctx, userId)
+	}
+
+	return ical.EncodeIn(calendarEvents(schedules), loc), nil
+}
+
+// ExportFeed renders the feed of the user owning token, see ExportSchedules.
+func (s *Schedule) ExportFeed(ctx context.Context, token string, groupId uuid.UUID, loc *time.Location) ([]byte, error) {
+	const op = "schedule.ExportFeed"
+
+	userId, err := s.db.ProvideFeedUser(ctx, hashFeedToken(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrFeedNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrFeedNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	data, err := s.ExportSchedules(ctx, userId, groupId, now.Add(-feedPast), now.Add(feedFuture), loc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return data, nil
+}
+
+// RotateFeedToken issues a new secret feed token for the user and revokes the previous one.
+// Only a hash of the token is stored, so it cannot be shown again.
+func (s *Schedule) RotateFeedToken(ctx context.Context, userId uuid.UUID) (string, error) {
+	const op = "schedule.RotateFeedToken"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	raw := make([]byte, feedTokenSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	if err := s.db.SetFeedToken(ctx, userId, hashFeedToken(token)); err != nil {
+		log.Error(ctx, "failed to set feed token", zap.Error(err))
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// calendarEvents turns schedules into one event per lesson. A group lesson is stored per student,
+// so its rows, sharing the lesson id, are collapsed into one event. The UID of the event is the lesson id
+// rather than the id of one of its rows, so it is the same in every export, in the feeds of all participants
+// and after the lesson moved.
+func calendarEvents(schedules []*models.Schedule) []*models.CalendarEvent {
+	slices.SortFunc(schedules, func(a, b *models.Schedule) int {
+		return compareCursor(a, &models.ScheduleCursor{Start: b.Start, Id: b.Id})
+	})
+
+	lessons := make(map[uuid.UUID]bool, len(schedules))
+
+	events := make([]*models.CalendarEvent, 0, len(schedules))
+	for _, sched := range schedules {
+		uid := lessonUID(sched)
+		if lessons[uid] {
+			continue
+		}
+		lessons[uid] = true
+
+		events = append(events, &models.CalendarEvent{
+			EventId: uid.String() + "@" + uidDomain,
+			Title:   sched.Title,
+			Start:   sched.Start,
+			End:     sched.End,
+		})
+	}
+
+	return events
+}
+
+// lessonUID identifies the lesson of sched.
+func lessonUID(sched *models.Schedule) uuid.UUID {
+	if sched.LessonId == uuid.Nil {
+		return sched.Id
+	}
+
+	return sched.LessonId
+}
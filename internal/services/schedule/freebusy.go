@@ -0,0 +1,144 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// GetFreeBusy returns the busy intervals of the trainer and the students between from and to, merged from
+// their lessons and the events of their connected calendars, and the intervals of at least duration
+// in which all of them are free. An unreachable calendar is logged and left out.
+// The user has to be the owner or a co-trainer of the group, which the trainer teaches and the students belong to.
+// Without studentIds all students of the group are included.
+func (s *Schedule) GetFreeBusy(ctx context.Context, userId, groupId, trainerId uuid.UUID, studentIds []uuid.UUID, from, to time.Time, duration time.Duration) (*models.FreeBusy, error) {
+	const op = "schedule.GetFreeBusy"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if !to.After(from) {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidTime)
+	}
+
+	group, err := s.gDB.ProvideGroup(ctx, groupId)
+	if err != nil {
+		log.Error(ctx, "failed to fetch group", zap.Error(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !group.HasRole(userId, managerRoles...) || !slices.Contains(group.Trainers(), trainerId) {
+		return nil, fmt.Errorf("%s: %w", op, ErrUnauthorized)
+	}
+	if len(studentIds) == 0 {
+		studentIds = group.Students
+	}
+	for _, studentId := range studentIds {
+		if !slices.Contains(group.Students, studentId) {
+			return nil, fmt.Errorf("%s: %w", op, ErrUnauthorized)
+		}
+	}
+
+	users := []uuid.UUID{trainerId}
+	for _, studentId := range studentIds {
+		if !slices.Contains(users, studentId) {
+			users = append(users, studentId)
+		}
+	}
+	busy := make(map[uuid.UUID][]models.Interval, len(users))
+
+	conflicts, err := s.findConflicts(ctx, users, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, conflict := range conflicts {
+		for _, userId := range participants(conflict.Schedule) {
+			if slices.Contains(users, userId) {
+				busy[userId] = append(busy[userId], models.Interval{Start: conflict.Schedule.Start, End: conflict.Schedule.End})
+			}
+		}
+	}
+
+	for _, userId := range users {
+		if !s.calendarManager.IsAuthorized(ctx, userId) {
+			continue
+		}
+
+		events, err := s.calendarManager.GetEvents(ctx, userId, from, to)
+		if err != nil {
+			log.Error(ctx, "failed to get calendar events", zap.String("user_id", userId.String()), zap.Error(err))
+
+			continue
+		}
+
+		for _, event := range *events {
+			busy[userId] = append(busy[userId], models.Interval{Start: event.Start, End: event.End})
+		}
+	}
+
+	result := &models.FreeBusy{Users: make([]*models.UserBusy, 0, len(users))}
+	all := make([]models.Interval, 0)
+	for _, userId := range users {
+		merged := mergeIntervals(busy[userId], from, to)
+
+		result.Users = append(result.Users, &models.UserBusy{UserId: userId, Busy: merged})
+		all = append(all, merged...)
+	}
+
+	result.Free = freeIntervals(mergeIntervals(all, from, to), from, to, duration)
+
+	return result, nil
+}
+
+// mergeIntervals clips intervals to [from, to) and joins the overlapping and adjacent ones.
+func mergeIntervals(intervals []models.Interval, from, to time.Time) []models.Interval {
+	clipped := make([]models.Interval, 0, len(intervals))
+	for _, interval := range intervals {
+		if interval.Start.Before(from) {
+			interval.Start = from
+		}
+		if interval.End.After(to) {
+			interval.End = to
+		}
+		if interval.End.After(interval.Start) {
+			clipped = append(clipped, interval)
+		}
+	}
+
+	slices.SortFunc(clipped, func(a, b models.Interval) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	merged := make([]models.Interval, 0, len(clipped))
+	for _, interval := range clipped {
+		if n := len(merged); n > 0 && !interval.Start.After(merged[n-1].End) {
+			if interval.End.After(merged[n-1].End) {
+				merged[n-1].End = interval.End
+			}
+			continue
+		}
+
+		merged = append(merged, interval)
+	}
+
+	return merged
+}
+
+// freeIntervals returns the gaps of at least duration between the merged busy intervals in [from, to).
+func freeIntervals(busy []models.Interval, from, to time.Time, duration time.Duration) []models.Interval {
+	free := make([]models.Interval, 0, len(busy)+1)
+
+	start := from
+	for _, interval := range append(busy, models.Interval{Start: to, End: to}) {
+		if interval.Start.Sub(start) >= max(duration, 1) {
+			free = append(free, models.Interval{Start: start, End: interval.Start})
+		}
+		start = interval.End
+	}
+
+	return free
+}
@@ -0,0 +1,255 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/lib/ical"
+	"github.com/hesoyamTM/apphelper-schedule/internal/lib/recurrence"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ImportSchedules turns the events of an iCalendar file into lessons of the trainer, the owner or a co-trainer
+// of the group: single events into schedules as CreateScheduleForGroup does and recurring events into series.
+// Every entry is returned with the lessons it overlaps. With dryRun nothing is created; otherwise all entries
+// are created in one transaction, or none of them with ErrScheduleConflict if any entry conflicts.
+func (s *Schedule) ImportSchedules(ctx context.Context, trainerId, groupId uuid.UUID, data []byte, dryRun bool) (*models.ImportResult, error) {
+	const op = "schedule.ImportSchedules"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	group, err := s.gDB.ProvideGroup(ctx, groupId)
+	if err != nil {
+		log.Error(ctx, "failed to fetch group", zap.Error(err))
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !group.HasRole(trainerId, managerRoles...) {
+		return nil, fmt.Errorf("%s: %w", op, ErrUnauthorized)
+	}
+
+	events, err := ical.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrInvalidImport, err)
+	}
+
+	events = applyOverrides(events)
+
+	users := append([]uuid.UUID{trainerId}, group.Students...)
+	// lessons of the file, to find entries overlapping each other
+	planned := make([]*models.Schedule, 0, len(events))
+
+	result := &models.ImportResult{Entries: make([]*models.ImportEntry, 0, len(events))}
+	conflicting := false
+
+	for _, event := range events {
+		if !event.End.After(event.Start) {
+			return nil, fmt.Errorf("%s: %w: %q ends before it starts", op, ErrInvalidImport, event.EventId)
+		}
+		if event.Recurrence != nil {
+			if err := recurrence.Validate(*event.Recurrence); err != nil {
+				return nil, fmt.Errorf("%s: %w: %w", op, ErrInvalidImport, err)
+			}
+		}
+
+		occurrences := importOccurrences(group, trainerId, event)
+		if len(occurrences) == 0 {
+			continue
+		}
+
+		conflicts, err := s.findConflicts(ctx, users, occurrences[0].Start, occurrences[len(occurrences)-1].End)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		for _, sched := range planned {
+			conflicts = append(conflicts, &models.Conflict{UserId: trainerId, Schedule: sched})
+		}
+
+		entry := &models.ImportEntry{
+			Uid:        event.EventId,
+			Title:      event.Title,
+			Start:      event.Start,
+			End:        event.End,
+			Recurrence: event.Recurrence,
+			Conflicts:  overlapping(conflicts, occurrences),
+		}
+		if len(entry.Conflicts) > 0 {
+			conflicting = true
+		}
+
+		result.Entries = append(result.Entries, entry)
+		planned = append(planned, occurrences...)
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if conflicting {
+		return result, fmt.Errorf("%s: %w", op, ErrScheduleConflict)
+	}
+
+	if err := s.commitImport(ctx, group, trainerId, result.Entries); err != nil {
+		return result, fmt.Errorf("%s: %w", op, err)
+	}
+	result.Committed = true
+
+	return result, nil
+}
+
+func (s *Schedule) commitImport(ctx context.Context, group *models.Group, trainerId uuid.UUID, entries []*models.ImportEntry) error {
+	const op = "schedule.commitImport"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	lessons := make([]*models.Schedule, 0)
+	lessonScheds := make([][]*models.Schedule, 0)
+	scheds := make([]*models.Schedule, 0)
+	series := make([]*models.ScheduleSeries, 0)
+	occurrences := make([]*models.Schedule, 0)
+
+	for _, entry := range entries {
+		if entry.Recurrence == nil {
+			sched := importSchedule(group, trainerId, entry)
+
+			groupScheds := groupSchedules(group, sched)
+			lessons = append(lessons, sched)
+			lessonScheds = append(lessonScheds, groupScheds)
+			scheds = append(scheds, groupScheds...)
+			continue
+		}
+
+		for _, ser := range importSeries(group, trainerId, entry) {
+			series = append(series, ser)
+			occurrences = append(occurrences, seriesOccurrences(ser)...)
+		}
+	}
+
+	newChanges := func() ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		jobs := make([]*models.CalendarJob, 0)
+		for i, lesson := range lessons {
+			jobs = append(jobs, groupEventJobs(group, lesson, lessonScheds[i])...)
+		}
+
+		messages, err := createdMessages(scheds)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		seriesMessages, err := seriesCreatedMessages(series)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return jobs, append(messages, seriesMessages...), nil
+	}
+
+	if err := s.db.ImportSchedules(ctx, scheds, series, occurrences, newChanges); err != nil {
+		log.Error(ctx, "failed to import schedules", zap.Error(err))
+
+		if errors.Is(err, storage.ErrScheduleConflict) {
+			return fmt.Errorf("%s: %w", op, ErrScheduleConflict)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// applyOverrides drops the modified instances of recurring events from their series, so that they are
+// imported as single lessons instead of the occurrence they replace.
+func applyOverrides(events []*models.CalendarEvent) []*models.CalendarEvent {
+	for _, event := range events {
+		if event.RecurrenceId.IsZero() {
+			continue
+		}
+
+		for _, master := range events {
+			if master.Recurrence != nil && master.EventId == event.EventId {
+				master.Recurrence.Exceptions = append(master.Recurrence.Exceptions, event.RecurrenceId)
+			}
+		}
+		event.Recurrence = nil
+	}
+
+	return events
+}
+
+// importOccurrences returns the lessons of the trainer an event turns into,
+// bounded by seriesConflictWindow for recurring events.
+func importOccurrences(group *models.Group, trainerId uuid.UUID, event *models.CalendarEvent) []*models.Schedule {
+	entry := &models.ImportEntry{
+		Title:      event.Title,
+		Start:      event.Start,
+		End:        event.End,
+		Recurrence: event.Recurrence,
+	}
+
+	if event.Recurrence == nil {
+		return []*models.Schedule{importSchedule(group, trainerId, entry)}
+	}
+
+	ser := importSeries(group, trainerId, entry)[0]
+	ser.StudentId = uuid.Nil
+
+	return expandSeries(ser, event.Start, event.Start.Add(seriesConflictWindow))
+}
+
+func importSchedule(group *models.Group, trainerId uuid.UUID, entry *models.ImportEntry) *models.Schedule {
+	return &models.Schedule{
+		Title:     entry.Title,
+		Start:     entry.Start,
+		End:       entry.End,
+		GroupId:   group.Id,
+		GroupName: group.Name,
+		TrainerId: trainerId,
+		LessonId:  uuid.New(),
+	}
+}
+
+// importSeries builds a series per student of the group, as CreateScheduleSeriesForGroup does.
+func importSeries(group *models.Group, trainerId uuid.UUID, entry *models.ImportEntry) []*models.ScheduleSeries {
+	series := &models.ScheduleSeries{
+		Title:      entry.Title,
+		Start:      entry.Start,
+		End:        entry.End,
+		GroupId:    group.Id,
+		GroupName:  group.Name,
+		TrainerId:  trainerId,
+		Recurrence: *entry.Recurrence,
+		TimeZone:   locationName(entry.Start),
+		LessonId:   uuid.New(),
+	}
+
+	if len(group.Students) == 0 {
+		return []*models.ScheduleSeries{series}
+	}
+
+	result := make([]*models.ScheduleSeries, 0, len(group.Students))
+	for _, student := range group.Students {
+		studentSeries := *series
+		studentSeries.StudentId = student
+
+		result = append(result, &studentSeries)
+	}
+
+	return result
+}
+
+// overlapping keeps the conflicts overlapping one of occurrences.
+func overlapping(conflicts []*models.Conflict, occurrences []*models.Schedule) []*models.Conflict {
+	result := make([]*models.Conflict, 0)
+	for _, conflict := range conflicts {
+		for _, occurrence := range occurrences {
+			if conflict.Schedule.Start.Before(occurrence.End) && occurrence.Start.Before(conflict.Schedule.End) {
+				result = append(result, conflict)
+				break
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,52 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// MemberSeries returns the series of the student in the group as they are ended right before at, when the student
+// leaves or is removed from the group: the series which started before at are truncated, the others are to be
+// deleted. Series which already ended are left out. Nothing is stored, see groups.removeMember.
+func (s *Schedule) MemberSeries(ctx context.Context, groupId, studentId uuid.UUID, at time.Time) ([]*models.ScheduleSeries, []*models.ScheduleSeries, error) {
+	const op = "schedule.MemberSeries"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	series, err := s.db.ProvideScheduleSeries(ctx, uuid.Nil, studentId)
+	if err != nil {
+		log.Error(ctx, "failed to provide schedule series", zap.Error(err))
+
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	truncated := make([]*models.ScheduleSeries, 0)
+	deleted := make([]*models.ScheduleSeries, 0)
+	for _, ser := range series {
+		if ser.GroupId != groupId {
+			continue
+		}
+
+		if !ser.Start.Before(at) {
+			deleted = append(deleted, ser)
+			continue
+		}
+
+		rule := ser.Recurrence
+		before := truncateSeries(ser, at)
+
+		// series which already ended are left as they are
+		if rule.Count > 0 && before >= rule.Count || !rule.Until.IsZero() && rule.Until.Before(at) {
+			continue
+		}
+
+		truncated = append(truncated, ser)
+	}
+
+	return truncated, deleted, nil
+}
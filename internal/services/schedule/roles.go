@@ -0,0 +1,60 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// managerRoles manage all schedules of a group
+var managerRoles = []models.GroupRole{models.RoleOwner, models.RoleCoTrainer}
+
+// canManage reports whether userId may act on a lesson of the group taught by trainerId:
+// as its trainer or with one of roles in the group.
+func (s *Schedule) canManage(ctx context.Context, groupId, trainerId, userId uuid.UUID, roles ...models.GroupRole) (bool, error) {
+	const op = "schedule.canManage"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if userId == trainerId {
+		return true, nil
+	}
+	if groupId == uuid.Nil {
+		return false, nil
+	}
+
+	group, err := s.gDB.ProvideGroup(ctx, groupId)
+	if err != nil {
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			return false, nil
+		}
+		log.Error(ctx, "failed to fetch group", zap.Error(err))
+
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return group.HasRole(userId, roles...), nil
+}
+
+// isMember reports whether userId has any role in the group.
+func (s *Schedule) isMember(ctx context.Context, groupId, userId uuid.UUID) (bool, error) {
+	const op = "schedule.isMember"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	group, err := s.gDB.ProvideGroup(ctx, groupId)
+	if err != nil {
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			return false, nil
+		}
+		log.Error(ctx, "failed to fetch group", zap.Error(err))
+
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return group.Role(userId) != "", nil
+}
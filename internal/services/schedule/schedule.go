@@ -2,36 +2,82 @@ package schedule
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/clients/redpanda"
 	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
 	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
 	"go.uber.org/zap"
 )
 
 type ScheduleStorage interface {
-	CreateSchedule(ctx context.Context, sched *models.Schedule) error
-	ProvideSchedules(ctx context.Context, trainerId, studentId uuid.UUID) ([]*models.Schedule, error)
-	DeleteSchedule(ctx context.Context, groupId, trainerId uuid.UUID) error
+	CreateSchedule(ctx context.Context, sched *models.Schedule, newChanges func() ([]*models.CalendarJob, []*models.OutboxMessage, error)) error
+	CreateSchedules(ctx context.Context, scheds []*models.Schedule, newChanges func() ([]*models.CalendarJob, []*models.OutboxMessage, error)) error
+	UpdateSchedule(ctx context.Context, sched *models.Schedule, jobs []*models.CalendarJob, messages []*models.OutboxMessage) error
+	MoveSchedules(ctx context.Context, scheds []*models.Schedule, jobs []*models.CalendarJob, messages []*models.OutboxMessage) error
+	ProvideSchedule(ctx context.Context, scheduleId uuid.UUID) (*models.Schedule, error)
+	ProvideConflicts(ctx context.Context, userIds []uuid.UUID, start, end time.Time) ([]*models.Conflict, error)
+	ProvideSchedules(ctx context.Context, filter models.ScheduleFilter) ([]*models.Schedule, error)
+	CancelSchedule(ctx context.Context, sched *models.Schedule, jobs []*models.CalendarJob, messages []*models.OutboxMessage) error
+	ImportSchedules(ctx context.Context, scheds []*models.Schedule, series []*models.ScheduleSeries, occurrences []*models.Schedule, newChanges func() ([]*models.CalendarJob, []*models.OutboxMessage, error)) error
+
+	CreateScheduleSeries(ctx context.Context, series []*models.ScheduleSeries, occurrences []*models.Schedule, newMessages func() ([]*models.OutboxMessage, error)) error
+	ProvideScheduleSeries(ctx context.Context, trainerId, studentId uuid.UUID) ([]*models.ScheduleSeries, error)
+	ProvideLessonSeries(ctx context.Context, seriesId uuid.UUID) ([]*models.ScheduleSeries, error)
+	UpdateScheduleSeries(ctx context.Context, series []*models.ScheduleSeries, occurrences []*models.Schedule) error
+	SplitScheduleSeries(ctx context.Context, series, next []*models.ScheduleSeries, at time.Time, occurrences []*models.Schedule) error
+	DetachOccurrences(ctx context.Context, scheds []*models.Schedule) error
+	AddSeriesException(ctx context.Context, seriesIds []uuid.UUID, trainerId uuid.UUID, at time.Time) error
+	DeleteScheduleSeries(ctx context.Context, seriesIds []uuid.UUID, trainerId uuid.UUID) error
+
+	CreateCalendarJobs(ctx context.Context, jobs []*models.CalendarJob) error
+	ProvideEventSchedules(ctx context.Context, userId uuid.UUID, calendarId, eventId string) ([]*models.Schedule, error)
+	SetCalendarSyncToken(ctx context.Context, userId, groupId uuid.UUID, syncToken string) error
+	RequestCalendarSync(ctx context.Context, channelId uuid.UUID, resourceId string) error
+	ProvideCalendarJobs(ctx context.Context, userId uuid.UUID) ([]*models.CalendarJob, error)
+	RetryCalendarJobs(ctx context.Context, userId uuid.UUID) (int, error)
+
+	CreateAvailability(ctx context.Context, av *models.Availability) error
+	ProvideAvailability(ctx context.Context, availabilityId uuid.UUID) (*models.Availability, error)
+	ProvideTrainerAvailability(ctx context.Context, trainerId uuid.UUID) ([]*models.Availability, error)
+	DeleteAvailability(ctx context.Context, availabilityId, trainerId uuid.UUID) error
+
+	SetAttendance(ctx context.Context, attendance *models.Attendance, messages []*models.OutboxMessage) error
+	ProvideAttendance(ctx context.Context, filter models.AttendanceFilter) ([]*models.Attendance, error)
+
+	SetUserTimeZone(ctx context.Context, userId uuid.UUID, timeZone string) error
+	ProvideUserTimeZone(ctx context.Context, userId uuid.UUID) (string, error)
+
+	SetFeedToken(ctx context.Context, userId uuid.UUID, tokenHash string) error
+	ProvideFeedUser(ctx context.Context, tokenHash string) (uuid.UUID, error)
 }
 
 type GroupStorage interface {
 	ProvideGroup(ctx context.Context, groupId uuid.UUID) (*models.Group, error)
 }
 
-type Redpanda interface {
-	ScheduleCreatedEvent(ctx context.Context, schedule *models.Schedule) error
-}
-
 type CalendarManagerInterface interface {
-	LoginURL(ctx context.Context, userID uuid.UUID) string
+	LoginURL(ctx context.Context, userID uuid.UUID, provider models.CalendarProvider) string
 	Authorize(ctx context.Context, userId uuid.UUID, authcode, state string) error
+	Connect(ctx context.Context, userId uuid.UUID, tok models.Token) error
 	IsAuthorized(ctx context.Context, userId uuid.UUID) bool
-	CreateEvent(ctx context.Context, userId, groupId uuid.UUID, event *models.CalendarEvent) error
+	CreateEvent(ctx context.Context, userId, groupId uuid.UUID, event *models.CalendarEvent, scheduleIds ...uuid.UUID) error
+	UpdateEvent(ctx context.Context, userId, scheduleId uuid.UUID, updated *models.CalendarEvent) error
+	RemoveEvent(ctx context.Context, userId, scheduleId uuid.UUID) error
 	GetEvents(ctx context.Context, userId uuid.UUID, minTime, maxTime time.Time) (*[]*models.CalendarEvent, error)
 	DeleteEvent(ctx context.Context, userId uuid.UUID, group_id uuid.UUID, eventId string) error
+	ChangedEvents(ctx context.Context, calend *models.GroupCalendar) ([]*models.CalendarEvent, string, bool, error)
+}
+
+type Config struct {
+	// students cannot cancel lessons starting sooner than this
+	CancellationNotice time.Duration `yaml:"cancellation-notice" env-default:"24h" env:"SCHEDULE_CANCELLATION_NOTICE"`
 }
 
 type Schedule struct {
@@ -39,52 +85,70 @@ type Schedule struct {
 	gDB GroupStorage
 
 	calendarManager CalendarManagerInterface
-	redpanda        Redpanda
+	cfg             Config
 }
 
-func New(ctx context.Context, db ScheduleStorage, gDB GroupStorage, calendarManager CalendarManagerInterface, redpanda Redpanda) *Schedule {
+func New(ctx context.Context, db ScheduleStorage, gDB GroupStorage, calendarManager CalendarManagerInterface, cfg Config) *Schedule {
 	return &Schedule{
 		db:              db,
 		gDB:             gDB,
 		calendarManager: calendarManager,
-		redpanda:        redpanda,
+		cfg:             cfg,
 	}
 }
 
+// CreateSchedule creates a lesson of the trainer with the student. Without an end a lesson of a group lasts
+// the default duration of the group. ErrInvalidTime is returned unless the lesson ends after it starts.
 func (s *Schedule) CreateSchedule(ctx context.Context, sched *models.Schedule) error {
 	const op = "schedule.CreateSchedule"
 	log := logger.GetLoggerFromCtx(ctx)
 
-	event := &models.CalendarEvent{
-		Title: sched.Title,
-		Start: sched.Start,
-		End:   sched.End,
-	}
+	if sched.End.IsZero() && sched.GroupId != uuid.Nil {
+		group, err := s.gDB.ProvideGroup(ctx, sched.GroupId)
+		if err != nil {
+			log.Error(ctx, "failed to fetch group", zap.Error(err))
 
-	if err := s.calendarManager.CreateEvent(ctx, sched.TrainerId, sched.GroupId, event); err != nil {
-		log.Error(ctx, "failed to create event", zap.Error(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
 
-		// TODO: error
+		if group.DefaultDuration > 0 {
+			sched.End = sched.Start.Add(group.DefaultDuration)
+		}
+	}
+	if !sched.End.After(sched.Start) {
+		return fmt.Errorf("%s: %w", op, ErrInvalidTime)
 	}
 
-	if err := s.calendarManager.CreateEvent(ctx, sched.StudentId, sched.GroupId, event); err != nil {
-		log.Error(ctx, "failed to create event", zap.Error(err))
-
-		// TODO: error
+	conflicts, err := s.findConflicts(ctx, participants(sched), sched.Start, sched.End)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%s: %w", op, ErrScheduleConflict)
 	}
 
-	if err := s.db.CreateSchedule(ctx, sched); err != nil {
-		log.Error(ctx, "failed to create schedule", zap.Error(err))
+	newChanges := func() ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		event := models.CalendarEvent{
+			Title: sched.Title,
+			Start: sched.Start,
+			End:   sched.End,
+		}
 
-		// TODO: error
+		jobs := []*models.CalendarJob{
+			createEventJob(sched.TrainerId, sched.GroupId, event, sched.Id),
+			createEventJob(sched.StudentId, sched.GroupId, event, sched.Id),
+		}
 
-		return fmt.Errorf("%s: %w", op, err)
+		messages, err := createdMessages([]*models.Schedule{sched})
+		return jobs, messages, err
 	}
 
-	if err := s.redpanda.ScheduleCreatedEvent(ctx, sched); err != nil {
-		log.Error(ctx, "failed to send schedule created event", zap.Error(err))
+	if err := s.db.CreateSchedule(ctx, sched, newChanges); err != nil {
+		log.Error(ctx, "failed to create schedule", zap.Error(err))
 
-		// TODO: error
+		if errors.Is(err, storage.ErrScheduleConflict) {
+			return fmt.Errorf("%s: %w", op, ErrScheduleConflict)
+		}
 
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -92,6 +156,7 @@ func (s *Schedule) CreateSchedule(ctx context.Context, sched *models.Schedule) e
 	return nil
 }
 
+// CreateScheduleForGroup creates a lesson of the trainer with every student of the group, see CreateSchedule.
 func (s *Schedule) CreateScheduleForGroup(ctx context.Context, groupId uuid.UUID, sched *models.Schedule) error {
 	const op = "schedule.CreateScheduleForGroup"
 	log := logger.GetLoggerFromCtx(ctx)
@@ -105,99 +170,373 @@ func (s *Schedule) CreateScheduleForGroup(ctx context.Context, groupId uuid.UUID
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	event := &models.CalendarEvent{
-		Title: sched.Title,
-		Start: sched.Start,
-		End:   sched.End,
+	if sched.End.IsZero() && group.DefaultDuration > 0 {
+		sched.End = sched.Start.Add(group.DefaultDuration)
+	}
+	if !sched.End.After(sched.Start) {
+		return fmt.Errorf("%s: %w", op, ErrInvalidTime)
 	}
 
-	if err := s.calendarManager.CreateEvent(ctx, sched.TrainerId, groupId, event); err != nil {
-		log.Error(ctx, "failed to create event", zap.Error(err))
+	conflicts, err := s.findConflicts(ctx, append([]uuid.UUID{sched.TrainerId}, group.Students...), sched.Start, sched.End)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%s: %w", op, ErrScheduleConflict)
+	}
 
-		// TODO: error
+	sched.LessonId = uuid.New()
+	scheds := groupSchedules(group, sched)
 
-		// return fmt.Errorf("%s: %w", op, err)
+	newChanges := func() ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		messages, err := createdMessages(scheds)
+		return groupEventJobs(group, sched, scheds), messages, err
 	}
 
-	if err := s.redpanda.ScheduleCreatedEvent(ctx, sched); err != nil {
-		log.Error(ctx, "failed to send schedule created event", zap.Error(err))
+	if err := s.db.CreateSchedules(ctx, scheds, newChanges); err != nil {
+		log.Error(ctx, "failed to create schedule", zap.Error(err))
+
+		if errors.Is(err, storage.ErrScheduleConflict) {
+			return fmt.Errorf("%s: %w", op, ErrScheduleConflict)
+		}
+
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	for _, student := range group.Students {
-		if err := s.calendarManager.CreateEvent(ctx, student, groupId, event); err != nil {
-			log.Error(ctx, "failed to create event", zap.Error(err))
+	return nil
+}
+
+// createdMessages builds a created event per stored schedule, so that every event carries the id
+// of its schedule. A group lesson is stored, and published, once per student.
+func createdMessages(scheds []*models.Schedule) ([]*models.OutboxMessage, error) {
+	messages := make([]*models.OutboxMessage, 0, len(scheds))
+	for _, sched := range scheds {
+		msg, err := redpanda.ScheduleCreatedMessage(sched)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+// GetSchedules returns a page of single schedules and series occurrences matching filter,
+// ordered by start date, and the token of the next page. The token is empty on the last page.
+// Series are expanded over defaultExpansionWindow from now unless the filter sets a window.
+func (s *Schedule) GetSchedules(ctx context.Context, filter models.ScheduleFilter, pageToken string) ([]*models.Schedule, string, error) {
+	const op = "schedule.GetSchedules"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if pageToken != "" {
+		cursor, err := decodeCursor(pageToken)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+		filter.After = cursor
+	}
+
+	limit := filter.Limit
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	if limit > 0 {
+		// one more row tells whether there is a next page
+		filter.Limit = limit + 1
+	}
+
+	schedules, err := s.db.ProvideSchedules(ctx, filter)
+	if err != nil {
+		log.Error(ctx, "failed to get schedule", zap.Error(err))
+
+		// TODO: error
 
-			// TODO: error
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
 
-			// return fmt.Errorf("%s: %w", op, err)
+	series, err := s.db.ProvideScheduleSeries(ctx, filter.TrainerId, filter.StudentId)
+	if err != nil {
+		log.Error(ctx, "failed to get schedule series", zap.Error(err))
+
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	from, to := filter.From, filter.To
+	if from.IsZero() {
+		from = time.Now()
+	}
+	if to.IsZero() {
+		to = from.Add(defaultExpansionWindow)
+	}
+
+	for _, ser := range series {
+		if filter.GroupId != uuid.Nil && ser.GroupId != filter.GroupId {
+			continue
+		}
+		if filter.Search != "" && !strings.Contains(strings.ToLower(ser.Title), strings.ToLower(filter.Search)) {
+			continue
 		}
 
-		sched.StudentId = student
+		for _, occurrence := range expandSeries(ser, from, to) {
+			if filter.After != nil && compareCursor(occurrence, filter.After) <= 0 {
+				continue
+			}
 
-		if err := s.db.CreateSchedule(ctx, sched); err != nil {
-			log.Error(ctx, "failed to create schedule", zap.Error(err))
+			schedules = append(schedules, occurrence)
+		}
+	}
 
-			// TODO: error
+	slices.SortFunc(schedules, func(a, b *models.Schedule) int {
+		return compareCursor(a, &models.ScheduleCursor{Start: b.Start, Id: b.Id})
+	})
 
-			return fmt.Errorf("%s: %w", op, err)
+	if limit == 0 || len(schedules) <= limit {
+		return schedules, "", nil
+	}
+
+	schedules = schedules[:limit]
+	last := schedules[limit-1]
+
+	return schedules, encodeCursor(&models.ScheduleCursor{Start: last.Start, Id: last.Id}), nil
+}
+
+// UpdateSchedule moves, renames or reassigns the schedule to another student on behalf of its trainer,
+// or of the owner or a co-trainer of its group. Zero fields of changes are left as they are.
+// Moving or renaming the schedule of one student of a group lesson takes it out of the shared event
+// of the trainer into an event of its own, the other students keep the lesson as it was.
+func (s *Schedule) UpdateSchedule(ctx context.Context, scheduleId, trainerId uuid.UUID, changes *models.Schedule) error {
+	const op = "schedule.UpdateSchedule"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	before, err := s.db.ProvideSchedule(ctx, scheduleId)
+	if err != nil {
+		log.Error(ctx, "failed to provide schedule", zap.Error(err))
+
+		if errors.Is(err, storage.ErrScheduleNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrScheduleNotFound)
 		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	ok, err := s.canManage(ctx, before.GroupId, before.TrainerId, trainerId, managerRoles...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return fmt.Errorf("%s: %w", op, ErrScheduleNotFound)
+	}
 
-		if err := s.redpanda.ScheduleCreatedEvent(ctx, &models.Schedule{
-			GroupId:   groupId,
-			GroupName: group.Name,
-			StudentId: student,
-			Title:     sched.Title,
-			Start:     sched.Start,
-			End:       sched.End,
-		}); err != nil {
-			log.Error(ctx, "failed to send schedule created event", zap.Error(err))
-			return fmt.Errorf("%s: %w", op, err)
+	after := *before
+	applyChanges(&after, changes)
+
+	if !after.End.After(after.Start) {
+		return fmt.Errorf("%s: %w", op, ErrInvalidTime)
+	}
+
+	conflicts, err := s.findConflicts(ctx, participants(&after), after.Start, after.End)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, conflict := range conflicts {
+		// the other students of a group lesson are not conflicts
+		if conflict.Schedule.Id != after.Id && !sameLesson(conflict.Schedule, before) {
+			return fmt.Errorf("%s: %w", op, ErrScheduleConflict)
 		}
 	}
-	if len(group.Students) == 0 {
-		if err := s.db.CreateSchedule(ctx, sched); err != nil {
-			log.Error(ctx, "failed to create schedule", zap.Error(err))
-			return fmt.Errorf("%s: %w", op, err)
+
+	msg, err := redpanda.ScheduleUpdatedMessage(&redpanda.ScheduleUpdatedEvent{
+		Before: before,
+		After:  &after,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.CalendarEvent{
+		Title: after.Title,
+		Start: after.Start,
+		End:   after.End,
+	}
+
+	siblings, err := s.lessonSiblings(ctx, before)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var jobs []*models.CalendarJob
+	if len(siblings) > 0 && (after.Title != before.Title || !after.Start.Equal(before.Start) || !after.End.Equal(before.End)) {
+		// the trainer's event stays with the other students, the changed schedule becomes a lesson of its own
+		after.LessonId = uuid.New()
+		jobs = append(jobs,
+			removeEventJob(before.TrainerId, before),
+			createEventJob(after.TrainerId, after.GroupId, event, after.Id),
+		)
+	} else {
+		jobs = append(jobs, updateEventJob(after.TrainerId, &after, event))
+	}
+	if before.StudentId == after.StudentId {
+		jobs = append(jobs, updateEventJob(after.StudentId, &after, event))
+	} else {
+		jobs = append(jobs,
+			removeEventJob(before.StudentId, before),
+			createEventJob(after.StudentId, after.GroupId, event, after.Id),
+		)
+	}
+
+	if err := s.db.UpdateSchedule(ctx, &after, jobs, []*models.OutboxMessage{msg}); err != nil {
+		log.Error(ctx, "failed to update schedule", zap.Error(err))
+
+		if errors.Is(err, storage.ErrScheduleConflict) {
+			return fmt.Errorf("%s: %w", op, ErrScheduleConflict)
 		}
+		if errors.Is(err, storage.ErrScheduleNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrScheduleNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
-func (s *Schedule) GetSchedules(ctx context.Context, groupId, trainerId, studentId uuid.UUID) ([]*models.Schedule, error) {
-	const op = "schedule.GetSchedules"
+// lessonSiblings returns the schedules of the other students of the group lesson of sched,
+// which share the calendar event of the trainer with it.
+func (s *Schedule) lessonSiblings(ctx context.Context, sched *models.Schedule) ([]*models.Schedule, error) {
+	const op = "schedule.lessonSiblings"
 	log := logger.GetLoggerFromCtx(ctx)
 
-	schedules, err := s.db.ProvideSchedules(ctx, trainerId, studentId)
-	if err != nil {
-		log.Error(ctx, "failed to get schedule", zap.Error(err))
+	if sched.GroupId == uuid.Nil {
+		return nil, nil
+	}
 
-		// TODO: error
+	conflicts, err := s.db.ProvideConflicts(ctx, []uuid.UUID{sched.TrainerId}, sched.Start, sched.End)
+	if err != nil {
+		log.Error(ctx, "failed to provide conflicts", zap.Error(err))
 
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return schedules, nil
+	var siblings []*models.Schedule
+	for _, conflict := range conflicts {
+		if conflict.Schedule.Id != sched.Id && sameLesson(conflict.Schedule, sched) &&
+			!slices.ContainsFunc(siblings, func(sibling *models.Schedule) bool { return sibling.Id == conflict.Schedule.Id }) {
+			siblings = append(siblings, conflict.Schedule)
+		}
+	}
+
+	return siblings, nil
 }
 
-func (s *Schedule) DeleteSchedule(ctx context.Context, groupId, trainerId uuid.UUID) error {
+// DeleteSchedule cancels the schedule on behalf of its trainer, or of the owner or a co-trainer of its group,
+// see CancelSchedule.
+func (s *Schedule) DeleteSchedule(ctx context.Context, scheduleId, trainerId uuid.UUID) error {
 	const op = "schedule.DeleteSchedule"
+
+	sched, err := s.provideSchedule(ctx, scheduleId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	ok, err := s.canManage(ctx, sched.GroupId, sched.TrainerId, trainerId, managerRoles...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return fmt.Errorf("%s: %w", op, ErrScheduleNotFound)
+	}
+
+	if err := s.cancelSchedule(ctx, sched, trainerId, ""); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CancelSchedule cancels the schedule on behalf of its trainer, its student, or the owner or a co-trainer of its group,
+// publishes schedule.schedule.cancelled and removes its events from the calendars of all participants.
+// The schedule is kept with the reason. Trainers can cancel at any time, the student only up to the cancellation
+// notice before the start.
+func (s *Schedule) CancelSchedule(ctx context.Context, scheduleId, userId uuid.UUID, reason string) error {
+	const op = "schedule.CancelSchedule"
+
+	sched, err := s.provideSchedule(ctx, scheduleId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ok, err := s.canManage(ctx, sched.GroupId, sched.TrainerId, userId, managerRoles...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch {
+	case ok:
+	case userId == sched.StudentId:
+		if time.Now().Add(s.cfg.CancellationNotice).After(sched.Start) {
+			return fmt.Errorf("%s: %w", op, ErrTooLateToCancel)
+		}
+	default:
+		return fmt.Errorf("%s: %w", op, ErrScheduleNotFound)
+	}
+
+	if err := s.cancelSchedule(ctx, sched, userId, reason); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Schedule) provideSchedule(ctx context.Context, scheduleId uuid.UUID) (*models.Schedule, error) {
+	const op = "schedule.provideSchedule"
 	log := logger.GetLoggerFromCtx(ctx)
 
-	if err := s.db.DeleteSchedule(ctx, groupId, trainerId); err != nil {
-		log.Error(ctx, "failed to delete schedule", zap.Error(err))
+	sched, err := s.db.ProvideSchedule(ctx, scheduleId)
+	if err != nil {
+		log.Error(ctx, "failed to provide schedule", zap.Error(err))
 
-		// TODO: error
+		if errors.Is(err, storage.ErrScheduleNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrScheduleNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sched, nil
+}
+
+func (s *Schedule) cancelSchedule(ctx context.Context, sched *models.Schedule, userId uuid.UUID, reason string) error {
+	const op = "schedule.cancelSchedule"
+	log := logger.GetLoggerFromCtx(ctx)
 
+	if !sched.CancelledAt.IsZero() {
+		return fmt.Errorf("%s: %w", op, ErrScheduleCancelled)
+	}
+
+	sched.CancelledAt = time.Now()
+	sched.CancelledBy = userId
+	sched.CancelReason = reason
+
+	msg, err := redpanda.ScheduleCancelledMessage(sched)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	jobs := make([]*models.CalendarJob, 0, 2)
+	for _, userId := range participants(sched) {
+		jobs = append(jobs, removeEventJob(userId, sched))
+	}
+
+	if err := s.db.CancelSchedule(ctx, sched, jobs, []*models.OutboxMessage{msg}); err != nil {
+		log.Error(ctx, "failed to cancel schedule", zap.Error(err))
+
+		if errors.Is(err, storage.ErrScheduleNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrScheduleCancelled)
+		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
-func (s *Schedule) LoginURL(ctx context.Context, userID uuid.UUID) string {
-	return s.calendarManager.LoginURL(ctx, userID)
+func (s *Schedule) LoginURL(ctx context.Context, userID uuid.UUID, provider models.CalendarProvider) string {
+	return s.calendarManager.LoginURL(ctx, userID, provider)
 }
 
 func (s *Schedule) Authorize(ctx context.Context, userId uuid.UUID, authcode, state string) error {
@@ -215,6 +554,27 @@ func (s *Schedule) Authorize(ctx context.Context, userId uuid.UUID, authcode, st
 	return nil
 }
 
+// ConnectCalDAV connects the CalDAV calendar home at endpoint with the credentials of the user.
+func (s *Schedule) ConnectCalDAV(ctx context.Context, userId uuid.UUID, endpoint, username, password string) error {
+	const op = "schedule.ConnectCalDAV"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	tok := models.Token{
+		Provider:    models.ProviderCalDAV,
+		AccessToken: password,
+		Endpoint:    endpoint,
+		Username:    username,
+	}
+
+	if err := s.calendarManager.Connect(ctx, userId, tok); err != nil {
+		log.Error(ctx, "failed to connect caldav", zap.Error(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
 func (s *Schedule) IsAuthorized(ctx context.Context, userId uuid.UUID) bool {
 	return s.calendarManager.IsAuthorized(ctx, userId)
 }
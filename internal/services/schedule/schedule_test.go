@@ -2,11 +2,17 @@ package schedule
 
 import (
 	"context"
+	"encoding/json"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/clients/redpanda"
+	"github.com/hesoyamTM/apphelper-schedule/internal/lib/ical"
 	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
 	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -16,18 +22,23 @@ func TestCreateSchedule(t *testing.T) {
 	MockCalendarManager := &MockCalendarManager{}
 	MockScheduleStorage := &MockScheduleStorage{}
 	MockGroupStorage := &MockGroupStorage{}
-	MockRedpanda := &MockRedpanda{}
 
-	MockCalendarManager.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
-	MockScheduleStorage.On("CreateSchedule", mock.Anything, mock.Anything, mock.Anything).Return(nil)
-	MockRedpanda.On("ScheduleCreatedEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	scheduleId := uuid.New()
+
+	MockScheduleStorage.On("CreateCalendarJobs", mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CreateSchedule", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Schedule).Id = scheduleId
+	})
+	MockScheduleStorage.On("CreateSchedules", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
 
 	ctx, err := logger.New(context.Background(), "dev")
 	if err != nil {
 		t.Errorf("logger.New() error = %v", err)
 	}
 
-	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, MockRedpanda)
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
 
 	trainerId := uuid.New()
 	studentId := uuid.New()
@@ -46,23 +57,30 @@ func TestCreateSchedule(t *testing.T) {
 		t.Errorf("CreateSchedule() error = %v", err)
 	}
 
-	MockCalendarManager.AssertCalled(t, "CreateEvent", ctx, trainerId, groupId, mock.Anything)
-	MockCalendarManager.AssertCalled(t, "CreateEvent", ctx, studentId, groupId, mock.Anything)
-	MockScheduleStorage.AssertCalled(t, "CreateSchedule", ctx, sched)
-	MockRedpanda.AssertCalled(t, "ScheduleCreatedEvent", ctx, sched)
+	MockScheduleStorage.AssertCalled(t, "CreateSchedule", ctx, sched, changesMatching(func(jobs []*models.CalendarJob, messages []*models.OutboxMessage) bool {
+		return len(jobs) == 2 &&
+			hasCalendarJob(jobs, trainerId, models.CalendarOperationCreate, scheduleId) &&
+			hasCalendarJob(jobs, studentId, models.CalendarOperationCreate, scheduleId) &&
+			len(messages) == 1 && messages[0].Topic == "schedule.schedule.created" &&
+			payloadScheduleId(messages[0]) == scheduleId
+	}))
 }
 
 func TestCreateScheduleForGroup(t *testing.T) {
 	MockCalendarManager := &MockCalendarManager{}
 	MockScheduleStorage := &MockScheduleStorage{}
 	MockGroupStorage := &MockGroupStorage{}
-	MockRedpanda := &MockRedpanda{}
 
 	studentId := uuid.New()
+	scheduleId := uuid.New()
 
-	MockCalendarManager.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CreateCalendarJobs", mock.Anything, mock.Anything).Return(nil)
 	MockScheduleStorage.On("CreateSchedule", mock.Anything, mock.Anything, mock.Anything).Return(nil)
-	MockRedpanda.On("ScheduleCreatedEvent", mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CreateSchedules", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).([]*models.Schedule)[0].Id = scheduleId
+	})
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
 	MockGroupStorage.On("ProvideGroup", mock.Anything, mock.Anything).Return(&models.Group{
 		Students: []uuid.UUID{studentId},
 	}, nil)
@@ -72,7 +90,7 @@ func TestCreateScheduleForGroup(t *testing.T) {
 		t.Errorf("logger.New() error = %v", err)
 	}
 
-	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, MockRedpanda)
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
 
 	groupId := uuid.New()
 	trainerId := uuid.New()
@@ -89,22 +107,203 @@ func TestCreateScheduleForGroup(t *testing.T) {
 		t.Errorf("CreateScheduleForGroup() error = %v", err)
 	}
 
-	MockCalendarManager.AssertCalled(t, "CreateEvent", ctx, trainerId, groupId, mock.Anything)
-	MockCalendarManager.AssertCalled(t, "CreateEvent", ctx, studentId, groupId, mock.Anything)
-	MockScheduleStorage.AssertCalled(t, "CreateSchedule", ctx, sched)
-	MockRedpanda.AssertCalled(t, "ScheduleCreatedEvent", ctx, sched)
+	// one created event per stored schedule, with its id
+	MockScheduleStorage.AssertCalled(t, "CreateSchedules", ctx, mock.MatchedBy(func(scheds []*models.Schedule) bool {
+		return len(scheds) == 1 && scheds[0].StudentId == studentId && scheds[0].TrainerId == trainerId
+	}), changesMatching(func(jobs []*models.CalendarJob, messages []*models.OutboxMessage) bool {
+		return len(jobs) == 2 &&
+			hasCalendarJob(jobs, trainerId, models.CalendarOperationCreate, scheduleId) &&
+			hasCalendarJob(jobs, studentId, models.CalendarOperationCreate, scheduleId) &&
+			len(messages) == 1 && payloadScheduleId(messages[0]) == scheduleId
+	}))
+}
+
+func TestCreateScheduleForGroupDefaultDuration(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+	MockScheduleStorage.On("CreateCalendarJobs", mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CreateSchedules", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+	MockGroupStorage.On("ProvideGroup", mock.Anything, mock.Anything).Return(&models.Group{
+		Students:        []uuid.UUID{uuid.New()},
+		DefaultDuration: 90 * time.Minute,
+	}, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	sched := &models.Schedule{
+		Title:     "test",
+		Start:     start,
+		TrainerId: uuid.New(),
+	}
+
+	if err := s.CreateScheduleForGroup(ctx, uuid.New(), sched); err != nil {
+		t.Errorf("CreateScheduleForGroup() error = %v", err)
+	}
+
+	MockScheduleStorage.AssertCalled(t, "CreateSchedules", ctx, mock.MatchedBy(func(scheds []*models.Schedule) bool {
+		return len(scheds) == 1 && scheds[0].End.Equal(start.Add(90*time.Minute))
+	}), mock.Anything)
+}
+
+func TestCreateScheduleInvalidTime(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+	groupId := uuid.New()
+	defaultGroupId := uuid.New()
+
+	MockScheduleStorage.On("CreateCalendarJobs", mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CreateSchedule", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+	// a group without a default duration
+	MockGroupStorage.On("ProvideGroup", mock.Anything, groupId).Return(&models.Group{
+		Id:       groupId,
+		Students: []uuid.UUID{uuid.New()},
+	}, nil)
+	MockGroupStorage.On("ProvideGroup", mock.Anything, defaultGroupId).Return(&models.Group{
+		Id:              defaultGroupId,
+		Students:        []uuid.UUID{uuid.New()},
+		DefaultDuration: time.Hour,
+	}, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	// no end and no default duration
+	err = s.CreateScheduleForGroup(ctx, groupId, &models.Schedule{Title: "test", Start: start, TrainerId: uuid.New()})
+	require.ErrorIs(t, err, ErrInvalidTime)
+
+	err = s.CreateSchedule(ctx, &models.Schedule{Title: "test", Start: start, TrainerId: uuid.New(), StudentId: uuid.New(), GroupId: groupId})
+	require.ErrorIs(t, err, ErrInvalidTime)
+
+	err = s.CreateSchedule(ctx, &models.Schedule{Title: "test", Start: start, End: start.Add(-time.Hour), TrainerId: uuid.New(), StudentId: uuid.New()})
+	require.ErrorIs(t, err, ErrInvalidTime)
+
+	MockScheduleStorage.AssertNotCalled(t, "CreateSchedule", mock.Anything, mock.Anything, mock.Anything)
+	MockScheduleStorage.AssertNotCalled(t, "CreateSchedules", mock.Anything, mock.Anything, mock.Anything)
+
+	// the default duration of the group applies to a single lesson as well
+	sched := &models.Schedule{Title: "test", Start: start, TrainerId: uuid.New(), StudentId: uuid.New(), GroupId: defaultGroupId}
+	require.NoError(t, s.CreateSchedule(ctx, sched))
+	require.True(t, sched.End.Equal(start.Add(time.Hour)))
+}
+
+func TestCreateScheduleForGroupSharedCalendar(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+
+	MockScheduleStorage.On("CreateCalendarJobs", mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CreateSchedules", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+	MockGroupStorage.On("ProvideGroup", mock.Anything, mock.Anything).Return(&models.Group{
+		Students:     []uuid.UUID{uuid.New(), uuid.New()},
+		CalendarMode: models.CalendarModeShared,
+	}, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	sched := &models.Schedule{
+		Title:     "test",
+		Start:     time.Now(),
+		End:       time.Now().Add(time.Hour),
+		TrainerId: trainerId,
+	}
+
+	if err := s.CreateScheduleForGroup(ctx, uuid.New(), sched); err != nil {
+		t.Errorf("CreateScheduleForGroup() error = %v", err)
+	}
+
+	MockScheduleStorage.AssertCalled(t, "CreateSchedules", ctx, mock.Anything, changesMatching(func(jobs []*models.CalendarJob, _ []*models.OutboxMessage) bool {
+		return len(jobs) == 1 && jobs[0].UserId == trainerId && len(jobs[0].ScheduleIds) == 2
+	}))
+}
+
+func TestCreateScheduleConflict(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	studentId := uuid.New()
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, uuid.Nil, studentId).Return([]*models.ScheduleSeries{
+		{
+			Id:        uuid.New(),
+			Start:     start.AddDate(0, 0, -7).Add(30 * time.Minute),
+			End:       start.AddDate(0, 0, -7).Add(90 * time.Minute),
+			TrainerId: uuid.New(),
+			StudentId: studentId,
+			Recurrence: models.Recurrence{
+				Frequency: models.FrequencyWeekly,
+			},
+		},
+	}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	sched := &models.Schedule{
+		Title:     "test",
+		Start:     start,
+		End:       start.Add(time.Hour),
+		TrainerId: trainerId,
+		StudentId: studentId,
+	}
+
+	conflicts, err := s.CheckScheduleConflicts(ctx, sched)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(conflicts))
+	require.Equal(t, studentId, conflicts[0].UserId)
+
+	err = s.CreateSchedule(ctx, sched)
+	require.ErrorIs(t, err, ErrScheduleConflict)
+
+	MockScheduleStorage.AssertNotCalled(t, "CreateSchedule", mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestGetScheduleByTrainer(t *testing.T) {
 	MockCalendarManager := &MockCalendarManager{}
 	MockScheduleStorage := &MockScheduleStorage{}
 	MockGroupStorage := &MockGroupStorage{}
-	MockRedpanda := &MockRedpanda{}
 
 	groupId := uuid.New()
 	trainerId := uuid.New()
 
-	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.Anything, mock.Anything).Return([]*models.Schedule{
+	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.Anything).Return([]*models.Schedule{
 		{
 			Title:     "test",
 			Start:     time.Now(),
@@ -114,15 +313,16 @@ func TestGetScheduleByTrainer(t *testing.T) {
 			GroupId:   groupId,
 		},
 	}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
 
 	ctx, err := logger.New(context.Background(), "dev")
 	if err != nil {
 		t.Errorf("logger.New() error = %v", err)
 	}
 
-	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, MockRedpanda)
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
 
-	schedules, err := s.GetSchedules(ctx, groupId, trainerId, uuid.Nil)
+	schedules, _, err := s.GetSchedules(ctx, models.ScheduleFilter{GroupId: groupId, TrainerId: trainerId}, "")
 	if err != nil {
 		t.Errorf("GetSchedules() error = %v", err)
 	}
@@ -131,19 +331,20 @@ func TestGetScheduleByTrainer(t *testing.T) {
 	require.Equal(t, "test", schedules[0].Title)
 	require.Equal(t, trainerId, schedules[0].TrainerId)
 
-	MockScheduleStorage.AssertCalled(t, "ProvideSchedules", ctx, trainerId, mock.Anything)
+	MockScheduleStorage.AssertCalled(t, "ProvideSchedules", ctx, mock.MatchedBy(func(f models.ScheduleFilter) bool {
+		return f.TrainerId == trainerId && f.GroupId == groupId
+	}))
 }
 
 func TestGetScheduleByStudent(t *testing.T) {
 	MockCalendarManager := &MockCalendarManager{}
 	MockScheduleStorage := &MockScheduleStorage{}
 	MockGroupStorage := &MockGroupStorage{}
-	MockRedpanda := &MockRedpanda{}
 
 	groupId := uuid.New()
 	StudentId := uuid.New()
 
-	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.Anything, mock.Anything).Return([]*models.Schedule{
+	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.Anything).Return([]*models.Schedule{
 		{
 			Title:     "test",
 			Start:     time.Now(),
@@ -153,15 +354,16 @@ func TestGetScheduleByStudent(t *testing.T) {
 			GroupId:   groupId,
 		},
 	}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
 
 	ctx, err := logger.New(context.Background(), "dev")
 	if err != nil {
 		t.Errorf("logger.New() error = %v", err)
 	}
 
-	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, MockRedpanda)
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
 
-	schedules, err := s.GetSchedules(ctx, groupId, uuid.Nil, StudentId)
+	schedules, _, err := s.GetSchedules(ctx, models.ScheduleFilter{GroupId: groupId, StudentId: StudentId}, "")
 	if err != nil {
 		t.Errorf("GetSchedules() error = %v", err)
 	}
@@ -170,20 +372,21 @@ func TestGetScheduleByStudent(t *testing.T) {
 	require.Equal(t, "test", schedules[0].Title)
 	require.Equal(t, StudentId, schedules[0].StudentId)
 
-	MockScheduleStorage.AssertCalled(t, "ProvideSchedules", ctx, mock.Anything, StudentId)
+	MockScheduleStorage.AssertCalled(t, "ProvideSchedules", ctx, mock.MatchedBy(func(f models.ScheduleFilter) bool {
+		return f.StudentId == StudentId && f.GroupId == groupId
+	}))
 }
 
 func TestGetScheduleByTrainerAndStudent(t *testing.T) {
 	MockCalendarManager := &MockCalendarManager{}
 	MockScheduleStorage := &MockScheduleStorage{}
 	MockGroupStorage := &MockGroupStorage{}
-	MockRedpanda := &MockRedpanda{}
 
 	groupId := uuid.New()
 	StudentId := uuid.New()
 	TrainerId := uuid.New()
 
-	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.Anything, mock.Anything).Return([]*models.Schedule{
+	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.Anything).Return([]*models.Schedule{
 		{
 			Title:     "test",
 			Start:     time.Now(),
@@ -193,15 +396,16 @@ func TestGetScheduleByTrainerAndStudent(t *testing.T) {
 			GroupId:   groupId,
 		},
 	}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
 
 	ctx, err := logger.New(context.Background(), "dev")
 	if err != nil {
 		t.Errorf("logger.New() error = %v", err)
 	}
 
-	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, MockRedpanda)
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
 
-	schedules, err := s.GetSchedules(ctx, groupId, TrainerId, StudentId)
+	schedules, _, err := s.GetSchedules(ctx, models.ScheduleFilter{GroupId: groupId, TrainerId: TrainerId, StudentId: StudentId}, "")
 	if err != nil {
 		t.Errorf("GetSchedules() error = %v", err)
 	}
@@ -211,30 +415,1331 @@ func TestGetScheduleByTrainerAndStudent(t *testing.T) {
 	require.Equal(t, StudentId, schedules[0].StudentId)
 	require.Equal(t, TrainerId, schedules[0].TrainerId)
 
-	MockScheduleStorage.AssertCalled(t, "ProvideSchedules", ctx, TrainerId, StudentId)
+	MockScheduleStorage.AssertCalled(t, "ProvideSchedules", ctx, mock.MatchedBy(func(f models.ScheduleFilter) bool {
+		return f.TrainerId == TrainerId && f.StudentId == StudentId
+	}))
 }
 
-func TestDeleteSchedule(t *testing.T) {
+func TestGetSchedulesPagination(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.MatchedBy(func(f models.ScheduleFilter) bool { return f.After == nil })).Return([]*models.Schedule{
+		{Id: uuid.New(), Title: "single", Start: start.Add(time.Hour), End: start.Add(2 * time.Hour), TrainerId: trainerId},
+	}, nil)
+	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.Anything).Return([]*models.Schedule{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{
+		{
+			Id:         uuid.New(),
+			Title:      "daily",
+			Start:      start,
+			End:        start.Add(time.Hour),
+			TrainerId:  trainerId,
+			Recurrence: models.Recurrence{Frequency: models.FrequencyDaily},
+		},
+	}, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	filter := models.ScheduleFilter{TrainerId: trainerId, From: start, To: start.AddDate(0, 0, 7), Limit: 2}
+
+	schedules, token, err := s.GetSchedules(ctx, filter, "")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(schedules))
+	require.Equal(t, "daily", schedules[0].Title)
+	require.Equal(t, "single", schedules[1].Title)
+	require.NotEmpty(t, token)
+
+	schedules, _, err = s.GetSchedules(ctx, filter, token)
+	require.NoError(t, err)
+	require.Equal(t, start.AddDate(0, 0, 1), schedules[0].Start)
+
+	MockScheduleStorage.AssertCalled(t, "ProvideSchedules", ctx, mock.MatchedBy(func(f models.ScheduleFilter) bool {
+		return f.Limit == 3 && f.After != nil && f.After.Start.Equal(start.Add(time.Hour))
+	}))
+
+	_, _, err = s.GetSchedules(ctx, filter, "not a token")
+	require.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestUpdateScheduleGroupLesson(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	groupId := uuid.New()
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+	// the lesson is stored once for each of its three students
+	lesson := make([]*models.Schedule, 3)
+	conflicts := make([]*models.Conflict, 3)
+	for i := range lesson {
+		lesson[i] = &models.Schedule{
+			Id:        uuid.New(),
+			Title:     "test",
+			Start:     start,
+			End:       start.Add(time.Hour),
+			TrainerId: trainerId,
+			StudentId: uuid.New(),
+			GroupId:   groupId,
+		}
+		conflicts[i] = &models.Conflict{UserId: trainerId, Schedule: lesson[i]}
+	}
+
+	MockScheduleStorage.On("ProvideSchedule", mock.Anything, lesson[0].Id).Return(lesson[0], nil)
+	MockGroupStorage.On("ProvideGroup", mock.Anything, groupId).Return(&models.Group{Id: groupId, TrainerId: trainerId}, nil)
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(conflicts, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+	MockScheduleStorage.On("UpdateSchedule", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CreateCalendarJobs", mock.Anything, mock.Anything).Return(nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	require.NoError(t, s.UpdateSchedule(ctx, lesson[0].Id, trainerId, &models.Schedule{Title: "renamed"}))
+	require.NoError(t, s.UpdateSchedule(ctx, lesson[0].Id, trainerId, &models.Schedule{Start: start.Add(30 * time.Minute)}))
+
+	MockScheduleStorage.AssertCalled(t, "UpdateSchedule", ctx, mock.MatchedBy(func(sched *models.Schedule) bool {
+		return sched.Id == lesson[0].Id && sched.Title == "renamed"
+	}), mock.Anything, mock.Anything)
+	MockScheduleStorage.AssertNumberOfCalls(t, "UpdateSchedule", 2)
+
+	// the moved student gets an event of the trainer of its own, the shared event is left to the others
+	MockScheduleStorage.AssertCalled(t, "UpdateSchedule", ctx, mock.MatchedBy(func(sched *models.Schedule) bool {
+		return sched.Start.Equal(start.Add(30 * time.Minute))
+	}), mock.MatchedBy(func(jobs []*models.CalendarJob) bool {
+		return len(jobs) == 3 &&
+			jobs[0].UserId == trainerId && jobs[0].Operation == models.CalendarOperationRemove &&
+			jobs[1].UserId == trainerId && jobs[1].Operation == models.CalendarOperationCreate &&
+			slices.Equal(jobs[1].ScheduleIds, []uuid.UUID{lesson[0].Id}) &&
+			!hasCalendarJob(jobs, trainerId, models.CalendarOperationUpdate, lesson[0].Id)
+	}), mock.Anything)
+
+	// changing only the student leaves the shared event in place
+	require.NoError(t, s.UpdateSchedule(ctx, lesson[0].Id, trainerId, &models.Schedule{StudentId: uuid.New()}))
+	MockScheduleStorage.AssertCalled(t, "UpdateSchedule", ctx, mock.Anything, mock.MatchedBy(func(jobs []*models.CalendarJob) bool {
+		return hasCalendarJob(jobs, trainerId, models.CalendarOperationUpdate, lesson[0].Id) &&
+			!hasCalendarJob(jobs, trainerId, models.CalendarOperationRemove, lesson[0].Id)
+	}), mock.Anything)
+
+	// a lesson of another group of the trainer is still a conflict
+	other := *lesson[1]
+	other.Id = uuid.New()
+	other.GroupId = uuid.New()
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Unset()
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(append(conflicts, &models.Conflict{
+		UserId:   trainerId,
+		Schedule: &other,
+	}), nil)
+
+	err = s.UpdateSchedule(ctx, lesson[0].Id, trainerId, &models.Schedule{Title: "renamed again"})
+	require.ErrorIs(t, err, ErrScheduleConflict)
+}
+
+func TestUpdateSchedule(t *testing.T) {
 	MockCalendarManager := &MockCalendarManager{}
 	MockScheduleStorage := &MockScheduleStorage{}
 	MockGroupStorage := &MockGroupStorage{}
-	MockRedpanda := &MockRedpanda{}
 
+	scheduleId := uuid.New()
+	trainerId := uuid.New()
+	oldStudentId := uuid.New()
+	newStudentId := uuid.New()
 	groupId := uuid.New()
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+	before := &models.Schedule{
+		Id:        scheduleId,
+		Title:     "test",
+		Start:     start,
+		End:       start.Add(time.Hour),
+		TrainerId: trainerId,
+		StudentId: oldStudentId,
+		GroupId:   groupId,
+	}
+
+	MockScheduleStorage.On("ProvideSchedule", mock.Anything, scheduleId).Return(before, nil)
+	MockGroupStorage.On("ProvideGroup", mock.Anything, groupId).Return(&models.Group{Id: groupId, TrainerId: trainerId}, nil)
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{
+		{UserId: trainerId, Schedule: before},
+	}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+	MockScheduleStorage.On("UpdateSchedule", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CreateCalendarJobs", mock.Anything, mock.Anything).Return(nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	err = s.UpdateSchedule(ctx, scheduleId, trainerId, &models.Schedule{
+		Start:     start.Add(30 * time.Minute),
+		StudentId: newStudentId,
+	})
+	require.NoError(t, err)
+
+	MockScheduleStorage.AssertCalled(t, "UpdateSchedule", ctx, mock.MatchedBy(func(sched *models.Schedule) bool {
+		return sched.Start.Equal(start.Add(30*time.Minute)) && sched.End.Equal(start.Add(90*time.Minute)) && sched.StudentId == newStudentId
+	}), mock.MatchedBy(func(jobs []*models.CalendarJob) bool {
+		return len(jobs) == 3 &&
+			hasCalendarJob(jobs, trainerId, models.CalendarOperationUpdate, scheduleId) &&
+			hasCalendarJob(jobs, oldStudentId, models.CalendarOperationRemove, scheduleId) &&
+			hasCalendarJob(jobs, newStudentId, models.CalendarOperationCreate, scheduleId)
+	}), mock.MatchedBy(func(messages []*models.OutboxMessage) bool {
+		if len(messages) != 1 {
+			return false
+		}
+
+		var e redpanda.ScheduleUpdatedEvent
+		if err := json.Unmarshal(messages[0].Payload, &e); err != nil {
+			return false
+		}
+		return e.Before.Start.Equal(start) && e.After.Start.Equal(start.Add(30*time.Minute))
+	}))
+
+	err = s.UpdateSchedule(ctx, scheduleId, uuid.New(), &models.Schedule{Title: "other"})
+	require.ErrorIs(t, err, ErrScheduleNotFound)
+}
+
+func TestDeleteSchedule(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	scheduleId := uuid.New()
 	trainerId := uuid.New()
 
-	MockScheduleStorage.On("DeleteSchedule", mock.Anything, groupId, trainerId).Return(nil)
+	MockScheduleStorage.On("CancelSchedule", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	studentId := uuid.New()
+
+	MockScheduleStorage.On("ProvideSchedule", mock.Anything, scheduleId).Return(&models.Schedule{
+		Id:        scheduleId,
+		TrainerId: trainerId,
+		StudentId: studentId,
+	}, nil)
+	MockScheduleStorage.On("CreateCalendarJobs", mock.Anything, mock.Anything).Return(nil)
 
 	ctx, err := logger.New(context.Background(), "dev")
 	if err != nil {
 		t.Errorf("logger.New() error = %v", err)
 	}
 
-	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, MockRedpanda)
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
 
-	if err := s.DeleteSchedule(ctx, groupId, trainerId); err != nil {
+	if err := s.DeleteSchedule(ctx, scheduleId, trainerId); err != nil {
 		t.Errorf("DeleteSchedule() error = %v", err)
 	}
 
-	MockScheduleStorage.AssertCalled(t, "DeleteSchedule", ctx, groupId, trainerId)
+	MockScheduleStorage.AssertCalled(t, "CancelSchedule", ctx, mock.MatchedBy(func(sched *models.Schedule) bool {
+		return sched.Id == scheduleId && sched.CancelledBy == trainerId && !sched.CancelledAt.IsZero()
+	}), mock.MatchedBy(func(jobs []*models.CalendarJob) bool {
+		return len(jobs) == 2 &&
+			hasCalendarJob(jobs, trainerId, models.CalendarOperationRemove, scheduleId) &&
+			hasCalendarJob(jobs, studentId, models.CalendarOperationRemove, scheduleId)
+	}), mock.MatchedBy(func(messages []*models.OutboxMessage) bool {
+		return len(messages) == 1 && messages[0].Topic == "schedule.schedule.cancelled"
+	}))
+}
+
+func TestCancelSchedule(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	studentId := uuid.New()
+
+	soon := &models.Schedule{Id: uuid.New(), TrainerId: trainerId, StudentId: studentId, Start: time.Now().Add(time.Hour)}
+	later := &models.Schedule{Id: uuid.New(), TrainerId: trainerId, StudentId: studentId, Start: time.Now().Add(72 * time.Hour)}
+	cancelled := &models.Schedule{Id: uuid.New(), TrainerId: trainerId, StudentId: studentId, Start: later.Start, CancelledAt: time.Now()}
+
+	MockScheduleStorage.On("ProvideSchedule", mock.Anything, soon.Id).Return(soon, nil)
+	MockScheduleStorage.On("ProvideSchedule", mock.Anything, later.Id).Return(later, nil)
+	MockScheduleStorage.On("ProvideSchedule", mock.Anything, cancelled.Id).Return(cancelled, nil)
+	MockScheduleStorage.On("CancelSchedule", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CreateCalendarJobs", mock.Anything, mock.Anything).Return(nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{CancellationNotice: 24 * time.Hour})
+
+	err = s.CancelSchedule(ctx, soon.Id, studentId, "ill")
+	require.ErrorIs(t, err, ErrTooLateToCancel)
+
+	err = s.CancelSchedule(ctx, later.Id, uuid.New(), "")
+	require.ErrorIs(t, err, ErrScheduleNotFound)
+
+	err = s.CancelSchedule(ctx, cancelled.Id, trainerId, "")
+	require.ErrorIs(t, err, ErrScheduleCancelled)
+
+	require.NoError(t, s.CancelSchedule(ctx, later.Id, studentId, "ill"))
+	require.Equal(t, studentId, later.CancelledBy)
+	require.Equal(t, "ill", later.CancelReason)
+
+	require.NoError(t, s.CancelSchedule(ctx, soon.Id, trainerId, "trainer is away"))
+	MockScheduleStorage.AssertNumberOfCalls(t, "CancelSchedule", 2)
+}
+
+func TestGroupRoles(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	coTrainerId := uuid.New()
+	assistantId := uuid.New()
+	studentId := uuid.New()
+	groupId := uuid.New()
+
+	lesson := &models.Schedule{
+		Id:        uuid.New(),
+		Title:     "test",
+		Start:     time.Now().Add(time.Hour),
+		End:       time.Now().Add(2 * time.Hour),
+		GroupId:   groupId,
+		TrainerId: trainerId,
+		StudentId: studentId,
+	}
+
+	MockGroupStorage.On("ProvideGroup", mock.Anything, groupId).Return(&models.Group{
+		Id:        groupId,
+		TrainerId: trainerId,
+		Students:  []uuid.UUID{studentId},
+		Staff: []*models.GroupMember{
+			{UserId: coTrainerId, Role: models.RoleCoTrainer},
+			{UserId: assistantId, Role: models.RoleAssistant},
+		},
+	}, nil)
+	MockScheduleStorage.On("ProvideSchedule", mock.Anything, lesson.Id).Return(lesson, nil)
+	MockScheduleStorage.On("SetAttendance", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CancelSchedule", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CreateCalendarJobs", mock.Anything, mock.Anything).Return(nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	_, err = s.MarkAttendance(ctx, assistantId, lesson.Id, models.AttendanceAttended, "")
+	require.NoError(t, err)
+
+	err = s.DeleteSchedule(ctx, lesson.Id, assistantId)
+	require.ErrorIs(t, err, ErrScheduleNotFound)
+
+	require.NoError(t, s.DeleteSchedule(ctx, lesson.Id, coTrainerId))
+	require.Equal(t, coTrainerId, lesson.CancelledBy)
+}
+
+func TestMemberSeries(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	studentId := uuid.New()
+	groupId := uuid.New()
+	now := time.Now()
+
+	weekly := models.Recurrence{Frequency: models.FrequencyWeekly}
+	started := &models.ScheduleSeries{Id: uuid.New(), GroupId: groupId, TrainerId: trainerId, StudentId: studentId, Start: now.AddDate(0, 0, -14), End: now.AddDate(0, 0, -14).Add(time.Hour), Recurrence: weekly}
+	ended := &models.ScheduleSeries{Id: uuid.New(), GroupId: groupId, TrainerId: trainerId, StudentId: studentId, Start: now.AddDate(0, 0, -14), End: now.AddDate(0, 0, -14).Add(time.Hour), Recurrence: models.Recurrence{Frequency: models.FrequencyWeekly, Count: 1}}
+	planned := &models.ScheduleSeries{Id: uuid.New(), GroupId: groupId, TrainerId: trainerId, StudentId: studentId, Start: now.AddDate(0, 0, 7), End: now.AddDate(0, 0, 7).Add(time.Hour), Recurrence: weekly}
+	otherGroup := &models.ScheduleSeries{Id: uuid.New(), GroupId: uuid.New(), TrainerId: trainerId, StudentId: studentId, Start: now.AddDate(0, 0, 7), End: now.AddDate(0, 0, 7).Add(time.Hour), Recurrence: weekly}
+
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, uuid.Nil, studentId).Return([]*models.ScheduleSeries{started, ended, planned, otherGroup}, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	truncated, deleted, err := s.MemberSeries(ctx, groupId, studentId, now)
+	require.NoError(t, err)
+
+	require.Equal(t, []*models.ScheduleSeries{started}, truncated)
+	require.False(t, started.Recurrence.Until.IsZero())
+	require.False(t, started.Recurrence.Until.After(now))
+	require.Equal(t, []*models.ScheduleSeries{planned}, deleted)
+
+	// nothing is stored, the series are ended by groups.removeMember
+	MockScheduleStorage.AssertNotCalled(t, "SplitScheduleSeries", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	MockScheduleStorage.AssertNotCalled(t, "DeleteScheduleSeries", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetSchedulesExpandsSeries(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC) // Monday
+
+	series := &models.ScheduleSeries{
+		Id:        uuid.New(),
+		Title:     "test",
+		Start:     start,
+		End:       start.Add(time.Hour),
+		TrainerId: trainerId,
+		StudentId: uuid.New(),
+		GroupId:   uuid.New(),
+		Recurrence: models.Recurrence{
+			Frequency:  models.FrequencyWeekly,
+			ByDay:      []time.Weekday{time.Monday, time.Wednesday},
+			Count:      5,
+			Exceptions: []time.Time{start.AddDate(0, 0, 7)},
+		},
+	}
+
+	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.Anything).Return([]*models.Schedule{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{series}, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	schedules, _, err := s.GetSchedules(ctx, models.ScheduleFilter{TrainerId: trainerId, From: start, To: start.AddDate(0, 1, 0)}, "")
+	if err != nil {
+		t.Errorf("GetSchedules() error = %v", err)
+	}
+
+	// Mar 3, Mar 5, (Mar 10 excluded), Mar 12, Mar 17
+	require.Equal(t, 4, len(schedules))
+	require.Equal(t, start, schedules[0].Start)
+	require.Equal(t, start.AddDate(0, 0, 2), schedules[1].Start)
+	require.Equal(t, start.AddDate(0, 0, 9), schedules[2].Start)
+	require.Equal(t, start.AddDate(0, 0, 14), schedules[3].Start)
+	require.Equal(t, series.Id, schedules[0].SeriesId)
+	require.Equal(t, start.AddDate(0, 0, 14).Add(time.Hour), schedules[3].End)
+}
+
+func TestSeriesKeepsWallClockAcrossDST(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	// Monday 10:00 in Berlin, stored as UTC; clocks move forward on Mar 30
+	start := time.Date(2025, time.March, 24, 9, 0, 0, 0, time.UTC)
+
+	series := &models.ScheduleSeries{
+		Id:         uuid.New(),
+		Title:      "test",
+		Start:      start,
+		End:        start.Add(time.Hour),
+		TrainerId:  trainerId,
+		StudentId:  uuid.New(),
+		GroupId:    uuid.New(),
+		Recurrence: models.Recurrence{Frequency: models.FrequencyWeekly, Count: 2},
+		TimeZone:   "Europe/Berlin",
+	}
+
+	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.Anything).Return([]*models.Schedule{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{series}, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	schedules, _, err := s.GetSchedules(ctx, models.ScheduleFilter{TrainerId: trainerId, From: start, To: start.AddDate(0, 1, 0)}, "")
+	require.NoError(t, err)
+	require.Len(t, schedules, 2)
+	require.True(t, start.Equal(schedules[0].Start))
+	require.True(t, time.Date(2025, time.March, 31, 8, 0, 0, 0, time.UTC).Equal(schedules[1].Start))
+
+	err = s.CreateScheduleSeries(ctx, &models.ScheduleSeries{
+		Start:      start,
+		End:        start.Add(time.Hour),
+		TrainerId:  trainerId,
+		Recurrence: models.Recurrence{Frequency: models.FrequencyWeekly},
+		TimeZone:   "Mars/Olympus",
+	})
+	require.ErrorIs(t, err, ErrInvalidTimeZone)
+}
+
+func TestUpdateScheduleSeriesFollowing(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	seriesId := uuid.New()
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+	MockScheduleStorage.On("ProvideLessonSeries", mock.Anything, seriesId).Return([]*models.ScheduleSeries{{
+		Id:        seriesId,
+		Title:     "test",
+		Start:     start,
+		End:       start.Add(time.Hour),
+		TrainerId: trainerId,
+		Recurrence: models.Recurrence{
+			Frequency: models.FrequencyDaily,
+			Count:     10,
+		},
+	}}, nil)
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+	MockScheduleStorage.On("SplitScheduleSeries", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	at := start.AddDate(0, 0, 4)
+	if err := s.UpdateScheduleSeries(ctx, trainerId, seriesId, at, models.ScopeFollowing, &models.Schedule{
+		Start: at.Add(2 * time.Hour),
+	}); err != nil {
+		t.Errorf("UpdateScheduleSeries() error = %v", err)
+	}
+
+	call := MockScheduleStorage.Calls[len(MockScheduleStorage.Calls)-1]
+	old := call.Arguments.Get(1).([]*models.ScheduleSeries)[0]
+	next := call.Arguments.Get(2).([]*models.ScheduleSeries)[0]
+
+	require.Equal(t, 4, old.Recurrence.Count)
+	require.Equal(t, 6, next.Recurrence.Count)
+	require.Equal(t, at.Add(2*time.Hour), next.Start)
+	require.Equal(t, at.Add(3*time.Hour), next.End)
+	require.Equal(t, at, call.Arguments.Get(3))
+}
+
+func TestCreateScheduleSeriesConflict(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	studentId := uuid.New()
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+	// the student has another lesson at the time of the third occurrence
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{
+		{
+			UserId: studentId,
+			Schedule: &models.Schedule{
+				Id:        uuid.New(),
+				Start:     start.AddDate(0, 0, 14).Add(30 * time.Minute),
+				End:       start.AddDate(0, 0, 14).Add(90 * time.Minute),
+				TrainerId: uuid.New(),
+				StudentId: studentId,
+			},
+		},
+	}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+	MockScheduleStorage.On("CreateScheduleSeries", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	err = s.CreateScheduleSeries(ctx, &models.ScheduleSeries{
+		Title:      "test",
+		Start:      start,
+		End:        start.Add(time.Hour),
+		TrainerId:  trainerId,
+		StudentId:  studentId,
+		Recurrence: models.Recurrence{Frequency: models.FrequencyWeekly},
+		TimeZone:   "UTC",
+	})
+	require.ErrorIs(t, err, ErrScheduleConflict)
+	MockScheduleStorage.AssertNotCalled(t, "CreateScheduleSeries", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateScheduleSeriesForGroup(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	groupId := uuid.New()
+	students := []uuid.UUID{uuid.New(), uuid.New()}
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+	MockScheduleStorage.On("CreateScheduleSeries", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+	MockGroupStorage.On("ProvideGroup", mock.Anything, groupId).Return(&models.Group{
+		Id:       groupId,
+		Students: students,
+	}, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	err = s.CreateScheduleSeriesForGroup(ctx, groupId, &models.ScheduleSeries{
+		Title:      "test",
+		Start:      start,
+		End:        start.Add(time.Hour),
+		TrainerId:  uuid.New(),
+		Recurrence: models.Recurrence{Frequency: models.FrequencyWeekly, Count: 4},
+		TimeZone:   "UTC",
+	})
+	require.NoError(t, err)
+
+	// the series of all students are stored together
+	MockScheduleStorage.AssertNumberOfCalls(t, "CreateScheduleSeries", 1)
+	MockScheduleStorage.AssertCalled(t, "CreateScheduleSeries", ctx, mock.MatchedBy(func(series []*models.ScheduleSeries) bool {
+		return len(series) == 2 && series[0].StudentId == students[0] && series[1].StudentId == students[1] &&
+			series[0].GroupId == groupId && series[1].GroupId == groupId &&
+			series[0].LessonId != uuid.Nil && series[0].LessonId == series[1].LessonId
+	}), mock.Anything, messagesMatching(func(messages []*models.OutboxMessage) bool {
+		return len(messages) == 2
+	}))
+
+	// series are published by the iCalendar feed, not written to the calendars
+	MockScheduleStorage.AssertNotCalled(t, "CreateCalendarJobs", mock.Anything, mock.Anything)
+}
+
+func TestUpdateGroupScheduleSeries(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	groupId := uuid.New()
+	lessonId := uuid.New()
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+	// a group lesson of two students, stored as a series per student
+	lesson := make([]*models.ScheduleSeries, 2)
+	for i := range lesson {
+		lesson[i] = &models.ScheduleSeries{
+			Id:         uuid.New(),
+			Title:      "group",
+			Start:      start,
+			End:        start.Add(time.Hour),
+			GroupId:    groupId,
+			TrainerId:  trainerId,
+			StudentId:  uuid.New(),
+			LessonId:   lessonId,
+			Recurrence: models.Recurrence{Frequency: models.FrequencyWeekly},
+		}
+	}
+
+	MockScheduleStorage.On("ProvideLessonSeries", mock.Anything, lesson[0].Id).Return(lesson, nil)
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, trainerId, uuid.Nil).Return(lesson, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+	MockScheduleStorage.On("UpdateScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("DetachOccurrences", mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("AddSeriesException", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	// the series of the other student shares the trainer and the times, it is not a conflict
+	err = s.UpdateScheduleSeries(ctx, trainerId, lesson[0].Id, start, models.ScopeSeries, &models.Schedule{Title: "renamed"})
+	require.NoError(t, err)
+	MockScheduleStorage.AssertCalled(t, "UpdateScheduleSeries", mock.Anything, mock.MatchedBy(func(series []*models.ScheduleSeries) bool {
+		return len(series) == 2 && series[0].Title == "renamed" && series[1].Title == "renamed"
+	}), mock.Anything)
+
+	at := start.AddDate(0, 0, 7)
+	err = s.UpdateScheduleSeries(ctx, trainerId, lesson[0].Id, at, models.ScopeOccurrence, &models.Schedule{Start: at.Add(time.Hour)})
+	require.NoError(t, err)
+	MockScheduleStorage.AssertCalled(t, "DetachOccurrences", mock.Anything, mock.MatchedBy(func(scheds []*models.Schedule) bool {
+		return len(scheds) == 2 &&
+			scheds[0].SeriesId == lesson[0].Id && scheds[0].StudentId == lesson[0].StudentId &&
+			scheds[1].SeriesId == lesson[1].Id && scheds[1].StudentId == lesson[1].StudentId &&
+			scheds[0].Start.Equal(at.Add(time.Hour)) && scheds[1].Start.Equal(at.Add(time.Hour))
+	}))
+
+	require.NoError(t, s.CancelScheduleSeries(ctx, trainerId, lesson[0].Id, at, models.ScopeOccurrence))
+	MockScheduleStorage.AssertCalled(t, "AddSeriesException", mock.Anything, []uuid.UUID{lesson[0].Id, lesson[1].Id}, trainerId, at)
+
+	MockScheduleStorage.AssertNotCalled(t, "CreateCalendarJobs", mock.Anything, mock.Anything)
+}
+
+func TestCancelScheduleSeriesRejectsUnknownOccurrence(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	seriesId := uuid.New()
+	start := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+	MockScheduleStorage.On("ProvideLessonSeries", mock.Anything, seriesId).Return([]*models.ScheduleSeries{{
+		Id:        seriesId,
+		Start:     start,
+		End:       start.Add(time.Hour),
+		TrainerId: trainerId,
+		Recurrence: models.Recurrence{
+			Frequency: models.FrequencyWeekly,
+		},
+	}}, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	err = s.CancelScheduleSeries(ctx, trainerId, seriesId, start.AddDate(0, 0, 1), models.ScopeOccurrence)
+	require.ErrorIs(t, err, ErrNotAnOccurrence)
+
+	MockScheduleStorage.AssertNotCalled(t, "AddSeriesException", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExportFeed(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	groupId := uuid.New()
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+
+	lesson := &models.Schedule{
+		Id:        uuid.New(),
+		Title:     "group lesson",
+		Start:     start,
+		End:       start.Add(time.Hour),
+		GroupId:   groupId,
+		TrainerId: trainerId,
+		StudentId: uuid.New(),
+		LessonId:  uuid.New(),
+	}
+	// the same group lesson of another student
+	other := *lesson
+	other.Id = uuid.New()
+	other.StudentId = uuid.New()
+
+	var tokenHash string
+	MockScheduleStorage.On("SetFeedToken", mock.Anything, trainerId, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		tokenHash = args.String(2)
+	})
+	MockScheduleStorage.On("ProvideFeedUser", mock.Anything, mock.MatchedBy(func(hash string) bool {
+		return hash == tokenHash
+	})).Return(trainerId, nil)
+	MockScheduleStorage.On("ProvideFeedUser", mock.Anything, mock.Anything).Return(uuid.Nil, storage.ErrFeedNotFound)
+	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.MatchedBy(func(filter models.ScheduleFilter) bool {
+		return filter.TrainerId == trainerId
+	})).Return([]*models.Schedule{lesson, &other}, nil)
+	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.Anything).Return([]*models.Schedule{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	token, err := s.RotateFeedToken(ctx, trainerId)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.NotEqual(t, token, tokenHash)
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	data, err := s.ExportFeed(ctx, token, uuid.Nil, loc)
+	require.NoError(t, err)
+
+	events, err := ical.Decode(data)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.True(t, strings.HasPrefix(events[0].EventId, lessonUID(lesson).String()))
+	require.True(t, start.Equal(events[0].Start))
+	require.Contains(t, string(data), "TZID:Europe/Berlin")
+
+	// the UID does not depend on which rows of the lesson are exported
+	data, err = s.ExportSchedules(ctx, trainerId, uuid.Nil, start.Add(-time.Hour), start.Add(time.Hour), loc)
+	require.NoError(t, err)
+	again, err := ical.Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, events[0].EventId, again[0].EventId)
+
+	// nor on the time of the lesson
+	moved := *lesson
+	moved.Start = start.Add(2 * time.Hour)
+	moved.End = start.Add(3 * time.Hour)
+	require.Equal(t, lessonUID(lesson), lessonUID(&moved))
+
+	_, err = s.ExportFeed(ctx, "unknown", uuid.Nil, loc)
+	require.ErrorIs(t, err, ErrFeedNotFound)
+}
+
+func TestExportSchedulesForGroup(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	group := &models.Group{
+		Id:        uuid.New(),
+		TrainerId: trainerId,
+		Students:  []uuid.UUID{uuid.New(), uuid.New()},
+	}
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+
+	lessonId := uuid.New()
+	lesson := make([]*models.Schedule, 0, len(group.Students))
+	for _, studentId := range group.Students {
+		lesson = append(lesson, &models.Schedule{
+			Id:        uuid.New(),
+			Title:     "group lesson",
+			Start:     start,
+			End:       start.Add(time.Hour),
+			GroupId:   group.Id,
+			TrainerId: trainerId,
+			StudentId: studentId,
+			LessonId:  lessonId,
+		})
+	}
+	// a lesson of another trainer at the same time is a separate event
+	other := *lesson[0]
+	other.Id = uuid.New()
+	other.TrainerId = uuid.New()
+	other.LessonId = uuid.New()
+
+	MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.MatchedBy(func(filter models.ScheduleFilter) bool {
+		return filter.TrainerId == trainerId && filter.GroupId == group.Id
+	})).Return([]*models.Schedule{lesson[0], lesson[1], &other}, nil)
+	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.MatchedBy(func(filter models.ScheduleFilter) bool {
+		return filter.StudentId == group.Students[1] && filter.GroupId == group.Id
+	})).Return([]*models.Schedule{lesson[1]}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	data, err := s.ExportSchedules(ctx, trainerId, group.Id, start.Add(-time.Hour), start.Add(time.Hour), time.UTC)
+	require.NoError(t, err)
+	events, err := ical.Decode(data)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	// students only get their own lessons, under the UID the trainer sees
+	data, err = s.ExportSchedules(ctx, group.Students[1], group.Id, start.Add(-time.Hour), start.Add(time.Hour), time.UTC)
+	require.NoError(t, err)
+	studentEvents, err := ical.Decode(data)
+	require.NoError(t, err)
+	require.Len(t, studentEvents, 1)
+	require.True(t, slices.ContainsFunc(events, func(event *models.CalendarEvent) bool {
+		return event.EventId == studentEvents[0].EventId
+	}))
+	MockScheduleStorage.AssertNotCalled(t, "ProvideSchedules", mock.Anything, mock.MatchedBy(func(filter models.ScheduleFilter) bool {
+		return filter.StudentId == group.Students[0]
+	}))
+
+	_, err = s.ExportSchedules(ctx, uuid.New(), group.Id, start.Add(-time.Hour), start.Add(time.Hour), time.UTC)
+	require.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestImportSchedules(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	group := &models.Group{
+		Id:        uuid.New(),
+		Name:      "group",
+		TrainerId: trainerId,
+		Students:  []uuid.UUID{uuid.New(), uuid.New()},
+	}
+
+	data := []byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\nUID:single\r\nSUMMARY:Exam\r\nDTSTART:20250310T090000Z\r\nDTEND:20250310T100000Z\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:weekly\r\nSUMMARY:Math\r\nDTSTART:20250311T090000Z\r\nDTEND:20250311T100000Z\r\n" +
+		"RRULE:FREQ=WEEKLY;BYDAY=TU;COUNT=4\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n")
+
+	MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+	MockScheduleStorage.On("ImportSchedules", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CreateCalendarJobs", mock.Anything, mock.Anything).Return(nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	preview, err := s.ImportSchedules(ctx, trainerId, group.Id, data, true)
+	require.NoError(t, err)
+	require.False(t, preview.Committed)
+	require.Len(t, preview.Entries, 2)
+	require.Empty(t, preview.Entries[0].Conflicts)
+	require.Equal(t, models.FrequencyWeekly, preview.Entries[1].Recurrence.Frequency)
+	MockScheduleStorage.AssertNotCalled(t, "ImportSchedules", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	result, err := s.ImportSchedules(ctx, trainerId, group.Id, data, false)
+	require.NoError(t, err)
+	require.True(t, result.Committed)
+	MockScheduleStorage.AssertCalled(t, "ImportSchedules", ctx, mock.MatchedBy(func(scheds []*models.Schedule) bool {
+		return len(scheds) == 2 && scheds[0].Title == "Exam" && scheds[0].GroupId == group.Id &&
+			scheds[0].LessonId != uuid.Nil && scheds[0].LessonId == scheds[1].LessonId
+	}), mock.MatchedBy(func(series []*models.ScheduleSeries) bool {
+		return len(series) == 2 && series[0].Recurrence.Count == 4 && series[1].StudentId == group.Students[1] &&
+			series[0].LessonId != uuid.Nil && series[0].LessonId == series[1].LessonId
+	}), mock.Anything, changesMatching(func(_ []*models.CalendarJob, messages []*models.OutboxMessage) bool {
+		return len(messages) == 4
+	}))
+
+	// the second lesson overlaps the first one of the file
+	overlapping := []byte("BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\nUID:a\r\nDTSTART:20250310T090000Z\r\nDTEND:20250310T100000Z\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:b\r\nDTSTART:20250310T093000Z\r\nDTEND:20250310T103000Z\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n")
+
+	result, err = s.ImportSchedules(ctx, trainerId, group.Id, overlapping, false)
+	require.ErrorIs(t, err, ErrScheduleConflict)
+	require.False(t, result.Committed)
+	require.Len(t, result.Entries[1].Conflicts, 1)
+}
+
+func TestBookSlot(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	studentId := uuid.New()
+	group := &models.Group{Id: uuid.New(), Name: "group", TrainerId: trainerId, Students: []uuid.UUID{studentId}}
+	start := time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+
+	av := &models.Availability{
+		Id:          uuid.New(),
+		TrainerId:   trainerId,
+		GroupId:     group.Id,
+		Title:       "consultation",
+		Start:       start,
+		End:         start.Add(3 * time.Hour),
+		SlotLength:  time.Hour,
+		BufferAfter: 15 * time.Minute,
+	}
+	// blocks the second slot, 10:15 - 11:15
+	lesson := &models.Schedule{Id: uuid.New(), Start: start.Add(75 * time.Minute), End: start.Add(2 * time.Hour), TrainerId: trainerId}
+
+	MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+	MockScheduleStorage.On("ProvideAvailability", mock.Anything, av.Id).Return(av, nil)
+	MockScheduleStorage.On("ProvideTrainerAvailability", mock.Anything, trainerId).Return([]*models.Availability{av}, nil)
+	MockScheduleStorage.On("ProvideUserTimeZone", mock.Anything, trainerId).Return("", nil)
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.MatchedBy(func(end time.Time) bool {
+		return !end.After(lesson.Start)
+	})).Return([]*models.Conflict{}, nil)
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{
+		{UserId: trainerId, Schedule: lesson},
+	}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+	MockScheduleStorage.On("CreateSchedule", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CreateCalendarJobs", mock.Anything, mock.Anything).Return(nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	slots, err := s.GetFreeSlots(ctx, studentId, trainerId, start, start.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, slots, 1)
+	require.Equal(t, start, slots[0].Start)
+
+	// the windows of a group are hidden from users outside of it
+	slots, err = s.GetFreeSlots(ctx, uuid.New(), trainerId, start, start.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, slots)
+
+	_, err = s.BookSlot(ctx, studentId, av.Id, start.Add(75*time.Minute))
+	require.ErrorIs(t, err, ErrSlotUnavailable)
+
+	_, err = s.BookSlot(ctx, uuid.New(), av.Id, start)
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	sched, err := s.BookSlot(ctx, studentId, av.Id, start)
+	require.NoError(t, err)
+	require.Equal(t, start.Add(time.Hour), sched.End)
+	MockScheduleStorage.AssertCalled(t, "CreateSchedule", ctx, mock.MatchedBy(func(sched *models.Schedule) bool {
+		return sched.StudentId == studentId && sched.TrainerId == trainerId && sched.Title == "consultation"
+	}), changesMatching(func(_ []*models.CalendarJob, messages []*models.OutboxMessage) bool {
+		return len(messages) == 1 && messages[0].Topic == "schedule.schedule.created"
+	}))
+}
+
+func TestGetFreeBusy(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	studentId := uuid.New()
+	coTrainerId := uuid.New()
+	group := &models.Group{
+		Id:        uuid.New(),
+		TrainerId: trainerId,
+		Students:  []uuid.UUID{studentId},
+		Staff:     []*models.GroupMember{{UserId: coTrainerId, Role: models.RoleCoTrainer}},
+	}
+	from := time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+	to := from.Add(8 * time.Hour)
+
+	// 10:00 - 11:00 with the student
+	lesson := &models.Schedule{Id: uuid.New(), Start: from.Add(time.Hour), End: from.Add(2 * time.Hour), TrainerId: trainerId, StudentId: studentId}
+	// 10:30 - 12:00 and 15:00 - 15:30 in the student's calendar
+	events := []*models.CalendarEvent{
+		{Start: from.Add(90 * time.Minute), End: from.Add(3 * time.Hour)},
+		{Start: from.Add(6 * time.Hour), End: from.Add(6*time.Hour + 30*time.Minute)},
+	}
+
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, from, to).Return([]*models.Conflict{
+		{UserId: trainerId, Schedule: lesson},
+	}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+	MockCalendarManager.On("IsAuthorized", mock.Anything, trainerId).Return(false)
+	MockCalendarManager.On("IsAuthorized", mock.Anything, studentId).Return(true)
+	MockCalendarManager.On("GetEvents", mock.Anything, studentId, from, to).Return(&events, nil)
+	MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	freeBusy, err := s.GetFreeBusy(ctx, trainerId, group.Id, trainerId, []uuid.UUID{studentId}, from, to, time.Hour)
+	require.NoError(t, err)
+
+	require.Len(t, freeBusy.Users, 2)
+	require.Equal(t, []models.Interval{{Start: lesson.Start, End: lesson.End}}, freeBusy.Users[0].Busy)
+	require.Equal(t, []models.Interval{
+		{Start: from.Add(time.Hour), End: from.Add(3 * time.Hour)},
+		{Start: from.Add(6 * time.Hour), End: from.Add(6*time.Hour + 30*time.Minute)},
+	}, freeBusy.Users[1].Busy)
+
+	// 9:00 - 10:00, 12:00 - 15:00, 15:30 - 17:00
+	require.Equal(t, []models.Interval{
+		{Start: from, End: from.Add(time.Hour)},
+		{Start: from.Add(3 * time.Hour), End: from.Add(6 * time.Hour)},
+		{Start: from.Add(6*time.Hour + 30*time.Minute), End: to},
+	}, freeBusy.Free)
+
+	// a co-trainer gets the same for the whole group
+	all, err := s.GetFreeBusy(ctx, coTrainerId, group.Id, trainerId, nil, from, to, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, freeBusy, all)
+
+	// students and users outside of the group do not see the time of others
+	_, err = s.GetFreeBusy(ctx, studentId, group.Id, trainerId, nil, from, to, time.Hour)
+	require.ErrorIs(t, err, ErrUnauthorized)
+	_, err = s.GetFreeBusy(ctx, trainerId, group.Id, trainerId, []uuid.UUID{uuid.New()}, from, to, time.Hour)
+	require.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestAttendance(t *testing.T) {
+	MockCalendarManager := &MockCalendarManager{}
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+
+	trainerId := uuid.New()
+	studentId := uuid.New()
+	start := time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+
+	attended := &models.Schedule{Id: uuid.New(), Title: "first", Start: start, End: start.Add(time.Hour), TrainerId: trainerId, StudentId: studentId}
+	upcoming := &models.Schedule{Id: uuid.New(), Title: "second", Start: start.Add(24 * time.Hour), End: start.Add(25 * time.Hour), TrainerId: trainerId, StudentId: studentId}
+
+	var marks []*models.Attendance
+	MockScheduleStorage.On("ProvideSchedule", mock.Anything, attended.Id).Return(attended, nil)
+	MockScheduleStorage.On("SetAttendance", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		marks = append(marks, args.Get(1).(*models.Attendance))
+	})
+	MockScheduleStorage.On("ProvideSchedules", mock.Anything, mock.Anything).Return([]*models.Schedule{attended, upcoming}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, MockCalendarManager, Config{})
+
+	_, err = s.MarkAttendance(ctx, trainerId, attended.Id, "sleeping", "")
+	require.ErrorIs(t, err, ErrInvalidAttendance)
+
+	_, err = s.MarkAttendance(ctx, uuid.New(), attended.Id, models.AttendanceAttended, "")
+	require.ErrorIs(t, err, ErrScheduleNotFound)
+
+	mark, err := s.MarkAttendance(ctx, trainerId, attended.Id, models.AttendanceLate, "ten minutes")
+	require.NoError(t, err)
+	require.Equal(t, studentId, mark.StudentId)
+	MockScheduleStorage.AssertCalled(t, "SetAttendance", ctx, mark, mock.MatchedBy(func(messages []*models.OutboxMessage) bool {
+		return len(messages) == 1 && messages[0].Topic == "attendance.marked"
+	}))
+
+	filter := models.AttendanceFilter{StudentId: studentId, From: start, To: start.AddDate(0, 0, 7)}
+	MockScheduleStorage.On("ProvideAttendance", mock.Anything, filter).Return(marks, nil)
+
+	attendance, err := s.GetAttendance(ctx, studentId, filter)
+	require.NoError(t, err)
+	require.Len(t, attendance, 2)
+	require.Equal(t, models.AttendanceLate, attendance[0].Status)
+	require.Equal(t, "ten minutes", attendance[0].Note)
+	require.Equal(t, upcoming.Id, attendance[1].ScheduleId)
+	require.Equal(t, models.AttendanceScheduled, attendance[1].Status)
+
+	// the attendance of others is only shown to the staff of the group
+	_, err = s.GetAttendance(ctx, trainerId, filter)
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	assistantId := uuid.New()
+	group := &models.Group{
+		Id:        uuid.New(),
+		TrainerId: trainerId,
+		Students:  []uuid.UUID{studentId, uuid.New()},
+		Staff:     []*models.GroupMember{{UserId: assistantId, Role: models.RoleAssistant}},
+	}
+	MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+
+	groupFilter := models.AttendanceFilter{GroupId: group.Id, From: start, To: start.AddDate(0, 0, 7)}
+	MockScheduleStorage.On("ProvideAttendance", mock.Anything, groupFilter).Return(marks, nil)
+
+	attendance, err = s.GetAttendance(ctx, assistantId, groupFilter)
+	require.NoError(t, err)
+	require.Len(t, attendance, 2)
+
+	_, err = s.GetAttendance(ctx, group.Students[1], groupFilter)
+	require.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func hasCalendarJob(jobs []*models.CalendarJob, userId uuid.UUID, operation models.CalendarOperation, scheduleId uuid.UUID) bool {
+	for _, job := range jobs {
+		if job.UserId == userId && job.Operation == operation && slices.Contains(job.ScheduleIds, scheduleId) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// messagesMatching matches the newMessages callback of the storage whose messages satisfy fn.
+func messagesMatching(fn func(messages []*models.OutboxMessage) bool) any {
+	return mock.MatchedBy(func(newMessages func() ([]*models.OutboxMessage, error)) bool {
+		messages, err := newMessages()
+		return err == nil && fn(messages)
+	})
+}
+
+// changesMatching matches the newChanges callback of the storage whose calendar jobs and messages satisfy fn.
+func changesMatching(fn func(jobs []*models.CalendarJob, messages []*models.OutboxMessage) bool) any {
+	return mock.MatchedBy(func(newChanges func() ([]*models.CalendarJob, []*models.OutboxMessage, error)) bool {
+		jobs, messages, err := newChanges()
+		return err == nil && fn(jobs, messages)
+	})
+}
+
+func payloadScheduleId(msg *models.OutboxMessage) uuid.UUID {
+	var sched models.Schedule
+	if err := json.Unmarshal(msg.Payload, &sched); err != nil {
+		return uuid.Nil
+	}
+
+	return sched.Id
+}
+
+func TestSyncCalendar(t *testing.T) {
+	MockScheduleStorage := &MockScheduleStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+	MockSessionStorage := &MockSessionStorage{}
+
+	trainerId := uuid.New()
+	studentId := uuid.New()
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+
+	moved := &models.Schedule{Id: uuid.New(), Title: "test", Start: start, End: start.Add(time.Hour), TrainerId: trainerId, StudentId: studentId}
+	deleted := &models.Schedule{Id: uuid.New(), Title: "test", Start: start.Add(24 * time.Hour), End: start.Add(25 * time.Hour), TrainerId: trainerId, StudentId: studentId}
+
+	// a group lesson, one row per student linked to the single event of the trainer
+	groupId := uuid.New()
+	groupStart := start.Add(48 * time.Hour)
+	lesson := make([]*models.Schedule, 3)
+	for i := range lesson {
+		lesson[i] = &models.Schedule{Id: uuid.New(), Title: "group", GroupId: groupId, Start: groupStart, End: groupStart.Add(time.Hour), TrainerId: trainerId, StudentId: uuid.New()}
+	}
+	lessonConflicts := make([]*models.Conflict, len(lesson))
+	for i, sched := range lesson {
+		lessonConflicts[i] = &models.Conflict{UserId: sched.StudentId, Schedule: sched}
+	}
+
+	calendar := &FakeCalendarService{
+		Events: []*models.CalendarEvent{
+			{EventId: "moved", Start: start.Add(2 * time.Hour), End: start.Add(3 * time.Hour)},
+			{EventId: "deleted", Deleted: true},
+			{EventId: "group", Start: groupStart.Add(30 * time.Minute), End: groupStart.Add(90 * time.Minute)},
+		},
+		SyncToken: "next",
+	}
+	calendarManager := NewCalendarManager(MockSessionStorage, nil, map[models.CalendarProvider]CalendarService{
+		models.ProviderGoogle: calendar,
+	}, nil, MockGroupStorage, 0)
+
+	MockSessionStorage.On("ProvideSession", mock.Anything, trainerId).Return(models.Token{Provider: models.ProviderGoogle}, nil)
+	MockScheduleStorage.On("ProvideEventSchedules", mock.Anything, trainerId, "calendar", "moved").Return([]*models.Schedule{moved}, nil)
+	MockScheduleStorage.On("ProvideEventSchedules", mock.Anything, trainerId, "calendar", "deleted").Return([]*models.Schedule{deleted}, nil)
+	MockScheduleStorage.On("ProvideEventSchedules", mock.Anything, trainerId, "calendar", "group").Return(lesson, nil)
+	// the moved group lesson overlaps only its own rows
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, groupStart.Add(30*time.Minute), mock.Anything).Return(lessonConflicts, nil)
+	MockScheduleStorage.On("ProvideConflicts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*models.Conflict{}, nil)
+	MockScheduleStorage.On("ProvideScheduleSeries", mock.Anything, mock.Anything, mock.Anything).Return([]*models.ScheduleSeries{}, nil)
+	MockScheduleStorage.On("MoveSchedules", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CancelSchedule", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("CreateCalendarJobs", mock.Anything, mock.Anything).Return(nil)
+	MockScheduleStorage.On("SetCalendarSyncToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	s := New(ctx, MockScheduleStorage, MockGroupStorage, calendarManager, Config{})
+
+	calend := &models.GroupCalendar{UserId: trainerId, GroupId: uuid.New(), CalendarId: "calendar", SyncToken: "last"}
+	require.NoError(t, s.SyncCalendar(ctx, calend))
+
+	MockScheduleStorage.AssertCalled(t, "MoveSchedules", ctx, mock.MatchedBy(func(scheds []*models.Schedule) bool {
+		return len(scheds) == 1 && scheds[0].Id == moved.Id && scheds[0].Start.Equal(start.Add(2*time.Hour))
+	}), mock.MatchedBy(func(jobs []*models.CalendarJob) bool {
+		return len(jobs) == 1 && jobs[0].UserId == studentId && jobs[0].Operation == models.CalendarOperationUpdate
+	}), mock.Anything)
+
+	// all rows of the group lesson are moved at once, the event of every student is updated
+	MockScheduleStorage.AssertCalled(t, "MoveSchedules", ctx, mock.MatchedBy(func(scheds []*models.Schedule) bool {
+		if len(scheds) != len(lesson) {
+			return false
+		}
+		for i, sched := range scheds {
+			if sched.Id != lesson[i].Id || !sched.Start.Equal(groupStart.Add(30*time.Minute)) || !sched.End.Equal(groupStart.Add(90*time.Minute)) {
+				return false
+			}
+		}
+		return true
+	}), mock.MatchedBy(func(jobs []*models.CalendarJob) bool {
+		if len(jobs) != len(lesson) {
+			return false
+		}
+		for i, job := range jobs {
+			if job.UserId != lesson[i].StudentId || job.Operation != models.CalendarOperationUpdate {
+				return false
+			}
+		}
+		return true
+	}), mock.MatchedBy(func(messages []*models.OutboxMessage) bool {
+		return len(messages) == len(lesson)
+	}))
+	MockScheduleStorage.AssertNumberOfCalls(t, "MoveSchedules", 2)
+	MockScheduleStorage.AssertNotCalled(t, "CreateCalendarJobs", mock.Anything, mock.Anything)
+	MockScheduleStorage.AssertCalled(t, "CancelSchedule", ctx, mock.MatchedBy(func(sched *models.Schedule) bool {
+		return sched.Id == deleted.Id && sched.CancelReason == calendarDeletedReason
+	}), mock.Anything, mock.Anything)
+	MockScheduleStorage.AssertCalled(t, "SetCalendarSyncToken", ctx, trainerId, calend.GroupId, "next")
+
+	// the first sync only records the state of the calendar
+	calend.SyncToken = ""
+	require.NoError(t, s.SyncCalendar(ctx, calend))
+	MockScheduleStorage.AssertNumberOfCalls(t, "MoveSchedules", 2)
+}
+
+func TestCreateEventPerMember(t *testing.T) {
+	MockSessionStorage := &MockSessionStorage{}
+	MockCalendarStorage := &MockCalendarStorage{}
+	MockGroupStorage := &MockGroupStorage{}
+	MockCalendarService := &MockCalendarService{}
+
+	trainerId := uuid.New()
+	studentId := uuid.New()
+	group := &models.Group{Id: uuid.New(), Name: "group", TrainerId: trainerId, Students: []uuid.UUID{studentId}}
+	scheduleId := uuid.New()
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	event := &models.CalendarEvent{Title: "test", Start: start, End: start.Add(time.Hour)}
+
+	trainerTok := models.Token{Provider: models.ProviderGoogle, AccessToken: "trainer"}
+	studentTok := models.Token{Provider: models.ProviderGoogle, AccessToken: "student"}
+
+	MockSessionStorage.On("ProvideSession", mock.Anything, trainerId).Return(trainerTok, nil)
+	MockSessionStorage.On("ProvideSession", mock.Anything, studentId).Return(studentTok, nil)
+	MockGroupStorage.On("ProvideGroup", mock.Anything, group.Id).Return(group, nil)
+	MockCalendarStorage.On("ProvideUserTimeZone", mock.Anything, mock.Anything).Return("UTC", nil)
+
+	// the trainer already has the calendar of the group, the student gets one in their own account
+	MockCalendarStorage.On("ProvideCalendar", mock.Anything, trainerId, group.Id).Return("trainer-calendar", nil)
+	MockCalendarStorage.On("ProvideCalendar", mock.Anything, studentId, group.Id).Return("", storage.ErrCalendarNotFound)
+	MockCalendarService.On("CreateCalendar", mock.Anything, studentTok, group.Name).Return(&models.Calendar{Id: "student-calendar", Title: group.Name}, nil)
+	MockCalendarStorage.On("CreateCalendar", mock.Anything, studentId, group.Id, "student-calendar").Return(nil)
+
+	MockCalendarService.On("CreateEvent", mock.Anything, trainerTok, mock.Anything, mock.Anything, event.Title, "trainer-calendar").Return("trainer-event", nil)
+	MockCalendarService.On("CreateEvent", mock.Anything, studentTok, mock.Anything, mock.Anything, event.Title, "student-calendar").Return("student-event", nil)
+	MockCalendarStorage.On("CreateScheduleEvents", mock.Anything, mock.Anything).Return(nil)
+
+	calendarManager := NewCalendarManager(MockSessionStorage, nil, map[models.CalendarProvider]CalendarService{
+		models.ProviderGoogle: MockCalendarService,
+	}, MockCalendarStorage, MockGroupStorage, 0)
+
+	ctx, err := logger.New(context.Background(), "dev")
+	if err != nil {
+		t.Errorf("logger.New() error = %v", err)
+	}
+
+	require.NoError(t, calendarManager.CreateEvent(ctx, trainerId, group.Id, event, scheduleId))
+	require.NoError(t, calendarManager.CreateEvent(ctx, studentId, group.Id, event, scheduleId))
+
+	MockCalendarService.AssertNumberOfCalls(t, "CreateCalendar", 1)
+	MockCalendarStorage.AssertCalled(t, "CreateScheduleEvents", mock.Anything, []*models.ScheduleEvent{
+		{ScheduleId: scheduleId, UserId: trainerId, CalendarId: "trainer-calendar", EventId: "trainer-event"},
+	})
+	MockCalendarStorage.AssertCalled(t, "CreateScheduleEvents", mock.Anything, []*models.ScheduleEvent{
+		{ScheduleId: scheduleId, UserId: studentId, CalendarId: "student-calendar", EventId: "student-event"},
+	})
 }
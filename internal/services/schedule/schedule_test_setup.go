@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/clients"
 	"github.com/hesoyamTM/apphelper-schedule/internal/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -13,8 +14,8 @@ type MockCalendarManager struct {
 	mock.Mock
 }
 
-func (m *MockCalendarManager) LoginURL(ctx context.Context, userID uuid.UUID) string {
-	args := m.Called(ctx, userID)
+func (m *MockCalendarManager) LoginURL(ctx context.Context, userID uuid.UUID, provider models.CalendarProvider) string {
+	args := m.Called(ctx, userID, provider)
 	return args.String(0)
 }
 
@@ -23,13 +24,28 @@ func (m *MockCalendarManager) Authorize(ctx context.Context, userId uuid.UUID, a
 	return args.Error(0)
 }
 
+func (m *MockCalendarManager) Connect(ctx context.Context, userId uuid.UUID, tok models.Token) error {
+	args := m.Called(ctx, userId, tok)
+	return args.Error(0)
+}
+
 func (m *MockCalendarManager) IsAuthorized(ctx context.Context, userId uuid.UUID) bool {
 	args := m.Called(ctx, userId)
 	return args.Bool(0)
 }
 
-func (m *MockCalendarManager) CreateEvent(ctx context.Context, userId, groupId uuid.UUID, event *models.CalendarEvent) error {
-	args := m.Called(ctx, userId, groupId, event)
+func (m *MockCalendarManager) CreateEvent(ctx context.Context, userId, groupId uuid.UUID, event *models.CalendarEvent, scheduleIds ...uuid.UUID) error {
+	args := m.Called(ctx, userId, groupId, event, scheduleIds)
+	return args.Error(0)
+}
+
+func (m *MockCalendarManager) UpdateEvent(ctx context.Context, userId, scheduleId uuid.UUID, updated *models.CalendarEvent) error {
+	args := m.Called(ctx, userId, scheduleId, updated)
+	return args.Error(0)
+}
+
+func (m *MockCalendarManager) RemoveEvent(ctx context.Context, userId, scheduleId uuid.UUID) error {
+	args := m.Called(ctx, userId, scheduleId)
 	return args.Error(0)
 }
 
@@ -43,25 +59,180 @@ func (m *MockCalendarManager) DeleteEvent(ctx context.Context, userId uuid.UUID,
 	return args.Error(0)
 }
 
+func (m *MockCalendarManager) ChangedEvents(ctx context.Context, calend *models.GroupCalendar) ([]*models.CalendarEvent, string, bool, error) {
+	args := m.Called(ctx, calend)
+	return args.Get(0).([]*models.CalendarEvent), args.String(1), args.Bool(2), args.Error(3)
+}
+
 type MockScheduleStorage struct {
 	mock.Mock
 }
 
-func (m *MockScheduleStorage) CreateSchedule(ctx context.Context, sched *models.Schedule) error {
-	args := m.Called(ctx, sched)
+func (m *MockScheduleStorage) CreateSchedule(ctx context.Context, sched *models.Schedule, newChanges func() ([]*models.CalendarJob, []*models.OutboxMessage, error)) error {
+	args := m.Called(ctx, sched, newChanges)
 	return args.Error(0)
 }
 
-func (m *MockScheduleStorage) ProvideSchedules(ctx context.Context, trainerId, studentId uuid.UUID) ([]*models.Schedule, error) {
+func (m *MockScheduleStorage) CreateSchedules(ctx context.Context, scheds []*models.Schedule, newChanges func() ([]*models.CalendarJob, []*models.OutboxMessage, error)) error {
+	args := m.Called(ctx, scheds, newChanges)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) UpdateSchedule(ctx context.Context, sched *models.Schedule, jobs []*models.CalendarJob, messages []*models.OutboxMessage) error {
+	args := m.Called(ctx, sched, jobs, messages)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) ProvideSchedule(ctx context.Context, scheduleId uuid.UUID) (*models.Schedule, error) {
+	args := m.Called(ctx, scheduleId)
+	return args.Get(0).(*models.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleStorage) ProvideConflicts(ctx context.Context, userIds []uuid.UUID, start, end time.Time) ([]*models.Conflict, error) {
+	args := m.Called(ctx, userIds, start, end)
+	return args.Get(0).([]*models.Conflict), args.Error(1)
+}
+
+func (m *MockScheduleStorage) ProvideSchedules(ctx context.Context, filter models.ScheduleFilter) ([]*models.Schedule, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*models.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleStorage) CancelSchedule(ctx context.Context, sched *models.Schedule, jobs []*models.CalendarJob, messages []*models.OutboxMessage) error {
+	args := m.Called(ctx, sched, jobs, messages)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) ImportSchedules(ctx context.Context, scheds []*models.Schedule, series []*models.ScheduleSeries, occurrences []*models.Schedule, newChanges func() ([]*models.CalendarJob, []*models.OutboxMessage, error)) error {
+	args := m.Called(ctx, scheds, series, occurrences, newChanges)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) CreateScheduleSeries(ctx context.Context, series []*models.ScheduleSeries, occurrences []*models.Schedule, newMessages func() ([]*models.OutboxMessage, error)) error {
+	args := m.Called(ctx, series, occurrences, newMessages)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) ProvideScheduleSeries(ctx context.Context, trainerId, studentId uuid.UUID) ([]*models.ScheduleSeries, error) {
 	args := m.Called(ctx, trainerId, studentId)
+	return args.Get(0).([]*models.ScheduleSeries), args.Error(1)
+}
+
+func (m *MockScheduleStorage) ProvideLessonSeries(ctx context.Context, seriesId uuid.UUID) ([]*models.ScheduleSeries, error) {
+	args := m.Called(ctx, seriesId)
+	return args.Get(0).([]*models.ScheduleSeries), args.Error(1)
+}
+
+func (m *MockScheduleStorage) MoveSchedules(ctx context.Context, scheds []*models.Schedule, jobs []*models.CalendarJob, messages []*models.OutboxMessage) error {
+	args := m.Called(ctx, scheds, jobs, messages)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) UpdateScheduleSeries(ctx context.Context, series []*models.ScheduleSeries, occurrences []*models.Schedule) error {
+	args := m.Called(ctx, series, occurrences)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) SplitScheduleSeries(ctx context.Context, series, next []*models.ScheduleSeries, at time.Time, occurrences []*models.Schedule) error {
+	args := m.Called(ctx, series, next, at, occurrences)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) DetachOccurrences(ctx context.Context, scheds []*models.Schedule) error {
+	args := m.Called(ctx, scheds)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) AddSeriesException(ctx context.Context, seriesIds []uuid.UUID, trainerId uuid.UUID, at time.Time) error {
+	args := m.Called(ctx, seriesIds, trainerId, at)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) DeleteScheduleSeries(ctx context.Context, seriesIds []uuid.UUID, trainerId uuid.UUID) error {
+	args := m.Called(ctx, seriesIds, trainerId)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) CreateCalendarJobs(ctx context.Context, jobs []*models.CalendarJob) error {
+	args := m.Called(ctx, jobs)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) ProvideEventSchedules(ctx context.Context, userId uuid.UUID, calendarId, eventId string) ([]*models.Schedule, error) {
+	args := m.Called(ctx, userId, calendarId, eventId)
 	return args.Get(0).([]*models.Schedule), args.Error(1)
 }
 
-func (m *MockScheduleStorage) DeleteSchedule(ctx context.Context, groupId, trainerId uuid.UUID) error {
-	args := m.Called(ctx, groupId, trainerId)
+func (m *MockScheduleStorage) SetCalendarSyncToken(ctx context.Context, userId, groupId uuid.UUID, syncToken string) error {
+	args := m.Called(ctx, userId, groupId, syncToken)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) RequestCalendarSync(ctx context.Context, channelId uuid.UUID, resourceId string) error {
+	args := m.Called(ctx, channelId, resourceId)
 	return args.Error(0)
 }
 
+func (m *MockScheduleStorage) ProvideCalendarJobs(ctx context.Context, userId uuid.UUID) ([]*models.CalendarJob, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).([]*models.CalendarJob), args.Error(1)
+}
+
+func (m *MockScheduleStorage) RetryCalendarJobs(ctx context.Context, userId uuid.UUID) (int, error) {
+	args := m.Called(ctx, userId)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockScheduleStorage) CreateAvailability(ctx context.Context, av *models.Availability) error {
+	args := m.Called(ctx, av)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) ProvideAvailability(ctx context.Context, availabilityId uuid.UUID) (*models.Availability, error) {
+	args := m.Called(ctx, availabilityId)
+	return args.Get(0).(*models.Availability), args.Error(1)
+}
+
+func (m *MockScheduleStorage) ProvideTrainerAvailability(ctx context.Context, trainerId uuid.UUID) ([]*models.Availability, error) {
+	args := m.Called(ctx, trainerId)
+	return args.Get(0).([]*models.Availability), args.Error(1)
+}
+
+func (m *MockScheduleStorage) DeleteAvailability(ctx context.Context, availabilityId, trainerId uuid.UUID) error {
+	args := m.Called(ctx, availabilityId, trainerId)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) SetAttendance(ctx context.Context, attendance *models.Attendance, messages []*models.OutboxMessage) error {
+	args := m.Called(ctx, attendance, messages)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) ProvideAttendance(ctx context.Context, filter models.AttendanceFilter) ([]*models.Attendance, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*models.Attendance), args.Error(1)
+}
+
+func (m *MockScheduleStorage) SetUserTimeZone(ctx context.Context, userId uuid.UUID, timeZone string) error {
+	args := m.Called(ctx, userId, timeZone)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) ProvideUserTimeZone(ctx context.Context, userId uuid.UUID) (string, error) {
+	args := m.Called(ctx, userId)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockScheduleStorage) SetFeedToken(ctx context.Context, userId uuid.UUID, tokenHash string) error {
+	args := m.Called(ctx, userId, tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockScheduleStorage) ProvideFeedUser(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(uuid.UUID), args.Error(1)
+}
+
 type MockGroupStorage struct {
 	mock.Mock
 }
@@ -71,11 +242,209 @@ func (m *MockGroupStorage) ProvideGroup(ctx context.Context, groupId uuid.UUID)
 	return args.Get(0).(*models.Group), args.Error(1)
 }
 
-type MockRedpanda struct {
+type MockSessionStorage struct {
+	mock.Mock
+}
+
+func (m *MockSessionStorage) SetSession(ctx context.Context, userId uuid.UUID, tok models.Token, ttl time.Duration) error {
+	args := m.Called(ctx, userId, tok, ttl)
+	return args.Error(0)
+}
+
+func (m *MockSessionStorage) ProvideSession(ctx context.Context, userId uuid.UUID) (models.Token, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).(models.Token), args.Error(1)
+}
+
+func (m *MockSessionStorage) DeleteSession(ctx context.Context, userId uuid.UUID) error {
+	args := m.Called(ctx, userId)
+	return args.Error(0)
+}
+
+// FakeCalendarService serves Events as the changes of every calendar, reporting SyncToken
+// as the next sync token. Other calls are not supported.
+type FakeCalendarService struct {
+	Events    []*models.CalendarEvent
+	SyncToken string
+}
+
+func (f *FakeCalendarService) LoginURL(ctx context.Context, state string) string {
+	return ""
+}
+
+func (f *FakeCalendarService) GetTokenFromCode(ctx context.Context, authCode string) (models.Token, error) {
+	return models.Token{}, clients.ErrUnsupported
+}
+
+func (f *FakeCalendarService) GetEvents(ctx context.Context, tok models.Token, minTime, maxTime time.Time) (*[]*models.CalendarEvent, error) {
+	return nil, clients.ErrUnsupported
+}
+
+func (f *FakeCalendarService) CreateEvent(ctx context.Context, tok models.Token, start, end time.Time, title, calendarId string) (string, error) {
+	return "", clients.ErrUnsupported
+}
+
+func (f *FakeCalendarService) UpdateEvent(ctx context.Context, tok models.Token, event *models.CalendarEvent) error {
+	return clients.ErrUnsupported
+}
+
+func (f *FakeCalendarService) DeleteEvent(ctx context.Context, tok models.Token, eventId, calendarId string) error {
+	return clients.ErrUnsupported
+}
+
+func (f *FakeCalendarService) CreateCalendar(ctx context.Context, tok models.Token, title string) (*models.Calendar, error) {
+	return nil, clients.ErrUnsupported
+}
+
+func (f *FakeCalendarService) DeleteCalendar(ctx context.Context, tok models.Token, calendarId string) error {
+	return clients.ErrUnsupported
+}
+
+func (f *FakeCalendarService) AccountId(ctx context.Context, tok models.Token) (string, error) {
+	return "", clients.ErrUnsupported
+}
+
+func (f *FakeCalendarService) ShareCalendar(ctx context.Context, tok models.Token, calendarId, accountId string) error {
+	return clients.ErrUnsupported
+}
+
+func (f *FakeCalendarService) UnshareCalendar(ctx context.Context, tok models.Token, calendarId, accountId string) error {
+	return clients.ErrUnsupported
+}
+
+func (f *FakeCalendarService) SyncEvents(ctx context.Context, tok models.Token, calendarId, syncToken string) ([]*models.CalendarEvent, string, error) {
+	return f.Events, f.SyncToken, nil
+}
+
+func (f *FakeCalendarService) WatchCalendar(ctx context.Context, tok models.Token, calendarId string, channel *models.CalendarChannel) error {
+	return clients.ErrUnsupported
+}
+
+func (f *FakeCalendarService) RefreshToken(ctx context.Context, tok models.Token) (models.Token, error) {
+	return models.Token{}, clients.ErrUnsupported
+}
+
+type MockCalendarService struct {
+	mock.Mock
+}
+
+func (m *MockCalendarService) LoginURL(ctx context.Context, state string) string {
+	args := m.Called(ctx, state)
+	return args.String(0)
+}
+
+func (m *MockCalendarService) GetTokenFromCode(ctx context.Context, authCode string) (models.Token, error) {
+	args := m.Called(ctx, authCode)
+	return args.Get(0).(models.Token), args.Error(1)
+}
+
+func (m *MockCalendarService) GetEvents(ctx context.Context, tok models.Token, minTime, maxTime time.Time) (*[]*models.CalendarEvent, error) {
+	args := m.Called(ctx, tok, minTime, maxTime)
+	return args.Get(0).(*[]*models.CalendarEvent), args.Error(1)
+}
+
+func (m *MockCalendarService) CreateEvent(ctx context.Context, tok models.Token, start, end time.Time, title, calendarId string) (string, error) {
+	args := m.Called(ctx, tok, start, end, title, calendarId)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockCalendarService) UpdateEvent(ctx context.Context, tok models.Token, event *models.CalendarEvent) error {
+	args := m.Called(ctx, tok, event)
+	return args.Error(0)
+}
+
+func (m *MockCalendarService) DeleteEvent(ctx context.Context, tok models.Token, eventId, calendarId string) error {
+	args := m.Called(ctx, tok, eventId, calendarId)
+	return args.Error(0)
+}
+
+func (m *MockCalendarService) CreateCalendar(ctx context.Context, tok models.Token, title string) (*models.Calendar, error) {
+	args := m.Called(ctx, tok, title)
+	calend, _ := args.Get(0).(*models.Calendar)
+	return calend, args.Error(1)
+}
+
+func (m *MockCalendarService) DeleteCalendar(ctx context.Context, tok models.Token, calendarId string) error {
+	args := m.Called(ctx, tok, calendarId)
+	return args.Error(0)
+}
+
+func (m *MockCalendarService) AccountId(ctx context.Context, tok models.Token) (string, error) {
+	args := m.Called(ctx, tok)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockCalendarService) ShareCalendar(ctx context.Context, tok models.Token, calendarId, accountId string) error {
+	args := m.Called(ctx, tok, calendarId, accountId)
+	return args.Error(0)
+}
+
+func (m *MockCalendarService) UnshareCalendar(ctx context.Context, tok models.Token, calendarId, accountId string) error {
+	args := m.Called(ctx, tok, calendarId, accountId)
+	return args.Error(0)
+}
+
+func (m *MockCalendarService) SyncEvents(ctx context.Context, tok models.Token, calendarId, syncToken string) ([]*models.CalendarEvent, string, error) {
+	args := m.Called(ctx, tok, calendarId, syncToken)
+	return args.Get(0).([]*models.CalendarEvent), args.String(1), args.Error(2)
+}
+
+func (m *MockCalendarService) WatchCalendar(ctx context.Context, tok models.Token, calendarId string, channel *models.CalendarChannel) error {
+	args := m.Called(ctx, tok, calendarId, channel)
+	return args.Error(0)
+}
+
+func (m *MockCalendarService) RefreshToken(ctx context.Context, tok models.Token) (models.Token, error) {
+	args := m.Called(ctx, tok)
+	return args.Get(0).(models.Token), args.Error(1)
+}
+
+type MockCalendarStorage struct {
 	mock.Mock
 }
 
-func (m *MockRedpanda) ScheduleCreatedEvent(ctx context.Context, schedule *models.Schedule) error {
-	args := m.Called(ctx, schedule)
+func (m *MockCalendarStorage) CreateCalendar(ctx context.Context, userId, groupId uuid.UUID, calendarId string) error {
+	args := m.Called(ctx, userId, groupId, calendarId)
+	return args.Error(0)
+}
+
+func (m *MockCalendarStorage) DeleteCalendar(ctx context.Context, userId, groupId uuid.UUID) error {
+	args := m.Called(ctx, userId, groupId)
+	return args.Error(0)
+}
+
+func (m *MockCalendarStorage) ProvideCalendar(ctx context.Context, userId, groupId uuid.UUID) (string, error) {
+	args := m.Called(ctx, userId, groupId)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockCalendarStorage) SetCalendarChannel(ctx context.Context, userId, groupId uuid.UUID, channel *models.CalendarChannel) error {
+	args := m.Called(ctx, userId, groupId, channel)
+	return args.Error(0)
+}
+
+func (m *MockCalendarStorage) CreateScheduleEvents(ctx context.Context, events []*models.ScheduleEvent) error {
+	args := m.Called(ctx, events)
+	return args.Error(0)
+}
+
+func (m *MockCalendarStorage) ProvideScheduleEvent(ctx context.Context, scheduleId, userId uuid.UUID) (*models.ScheduleEvent, error) {
+	args := m.Called(ctx, scheduleId, userId)
+	event, _ := args.Get(0).(*models.ScheduleEvent)
+	return event, args.Error(1)
+}
+
+func (m *MockCalendarStorage) DeleteScheduleEvent(ctx context.Context, scheduleId, userId uuid.UUID) error {
+	args := m.Called(ctx, scheduleId, userId)
 	return args.Error(0)
 }
+
+func (m *MockCalendarStorage) CountScheduleEvents(ctx context.Context, calendarId, eventId string) (int, error) {
+	args := m.Called(ctx, calendarId, eventId)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockCalendarStorage) ProvideUserTimeZone(ctx context.Context, userId uuid.UUID) (string, error) {
+	args := m.Called(ctx, userId)
+	return args.String(0), args.Error(1)
+}
@@ -0,0 +1,433 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/clients/redpanda"
+	"github.com/hesoyamTM/apphelper-schedule/internal/lib/recurrence"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const defaultExpansionWindow = 30 * 24 * time.Hour
+
+// CreateScheduleSeries stores a recurring lesson of the trainer and the student.
+// Series are not written to the calendars of their participants: no calendar job is queued for them
+// or their later changes, their occurrences are published by the iCalendar export and feed instead.
+func (s *Schedule) CreateScheduleSeries(ctx context.Context, series *models.ScheduleSeries) error {
+	const op = "schedule.CreateScheduleSeries"
+
+	if err := s.createScheduleSeries(ctx, []*models.ScheduleSeries{series}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CreateScheduleSeriesForGroup stores a recurring lesson of the group as a series per student,
+// sharing a lesson id. Like CreateScheduleSeries, it queues no calendar jobs.
+func (s *Schedule) CreateScheduleSeriesForGroup(ctx context.Context, groupId uuid.UUID, series *models.ScheduleSeries) error {
+	const op = "schedule.CreateScheduleSeriesForGroup"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	group, err := s.gDB.ProvideGroup(ctx, groupId)
+	if err != nil {
+		log.Error(ctx, "failed to fetch group", zap.Error(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	series.GroupId = groupId
+	series.GroupName = group.Name
+	series.LessonId = uuid.New()
+
+	groupSeries := []*models.ScheduleSeries{series}
+	if len(group.Students) > 0 {
+		groupSeries = make([]*models.ScheduleSeries, 0, len(group.Students))
+		for _, student := range group.Students {
+			studentSeries := *series
+			studentSeries.StudentId = student
+
+			groupSeries = append(groupSeries, &studentSeries)
+		}
+	}
+
+	if err := s.createScheduleSeries(ctx, groupSeries); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// createScheduleSeries validates series and stores all of them in a single transaction.
+// Series overlapping lessons of their trainer or student are rejected with ErrScheduleConflict.
+func (s *Schedule) createScheduleSeries(ctx context.Context, series []*models.ScheduleSeries) error {
+	const op = "schedule.createScheduleSeries"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	occurrences := make([]*models.Schedule, 0)
+	for _, ser := range series {
+		if err := recurrence.Validate(ser.Recurrence); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !ser.End.After(ser.Start) {
+			return fmt.Errorf("%s: %w", op, recurrence.ErrInvalidRule)
+		}
+
+		if ser.TimeZone == "" {
+			ser.TimeZone = s.userLocation(ctx, ser.TrainerId).String()
+		}
+		if _, err := time.LoadLocation(ser.TimeZone); err != nil {
+			return fmt.Errorf("%s: %w", op, ErrInvalidTimeZone)
+		}
+
+		occurrences = append(occurrences, seriesOccurrences(ser)...)
+	}
+
+	if err := s.checkOccurrences(ctx, occurrences); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	newMessages := func() ([]*models.OutboxMessage, error) {
+		return seriesCreatedMessages(series)
+	}
+
+	if err := s.db.CreateScheduleSeries(ctx, series, occurrences, newMessages); err != nil {
+		log.Error(ctx, "failed to create schedule series", zap.Error(err))
+
+		if errors.Is(err, storage.ErrScheduleConflict) {
+			return fmt.Errorf("%s: %w", op, ErrScheduleConflict)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UpdateScheduleSeries applies changes to the occurrence of the series starting at recurrenceId,
+// to it and all following occurrences or to the whole series, depending on scope.
+// The series of a group lesson are changed together, keeping their students.
+// Zero fields of changes are left as they are. Changes making the series overlap other lessons
+// are rejected with ErrScheduleConflict.
+func (s *Schedule) UpdateScheduleSeries(ctx context.Context, trainerId, seriesId uuid.UUID, recurrenceId time.Time, scope models.EditScope, changes *models.Schedule) error {
+	const op = "schedule.UpdateScheduleSeries"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	lesson, err := s.provideOccurrenceLesson(ctx, trainerId, seriesId, recurrenceId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	series := lesson[0]
+	ignoreIds := lessonSeriesIds(lesson)
+
+	occurrence := occurrenceOf(series, recurrenceId)
+	applyChanges(occurrence, changes)
+	delta := occurrence.Start.Sub(recurrenceId)
+
+	if scope == models.ScopeFollowing && recurrenceId.Equal(series.Start) {
+		scope = models.ScopeSeries
+	}
+
+	switch scope {
+	case models.ScopeOccurrence:
+		detached := []*models.Schedule{occurrence}
+		for _, ser := range occurringAt(lesson[1:], recurrenceId) {
+			studentOccurrence := *occurrence
+			studentOccurrence.Id = occurrenceOf(ser, recurrenceId).Id
+			studentOccurrence.SeriesId = ser.Id
+			studentOccurrence.StudentId = ser.StudentId
+
+			detached = append(detached, &studentOccurrence)
+		}
+
+		err = s.checkOccurrences(ctx, detached, ignoreIds...)
+		if err == nil {
+			err = s.db.DetachOccurrences(ctx, detached)
+		}
+
+	case models.ScopeFollowing:
+		lessonId := uuid.New()
+		following := occurringAt(lesson, recurrenceId)
+		nexts := make([]*models.ScheduleSeries, 0, len(following))
+		occurrences := make([]*models.Schedule, 0)
+		for _, ser := range following {
+			next := *ser
+			next.LessonId = lessonId
+			next.Title = occurrence.Title
+			next.Start = occurrence.Start
+			next.End = occurrence.End
+			next.Recurrence = shiftRecurrence(ser.Recurrence, recurrenceId, delta)
+			next.Recurrence.Exceptions = slices.DeleteFunc(next.Recurrence.Exceptions, func(t time.Time) bool {
+				return t.Before(next.Start)
+			})
+
+			before := truncateSeries(ser, recurrenceId)
+			if next.Recurrence.Count > 0 {
+				next.Recurrence.Count -= before
+			}
+
+			nexts = append(nexts, &next)
+			occurrences = append(occurrences, seriesOccurrences(&next)...)
+		}
+
+		err = s.checkOccurrences(ctx, occurrences, ignoreIds...)
+		if err == nil {
+			err = s.db.SplitScheduleSeries(ctx, following, nexts, recurrenceId, occurrences)
+		}
+
+	case models.ScopeSeries:
+		occurrences := make([]*models.Schedule, 0)
+		for _, ser := range lesson {
+			ser.Recurrence = shiftRecurrence(ser.Recurrence, ser.Start, delta)
+			ser.Title = occurrence.Title
+			ser.End = ser.Start.Add(delta).Add(occurrence.End.Sub(occurrence.Start))
+			ser.Start = ser.Start.Add(delta)
+
+			occurrences = append(occurrences, seriesOccurrences(ser)...)
+		}
+
+		err = s.checkOccurrences(ctx, occurrences, ignoreIds...)
+		if err == nil {
+			err = s.db.UpdateScheduleSeries(ctx, lesson, occurrences)
+		}
+
+	default:
+		return fmt.Errorf("%s: %w", op, ErrInvalidScope)
+	}
+
+	if err != nil {
+		log.Error(ctx, "failed to update schedule series", zap.Error(err))
+
+		if errors.Is(err, storage.ErrSeriesNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrSeriesNotFound)
+		}
+		if errors.Is(err, storage.ErrScheduleConflict) {
+			return fmt.Errorf("%s: %w", op, ErrScheduleConflict)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CancelScheduleSeries cancels the occurrence of the series starting at recurrenceId,
+// it and all following occurrences or the whole series, depending on scope.
+// The series of a group lesson are cancelled together.
+func (s *Schedule) CancelScheduleSeries(ctx context.Context, trainerId, seriesId uuid.UUID, recurrenceId time.Time, scope models.EditScope) error {
+	const op = "schedule.CancelScheduleSeries"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	lesson, err := s.provideOccurrenceLesson(ctx, trainerId, seriesId, recurrenceId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	series := lesson[0]
+
+	if scope == models.ScopeFollowing && recurrenceId.Equal(series.Start) {
+		scope = models.ScopeSeries
+	}
+
+	switch scope {
+	case models.ScopeOccurrence:
+		err = s.db.AddSeriesException(ctx, lessonSeriesIds(occurringAt(lesson, recurrenceId)), series.TrainerId, recurrenceId)
+
+	case models.ScopeFollowing:
+		following := occurringAt(lesson, recurrenceId)
+		for _, ser := range following {
+			truncateSeries(ser, recurrenceId)
+		}
+
+		err = s.db.SplitScheduleSeries(ctx, following, nil, recurrenceId, nil)
+
+	case models.ScopeSeries:
+		err = s.db.DeleteScheduleSeries(ctx, lessonSeriesIds(lesson), series.TrainerId)
+
+	default:
+		return fmt.Errorf("%s: %w", op, ErrInvalidScope)
+	}
+
+	if err != nil {
+		log.Error(ctx, "failed to cancel schedule series", zap.Error(err))
+
+		if errors.Is(err, storage.ErrSeriesNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrSeriesNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// provideOccurrenceLesson returns the series seriesId, which must have an occurrence at recurrenceId,
+// followed by the other series of its lesson.
+func (s *Schedule) provideOccurrenceLesson(ctx context.Context, trainerId, seriesId uuid.UUID, recurrenceId time.Time) ([]*models.ScheduleSeries, error) {
+	const op = "schedule.provideOccurrenceLesson"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	lesson, err := s.db.ProvideLessonSeries(ctx, seriesId)
+	if err != nil {
+		log.Error(ctx, "failed to provide schedule series", zap.Error(err))
+
+		if errors.Is(err, storage.ErrSeriesNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrSeriesNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	series := lesson[0]
+
+	ok, err := s.canManage(ctx, series.GroupId, series.TrainerId, trainerId, managerRoles...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, ErrSeriesNotFound)
+	}
+
+	if !recurrence.IsOccurrence(series.Recurrence, seriesStart(series), recurrenceId) {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotAnOccurrence)
+	}
+
+	return lesson, nil
+}
+
+// occurringAt returns the series of lesson having an occurrence at recurrenceId. The series of a student
+// who has left the group end before it, the ones whose occurrence was detached exclude it.
+func occurringAt(lesson []*models.ScheduleSeries, recurrenceId time.Time) []*models.ScheduleSeries {
+	series := make([]*models.ScheduleSeries, 0, len(lesson))
+	for _, ser := range lesson {
+		if recurrence.IsOccurrence(ser.Recurrence, seriesStart(ser), recurrenceId) {
+			series = append(series, ser)
+		}
+	}
+
+	return series
+}
+
+func lessonSeriesIds(lesson []*models.ScheduleSeries) []uuid.UUID {
+	ids := make([]uuid.UUID, len(lesson))
+	for i, ser := range lesson {
+		ids[i] = ser.Id
+	}
+
+	return ids
+}
+
+func expandSeries(series *models.ScheduleSeries, from, to time.Time) []*models.Schedule {
+	duration := series.End.Sub(series.Start)
+
+	// occurrences which started before the window but are still running are included
+	starts := recurrence.Expand(series.Recurrence, seriesStart(series), from.Add(-duration), to)
+
+	schedules := make([]*models.Schedule, 0, len(starts))
+	for _, start := range starts {
+		if !start.Add(duration).After(from) {
+			continue
+		}
+
+		schedules = append(schedules, occurrenceOf(series, start))
+	}
+
+	return schedules
+}
+
+// seriesCreatedMessages builds the created event of the first occurrence of every stored series.
+func seriesCreatedMessages(series []*models.ScheduleSeries) ([]*models.OutboxMessage, error) {
+	messages := make([]*models.OutboxMessage, 0, len(series))
+	for _, ser := range series {
+		msg, err := redpanda.ScheduleCreatedMessage(occurrenceOf(ser, ser.Start))
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+// seriesOccurrences expands series over seriesConflictWindow from its start, for the conflict check.
+func seriesOccurrences(series *models.ScheduleSeries) []*models.Schedule {
+	return expandSeries(series, series.Start, series.Start.Add(seriesConflictWindow))
+}
+
+// occurrenceOf builds the occurrence of series starting at start.
+// Its id is derived from the series id and the start, so it is stable between expansions,
+// its lesson from the lesson of the series and the start, so it is shared with the other students.
+func occurrenceOf(series *models.ScheduleSeries, start time.Time) *models.Schedule {
+	return &models.Schedule{
+		Id:           uuid.NewSHA1(series.Id, []byte(start.UTC().Format(time.RFC3339))),
+		Start:        start,
+		End:          start.Add(series.End.Sub(series.Start)),
+		GroupName:    series.GroupName,
+		Title:        series.Title,
+		GroupId:      series.GroupId,
+		StudentId:    series.StudentId,
+		TrainerId:    series.TrainerId,
+		SeriesId:     series.Id,
+		RecurrenceId: start,
+		LessonId:     uuid.NewSHA1(series.LessonId, []byte(start.UTC().Format(time.RFC3339))),
+	}
+}
+
+func applyChanges(sched *models.Schedule, changes *models.Schedule) {
+	if changes == nil {
+		return
+	}
+
+	duration := sched.End.Sub(sched.Start)
+
+	if changes.Title != "" {
+		sched.Title = changes.Title
+	}
+	if changes.StudentId != uuid.Nil {
+		sched.StudentId = changes.StudentId
+	}
+	if !changes.Start.IsZero() {
+		sched.Start = changes.Start
+		sched.End = changes.Start.Add(duration)
+	}
+	if !changes.End.IsZero() {
+		sched.End = changes.End
+	}
+}
+
+// truncateSeries ends series right before at and returns the number of occurrences generated before it.
+func truncateSeries(series *models.ScheduleSeries, at time.Time) int {
+	rule := series.Recurrence
+	rule.Exceptions = nil
+
+	before := len(recurrence.Expand(rule, seriesStart(series), time.Time{}, at.Add(-time.Nanosecond)))
+
+	if series.Recurrence.Count > 0 {
+		series.Recurrence.Count = before
+	} else {
+		series.Recurrence.Until = at.Add(-time.Second)
+	}
+
+	return before
+}
+
+// shiftRecurrence moves exceptions by delta and BYDAY by the number of days anchor moves.
+func shiftRecurrence(rule models.Recurrence, anchor time.Time, delta time.Duration) models.Recurrence {
+	shifted := rule
+
+	days := (int(anchor.Add(delta).Weekday()) - int(anchor.Weekday()) + 7) % 7
+	shifted.ByDay = make([]time.Weekday, len(rule.ByDay))
+	for i, day := range rule.ByDay {
+		shifted.ByDay[i] = time.Weekday((int(day) + days) % 7)
+	}
+
+	shifted.Exceptions = make([]time.Time, len(rule.Exceptions))
+	for i, ex := range rule.Exceptions {
+		shifted.Exceptions[i] = ex.Add(delta)
+	}
+
+	return shifted
+}
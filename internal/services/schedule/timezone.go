@@ -0,0 +1,86 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-sso/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// SetTimeZone sets the IANA time zone of the user. It is the default zone of the series the user creates
+// as trainer, of the feeds of the user and of the events in the connected calendar.
+func (s *Schedule) SetTimeZone(ctx context.Context, userId uuid.UUID, timeZone string) error {
+	const op = "schedule.SetTimeZone"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	if _, err := time.LoadLocation(timeZone); err != nil || timeZone == "" {
+		return fmt.Errorf("%s: %w", op, ErrInvalidTimeZone)
+	}
+
+	if err := s.db.SetUserTimeZone(ctx, userId, timeZone); err != nil {
+		log.Error(ctx, "failed to set time zone", zap.Error(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetTimeZone returns the time zone of the user, UTC if the user has not set one.
+func (s *Schedule) GetTimeZone(ctx context.Context, userId uuid.UUID) (string, error) {
+	const op = "schedule.GetTimeZone"
+	log := logger.GetLoggerFromCtx(ctx)
+
+	timeZone, err := s.db.ProvideUserTimeZone(ctx, userId)
+	if err != nil {
+		log.Error(ctx, "failed to provide time zone", zap.Error(err))
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if timeZone == "" {
+		return time.UTC.String(), nil
+	}
+
+	return timeZone, nil
+}
+
+// userLocation returns the location of the user, falling back to UTC.
+func (s *Schedule) userLocation(ctx context.Context, userId uuid.UUID) *time.Location {
+	timeZone, err := s.GetTimeZone(ctx, userId)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loadLocation(timeZone)
+}
+
+// seriesStart returns the start of series in its time zone, the wall clock time its occurrences keep.
+func seriesStart(series *models.ScheduleSeries) time.Time {
+	return series.Start.In(loadLocation(series.TimeZone))
+}
+
+func loadLocation(timeZone string) *time.Location {
+	if timeZone == "" {
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// locationName returns the IANA name of the location of t, or an empty string if it is the local one.
+func locationName(t time.Time) string {
+	if t.Location() == time.Local {
+		return ""
+	}
+
+	return t.Location().String()
+}
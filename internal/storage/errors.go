@@ -3,11 +3,21 @@ package storage
 import "errors"
 
 var (
-	ErrSessionNotFound  = errors.New("session not found")
-	ErrScheduleNotFound = errors.New("schedule not found")
-	ErrGroupNotFound    = errors.New("group not found")
-	ErrEventNotFound    = errors.New("event not found")
-	ErrInvalidUUID      = errors.New("invalid uuid")
-	ErrCalendarNotFound = errors.New("calendar not found")
-	ErrStateNotFound    = errors.New("state not found")
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrScheduleNotFound     = errors.New("schedule not found")
+	ErrGroupNotFound        = errors.New("group not found")
+	ErrEventNotFound        = errors.New("event not found")
+	ErrInvalidUUID          = errors.New("invalid uuid")
+	ErrCalendarNotFound     = errors.New("calendar not found")
+	ErrStateNotFound        = errors.New("state not found")
+	ErrSeriesNotFound       = errors.New("series not found")
+	ErrScheduleConflict     = errors.New("schedule conflict")
+	ErrFeedNotFound         = errors.New("feed not found")
+	ErrAvailabilityNotFound = errors.New("availability not found")
+	ErrMemberNotFound       = errors.New("member not found")
+	ErrMemberExists         = errors.New("member already exists")
+	ErrInvitationNotFound   = errors.New("invitation not found")
+	ErrJoinRequestNotFound  = errors.New("join request not found")
+	ErrJoinRequestExists    = errors.New("join request already exists")
+	ErrGroupFull            = errors.New("group is full")
 )
@@ -0,0 +1,99 @@
+package psql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+)
+
+// SetAttendance records the attendance and its outbox messages atomically, replacing an earlier mark.
+func (s *Storage) SetAttendance(ctx context.Context, attendance *models.Attendance, messages []*models.OutboxMessage) error {
+	const op = "psql.SetAttendance"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO attendance (schedule_id, student_id, trainer_id, group_id, title, start_date, end_date, status, note)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (schedule_id, student_id) DO UPDATE
+	SET status = EXCLUDED.status, note = EXCLUDED.note, title = EXCLUDED.title,
+		start_date = EXCLUDED.start_date, end_date = EXCLUDED.end_date, marked_at = now()
+	RETURNING marked_at`
+
+	row := tx.QueryRow(ctx, query, attendance.ScheduleId, attendance.StudentId, attendance.TrainerId, attendance.GroupId,
+		attendance.Title, attendance.Start, attendance.End, attendance.Status, attendance.Note)
+	if err := row.Scan(&attendance.MarkedAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertOutbox(ctx, tx, messages); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ProvideAttendance returns the marks matching filter, ordered by start date.
+func (s *Storage) ProvideAttendance(ctx context.Context, filter models.AttendanceFilter) ([]*models.Attendance, error) {
+	const op = "psql.ProvideAttendance"
+
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+	where := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.StudentId != uuid.Nil {
+		where("student_id = $%d", filter.StudentId)
+	}
+	if filter.GroupId != uuid.Nil {
+		where("group_id = $%d", filter.GroupId)
+	}
+	if !filter.From.IsZero() {
+		where("end_date > $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("start_date < $%d", filter.To)
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT schedule_id, student_id, trainer_id, group_id, title, start_date, end_date, status, note, marked_at
+	FROM attendance
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	ORDER BY start_date, schedule_id`
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	marks := make([]*models.Attendance, 0)
+	for rows.Next() {
+		var a models.Attendance
+		if err := rows.Scan(&a.ScheduleId, &a.StudentId, &a.TrainerId, &a.GroupId, &a.Title, &a.Start, &a.End,
+			&a.Status, &a.Note, &a.MarkedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		marks = append(marks, &a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return marks, nil
+}
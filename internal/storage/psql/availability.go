@@ -0,0 +1,123 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+const availabilityColumns = `id, trainer_id, group_id, title, start_date, end_date, slot_length, buffer_before, buffer_after,
+	frequency, interval, by_day, count, until, exdates`
+
+func (s *Storage) CreateAvailability(ctx context.Context, av *models.Availability) error {
+	const op = "psql.CreateAvailability"
+
+	query := `INSERT INTO availability (trainer_id, group_id, title, start_date, end_date, slot_length, buffer_before, buffer_after,
+	frequency, interval, by_day, count, until, exdates)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id`
+
+	rule := av.Recurrence
+	row := s.db.QueryRow(ctx, query, av.TrainerId, av.GroupId, av.Title, av.Start, av.End,
+		int(av.SlotLength.Seconds()), int(av.BufferBefore.Seconds()), int(av.BufferAfter.Seconds()),
+		rule.Frequency, rule.Interval, weekdaysToInts(rule.ByDay), rule.Count, nullTime(rule.Until), exceptionsOrEmpty(rule.Exceptions))
+
+	if err := row.Scan(&av.Id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ProvideAvailability(ctx context.Context, availabilityId uuid.UUID) (*models.Availability, error) {
+	const op = "psql.ProvideAvailability"
+
+	query := `SELECT ` + availabilityColumns + ` FROM availability WHERE id = $1`
+
+	av, err := scanAvailability(s.db.QueryRow(ctx, query, availabilityId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.ErrAvailabilityNotFound
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return av, nil
+}
+
+func (s *Storage) ProvideTrainerAvailability(ctx context.Context, trainerId uuid.UUID) ([]*models.Availability, error) {
+	const op = "psql.ProvideTrainerAvailability"
+
+	query := `SELECT ` + availabilityColumns + ` FROM availability WHERE trainer_id = $1 ORDER BY start_date`
+
+	rows, err := s.db.Query(ctx, query, trainerId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	windows := make([]*models.Availability, 0)
+	for rows.Next() {
+		av, err := scanAvailability(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		windows = append(windows, av)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return windows, nil
+}
+
+func (s *Storage) DeleteAvailability(ctx context.Context, availabilityId, trainerId uuid.UUID) error {
+	const op = "psql.DeleteAvailability"
+
+	query := `DELETE FROM availability WHERE id = $1 AND trainer_id = $2`
+
+	tag, err := s.db.Exec(ctx, query, availabilityId, trainerId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrAvailabilityNotFound
+	}
+
+	return nil
+}
+
+func scanAvailability(row pgx.Row) (*models.Availability, error) {
+	var av models.Availability
+	var slotLength, bufferBefore, bufferAfter int
+	var byDay []int16
+	var until *time.Time
+
+	if err := row.Scan(
+		&av.Id, &av.TrainerId, &av.GroupId, &av.Title, &av.Start, &av.End, &slotLength, &bufferBefore, &bufferAfter,
+		&av.Recurrence.Frequency, &av.Recurrence.Interval, &byDay, &av.Recurrence.Count, &until, &av.Recurrence.Exceptions,
+	); err != nil {
+		return nil, err
+	}
+
+	av.SlotLength = time.Duration(slotLength) * time.Second
+	av.BufferBefore = time.Duration(bufferBefore) * time.Second
+	av.BufferAfter = time.Duration(bufferAfter) * time.Second
+
+	av.Recurrence.ByDay = make([]time.Weekday, len(byDay))
+	for i := range byDay {
+		av.Recurrence.ByDay[i] = time.Weekday(byDay[i])
+	}
+	if until != nil {
+		av.Recurrence.Until = *until
+	}
+
+	return &av, nil
+}
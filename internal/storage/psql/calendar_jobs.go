@@ -0,0 +1,186 @@
+package psql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const calendarJobColumns = `id, user_id, group_id, schedule_ids, operation, title, start_date, end_date, calendar_id,
+	status, attempts, last_error, created_at, next_attempt_at`
+
+func (s *Storage) CreateCalendarJobs(ctx context.Context, jobs []*models.CalendarJob) error {
+	const op = "psql.CreateCalendarJobs"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertCalendarJobs(ctx, tx, jobs); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func insertCalendarJobs(ctx context.Context, tx pgx.Tx, jobs []*models.CalendarJob) error {
+	query := `INSERT INTO calendar_jobs (user_id, group_id, schedule_ids, operation, title, start_date, end_date, calendar_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	for _, job := range jobs {
+		if job.ScheduleIds == nil {
+			job.ScheduleIds = []uuid.UUID{}
+		}
+
+		if _, err := tx.Exec(ctx, query, job.UserId, job.GroupId, job.ScheduleIds, job.Operation,
+			job.Event.Title, nullTime(job.Event.Start), nullTime(job.Event.End), job.Event.CalendarId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ProvideCalendarJobs returns the pending and dead jobs of the user in the order they were queued.
+func (s *Storage) ProvideCalendarJobs(ctx context.Context, userId uuid.UUID) ([]*models.CalendarJob, error) {
+	const op = "psql.ProvideCalendarJobs"
+
+	query := `SELECT ` + calendarJobColumns + ` FROM calendar_jobs WHERE user_id = $1 ORDER BY id`
+
+	rows, err := s.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	jobs, err := scanCalendarJobs(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return jobs, nil
+}
+
+// RetryCalendarJobs requeues the dead jobs of the user with a fresh set of attempts
+// and returns their number.
+func (s *Storage) RetryCalendarJobs(ctx context.Context, userId uuid.UUID) (int, error) {
+	const op = "psql.RetryCalendarJobs"
+
+	query := `UPDATE calendar_jobs SET status = 'pending', attempts = 0, next_attempt_at = now()
+	WHERE user_id = $1 AND status = 'dead'`
+
+	tag, err := s.db.Exec(ctx, query, userId)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// ClaimCalendarJobs leases up to limit due jobs for lease, so that concurrent workers skip them.
+// Only the oldest pending job of each user is claimed, so the jobs of a user are applied in order.
+func (s *Storage) ClaimCalendarJobs(ctx context.Context, limit int, lease time.Duration) ([]*models.CalendarJob, error) {
+	const op = "psql.ClaimCalendarJobs"
+
+	query := `UPDATE calendar_jobs SET next_attempt_at = now() + make_interval(secs => $2)
+	WHERE id IN (
+		SELECT id FROM calendar_jobs
+		WHERE status = 'pending' AND next_attempt_at <= now()
+		AND NOT EXISTS (
+			SELECT 1 FROM calendar_jobs AS earlier
+			WHERE earlier.user_id = calendar_jobs.user_id AND earlier.status = 'pending' AND earlier.id < calendar_jobs.id
+		)
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + calendarJobColumns
+
+	rows, err := s.db.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	jobs, err := scanCalendarJobs(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return jobs, nil
+}
+
+func (s *Storage) DeleteCalendarJob(ctx context.Context, id int64) error {
+	const op = "psql.DeleteCalendarJob"
+
+	query := `DELETE FROM calendar_jobs WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) MarkCalendarJobFailed(ctx context.Context, id int64, nextAttempt time.Time, reason string) error {
+	const op = "psql.MarkCalendarJobFailed"
+
+	query := `UPDATE calendar_jobs SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3 WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, id, nextAttempt, reason); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) MarkCalendarJobDead(ctx context.Context, id int64, reason string) error {
+	const op = "psql.MarkCalendarJobDead"
+
+	query := `UPDATE calendar_jobs SET status = 'dead', attempts = attempts + 1, last_error = $2 WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, id, reason); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func scanCalendarJobs(rows pgx.Rows) ([]*models.CalendarJob, error) {
+	defer rows.Close()
+
+	jobs := make([]*models.CalendarJob, 0)
+	for rows.Next() {
+		var job models.CalendarJob
+		var start, end *time.Time
+
+		if err := rows.Scan(
+			&job.Id, &job.UserId, &job.GroupId, &job.ScheduleIds, &job.Operation, &job.Event.Title, &start, &end, &job.Event.CalendarId,
+			&job.Status, &job.Attempts, &job.LastError, &job.CreatedAt, &job.NextAttemptAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if start != nil {
+			job.Event.Start = *start
+		}
+		if end != nil {
+			job.Event.End = *end
+		}
+
+		jobs = append(jobs, &job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
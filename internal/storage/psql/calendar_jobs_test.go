@@ -0,0 +1,164 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStorage connects to the database given by the PSQL_* variables and migrates it.
+// The test is skipped without PSQL_HOST.
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	if os.Getenv("PSQL_HOST") == "" {
+		t.Skip("PSQL_HOST is not set")
+	}
+
+	port, err := strconv.Atoi(os.Getenv("PSQL_PORT"))
+	require.NoError(t, err)
+
+	cfg := PsqlConfig{
+		Host:     os.Getenv("PSQL_HOST"),
+		Port:     port,
+		User:     os.Getenv("PSQL_USER"),
+		Password: os.Getenv("PSQL_PASSWORD"),
+		DB:       os.Getenv("PSQL_DB"),
+	}
+
+	m, err := migrate.New(
+		"file://../../../migrations",
+		fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable", cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DB),
+	)
+	require.NoError(t, err)
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("migrations: %v", err)
+	}
+
+	s := New(cfg)
+	t.Cleanup(s.db.Close)
+
+	return s
+}
+
+// claimedOf returns the claimed jobs of userId.
+func claimedOf(t *testing.T, s *Storage, userId uuid.UUID) []*models.CalendarJob {
+	t.Helper()
+
+	jobs, err := s.ClaimCalendarJobs(context.Background(), 1000, time.Minute)
+	require.NoError(t, err)
+
+	claimed := make([]*models.CalendarJob, 0)
+	for _, job := range jobs {
+		if job.UserId == userId {
+			claimed = append(claimed, job)
+		}
+	}
+
+	return claimed
+}
+
+func TestClaimCalendarJobs(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	userId := uuid.New()
+	groupId := uuid.New()
+
+	first := &models.CalendarJob{UserId: userId, GroupId: groupId, Operation: models.CalendarOperationShare}
+	second := &models.CalendarJob{UserId: userId, GroupId: groupId, Operation: models.CalendarOperationUnshare}
+	require.NoError(t, s.CreateCalendarJobs(ctx, []*models.CalendarJob{first, second}))
+
+	// the jobs of a user are claimed one at a time, in order
+	claimed := claimedOf(t, s, userId)
+	require.Len(t, claimed, 1)
+	require.Equal(t, models.CalendarOperationShare, claimed[0].Operation)
+	firstId := claimed[0].Id
+
+	// a leased job is not claimed again
+	require.Empty(t, claimedOf(t, s, userId))
+
+	// a failed job is retried once due, with the attempt recorded
+	require.NoError(t, s.MarkCalendarJobFailed(ctx, firstId, time.Now().Add(-time.Second), "unavailable"))
+	claimed = claimedOf(t, s, userId)
+	require.Len(t, claimed, 1)
+	require.Equal(t, firstId, claimed[0].Id)
+	require.Equal(t, 1, claimed[0].Attempts)
+	require.Equal(t, "unavailable", claimed[0].LastError)
+
+	// a failed job waits for its backoff and holds back the later jobs of the user
+	require.NoError(t, s.MarkCalendarJobFailed(ctx, firstId, time.Now().Add(time.Hour), "unavailable"))
+	require.Empty(t, claimedOf(t, s, userId))
+
+	// a dead job no longer holds them back
+	require.NoError(t, s.MarkCalendarJobDead(ctx, firstId, "unavailable"))
+	claimed = claimedOf(t, s, userId)
+	require.Len(t, claimed, 1)
+	require.Equal(t, models.CalendarOperationUnshare, claimed[0].Operation)
+	require.NoError(t, s.DeleteCalendarJob(ctx, claimed[0].Id))
+
+	jobs, err := s.ProvideCalendarJobs(ctx, userId)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.Equal(t, models.CalendarJobDead, jobs[0].Status)
+	require.Equal(t, 3, jobs[0].Attempts)
+
+	n, err := s.RetryCalendarJobs(ctx, userId)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.NoError(t, s.DeleteCalendarJob(ctx, firstId))
+}
+
+func TestCreateScheduleQueuesCalendarJobs(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	sched := &models.Schedule{
+		Title:     "test",
+		GroupId:   uuid.New(),
+		TrainerId: uuid.New(),
+		StudentId: uuid.New(),
+		Start:     start,
+		End:       start.Add(time.Hour),
+	}
+
+	newChanges := func(sched *models.Schedule) func() ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		return func() ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+			return []*models.CalendarJob{{
+				UserId:      sched.TrainerId,
+				GroupId:     sched.GroupId,
+				ScheduleIds: []uuid.UUID{sched.Id},
+				Operation:   models.CalendarOperationCreate,
+			}}, nil, nil
+		}
+	}
+
+	require.NoError(t, s.CreateSchedule(ctx, sched, newChanges(sched)))
+
+	jobs, err := s.ProvideCalendarJobs(ctx, sched.TrainerId)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.Equal(t, []uuid.UUID{sched.Id}, jobs[0].ScheduleIds)
+
+	// the jobs of a rejected schedule are not queued
+	overlapping := *sched
+	overlapping.Id = uuid.Nil
+	overlapping.StudentId = uuid.New()
+	err = s.CreateSchedule(ctx, &overlapping, newChanges(&overlapping))
+	require.ErrorIs(t, err, storage.ErrScheduleConflict)
+
+	jobs, err = s.ProvideCalendarJobs(ctx, sched.TrainerId)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+}
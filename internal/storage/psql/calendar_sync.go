@@ -0,0 +1,137 @@
+package psql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+const calendarColumns = `calendars.user_id, calendars.group_id, calendars.calendar_id, calendars.sync_token,
+	calendars.channel_id, calendars.channel_resource_id, calendars.channel_expires_at`
+
+// ClaimCalendarSyncs returns up to limit calendars due for a sync and puts their next sync off by interval,
+// so that concurrent syncers skip them. Only the calendars of the owners and co-trainers of the groups are
+// synced, as changes made by others are not applied.
+func (s *Storage) ClaimCalendarSyncs(ctx context.Context, limit int, interval time.Duration) ([]*models.GroupCalendar, error) {
+	const op = "psql.ClaimCalendarSyncs"
+
+	query := `UPDATE calendars SET next_sync_at = now() + make_interval(secs => $2)
+	WHERE (user_id, group_id) IN (
+		SELECT calendars.user_id, calendars.group_id FROM calendars
+		INNER JOIN groups ON groups.id = calendars.group_id
+		WHERE calendars.next_sync_at <= now()
+		AND (calendars.user_id = groups.trainer_id OR calendars.user_id IN (
+			SELECT user_id FROM group_staff WHERE group_staff.group_id = calendars.group_id AND role = 'co-trainer'
+		))
+		ORDER BY calendars.next_sync_at
+		LIMIT $1
+		FOR UPDATE OF calendars SKIP LOCKED
+	)
+	RETURNING ` + calendarColumns
+
+	rows, err := s.db.Query(ctx, query, limit, interval.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	calendars := make([]*models.GroupCalendar, 0)
+	for rows.Next() {
+		calend, err := scanGroupCalendar(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		calendars = append(calendars, calend)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return calendars, nil
+}
+
+func (s *Storage) SetCalendarSyncToken(ctx context.Context, userId, groupId uuid.UUID, syncToken string) error {
+	const op = "psql.SetCalendarSyncToken"
+
+	query := `UPDATE calendars SET sync_token = $3 WHERE user_id = $1 AND group_id = $2`
+
+	if _, err := s.db.Exec(ctx, query, userId, groupId, syncToken); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetCalendarChannel replaces the push notification channel of the calendar.
+func (s *Storage) SetCalendarChannel(ctx context.Context, userId, groupId uuid.UUID, channel *models.CalendarChannel) error {
+	const op = "psql.SetCalendarChannel"
+
+	query := `UPDATE calendars SET channel_id = $3, channel_resource_id = $4, channel_expires_at = $5
+	WHERE user_id = $1 AND group_id = $2`
+
+	if _, err := s.db.Exec(ctx, query, userId, groupId, channel.Id, channel.ResourceId, nullTime(channel.ExpiresAt)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RequestCalendarSync makes the calendar watched by the channel due for a sync.
+func (s *Storage) RequestCalendarSync(ctx context.Context, channelId uuid.UUID, resourceId string) error {
+	const op = "psql.RequestCalendarSync"
+
+	query := `UPDATE calendars SET next_sync_at = now() WHERE channel_id = $1 AND channel_resource_id = $2`
+
+	tag, err := s.db.Exec(ctx, query, channelId, resourceId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrCalendarNotFound
+	}
+
+	return nil
+}
+
+// ProvideEventSchedules returns the schedules linked to the event in the calendar of the user.
+// Schedules with calendar jobs of the user which are not applied yet are left out, as the event
+// does not show their latest state.
+func (s *Storage) ProvideEventSchedules(ctx context.Context, userId uuid.UUID, calendarId, eventId string) ([]*models.Schedule, error) {
+	query := `SELECT groups.name, schedules.group_id, schedules.title, schedules.student_id, schedules.trainer_id, schedules.start_date, schedules.end_date, schedules.id, schedules.series_id, schedules.recurrence_id, schedules.cancelled_at, schedules.cancelled_by, COALESCE(schedules.cancel_reason, ''), schedules.lesson_id
+	FROM schedules
+	INNER JOIN groups ON groups.id = schedules.group_id
+	INNER JOIN schedule_events ON schedule_events.schedule_id = schedules.id
+	WHERE schedule_events.user_id = $1 AND schedule_events.calendar_id = $2 AND schedule_events.event_id = $3
+	AND NOT EXISTS (
+		SELECT 1 FROM calendar_jobs WHERE calendar_jobs.user_id = $1 AND schedules.id = ANY(calendar_jobs.schedule_ids)
+	)
+	ORDER BY schedules.id`
+
+	return s.provideSchedules(ctx, func() (pgx.Rows, error) { return s.db.Query(ctx, query, userId, calendarId, eventId) })
+}
+
+func scanGroupCalendar(row pgx.Row) (*models.GroupCalendar, error) {
+	var calend models.GroupCalendar
+	var channelId uuid.NullUUID
+	var expiresAt *time.Time
+
+	if err := row.Scan(&calend.UserId, &calend.GroupId, &calend.CalendarId, &calend.SyncToken,
+		&channelId, &calend.Channel.ResourceId, &expiresAt); err != nil {
+		return nil, err
+	}
+
+	if channelId.Valid {
+		calend.Channel.Id = channelId.UUID
+	}
+	if expiresAt != nil {
+		calend.Channel.ExpiresAt = *expiresAt
+	}
+
+	return &calend, nil
+}
@@ -6,41 +6,47 @@ import (
 	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
 	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
 	"github.com/jackc/pgx/v5"
 )
 
-func (s *Storage) CreateCalendar(ctx context.Context, groupId uuid.UUID, calendarId string) error {
+// CreateCalendar stores calendarId as the calendar of the group in the account of the user,
+// replacing the previous one, if any, together with its sync state.
+func (s *Storage) CreateCalendar(ctx context.Context, userId, groupId uuid.UUID, calendarId string) error {
 	const op = "psql.CreateCalendar"
 
-	query := `INSERT INTO calendars (group_id, calendar_id)
-	VALUES ($1, $2)`
+	query := `INSERT INTO calendars (user_id, group_id, calendar_id)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, group_id) DO UPDATE SET calendar_id = EXCLUDED.calendar_id, sync_token = '',
+		channel_id = NULL, channel_resource_id = '', channel_expires_at = NULL`
 
-	if _, err := s.db.Exec(ctx, query, groupId, calendarId); err != nil {
+	if _, err := s.db.Exec(ctx, query, userId, groupId, calendarId); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
-func (s *Storage) DeleteCalendar(ctx context.Context, groupId uuid.UUID) error {
+func (s *Storage) DeleteCalendar(ctx context.Context, userId, groupId uuid.UUID) error {
 	const op = "psql.DeleteCalendar"
 
-	query := `DELETE FROM calendars WHERE group_id = $1`
+	query := `DELETE FROM calendars WHERE user_id = $1 AND group_id = $2`
 
-	if _, err := s.db.Exec(ctx, query, groupId); err != nil {
+	if _, err := s.db.Exec(ctx, query, userId, groupId); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
-func (s *Storage) ProvideCalendar(ctx context.Context, groupId uuid.UUID) (string, error) {
+// ProvideCalendar returns the id of the calendar of the group in the account of the user.
+func (s *Storage) ProvideCalendar(ctx context.Context, userId, groupId uuid.UUID) (string, error) {
 	const op = "psql.ProvideCalendar"
 
-	query := `SELECT calendar_id FROM calendars WHERE group_id = $1`
+	query := `SELECT calendar_id FROM calendars WHERE user_id = $1 AND group_id = $2`
 
-	row := s.db.QueryRow(ctx, query, groupId)
+	row := s.db.QueryRow(ctx, query, userId, groupId)
 
 	var calendarId string
 
@@ -54,3 +60,66 @@ func (s *Storage) ProvideCalendar(ctx context.Context, groupId uuid.UUID) (strin
 
 	return calendarId, nil
 }
+
+func (s *Storage) CreateScheduleEvents(ctx context.Context, events []*models.ScheduleEvent) error {
+	const op = "psql.CreateScheduleEvents"
+
+	query := `INSERT INTO schedule_events (schedule_id, user_id, calendar_id, event_id)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (schedule_id, user_id) DO UPDATE SET calendar_id = EXCLUDED.calendar_id, event_id = EXCLUDED.event_id`
+
+	for _, event := range events {
+		if _, err := s.db.Exec(ctx, query, event.ScheduleId, event.UserId, event.CalendarId, event.EventId); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) ProvideScheduleEvent(ctx context.Context, scheduleId, userId uuid.UUID) (*models.ScheduleEvent, error) {
+	const op = "psql.ProvideScheduleEvent"
+
+	query := `SELECT schedule_id, user_id, calendar_id, event_id FROM schedule_events
+	WHERE schedule_id = $1 AND user_id = $2`
+
+	var event models.ScheduleEvent
+
+	row := s.db.QueryRow(ctx, query, scheduleId, userId)
+	if err := row.Scan(&event.ScheduleId, &event.UserId, &event.CalendarId, &event.EventId); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.ErrEventNotFound
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &event, nil
+}
+
+func (s *Storage) DeleteScheduleEvent(ctx context.Context, scheduleId, userId uuid.UUID) error {
+	const op = "psql.DeleteScheduleEvent"
+
+	query := `DELETE FROM schedule_events WHERE schedule_id = $1 AND user_id = $2`
+
+	if _, err := s.db.Exec(ctx, query, scheduleId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CountScheduleEvents returns the number of schedules linked to the calendar event.
+func (s *Storage) CountScheduleEvents(ctx context.Context, calendarId, eventId string) (int, error) {
+	const op = "psql.CountScheduleEvents"
+
+	query := `SELECT count(*) FROM schedule_events WHERE calendar_id = $1 AND event_id = $2`
+
+	var count int
+
+	if err := s.db.QueryRow(ctx, query, calendarId, eventId).Scan(&count); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,44 @@
+package psql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendarsByUser(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	groupId := uuid.New()
+	trainerId := uuid.New()
+	studentId := uuid.New()
+
+	// every member has a calendar of the group in their own account
+	require.NoError(t, s.CreateCalendar(ctx, trainerId, groupId, "trainer"))
+	require.NoError(t, s.CreateCalendar(ctx, studentId, groupId, "student"))
+
+	calendarId, err := s.ProvideCalendar(ctx, trainerId, groupId)
+	require.NoError(t, err)
+	require.Equal(t, "trainer", calendarId)
+	calendarId, err = s.ProvideCalendar(ctx, studentId, groupId)
+	require.NoError(t, err)
+	require.Equal(t, "student", calendarId)
+
+	// a calendar recreated after it was deleted on the provider replaces the mapping
+	require.NoError(t, s.CreateCalendar(ctx, studentId, groupId, "recreated"))
+	calendarId, err = s.ProvideCalendar(ctx, studentId, groupId)
+	require.NoError(t, err)
+	require.Equal(t, "recreated", calendarId)
+
+	require.NoError(t, s.DeleteCalendar(ctx, studentId, groupId))
+	_, err = s.ProvideCalendar(ctx, studentId, groupId)
+	require.ErrorIs(t, err, storage.ErrCalendarNotFound)
+
+	calendarId, err = s.ProvideCalendar(ctx, trainerId, groupId)
+	require.NoError(t, err)
+	require.Equal(t, "trainer", calendarId)
+}
@@ -0,0 +1,42 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// SetFeedToken replaces the feed token of the user, so that links with the previous token stop working.
+func (s *Storage) SetFeedToken(ctx context.Context, userId uuid.UUID, tokenHash string) error {
+	const op = "psql.SetFeedToken"
+
+	query := `INSERT INTO feed_tokens (user_id, token_hash) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = now()`
+
+	if _, err := s.db.Exec(ctx, query, userId, tokenHash); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ProvideFeedUser(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	const op = "psql.ProvideFeedUser"
+
+	query := `SELECT user_id FROM feed_tokens WHERE token_hash = $1`
+
+	var userId uuid.UUID
+	if err := s.db.QueryRow(ctx, query, tokenHash).Scan(&userId); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, fmt.Errorf("%s: %w", op, storage.ErrFeedNotFound)
+		}
+
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userId, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/hesoyamTM/apphelper-schedule/internal/models"
@@ -11,55 +12,118 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
-func (s *Storage) CreateGroup(ctx context.Context, name, link string, trainerId uuid.UUID) error {
+// groupColumns selects a group together with its active students, in joining order, and its staff, ordered by user id.
+const groupColumns = `groups.id, groups.name, groups.trainer_id,
+	ARRAY(SELECT user_id FROM group_members WHERE group_members.group_id = groups.id AND status = 'active' ORDER BY joined_at),
+	groups.invitation_link, groups.invitation_id, groups.requires_approval,
+	groups.description, groups.capacity, groups.default_duration, groups.location, groups.archived_at, groups.calendar_mode,
+	ARRAY(SELECT user_id FROM group_staff WHERE group_staff.group_id = groups.id ORDER BY user_id),
+	ARRAY(SELECT role FROM group_staff WHERE group_staff.group_id = groups.id ORDER BY user_id)`
+
+// CreateGroup creates the group with invitation as its default invitation. Unlike the other invitations,
+// the raw token of the default one is kept on the group as its link.
+func (s *Storage) CreateGroup(ctx context.Context, name string, trainerId uuid.UUID, invitation *models.Invitation) error {
 	const op = "psql.CreateGroup"
 
-	query := `INSERT INTO groups (name, trainer_id, student_ids, invitation_link)
-	VALUES ($1, $2, array[]::uuid[], $3) RETURNING id`
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
 
-	if _, err := s.db.Exec(ctx, query, name, trainerId, link); err != nil {
+	query := `INSERT INTO groups (name, trainer_id, invitation_link)
+	VALUES ($1, $2, $3) RETURNING id`
+
+	if err := tx.QueryRow(ctx, query, name, trainerId, invitation.Token).Scan(&invitation.GroupId); err != nil {
 		// TODO: error
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := insertInvitation(ctx, tx, invitation); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `UPDATE groups SET invitation_id = $2 WHERE id = $1`
+
+	if _, err := tx.Exec(ctx, query, invitation.GroupId, invitation.Id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
-func (s *Storage) AddToGroup(ctx context.Context, studentId uuid.UUID, link string) (*models.Group, error) {
+// AddToGroup adds the student to the group of the invitation with the token hash and counts the use.
+// Revoked, expired, used up invitations and the ones meant for another student are not found.
+// If the group requires approval, a pending join request is created instead and returned.
+// The calendar jobs and the messages built by newChanges from the group and the request, if any,
+// are stored in the same transaction.
+func (s *Storage) AddToGroup(ctx context.Context, studentId uuid.UUID, tokenHash string, newChanges func(*models.Group, *models.JoinRequest) ([]*models.CalendarJob, []*models.OutboxMessage, error)) (*models.Group, *models.JoinRequest, error) {
 	const op = "psql.AddToGroup"
 
-	query := `
-	UPDATE groups SET student_ids = array_append(student_ids, $1)
-	WHERE invitation_link = $2
-	AND NOT $1 = ANY(student_ids::uuid[])
-	AND $1 != trainer_id
-	RETURNING id, name, trainer_id, student_ids, invitation_link`
-
-	row := s.db.QueryRow(ctx, query, studentId, link)
-
-	var group models.Group
-	var studentIds []uuid.NullUUID
-	var trainerId uuid.NullUUID
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
 
-	if err := row.Scan(&group.Id, &group.Name, &trainerId, &studentIds, &group.Link); err != nil {
+	query := `
+	UPDATE group_invitations SET uses = uses + 1
+	WHERE token_hash = $1
+	AND revoked_at IS NULL
+	AND (expires_at IS NULL OR expires_at > now())
+	AND (max_uses = 0 OR uses < max_uses)
+	AND (student_id IS NULL OR student_id = $2)
+	AND group_id IN (SELECT id FROM groups WHERE trainer_id != $2 AND archived_at IS NULL)
+	RETURNING id, group_id`
+
+	var invitationId, groupId uuid.UUID
+	if err := tx.QueryRow(ctx, query, tokenHash, studentId).Scan(&invitationId, &groupId); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, storage.ErrGroupNotFound
+			return nil, nil, storage.ErrInvitationNotFound
 		}
-		return &group, fmt.Errorf("%s: %w", op, err)
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	group.Students = make([]uuid.UUID, len(studentIds))
-	for i := range studentIds {
-		if studentIds[i].Valid {
-			group.Students[i] = studentIds[i].UUID
-		}
+	query = `SELECT ` + groupColumns + ` FROM groups WHERE id = $1`
+
+	group, err := scanGroup(tx.QueryRow(ctx, query, groupId))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
 	}
-	if trainerId.Valid {
-		group.TrainerId = trainerId.UUID
+
+	var request *models.JoinRequest
+	if group.RequiresApproval {
+		request = &models.JoinRequest{GroupId: groupId, StudentId: studentId, InvitationId: invitationId, Status: models.JoinRequestPending}
+
+		err = insertJoinRequest(ctx, tx, request)
 	} else {
-		return &group, fmt.Errorf("%s: %w", op, storage.ErrInvalidUUID)
+		err = insertGroupMember(ctx, tx, groupId, studentId, invitationId)
 	}
-	return &group, nil
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	jobs, messages, err := newChanges(group, request)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertCalendarJobs(ctx, tx, jobs); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := insertOutbox(ctx, tx, messages); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return group, request, nil
 }
 
 func (s *Storage) ProvideGroups(ctx context.Context, trainerId, studentId uuid.UUID) ([]*models.Group, error) {
@@ -80,45 +144,29 @@ func (s *Storage) ProvideGroup(ctx context.Context, groupId uuid.UUID) (*models.
 	const op = "psql.provideGroup"
 
 	query := `
-	SELECT id, name, trainer_id, student_ids, invitation_link
+	SELECT ` + groupColumns + `
 	FROM groups
 	WHERE id  = $1`
 
-	row := s.db.QueryRow(ctx, query, groupId)
-
-	var group models.Group
-	var studentIds []uuid.NullUUID
-	var trainerId uuid.NullUUID
-
-	if err := row.Scan(&group.Id, &group.Name, &trainerId, &studentIds, &group.Link); err != nil {
+	group, err := scanGroup(s.db.QueryRow(ctx, query, groupId))
+	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, storage.ErrGroupNotFound
 		}
-		return &group, fmt.Errorf("%s: %w", op, err)
-	}
-
-	group.Students = make([]uuid.UUID, len(studentIds))
-	for i := range studentIds {
-		if studentIds[i].Valid {
-			group.Students[i] = studentIds[i].UUID
-		}
-	}
-	if trainerId.Valid {
-		group.TrainerId = trainerId.UUID
-	} else {
-		return &group, fmt.Errorf("%s: %w", op, storage.ErrInvalidUUID)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &group, nil
+	return group, nil
 }
 
 func (s *Storage) provideGroups(ctx context.Context, trainerId, studentId uuid.UUID) ([]*models.Group, error) {
 	const op = "psql.provideGroups"
 
 	query := `
-	SELECT id, name, trainer_id, student_ids, invitation_link
+	SELECT ` + groupColumns + `
 	FROM groups
-	WHERE trainer_id = $1 AND $2 = ANY(student_ids::uuid[])`
+	WHERE (trainer_id = $1 OR id IN (SELECT group_id FROM group_staff WHERE user_id = $1)) AND id IN (SELECT group_id FROM group_members WHERE user_id = $2 AND status = 'active')
+	AND archived_at IS NULL`
 
 	rows, err := s.db.Query(ctx, query, trainerId, studentId)
 	if err != nil {
@@ -127,15 +175,16 @@ func (s *Storage) provideGroups(ctx context.Context, trainerId, studentId uuid.U
 		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
 
 	groups := make([]*models.Group, 0)
 	for rows.Next() {
-		var group models.Group
-		if err := rows.Scan(&group.Id, &group.Name, &group.TrainerId, &group.Students, &group.Link); err != nil {
+		group, err := scanGroup(rows)
+		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 
-		groups = append(groups, &group)
+		groups = append(groups, group)
 	}
 
 	return groups, nil
@@ -145,9 +194,9 @@ func (s *Storage) provideGroupsByTrainer(ctx context.Context, trainerId uuid.UUI
 	const op = "psql.provideGroupsByTrainer"
 
 	query := `
-	SELECT id, name, trainer_id, student_ids, invitation_link
+	SELECT ` + groupColumns + `
 	FROM groups
-	WHERE trainer_id = $1`
+	WHERE (trainer_id = $1 OR id IN (SELECT group_id FROM group_staff WHERE user_id = $1)) AND archived_at IS NULL`
 
 	rows, err := s.db.Query(ctx, query, trainerId)
 	if err != nil {
@@ -156,15 +205,16 @@ func (s *Storage) provideGroupsByTrainer(ctx context.Context, trainerId uuid.UUI
 		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
 
 	groups := make([]*models.Group, 0)
 	for rows.Next() {
-		var group models.Group
-		if err := rows.Scan(&group.Id, &group.Name, &group.TrainerId, &group.Students, &group.Link); err != nil {
+		group, err := scanGroup(rows)
+		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 
-		groups = append(groups, &group)
+		groups = append(groups, group)
 	}
 
 	return groups, nil
@@ -174,9 +224,9 @@ func (s *Storage) provideGroupsByStudent(ctx context.Context, studentId uuid.UUI
 	const op = "psql.provideGroupsByStudent"
 
 	query := `
-	SELECT id, name, trainer_id, student_ids, invitation_link
+	SELECT ` + groupColumns + `
 	FROM groups
-	WHERE $1 = ANY(student_ids::uuid[])`
+	WHERE id IN (SELECT group_id FROM group_members WHERE user_id = $1 AND status = 'active') AND archived_at IS NULL`
 
 	rows, err := s.db.Query(ctx, query, studentId)
 	if err != nil {
@@ -185,30 +235,491 @@ func (s *Storage) provideGroupsByStudent(ctx context.Context, studentId uuid.UUI
 		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
 
 	groups := make([]*models.Group, 0)
 	for rows.Next() {
-		var group models.Group
-		if err := rows.Scan(&group.Id, &group.Name, &group.TrainerId, &group.Students, &group.Link); err != nil {
+		group, err := scanGroup(rows)
+		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 
-		groups = append(groups, &group)
+		groups = append(groups, group)
 	}
 
 	return groups, nil
 }
 
-func (s *Storage) DeleteGroup(ctx context.Context, groupId uuid.UUID, trainerId uuid.UUID) error {
+// DeleteGroup deletes the group of the trainer in a single transaction: the lessons which have not started yet
+// are cancelled by the trainer for reason, the series which have not started yet, availability, members, staff,
+// invitations, join requests and the calendar mapping of the group are deleted. Past lessons are kept,
+// the running series end now.
+// newChanges returns the calendar jobs and outbox messages of the deletion, given the cancelled lessons
+// and the calendars of the group in the accounts of its members.
+func (s *Storage) DeleteGroup(
+	ctx context.Context,
+	groupId, trainerId uuid.UUID,
+	reason string,
+	newChanges func(cancelled []*models.Schedule, calendars []*models.GroupCalendar) ([]*models.CalendarJob, []*models.OutboxMessage, error),
+) error {
 	const op = "psql.DeleteGroup"
 
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
 	query := `DELETE FROM groups WHERE id = $1 AND trainer_id = $2`
 
-	if _, err := s.db.Exec(ctx, query, groupId); err != nil {
-		// TODO: error
+	tag, err := tx.Exec(ctx, query, groupId, trainerId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrGroupNotFound
+	}
+
+	cancelled, err := cancelGroupSchedules(ctx, tx, groupId, uuid.Nil, trainerId, reason)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := endGroupSeries(ctx, tx, groupId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, table := range []string{"availability", "group_members", "group_staff", "group_invitations", "group_join_requests"} {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE group_id = $1`, groupId); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	calendars, err := deleteGroupCalendars(ctx, tx, groupId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	jobs, messages, err := newChanges(cancelled, calendars)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertCalendarJobs(ctx, tx, jobs); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertOutbox(ctx, tx, messages); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// endGroupSeries deletes the series of the group which have not started yet and ends the others now,
+// keeping their past occurrences.
+func endGroupSeries(ctx context.Context, tx pgx.Tx, groupId uuid.UUID) error {
+	now := time.Now()
+
+	query := `DELETE FROM schedule_series WHERE group_id = $1 AND start_date > $2`
+
+	if _, err := tx.Exec(ctx, query, groupId, now); err != nil {
+		return err
+	}
+
+	query = `UPDATE schedule_series SET until = $2 WHERE group_id = $1 AND (until IS NULL OR until > $2)`
+
+	if _, err := tx.Exec(ctx, query, groupId, now); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func deleteGroupCalendars(ctx context.Context, tx pgx.Tx, groupId uuid.UUID) ([]*models.GroupCalendar, error) {
+	query := `DELETE FROM calendars WHERE group_id = $1 RETURNING user_id, calendar_id`
+
+	rows, err := tx.Query(ctx, query, groupId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calendars := make([]*models.GroupCalendar, 0)
+	for rows.Next() {
+		calend := models.GroupCalendar{GroupId: groupId}
+
+		if err := rows.Scan(&calend.UserId, &calend.CalendarId); err != nil {
+			return nil, err
+		}
+
+		calendars = append(calendars, &calend)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return calendars, nil
+}
+
+// cancelGroupSchedules cancels the lessons of the group which have not started yet, only the ones of the student
+// unless studentId is nil, and returns them.
+func cancelGroupSchedules(ctx context.Context, tx pgx.Tx, groupId, studentId, userId uuid.UUID, reason string) ([]*models.Schedule, error) {
+	query := `UPDATE schedules SET cancelled_at = now(), cancelled_by = $2, cancel_reason = $3
+	WHERE group_id = $1 AND ($4 = $5 OR student_id = $4) AND start_date > now() AND cancelled_at IS NULL
+	RETURNING id, title, student_id, trainer_id, start_date, end_date, series_id, recurrence_id, cancelled_at, lesson_id`
+
+	rows, err := tx.Query(ctx, query, groupId, userId, reason, studentId, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scheds := make([]*models.Schedule, 0)
+	for rows.Next() {
+		sched := models.Schedule{
+			GroupId:      groupId,
+			CancelledBy:  userId,
+			CancelReason: reason,
+		}
+		var studentId, seriesId uuid.NullUUID
+		var recurrenceId *time.Time
+
+		if err := rows.Scan(&sched.Id, &sched.Title, &studentId, &sched.TrainerId, &sched.Start, &sched.End,
+			&seriesId, &recurrenceId, &sched.CancelledAt, &sched.LessonId); err != nil {
+			return nil, err
+		}
+
+		if studentId.Valid {
+			sched.StudentId = studentId.UUID
+		}
+		if seriesId.Valid {
+			sched.SeriesId = seriesId.UUID
+		}
+		if recurrenceId != nil {
+			sched.RecurrenceId = *recurrenceId
+		}
+
+		scheds = append(scheds, &sched)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return scheds, nil
+}
+
+// UpdateGroup replaces the name and the settings of the group.
+func (s *Storage) UpdateGroup(ctx context.Context, group *models.Group) error {
+	const op = "psql.UpdateGroup"
+
+	query := `UPDATE groups SET name = $2, description = $3, capacity = $4, default_duration = $5, location = $6
+	WHERE id = $1`
+
+	tag, err := s.db.Exec(ctx, query, group.Id, group.Name, group.Description, group.Capacity,
+		int(group.DefaultDuration.Seconds()), group.Location)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrGroupNotFound
+	}
+
+	return nil
+}
+
+// SetGroupArchived archives the group, which hides it from the group lists and stops new students from joining,
+// or brings it back. Its schedules are kept either way.
+func (s *Storage) SetGroupArchived(ctx context.Context, groupId uuid.UUID, archived bool) error {
+	const op = "psql.SetGroupArchived"
+
+	query := `UPDATE groups SET archived_at = CASE WHEN $2 THEN now() END WHERE id = $1`
+
+	tag, err := s.db.Exec(ctx, query, groupId, archived)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrGroupNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) SetCalendarMode(ctx context.Context, groupId uuid.UUID, mode models.CalendarMode) error {
+	const op = "psql.SetCalendarMode"
+
+	query := `UPDATE groups SET calendar_mode = $2 WHERE id = $1`
+
+	tag, err := s.db.Exec(ctx, query, groupId, mode)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrGroupNotFound
+	}
+
+	return nil
+}
+
+// SetGroupRole grants the role to the user in the group, replacing the previous one, and stores messages in the outbox.
+func (s *Storage) SetGroupRole(ctx context.Context, groupId, userId uuid.UUID, role models.GroupRole, messages []*models.OutboxMessage) error {
+	const op = "psql.SetGroupRole"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO group_staff (group_id, user_id, role) VALUES ($1, $2, $3)
+	ON CONFLICT (group_id, user_id) DO UPDATE SET role = EXCLUDED.role`
+
+	if _, err := tx.Exec(ctx, query, groupId, userId, role); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertOutbox(ctx, tx, messages); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteGroupRole revokes the staff role of the user in the group and stores messages in the outbox.
+func (s *Storage) DeleteGroupRole(ctx context.Context, groupId, userId uuid.UUID, messages []*models.OutboxMessage) error {
+	const op = "psql.DeleteGroupRole"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `DELETE FROM group_staff WHERE group_id = $1 AND user_id = $2`
+
+	tag, err := tx.Exec(ctx, query, groupId, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrMemberNotFound
+	}
+
+	if err := insertOutbox(ctx, tx, messages); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveGroupMember ends the active membership of the user with status, left or removed, in a single transaction
+// with the end of the user's lessons in the group: the ones which have not started yet are cancelled by removedBy
+// for reason, the series in truncated are stored as ended and the ones in deleted are deleted.
+// newChanges returns the calendar jobs and outbox messages of the removal, given the cancelled lessons.
+func (s *Storage) RemoveGroupMember(
+	ctx context.Context,
+	groupId, userId, removedBy uuid.UUID,
+	status models.MembershipStatus,
+	reason string,
+	truncated, deleted []*models.ScheduleSeries,
+	newChanges func(cancelled []*models.Schedule) ([]*models.CalendarJob, []*models.OutboxMessage, error),
+) error {
+	const op = "psql.RemoveGroupMember"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE group_members SET status = $3, left_at = now()
+	WHERE group_id = $1 AND user_id = $2 AND status = 'active'`
+
+	tag, err := tx.Exec(ctx, query, groupId, userId, status)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrMemberNotFound
+	}
+
+	cancelled, err := cancelGroupSchedules(ctx, tx, groupId, userId, removedBy, reason)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, ser := range truncated {
+		if err := updateSeries(ctx, tx, ser); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	query = `DELETE FROM schedule_series WHERE id = $1 AND student_id = $2`
+
+	for _, ser := range deleted {
+		if _, err := tx.Exec(ctx, query, ser.Id, userId); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
 
+	jobs, messages, err := newChanges(cancelled)
+	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := insertCalendarJobs(ctx, tx, jobs); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertOutbox(ctx, tx, messages); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ProvideGroupMembers returns the active memberships of the group in joining order,
+// or all of them, past ones included, with history.
+func (s *Storage) ProvideGroupMembers(ctx context.Context, groupId uuid.UUID, history bool) ([]*models.Membership, error) {
+	const op = "psql.ProvideGroupMembers"
+
+	query := `
+	SELECT id, group_id, user_id, status, joined_at, left_at, invitation_id
+	FROM group_members
+	WHERE group_id = $1 AND ($2 OR status = 'active')
+	ORDER BY joined_at`
+
+	rows, err := s.db.Query(ctx, query, groupId, history)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	members := make([]*models.Membership, 0)
+	for rows.Next() {
+		var member models.Membership
+		var status string
+		var leftAt *time.Time
+		var invitationId uuid.NullUUID
+
+		if err := rows.Scan(&member.Id, &member.GroupId, &member.UserId, &status, &member.JoinedAt, &leftAt, &invitationId); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		member.Status = models.MembershipStatus(status)
+		if leftAt != nil {
+			member.LeftAt = *leftAt
+		}
+		if invitationId.Valid {
+			member.InvitationId = invitationId.UUID
+		}
+
+		members = append(members, &member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return members, nil
+}
+
+// insertGroupMember starts a membership of the user through the invitation, if any,
+// failing with ErrMemberExists if one is active and with ErrGroupFull if the group has reached its capacity.
+// The group is locked until the end of tx, so that concurrent joins cannot exceed the capacity.
+func insertGroupMember(ctx context.Context, tx pgx.Tx, groupId, userId, invitationId uuid.UUID) error {
+	query := `SELECT capacity FROM groups WHERE id = $1 FOR UPDATE`
+
+	var capacity int
+	if err := tx.QueryRow(ctx, query, groupId).Scan(&capacity); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrGroupNotFound
+		}
+		return err
+	}
+
+	if capacity > 0 {
+		query = `SELECT count(*) FROM group_members WHERE group_id = $1 AND status = 'active'`
+
+		var members int
+		if err := tx.QueryRow(ctx, query, groupId).Scan(&members); err != nil {
+			return err
+		}
+		if members >= capacity {
+			return storage.ErrGroupFull
+		}
+	}
+
+	query = `INSERT INTO group_members (group_id, user_id, invitation_id) VALUES ($1, $2, $3)
+	ON CONFLICT (group_id, user_id) WHERE status = 'active' DO NOTHING`
+
+	tag, err := tx.Exec(ctx, query, groupId, userId, nullUUID(invitationId))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrMemberExists
+	}
+
 	return nil
 }
+
+func scanGroup(row pgx.Row) (*models.Group, error) {
+	var group models.Group
+	var studentIds []uuid.NullUUID
+	var trainerId, invitationId uuid.NullUUID
+	var staffIds []uuid.UUID
+	var staffRoles []string
+	var defaultDuration int
+	var archivedAt *time.Time
+
+	if err := row.Scan(
+		&group.Id, &group.Name, &trainerId, &studentIds, &group.Link, &invitationId, &group.RequiresApproval,
+		&group.Description, &group.Capacity, &defaultDuration, &group.Location, &archivedAt, &group.CalendarMode,
+		&staffIds, &staffRoles,
+	); err != nil {
+		return nil, err
+	}
+
+	group.DefaultDuration = time.Duration(defaultDuration) * time.Second
+	if invitationId.Valid {
+		group.InvitationId = invitationId.UUID
+	}
+	if archivedAt != nil {
+		group.ArchivedAt = *archivedAt
+	}
+
+	group.Students = make([]uuid.UUID, len(studentIds))
+	for i := range studentIds {
+		if studentIds[i].Valid {
+			group.Students[i] = studentIds[i].UUID
+		}
+	}
+	if trainerId.Valid {
+		group.TrainerId = trainerId.UUID
+	} else {
+		return nil, storage.ErrInvalidUUID
+	}
+
+	group.Staff = make([]*models.GroupMember, len(staffIds))
+	for i := range staffIds {
+		group.Staff[i] = &models.GroupMember{UserId: staffIds[i], Role: models.GroupRole(staffRoles[i])}
+	}
+
+	return &group, nil
+}
@@ -0,0 +1,230 @@
+package psql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestInvitation(groupId, trainerId uuid.UUID) *models.Invitation {
+	token := uuid.NewString()
+	sum := sha256.Sum256([]byte(token))
+
+	return &models.Invitation{GroupId: groupId, CreatedBy: trainerId, Token: token, TokenHash: hex.EncodeToString(sum[:])}
+}
+
+func TestAddToGroupCapacity(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	trainerId := uuid.New()
+	noJoinChanges := func(*models.Group, *models.JoinRequest) ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		return nil, nil, nil
+	}
+
+	invitation := newTestInvitation(uuid.Nil, trainerId)
+	require.NoError(t, s.CreateGroup(ctx, "test", trainerId, invitation))
+
+	group, err := s.ProvideGroup(ctx, invitation.GroupId)
+	require.NoError(t, err)
+	group.Capacity = 1
+	require.NoError(t, s.UpdateGroup(ctx, group))
+
+	studentId := uuid.New()
+	_, _, err = s.AddToGroup(ctx, studentId, invitation.TokenHash, noJoinChanges)
+	require.NoError(t, err)
+
+	_, _, err = s.AddToGroup(ctx, studentId, invitation.TokenHash, noJoinChanges)
+	require.ErrorIs(t, err, storage.ErrMemberExists)
+	_, _, err = s.AddToGroup(ctx, uuid.New(), invitation.TokenHash, noJoinChanges)
+	require.ErrorIs(t, err, storage.ErrGroupFull)
+
+	// the place of a student who left is free again, the membership is kept in the history
+	err = s.RemoveGroupMember(ctx, group.Id, studentId, studentId, models.MembershipLeft, "left the group", nil, nil,
+		func([]*models.Schedule) ([]*models.CalendarJob, []*models.OutboxMessage, error) { return nil, nil, nil })
+	require.NoError(t, err)
+
+	_, _, err = s.AddToGroup(ctx, uuid.New(), invitation.TokenHash, noJoinChanges)
+	require.NoError(t, err)
+
+	members, err := s.ProvideGroupMembers(ctx, group.Id, true)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	require.Equal(t, models.MembershipLeft, members[0].Status)
+}
+
+func TestJoinRequests(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	trainerId := uuid.New()
+	noJoinChanges := func(*models.Group, *models.JoinRequest) ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		return nil, nil, nil
+	}
+
+	invitation := newTestInvitation(uuid.Nil, trainerId)
+	require.NoError(t, s.CreateGroup(ctx, "test", trainerId, invitation))
+	groupId := invitation.GroupId
+	require.NoError(t, s.SetRequiresApproval(ctx, groupId, true))
+
+	approvedId := uuid.New()
+	_, request, err := s.AddToGroup(ctx, approvedId, invitation.TokenHash, noJoinChanges)
+	require.NoError(t, err)
+	require.NotNil(t, request)
+
+	// a student asks once until the request is decided
+	_, _, err = s.AddToGroup(ctx, approvedId, invitation.TokenHash, noJoinChanges)
+	require.ErrorIs(t, err, storage.ErrJoinRequestExists)
+
+	rejectedId := uuid.New()
+	_, rejected, err := s.AddToGroup(ctx, rejectedId, invitation.TokenHash, noJoinChanges)
+	require.NoError(t, err)
+
+	requests, err := s.ProvideJoinRequests(ctx, groupId)
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+
+	request.Status = models.JoinRequestApproved
+	request.DecidedBy = trainerId
+	require.NoError(t, s.DecideJoinRequest(ctx, request, nil, nil))
+	require.ErrorIs(t, s.DecideJoinRequest(ctx, request, nil, nil), storage.ErrJoinRequestNotFound)
+
+	rejected.Status = models.JoinRequestRejected
+	rejected.DecidedBy = trainerId
+	require.NoError(t, s.DecideJoinRequest(ctx, rejected, nil, nil))
+
+	group, err := s.ProvideGroup(ctx, groupId)
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{approvedId}, group.Students)
+}
+
+func TestDeleteGroup(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	trainerId := uuid.New()
+	studentId := uuid.New()
+	noJoinChanges := func(*models.Group, *models.JoinRequest) ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		return nil, nil, nil
+	}
+	noChanges := func() ([]*models.CalendarJob, []*models.OutboxMessage, error) { return nil, nil, nil }
+
+	invitation := newTestInvitation(uuid.Nil, trainerId)
+	require.NoError(t, s.CreateGroup(ctx, "test", trainerId, invitation))
+	groupId := invitation.GroupId
+
+	_, _, err := s.AddToGroup(ctx, studentId, invitation.TokenHash, noJoinChanges)
+	require.NoError(t, err)
+	require.NoError(t, s.CreateCalendar(ctx, trainerId, groupId, "calendar"))
+
+	now := time.Now().Truncate(time.Second)
+	upcoming := &models.Schedule{Title: "test", GroupId: groupId, TrainerId: trainerId, StudentId: studentId, Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)}
+	past := &models.Schedule{Title: "test", GroupId: groupId, TrainerId: trainerId, StudentId: studentId, Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)}
+	require.NoError(t, s.CreateSchedule(ctx, upcoming, noChanges))
+	require.NoError(t, s.CreateSchedule(ctx, past, noChanges))
+
+	// nothing is deleted if the changes cannot be built
+	failed := errors.New("failed")
+	err = s.DeleteGroup(ctx, groupId, trainerId, "group deleted", func([]*models.Schedule, []*models.GroupCalendar) ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		return nil, nil, failed
+	})
+	require.ErrorIs(t, err, failed)
+
+	_, err = s.ProvideGroup(ctx, groupId)
+	require.NoError(t, err)
+	sched, err := s.ProvideSchedule(ctx, upcoming.Id)
+	require.NoError(t, err)
+	require.True(t, sched.CancelledAt.IsZero())
+	_, err = s.ProvideCalendar(ctx, trainerId, groupId)
+	require.NoError(t, err)
+
+	var cancelled []*models.Schedule
+	var calendars []*models.GroupCalendar
+	err = s.DeleteGroup(ctx, groupId, trainerId, "group deleted", func(c []*models.Schedule, cals []*models.GroupCalendar) ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		cancelled, calendars = c, cals
+
+		return []*models.CalendarJob{{UserId: trainerId, GroupId: groupId, Operation: models.CalendarOperationDeleteCalendar}}, nil, nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, cancelled, 1)
+	require.Equal(t, upcoming.Id, cancelled[0].Id)
+	require.Len(t, calendars, 1)
+	require.Equal(t, "calendar", calendars[0].CalendarId)
+
+	_, err = s.ProvideGroup(ctx, groupId)
+	require.ErrorIs(t, err, storage.ErrGroupNotFound)
+	_, err = s.ProvideCalendar(ctx, trainerId, groupId)
+	require.ErrorIs(t, err, storage.ErrCalendarNotFound)
+
+	sched, err = s.ProvideSchedule(ctx, upcoming.Id)
+	require.NoError(t, err)
+	require.False(t, sched.CancelledAt.IsZero())
+	require.Equal(t, "group deleted", sched.CancelReason)
+	sched, err = s.ProvideSchedule(ctx, past.Id)
+	require.NoError(t, err)
+	require.True(t, sched.CancelledAt.IsZero())
+
+	jobs, err := s.ProvideCalendarJobs(ctx, trainerId)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+
+	members, err := s.ProvideGroupMembers(ctx, groupId, true)
+	require.NoError(t, err)
+	require.Empty(t, members)
+}
+
+func TestDeleteGroupKeepsHistory(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	trainerId := uuid.New()
+	studentId := uuid.New()
+	noChanges := func() ([]*models.CalendarJob, []*models.OutboxMessage, error) { return nil, nil, nil }
+	noMessages := func() ([]*models.OutboxMessage, error) { return nil, nil }
+
+	invitation := newTestInvitation(uuid.Nil, trainerId)
+	require.NoError(t, s.CreateGroup(ctx, "test", trainerId, invitation))
+	groupId := invitation.GroupId
+
+	now := time.Now().Truncate(time.Second)
+	past := &models.Schedule{Title: "past", GroupId: groupId, TrainerId: trainerId, StudentId: studentId, Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)}
+	require.NoError(t, s.CreateSchedule(ctx, past, noChanges))
+
+	weekly := models.Recurrence{Frequency: models.FrequencyWeekly, Interval: 1}
+	running := &models.ScheduleSeries{Title: "running", GroupId: groupId, TrainerId: trainerId, StudentId: studentId, Start: now.AddDate(0, 0, -20), End: now.AddDate(0, 0, -20).Add(time.Hour), Recurrence: weekly}
+	upcoming := &models.ScheduleSeries{Title: "upcoming", GroupId: groupId, TrainerId: trainerId, StudentId: studentId, Start: now.AddDate(0, 0, 1), End: now.AddDate(0, 0, 1).Add(time.Hour), Recurrence: weekly}
+	require.NoError(t, s.CreateScheduleSeries(ctx, []*models.ScheduleSeries{running, upcoming}, nil, noMessages))
+
+	err := s.DeleteGroup(ctx, groupId, trainerId, "group deleted", func([]*models.Schedule, []*models.GroupCalendar) ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		return nil, nil, nil
+	})
+	require.NoError(t, err)
+
+	// the past lessons of the deleted group are still listed
+	scheds, err := s.ProvideSchedules(ctx, models.ScheduleFilter{TrainerId: trainerId, GroupId: groupId})
+	require.NoError(t, err)
+	require.Len(t, scheds, 1)
+	require.Equal(t, past.Id, scheds[0].Id)
+	require.Empty(t, scheds[0].GroupName)
+
+	scheds, err = s.ProvideSchedules(ctx, models.ScheduleFilter{StudentId: studentId})
+	require.NoError(t, err)
+	require.Len(t, scheds, 1)
+
+	// and so are the series which had started, up to now
+	series, err := s.ProvideScheduleSeries(ctx, trainerId, uuid.Nil)
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	require.Equal(t, running.Id, series[0].Id)
+	require.False(t, series[0].Recurrence.Until.IsZero())
+	require.False(t, series[0].Recurrence.Until.After(time.Now()))
+}
@@ -0,0 +1,181 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+const invitationColumns = `id, group_id, token_hash, created_by, student_id, max_uses, uses, expires_at, revoked_at, created_at`
+
+func (s *Storage) CreateInvitation(ctx context.Context, invitation *models.Invitation) error {
+	const op = "psql.CreateInvitation"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertInvitation(ctx, tx, invitation); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ProvideInvitations returns the invitations of the group, revoked ones included, newest first.
+func (s *Storage) ProvideInvitations(ctx context.Context, groupId uuid.UUID) ([]*models.Invitation, error) {
+	const op = "psql.ProvideInvitations"
+
+	query := `SELECT ` + invitationColumns + `
+	FROM group_invitations
+	WHERE group_id = $1
+	ORDER BY created_at DESC`
+
+	rows, err := s.db.Query(ctx, query, groupId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	invitations := make([]*models.Invitation, 0)
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		invitations = append(invitations, invitation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invitations, nil
+}
+
+func (s *Storage) ProvideInvitation(ctx context.Context, invitationId uuid.UUID) (*models.Invitation, error) {
+	const op = "psql.ProvideInvitation"
+
+	query := `SELECT ` + invitationColumns + `
+	FROM group_invitations
+	WHERE id = $1`
+
+	invitation, err := scanInvitation(s.db.QueryRow(ctx, query, invitationId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return invitation, nil
+}
+
+// RevokeInvitation revokes the invitation of the group, so that its token stops working.
+func (s *Storage) RevokeInvitation(ctx context.Context, groupId, invitationId uuid.UUID) error {
+	const op = "psql.RevokeInvitation"
+
+	query := `UPDATE group_invitations SET revoked_at = now()
+	WHERE id = $1 AND group_id = $2 AND revoked_at IS NULL`
+
+	tag, err := s.db.Exec(ctx, query, invitationId, groupId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+// RotateInvitation revokes invitation and issues next in its place. If invitation is the default link
+// of the group, next becomes it.
+func (s *Storage) RotateInvitation(ctx context.Context, invitation, next *models.Invitation, isDefault bool) error {
+	const op = "psql.RotateInvitation"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE group_invitations SET revoked_at = now()
+	WHERE id = $1 AND group_id = $2 AND revoked_at IS NULL`
+
+	tag, err := tx.Exec(ctx, query, invitation.Id, invitation.GroupId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrInvitationNotFound
+	}
+
+	if err := insertInvitation(ctx, tx, next); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if isDefault {
+		query = `UPDATE groups SET invitation_link = $2, invitation_id = $3 WHERE id = $1`
+
+		if _, err := tx.Exec(ctx, query, next.GroupId, next.Token, next.Id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func insertInvitation(ctx context.Context, tx pgx.Tx, invitation *models.Invitation) error {
+	query := `INSERT INTO group_invitations (group_id, token_hash, created_by, student_id, max_uses, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+
+	row := tx.QueryRow(ctx, query, invitation.GroupId, invitation.TokenHash, invitation.CreatedBy,
+		nullUUID(invitation.StudentId), invitation.MaxUses, nullTime(invitation.ExpiresAt))
+
+	return row.Scan(&invitation.Id, &invitation.CreatedAt)
+}
+
+func scanInvitation(row pgx.Row) (*models.Invitation, error) {
+	var invitation models.Invitation
+	var studentId uuid.NullUUID
+	var expiresAt, revokedAt *time.Time
+
+	if err := row.Scan(
+		&invitation.Id, &invitation.GroupId, &invitation.TokenHash, &invitation.CreatedBy, &studentId, &invitation.MaxUses,
+		&invitation.Uses, &expiresAt, &revokedAt, &invitation.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if studentId.Valid {
+		invitation.StudentId = studentId.UUID
+	}
+	if expiresAt != nil {
+		invitation.ExpiresAt = *expiresAt
+	}
+	if revokedAt != nil {
+		invitation.RevokedAt = *revokedAt
+	}
+
+	return &invitation, nil
+}
+
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
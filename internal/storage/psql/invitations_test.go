@@ -0,0 +1,72 @@
+package psql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddToGroupInvitations(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	trainerId := uuid.New()
+	noJoinChanges := func(*models.Group, *models.JoinRequest) ([]*models.CalendarJob, []*models.OutboxMessage, error) {
+		return nil, nil, nil
+	}
+
+	invitation := newTestInvitation(uuid.Nil, trainerId)
+	require.NoError(t, s.CreateGroup(ctx, "test", trainerId, invitation))
+	groupId := invitation.GroupId
+
+	group, err := s.ProvideGroup(ctx, groupId)
+	require.NoError(t, err)
+	require.Equal(t, invitation.Id, group.InvitationId)
+	require.Equal(t, invitation.Token, group.Link)
+
+	// a used up invitation is not found
+	single := newTestInvitation(groupId, trainerId)
+	single.MaxUses = 1
+	require.NoError(t, s.CreateInvitation(ctx, single))
+
+	_, _, err = s.AddToGroup(ctx, uuid.New(), single.TokenHash, noJoinChanges)
+	require.NoError(t, err)
+	_, _, err = s.AddToGroup(ctx, uuid.New(), single.TokenHash, noJoinChanges)
+	require.ErrorIs(t, err, storage.ErrInvitationNotFound)
+
+	// so is an expired one
+	expired := newTestInvitation(groupId, trainerId)
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, s.CreateInvitation(ctx, expired))
+
+	_, _, err = s.AddToGroup(ctx, uuid.New(), expired.TokenHash, noJoinChanges)
+	require.ErrorIs(t, err, storage.ErrInvitationNotFound)
+
+	// and a revoked one
+	revoked := newTestInvitation(groupId, trainerId)
+	require.NoError(t, s.CreateInvitation(ctx, revoked))
+	require.NoError(t, s.RevokeInvitation(ctx, groupId, revoked.Id))
+	require.ErrorIs(t, s.RevokeInvitation(ctx, groupId, revoked.Id), storage.ErrInvitationNotFound)
+
+	_, _, err = s.AddToGroup(ctx, uuid.New(), revoked.TokenHash, noJoinChanges)
+	require.ErrorIs(t, err, storage.ErrInvitationNotFound)
+
+	// rotating the default invitation replaces the link of the group
+	next := newTestInvitation(groupId, trainerId)
+	require.NoError(t, s.RotateInvitation(ctx, invitation, next, true))
+
+	group, err = s.ProvideGroup(ctx, groupId)
+	require.NoError(t, err)
+	require.Equal(t, next.Id, group.InvitationId)
+	require.Equal(t, next.Token, group.Link)
+
+	_, _, err = s.AddToGroup(ctx, uuid.New(), invitation.TokenHash, noJoinChanges)
+	require.ErrorIs(t, err, storage.ErrInvitationNotFound)
+	_, _, err = s.AddToGroup(ctx, uuid.New(), next.TokenHash, noJoinChanges)
+	require.NoError(t, err)
+}
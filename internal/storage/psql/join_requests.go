@@ -0,0 +1,171 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+const joinRequestColumns = `id, group_id, student_id, invitation_id, status, requested_at, decided_at, decided_by`
+
+func (s *Storage) SetRequiresApproval(ctx context.Context, groupId uuid.UUID, required bool) error {
+	const op = "psql.SetRequiresApproval"
+
+	query := `UPDATE groups SET requires_approval = $2 WHERE id = $1`
+
+	tag, err := s.db.Exec(ctx, query, groupId, required)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrGroupNotFound
+	}
+
+	return nil
+}
+
+// ProvideJoinRequests returns the pending join requests of the group, oldest first.
+func (s *Storage) ProvideJoinRequests(ctx context.Context, groupId uuid.UUID) ([]*models.JoinRequest, error) {
+	const op = "psql.ProvideJoinRequests"
+
+	query := `SELECT ` + joinRequestColumns + `
+	FROM group_join_requests
+	WHERE group_id = $1 AND status = 'pending'
+	ORDER BY requested_at`
+
+	rows, err := s.db.Query(ctx, query, groupId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	requests := make([]*models.JoinRequest, 0)
+	for rows.Next() {
+		request, err := scanJoinRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		requests = append(requests, request)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return requests, nil
+}
+
+func (s *Storage) ProvideJoinRequest(ctx context.Context, requestId uuid.UUID) (*models.JoinRequest, error) {
+	const op = "psql.ProvideJoinRequest"
+
+	query := `SELECT ` + joinRequestColumns + `
+	FROM group_join_requests
+	WHERE id = $1`
+
+	request, err := scanJoinRequest(s.db.QueryRow(ctx, query, requestId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.ErrJoinRequestNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return request, nil
+}
+
+// DecideJoinRequest stores the decision on the pending request and, if it is approved, adds the student
+// to the group through the invitation of the request. jobs and messages are stored in the same transaction.
+func (s *Storage) DecideJoinRequest(ctx context.Context, request *models.JoinRequest, jobs []*models.CalendarJob, messages []*models.OutboxMessage) error {
+	const op = "psql.DecideJoinRequest"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE group_join_requests SET status = $2, decided_at = $3, decided_by = $4
+	WHERE id = $1 AND status = 'pending'`
+
+	tag, err := tx.Exec(ctx, query, request.Id, request.Status, request.DecidedAt, request.DecidedBy)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrJoinRequestNotFound
+	}
+
+	if request.Status == models.JoinRequestApproved {
+		if err := insertGroupMember(ctx, tx, request.GroupId, request.StudentId, request.InvitationId); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := insertCalendarJobs(ctx, tx, jobs); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := insertOutbox(ctx, tx, messages); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// insertJoinRequest creates the pending request, failing with ErrMemberExists if the student already belongs
+// to the group and with ErrJoinRequestExists if a request of the student is pending.
+func insertJoinRequest(ctx context.Context, tx pgx.Tx, request *models.JoinRequest) error {
+	query := `SELECT EXISTS (SELECT 1 FROM group_members WHERE group_id = $1 AND user_id = $2 AND status = 'active')`
+
+	var member bool
+	if err := tx.QueryRow(ctx, query, request.GroupId, request.StudentId).Scan(&member); err != nil {
+		return err
+	}
+	if member {
+		return storage.ErrMemberExists
+	}
+
+	query = `INSERT INTO group_join_requests (group_id, student_id, invitation_id) VALUES ($1, $2, $3)
+	ON CONFLICT (group_id, student_id) WHERE status = 'pending' DO NOTHING
+	RETURNING id, requested_at`
+
+	err := tx.QueryRow(ctx, query, request.GroupId, request.StudentId, nullUUID(request.InvitationId)).Scan(&request.Id, &request.RequestedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrJoinRequestExists
+	}
+
+	return err
+}
+
+func scanJoinRequest(row pgx.Row) (*models.JoinRequest, error) {
+	var request models.JoinRequest
+	var invitationId, decidedBy uuid.NullUUID
+	var status string
+	var decidedAt *time.Time
+
+	if err := row.Scan(&request.Id, &request.GroupId, &request.StudentId, &invitationId, &status, &request.RequestedAt, &decidedAt, &decidedBy); err != nil {
+		return nil, err
+	}
+
+	request.Status = models.JoinRequestStatus(status)
+	if invitationId.Valid {
+		request.InvitationId = invitationId.UUID
+	}
+	if decidedAt != nil {
+		request.DecidedAt = *decidedAt
+	}
+	if decidedBy.Valid {
+		request.DecidedBy = decidedBy.UUID
+	}
+
+	return &request, nil
+}
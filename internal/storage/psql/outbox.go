@@ -0,0 +1,97 @@
+package psql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// ClaimOutboxMessages leases up to limit pending messages for lease, so that concurrent relays skip them.
+func (s *Storage) ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxMessage, error) {
+	const op = "psql.ClaimOutboxMessages"
+
+	query := `UPDATE outbox SET next_attempt_at = now() + make_interval(secs => $2)
+	WHERE id IN (
+		SELECT id FROM outbox
+		WHERE delivered_at IS NULL AND next_attempt_at <= now()
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, topic, key, payload, attempts`
+
+	rows, err := s.db.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	messages := make([]*models.OutboxMessage, 0)
+	for rows.Next() {
+		var msg models.OutboxMessage
+
+		if err := rows.Scan(&msg.Id, &msg.Topic, &msg.Key, &msg.Payload, &msg.Attempts); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		messages = append(messages, &msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return messages, nil
+}
+
+func (s *Storage) MarkOutboxDelivered(ctx context.Context, id int64) error {
+	const op = "psql.MarkOutboxDelivered"
+
+	query := `UPDATE outbox SET delivered_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) MarkOutboxFailed(ctx context.Context, id int64, nextAttempt time.Time, reason string) error {
+	const op = "psql.MarkOutboxFailed"
+
+	query := `UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3 WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, id, nextAttempt, reason); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteDeliveredOutboxMessages deletes the messages delivered more than retention ago and returns their number.
+func (s *Storage) DeleteDeliveredOutboxMessages(ctx context.Context, retention time.Duration) (int64, error) {
+	const op = "psql.DeleteDeliveredOutboxMessages"
+
+	query := `DELETE FROM outbox WHERE delivered_at < now() - make_interval(secs => $1)`
+
+	tag, err := s.db.Exec(ctx, query, retention.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func insertOutbox(ctx context.Context, tx pgx.Tx, messages []*models.OutboxMessage) error {
+	query := `INSERT INTO outbox (topic, key, payload) VALUES ($1, $2, $3)`
+
+	for _, msg := range messages {
+		if _, err := tx.Exec(ctx, query, msg.Topic, msg.Key, msg.Payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,7 +2,11 @@ package psql
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/hesoyamTM/apphelper-schedule/internal/models"
@@ -11,46 +15,433 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
-func (s *Storage) CreateSchedule(ctx context.Context, sched *models.Schedule) error {
+func (s *Storage) CreateSchedule(ctx context.Context, sched *models.Schedule, newChanges func() ([]*models.CalendarJob, []*models.OutboxMessage, error)) error {
 	const op = "psql.CreateSchedule"
 
-	query := `INSERT INTO schedules (group_id, title, student_id, trainer_id, start_date, end_date)
-	VALUES ($1, $2, $3, $4, $5, $6)`
-
 	logger.GetLoggerFromCtx(ctx).Debug(ctx, fmt.Sprintf("start_date: %v, end_date: %v", sched.Start, sched.End))
 
-	if _, err := s.db.Exec(ctx, query, sched.GroupId, sched.Title, sched.StudentId, sched.TrainerId, sched.Start, sched.End); err != nil {
-		// TODO: error
+	if err := s.CreateSchedules(ctx, []*models.Schedule{sched}, newChanges); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CreateSchedules inserts scheds, their calendar jobs and outbox messages atomically. The jobs and messages
+// are built by newChanges once the ids of scheds are known. Participants are locked for the duration of the transaction, so concurrent
+// requests cannot book overlapping schedules; storage.ErrScheduleConflict is returned on overlap with a schedule
+// or an occurrence of a series.
+func (s *Storage) CreateSchedules(ctx context.Context, scheds []*models.Schedule, newChanges func() ([]*models.CalendarJob, []*models.OutboxMessage, error)) error {
+	const op = "psql.CreateSchedules"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.insertSchedules(ctx, tx, scheds); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	jobs, messages, err := newChanges()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertCalendarJobs(ctx, tx, jobs); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertOutbox(ctx, tx, messages); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ImportSchedules inserts scheds, series, their calendar jobs and outbox messages in a single transaction,
+// with the overlap check of CreateSchedules for scheds and of CreateScheduleSeries for occurrences of series.
+// The jobs and messages are built by newChanges once the ids of scheds and series are known.
+func (s *Storage) ImportSchedules(ctx context.Context, scheds []*models.Schedule, series []*models.ScheduleSeries, occurrences []*models.Schedule, newChanges func() ([]*models.CalendarJob, []*models.OutboxMessage, error)) error {
+	const op = "psql.ImportSchedules"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// all participants are locked at once, in the order which avoids deadlocks
+	userIds := make([]uuid.UUID, 0, len(scheds)+len(occurrences)+1)
+	for _, sched := range append(slices.Clip(scheds), occurrences...) {
+		userIds = append(userIds, participants(sched)...)
+	}
+	if err := lockUsers(ctx, tx, userIds); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.insertSchedules(ctx, tx, scheds); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkOccurrences(ctx, tx, occurrences, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, ser := range series {
+		if err := insertScheduleSeries(ctx, tx, ser); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	jobs, messages, err := newChanges()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertCalendarJobs(ctx, tx, jobs); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertOutbox(ctx, tx, messages); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) insertSchedules(ctx context.Context, tx pgx.Tx, scheds []*models.Schedule) error {
+	userIds := make([]uuid.UUID, 0, len(scheds)+1)
+	for _, sched := range scheds {
+		userIds = append(userIds, participants(sched)...)
+	}
+	if err := lockUsers(ctx, tx, userIds); err != nil {
+		return err
+	}
+
+	for _, sched := range scheds {
+		conflicts, err := s.provideLockedConflicts(ctx, tx, participants(sched), sched.Start, sched.End)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return storage.ErrScheduleConflict
+		}
+	}
+
+	query := `INSERT INTO schedules (group_id, title, student_id, trainer_id, start_date, end_date, lesson_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+
+	for _, sched := range scheds {
+		if sched.LessonId == uuid.Nil {
+			sched.LessonId = uuid.New()
+		}
+
+		row := tx.QueryRow(ctx, query, sched.GroupId, sched.Title, sched.StudentId, sched.TrainerId, sched.Start, sched.End, sched.LessonId)
+		if err := row.Scan(&sched.Id); err != nil {
+			// TODO: error
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateSchedule stores the new title, student, time and lesson of sched together with its calendar jobs
+// and outbox messages.
+// The overlap check of CreateSchedules applies, ignoring sched itself and the schedules of the other students
+// of its group lesson.
+func (s *Storage) UpdateSchedule(ctx context.Context, sched *models.Schedule, jobs []*models.CalendarJob, messages []*models.OutboxMessage) error {
+	const op = "psql.UpdateSchedule"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUsers(ctx, tx, participants(sched)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var before models.Schedule
+	query := `SELECT group_id, trainer_id, start_date, end_date FROM schedules WHERE id = $1 FOR UPDATE`
+
+	if err := tx.QueryRow(ctx, query, sched.Id).Scan(&before.GroupId, &before.TrainerId, &before.Start, &before.End); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrScheduleNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	conflicts, err := s.provideLockedConflicts(ctx, tx, participants(sched), sched.Start, sched.End)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, conflict := range conflicts {
+		if conflict.Schedule.Id != sched.Id && !sameLesson(conflict.Schedule, &before) {
+			return fmt.Errorf("%s: %w", op, storage.ErrScheduleConflict)
+		}
+	}
+
+	query = `UPDATE schedules SET title = $3, student_id = $4, start_date = $5, end_date = $6, lesson_id = $7
+	WHERE id = $1 AND trainer_id = $2 AND cancelled_at IS NULL`
+
+	tag, err := tx.Exec(ctx, query, sched.Id, sched.TrainerId, sched.Title, sched.StudentId, sched.Start, sched.End, sched.LessonId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrScheduleNotFound
+	}
+
+	if err := insertCalendarJobs(ctx, tx, jobs); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertOutbox(ctx, tx, messages); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MoveSchedules stores the new time of the rows of one lesson, linked to the same calendar event, in a single
+// transaction together with their calendar jobs and outbox messages. The overlap check of CreateSchedules applies,
+// ignoring the rows being moved.
+func (s *Storage) MoveSchedules(ctx context.Context, scheds []*models.Schedule, jobs []*models.CalendarJob, messages []*models.OutboxMessage) error {
+	const op = "psql.MoveSchedules"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	userIds := make([]uuid.UUID, 0, len(scheds)+1)
+	scheduleIds := make([]uuid.UUID, len(scheds))
+	for i, sched := range scheds {
+		userIds = append(userIds, participants(sched)...)
+		scheduleIds[i] = sched.Id
+	}
+
+	if err := lockUsers(ctx, tx, userIds); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `UPDATE schedules SET start_date = $3, end_date = $4
+	WHERE id = $1 AND trainer_id = $2 AND cancelled_at IS NULL`
+
+	for _, sched := range scheds {
+		conflicts, err := s.provideLockedConflicts(ctx, tx, participants(sched), sched.Start, sched.End)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		for _, conflict := range conflicts {
+			if !slices.Contains(scheduleIds, conflict.Schedule.Id) {
+				return fmt.Errorf("%s: %w", op, storage.ErrScheduleConflict)
+			}
+		}
+
+		tag, err := tx.Exec(ctx, query, sched.Id, sched.TrainerId, sched.Start, sched.End)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if tag.RowsAffected() == 0 {
+			return storage.ErrScheduleNotFound
+		}
+	}
+
+	if err := insertCalendarJobs(ctx, tx, jobs); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
+	if err := insertOutbox(ctx, tx, messages); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ProvideSchedule(ctx context.Context, scheduleId uuid.UUID) (*models.Schedule, error) {
+	const op = "psql.ProvideSchedule"
+
+	query := `SELECT COALESCE(groups.name, ''), schedules.group_id, schedules.title, schedules.student_id, schedules.trainer_id, schedules.start_date, schedules.end_date, schedules.id, schedules.series_id, schedules.recurrence_id, schedules.cancelled_at, schedules.cancelled_by, COALESCE(schedules.cancel_reason, ''), schedules.lesson_id
+	FROM schedules
+	LEFT JOIN groups ON groups.id = schedules.group_id
+	WHERE schedules.id = $1`
+
+	schedules, err := s.provideSchedules(ctx, func() (pgx.Rows, error) { return s.db.Query(ctx, query, scheduleId) })
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(schedules) == 0 {
+		return nil, storage.ErrScheduleNotFound
+	}
+
+	return schedules[0], nil
+}
+
+// ProvideConflicts returns schedules of userIds overlapping [start, end).
+func (s *Storage) ProvideConflicts(ctx context.Context, userIds []uuid.UUID, start, end time.Time) ([]*models.Conflict, error) {
+	const op = "psql.ProvideConflicts"
+
+	conflicts, err := s.provideConflicts(ctx, s.db, userIds, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return conflicts, nil
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func (s *Storage) provideConflicts(ctx context.Context, db querier, userIds []uuid.UUID, start, end time.Time) ([]*models.Conflict, error) {
+	query := `SELECT COALESCE(groups.name, ''), schedules.group_id, schedules.title, schedules.student_id, schedules.trainer_id, schedules.start_date, schedules.end_date, schedules.id, schedules.series_id, schedules.recurrence_id, schedules.cancelled_at, schedules.cancelled_by, COALESCE(schedules.cancel_reason, ''), schedules.lesson_id
+	FROM schedules
+	LEFT JOIN groups ON groups.id = schedules.group_id
+	WHERE (schedules.trainer_id = ANY($1) OR schedules.student_id = ANY($1))
+	AND tstzrange(schedules.start_date, schedules.end_date) && tstzrange($2, $3)
+	AND schedules.cancelled_at IS NULL`
+
+	schedules, err := s.provideSchedules(ctx, func() (pgx.Rows, error) { return db.Query(ctx, query, userIds, start, end) })
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := make([]*models.Conflict, 0, len(schedules))
+	for _, sched := range schedules {
+		userId := sched.StudentId
+		if slices.Contains(userIds, sched.TrainerId) {
+			userId = sched.TrainerId
+		}
+
+		conflicts = append(conflicts, &models.Conflict{
+			UserId:   userId,
+			Schedule: sched,
+		})
+	}
+
+	return conflicts, nil
+}
+
+// provideLockedConflicts returns the schedules and the series occurrences of userIds overlapping [start, end),
+// for the overlap checks made under the locks of lockUsers.
+func (s *Storage) provideLockedConflicts(ctx context.Context, tx pgx.Tx, userIds []uuid.UUID, start, end time.Time) ([]*models.Conflict, error) {
+	conflicts, err := s.provideConflicts(ctx, tx, userIds, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences, err := provideSeriesConflicts(ctx, tx, userIds, start, end, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(conflicts, occurrences...), nil
+}
+
+// lockUsers serializes schedule writes per user until the end of the transaction.
+// Ids are locked in a stable order to avoid deadlocks.
+func lockUsers(ctx context.Context, tx pgx.Tx, userIds []uuid.UUID) error {
+	ids := slices.Clone(userIds)
+	slices.SortFunc(ids, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+	ids = slices.Compact(ids)
+
+	for _, id := range ids {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1::text, 0))`, id.String()); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (s *Storage) ProvideSchedules(ctx context.Context, trainerId, studentId uuid.UUID) ([]*models.Schedule, error) {
-	if trainerId != uuid.Nil && studentId != uuid.Nil {
-		query := `SELECT groups.name, schedules.group_id, schedules.title, schedules.student_id, schedules.trainer_id, schedules.start_date, schedules.end_date, schedules.id
-		FROM schedules
-		INNER JOIN groups ON groups.id = schedules.group_id
-		WHERE schedules.trainer_id = $1 AND schedules.student_id = $2`
-		return s.provideSchedules(ctx, func() (pgx.Rows, error) { return s.db.Query(ctx, query, trainerId, studentId) })
+// sameLesson reports whether a and b are rows of the same group lesson, stored once per student.
+func sameLesson(a, b *models.Schedule) bool {
+	return a.GroupId == b.GroupId && a.TrainerId == b.TrainerId && a.Start.Equal(b.Start) && a.End.Equal(b.End)
+}
+
+// participants returns the users whose time a schedule occupies
+func participants(sched *models.Schedule) []uuid.UUID {
+	if sched.StudentId == uuid.Nil {
+		return []uuid.UUID{sched.TrainerId}
+	}
+
+	return []uuid.UUID{sched.TrainerId, sched.StudentId}
+}
+
+// ProvideSchedules returns the schedules matching filter ordered by start date and id, without cancelled ones.
+// Either the trainer or the student has to be set. The past lessons of deleted groups are included.
+func (s *Storage) ProvideSchedules(ctx context.Context, filter models.ScheduleFilter) ([]*models.Schedule, error) {
+	if filter.TrainerId == uuid.Nil && filter.StudentId == uuid.Nil {
+		return nil, nil
+	}
+
+	conditions := []string{"schedules.cancelled_at IS NULL"}
+	args := make([]any, 0)
+	where := func(condition string, values ...any) {
+		placeholders := make([]any, len(values))
+		for i := range values {
+			args = append(args, values[i])
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+
+	if filter.TrainerId != uuid.Nil {
+		where("schedules.trainer_id = $%d", filter.TrainerId)
+	}
+	if filter.StudentId != uuid.Nil {
+		where("schedules.student_id = $%d", filter.StudentId)
+	}
+	if filter.GroupId != uuid.Nil {
+		where("schedules.group_id = $%d", filter.GroupId)
+	}
+	if !filter.From.IsZero() {
+		where("schedules.end_date > $%d", filter.From)
 	}
-	if trainerId != uuid.Nil {
-		query := `SELECT groups.name, schedules.group_id, schedules.title, schedules.student_id, schedules.trainer_id, schedules.start_date, schedules.end_date, schedules.id
-		FROM schedules
-		INNER JOIN groups ON groups.id = schedules.group_id
-		WHERE schedules.trainer_id = $1`
-		return s.provideSchedules(ctx, func() (pgx.Rows, error) { return s.db.Query(ctx, query, trainerId) })
+	if !filter.To.IsZero() {
+		where("schedules.start_date < $%d", filter.To)
 	}
-	if studentId != uuid.Nil {
-		query := `SELECT groups.name, schedules.group_id, schedules.title, schedules.student_id, schedules.trainer_id, schedules.start_date, schedules.end_date, schedules.id
-		FROM schedules
-		INNER JOIN groups ON groups.id = schedules.group_id
-		WHERE schedules.student_id = $1`
-		return s.provideSchedules(ctx, func() (pgx.Rows, error) { return s.db.Query(ctx, query, studentId) })
+	if filter.Search != "" {
+		where("position(lower($%d) in lower(schedules.title)) > 0", filter.Search)
+	}
+	if filter.After != nil {
+		where("(schedules.start_date, schedules.id) > ($%d, $%d)", filter.After.Start, filter.After.Id)
+	}
+
+	query := `SELECT COALESCE(groups.name, ''), schedules.group_id, schedules.title, schedules.student_id, schedules.trainer_id, schedules.start_date, schedules.end_date, schedules.id, schedules.series_id, schedules.recurrence_id, schedules.cancelled_at, schedules.cancelled_by, COALESCE(schedules.cancel_reason, ''), schedules.lesson_id
+	FROM schedules
+	LEFT JOIN groups ON groups.id = schedules.group_id
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	ORDER BY schedules.start_date, schedules.id`
+
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
 	}
-	return nil, nil
+
+	return s.provideSchedules(ctx, func() (pgx.Rows, error) { return s.db.Query(ctx, query, args...) })
 }
 
 func (s *Storage) provideSchedules(ctx context.Context, queryFunc func() (pgx.Rows, error)) ([]*models.Schedule, error) {
@@ -60,13 +451,15 @@ func (s *Storage) provideSchedules(ctx context.Context, queryFunc func() (pgx.Ro
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
 
 	schedules := make([]*models.Schedule, 0)
 	for rows.Next() {
 		var schedule models.Schedule
-		var groupId, studentId, trainerId uuid.NullUUID
+		var groupId, studentId, trainerId, seriesId, cancelledBy uuid.NullUUID
+		var recurrenceId, cancelledAt *time.Time
 
-		if err := rows.Scan(&schedule.GroupName, &groupId, &schedule.Title, &studentId, &trainerId, &schedule.Start, &schedule.End, &schedule.Id); err != nil {
+		if err := rows.Scan(&schedule.GroupName, &groupId, &schedule.Title, &studentId, &trainerId, &schedule.Start, &schedule.End, &schedule.Id, &seriesId, &recurrenceId, &cancelledAt, &cancelledBy, &schedule.CancelReason, &schedule.LessonId); err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 
@@ -87,6 +480,18 @@ func (s *Storage) provideSchedules(ctx context.Context, queryFunc func() (pgx.Ro
 		} else {
 			return nil, fmt.Errorf("%s: %w", op, storage.ErrInvalidUUID)
 		}
+		if seriesId.Valid {
+			schedule.SeriesId = seriesId.UUID
+		}
+		if recurrenceId != nil {
+			schedule.RecurrenceId = *recurrenceId
+		}
+		if cancelledAt != nil {
+			schedule.CancelledAt = *cancelledAt
+		}
+		if cancelledBy.Valid {
+			schedule.CancelledBy = cancelledBy.UUID
+		}
 
 		schedules = append(schedules, &schedule)
 	}
@@ -96,16 +501,41 @@ func (s *Storage) provideSchedules(ctx context.Context, queryFunc func() (pgx.Ro
 	return schedules, nil
 }
 
-func (s *Storage) DeleteSchedule(ctx context.Context, groupId, trainerId uuid.UUID) error {
-	const op = "psql.DeleteSchedule"
+// CancelSchedule marks sched as cancelled by sched.CancelledBy and stores its calendar jobs and outbox messages.
+// The row is kept, but no longer returned by ProvideSchedules nor counted as a conflict.
+func (s *Storage) CancelSchedule(ctx context.Context, sched *models.Schedule, jobs []*models.CalendarJob, messages []*models.OutboxMessage) error {
+	const op = "psql.CancelSchedule"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
 
-	query := `DELETE FROM schedules WHERE id = $1 AND trainer_id = $2`
+	query := `UPDATE schedules SET cancelled_at = $2, cancelled_by = $3, cancel_reason = $4
+	WHERE id = $1 AND cancelled_at IS NULL`
 
-	if _, err := s.db.Exec(ctx, query, groupId, trainerId); err != nil {
+	tag, err := tx.Exec(ctx, query, sched.Id, sched.CancelledAt, sched.CancelledBy, sched.CancelReason)
+	if err != nil {
 		// TODO: error
 
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrScheduleNotFound
+	}
+
+	if err := insertCalendarJobs(ctx, tx, jobs); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertOutbox(ctx, tx, messages); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
 	return nil
 }
@@ -0,0 +1,67 @@
+package psql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveSchedules(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	noChanges := func() ([]*models.CalendarJob, []*models.OutboxMessage, error) { return nil, nil, nil }
+
+	trainerId := uuid.New()
+	groupId := uuid.New()
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	// a group lesson of two students
+	lessonId := uuid.New()
+	lesson := make([]*models.Schedule, 2)
+	for i := range lesson {
+		lesson[i] = &models.Schedule{Title: "group", GroupId: groupId, TrainerId: trainerId, StudentId: uuid.New(), Start: start, End: start.Add(time.Hour), LessonId: lessonId}
+	}
+	require.NoError(t, s.CreateSchedules(ctx, lesson, noChanges))
+
+	other := &models.Schedule{Title: "other", TrainerId: trainerId, Start: start.Add(2 * time.Hour), End: start.Add(3 * time.Hour)}
+	require.NoError(t, s.CreateSchedule(ctx, other, noChanges))
+	require.NotEqual(t, uuid.Nil, other.LessonId)
+
+	moved := func(start time.Time) []*models.Schedule {
+		scheds := make([]*models.Schedule, len(lesson))
+		for i, sched := range lesson {
+			after := *sched
+			after.Start = start
+			after.End = start.Add(time.Hour)
+			scheds[i] = &after
+		}
+		return scheds
+	}
+
+	// the rows of the lesson overlap each other, and their old time, but nothing else
+	require.NoError(t, s.MoveSchedules(ctx, moved(start.Add(30*time.Minute)), nil, nil))
+
+	// the moved rows are still one lesson
+	for _, sched := range lesson {
+		stored, err := s.ProvideSchedule(ctx, sched.Id)
+		require.NoError(t, err)
+		require.True(t, stored.Start.Equal(start.Add(30*time.Minute)))
+		require.Equal(t, lessonId, stored.LessonId)
+	}
+
+	// a conflict of any row leaves all of them where they are
+	err := s.MoveSchedules(ctx, moved(start.Add(90*time.Minute)), nil, nil)
+	require.ErrorIs(t, err, storage.ErrScheduleConflict)
+
+	for _, sched := range lesson {
+		stored, err := s.ProvideSchedule(ctx, sched.Id)
+		require.NoError(t, err)
+		require.True(t, stored.Start.Equal(start.Add(30*time.Minute)))
+	}
+}
@@ -0,0 +1,563 @@
+package psql
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/lib/recurrence"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const seriesColumns = `schedule_series.id, COALESCE(groups.name, ''), schedule_series.group_id, schedule_series.title,
+	schedule_series.student_id, schedule_series.trainer_id, schedule_series.start_date, schedule_series.end_date,
+	schedule_series.frequency, schedule_series.interval, schedule_series.by_day, schedule_series.count,
+	schedule_series.until, schedule_series.exdates, schedule_series.time_zone, schedule_series.lesson_id`
+
+// CreateScheduleSeries inserts series and their outbox messages atomically, so that a group gets
+// the series of all of its students or none. The messages are built by newMessages once the ids of series
+// are known. storage.ErrScheduleConflict is returned if one of occurrences, the expanded series, overlaps
+// a lesson of its participants.
+func (s *Storage) CreateScheduleSeries(ctx context.Context, series []*models.ScheduleSeries, occurrences []*models.Schedule, newMessages func() ([]*models.OutboxMessage, error)) error {
+	const op = "psql.CreateScheduleSeries"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := checkOccurrences(ctx, tx, occurrences, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, ser := range series {
+		if err := insertScheduleSeries(ctx, tx, ser); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	messages, err := newMessages()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertOutbox(ctx, tx, messages); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// insertScheduleSeries stores series, as a lesson of its own unless its LessonId is set.
+func insertScheduleSeries(ctx context.Context, tx pgx.Tx, series *models.ScheduleSeries) error {
+	query := `INSERT INTO schedule_series (group_id, title, student_id, trainer_id, start_date, end_date, frequency, interval, by_day, count, until, exdates, time_zone, lesson_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id`
+
+	if series.LessonId == uuid.Nil {
+		series.LessonId = uuid.New()
+	}
+
+	rule := series.Recurrence
+	row := tx.QueryRow(ctx, query, series.GroupId, series.Title, series.StudentId, series.TrainerId, series.Start, series.End,
+		rule.Frequency, rule.Interval, weekdaysToInts(rule.ByDay), rule.Count, nullTime(rule.Until), exceptionsOrEmpty(rule.Exceptions), timeZoneOrUTC(series.TimeZone),
+		series.LessonId)
+
+	return row.Scan(&series.Id)
+}
+
+// checkOccurrences locks the participants of occurrences as insertSchedules does and fails with
+// storage.ErrScheduleConflict if one of occurrences overlaps a lesson of its participants,
+// a single one or an occurrence of their series. The series ignoreIds and the lessons detached from them
+// are not counted.
+func checkOccurrences(ctx context.Context, tx pgx.Tx, occurrences []*models.Schedule, ignoreIds []uuid.UUID) error {
+	if len(occurrences) == 0 {
+		return nil
+	}
+
+	userIds := make([]uuid.UUID, 0, 2)
+	starts := make([]time.Time, len(occurrences))
+	ends := make([]time.Time, len(occurrences))
+	trainerIds := make([]uuid.UUID, len(occurrences))
+	studentIds := make([]uuid.UUID, len(occurrences))
+	for i, occurrence := range occurrences {
+		userIds = append(userIds, participants(occurrence)...)
+		starts[i] = occurrence.Start
+		ends[i] = occurrence.End
+		trainerIds[i] = occurrence.TrainerId
+		studentIds[i] = occurrence.StudentId
+	}
+	if err := lockUsers(ctx, tx, userIds); err != nil {
+		return err
+	}
+
+	query := `SELECT EXISTS (
+		SELECT 1 FROM unnest($1::timestamptz[], $2::timestamptz[], $3::uuid[], $4::uuid[]) AS o(start_date, end_date, trainer_id, student_id)
+		INNER JOIN schedules ON tstzrange(schedules.start_date, schedules.end_date) && tstzrange(o.start_date, o.end_date)
+		WHERE schedules.cancelled_at IS NULL AND (schedules.series_id IS NULL OR schedules.series_id <> ALL($5))
+		AND (schedules.trainer_id = o.trainer_id OR schedules.student_id = o.trainer_id
+			OR o.student_id <> $6 AND (schedules.trainer_id = o.student_id OR schedules.student_id = o.student_id))
+	)`
+
+	var conflict bool
+	if err := tx.QueryRow(ctx, query, starts, ends, trainerIds, studentIds, uuidsOrEmpty(ignoreIds), uuid.Nil).Scan(&conflict); err != nil {
+		return err
+	}
+	if conflict {
+		return storage.ErrScheduleConflict
+	}
+
+	from, to := slices.MinFunc(starts, time.Time.Compare), slices.MaxFunc(ends, time.Time.Compare)
+
+	conflicts, err := provideSeriesConflicts(ctx, tx, userIds, from, to, ignoreIds)
+	if err != nil {
+		return err
+	}
+
+	for _, conflict := range conflicts {
+		for _, occurrence := range occurrences {
+			shared := slices.ContainsFunc(participants(occurrence), func(userId uuid.UUID) bool {
+				return slices.Contains(participants(conflict.Schedule), userId)
+			})
+
+			if shared && conflict.Schedule.Start.Before(occurrence.End) && occurrence.Start.Before(conflict.Schedule.End) {
+				return storage.ErrScheduleConflict
+			}
+		}
+	}
+
+	return nil
+}
+
+// provideSeriesConflicts expands the series of userIds, except ignoreIds, into their occurrences overlapping
+// [start, end), for the overlap checks made under the locks of lockUsers.
+func provideSeriesConflicts(ctx context.Context, db querier, userIds []uuid.UUID, start, end time.Time, ignoreIds []uuid.UUID) ([]*models.Conflict, error) {
+	query := `SELECT ` + seriesColumns + `
+	FROM schedule_series
+	LEFT JOIN groups ON groups.id = schedule_series.group_id
+	WHERE (schedule_series.trainer_id = ANY($1) OR schedule_series.student_id = ANY($1))
+	AND schedule_series.start_date < $2 AND NOT schedule_series.id = ANY($3)`
+
+	rows, err := db.Query(ctx, query, userIds, end, uuidsOrEmpty(ignoreIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conflicts := make([]*models.Conflict, 0)
+	for rows.Next() {
+		ser, err := scanSeries(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		userId := ser.StudentId
+		if slices.Contains(userIds, ser.TrainerId) {
+			userId = ser.TrainerId
+		}
+
+		// occurrences which started before the window but are still running are included
+		duration := ser.End.Sub(ser.Start)
+		for _, at := range recurrence.Expand(ser.Recurrence, ser.Start.In(seriesLocation(ser)), start.Add(-duration), end) {
+			if !at.Add(duration).After(start) || !at.Before(end) {
+				continue
+			}
+
+			conflicts = append(conflicts, &models.Conflict{
+				UserId: userId,
+				Schedule: &models.Schedule{
+					Id:           uuid.NewSHA1(ser.Id, []byte(at.UTC().Format(time.RFC3339))),
+					Start:        at,
+					End:          at.Add(duration),
+					GroupName:    ser.GroupName,
+					Title:        ser.Title,
+					GroupId:      ser.GroupId,
+					StudentId:    ser.StudentId,
+					TrainerId:    ser.TrainerId,
+					SeriesId:     ser.Id,
+					RecurrenceId: at,
+				},
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return conflicts, nil
+}
+
+func (s *Storage) ProvideScheduleSeries(ctx context.Context, trainerId, studentId uuid.UUID) ([]*models.ScheduleSeries, error) {
+	if trainerId != uuid.Nil && studentId != uuid.Nil {
+		query := `SELECT ` + seriesColumns + `
+		FROM schedule_series
+		LEFT JOIN groups ON groups.id = schedule_series.group_id
+		WHERE schedule_series.trainer_id = $1 AND schedule_series.student_id = $2`
+		return s.provideScheduleSeries(func() (pgx.Rows, error) { return s.db.Query(ctx, query, trainerId, studentId) })
+	}
+	if trainerId != uuid.Nil {
+		query := `SELECT ` + seriesColumns + `
+		FROM schedule_series
+		LEFT JOIN groups ON groups.id = schedule_series.group_id
+		WHERE schedule_series.trainer_id = $1`
+		return s.provideScheduleSeries(func() (pgx.Rows, error) { return s.db.Query(ctx, query, trainerId) })
+	}
+	if studentId != uuid.Nil {
+		query := `SELECT ` + seriesColumns + `
+		FROM schedule_series
+		LEFT JOIN groups ON groups.id = schedule_series.group_id
+		WHERE schedule_series.student_id = $1`
+		return s.provideScheduleSeries(func() (pgx.Rows, error) { return s.db.Query(ctx, query, studentId) })
+	}
+	return nil, nil
+}
+
+func (s *Storage) provideScheduleSeries(queryFunc func() (pgx.Rows, error)) ([]*models.ScheduleSeries, error) {
+	const op = "psql.provideScheduleSeries"
+
+	rows, err := queryFunc()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	series := make([]*models.ScheduleSeries, 0)
+	for rows.Next() {
+		ser, err := scanSeries(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		series = append(series, ser)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return series, nil
+}
+
+// ProvideLessonSeries returns the series seriesId followed by the other series of its lesson,
+// the series of the other students of a group lesson.
+func (s *Storage) ProvideLessonSeries(ctx context.Context, seriesId uuid.UUID) ([]*models.ScheduleSeries, error) {
+	const op = "psql.ProvideLessonSeries"
+
+	query := `SELECT ` + seriesColumns + `
+	FROM schedule_series
+	LEFT JOIN groups ON groups.id = schedule_series.group_id
+	WHERE schedule_series.lesson_id = (SELECT lesson_id FROM schedule_series WHERE id = $1)
+	ORDER BY schedule_series.id = $1 DESC, schedule_series.student_id`
+
+	series, err := s.provideScheduleSeries(func() (pgx.Rows, error) { return s.db.Query(ctx, query, seriesId) })
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(series) == 0 || series[0].Id != seriesId {
+		return nil, storage.ErrSeriesNotFound
+	}
+
+	return series, nil
+}
+
+// UpdateScheduleSeries stores the changed series of a lesson, with the overlap check of CreateScheduleSeries
+// for occurrences, not counting the series themselves.
+func (s *Storage) UpdateScheduleSeries(ctx context.Context, series []*models.ScheduleSeries, occurrences []*models.Schedule) error {
+	const op = "psql.UpdateScheduleSeries"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := checkOccurrences(ctx, tx, occurrences, seriesIds(series)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, ser := range series {
+		if err := updateSeries(ctx, tx, ser); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SplitScheduleSeries ends the series of a lesson before at and starts next in their place, with the overlap check
+// of UpdateScheduleSeries for occurrences of next. Occurrences detached from series at or after at
+// are dropped. An empty next only truncates the series.
+func (s *Storage) SplitScheduleSeries(ctx context.Context, series, next []*models.ScheduleSeries, at time.Time, occurrences []*models.Schedule) error {
+	const op = "psql.SplitScheduleSeries"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := checkOccurrences(ctx, tx, occurrences, seriesIds(series)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, ser := range series {
+		if err := updateSeries(ctx, tx, ser); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	query := `DELETE FROM schedules WHERE series_id = ANY($1) AND recurrence_id >= $2`
+
+	if _, err := tx.Exec(ctx, query, seriesIds(series), at); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, ser := range next {
+		if err := insertScheduleSeries(ctx, tx, ser); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DetachOccurrences excludes the RecurrenceId of every schedule of scheds, the occurrences of a lesson,
+// from its series and stores them as standalone schedules, with the overlap check of UpdateScheduleSeries.
+func (s *Storage) DetachOccurrences(ctx context.Context, scheds []*models.Schedule) error {
+	const op = "psql.DetachOccurrences"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	ids := make([]uuid.UUID, len(scheds))
+	for i, sched := range scheds {
+		ids[i] = sched.SeriesId
+	}
+
+	if err := checkOccurrences(ctx, tx, scheds, ids); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `INSERT INTO schedules (group_id, title, student_id, trainer_id, start_date, end_date, series_id, recurrence_id, lesson_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	for _, sched := range scheds {
+		if err := addSeriesException(ctx, tx, sched.SeriesId, sched.TrainerId, sched.RecurrenceId); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if _, err := tx.Exec(ctx, query, sched.GroupId, sched.Title, sched.StudentId, sched.TrainerId, sched.Start, sched.End, sched.SeriesId, sched.RecurrenceId, sched.LessonId); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AddSeriesException excludes at from every series of seriesIds, the series of a lesson.
+func (s *Storage) AddSeriesException(ctx context.Context, seriesIds []uuid.UUID, trainerId uuid.UUID, at time.Time) error {
+	const op = "psql.AddSeriesException"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, seriesId := range seriesIds {
+		if err := addSeriesException(ctx, tx, seriesId, trainerId, at); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteScheduleSeries deletes every series of seriesIds, the series of a lesson, with their detached occurrences.
+func (s *Storage) DeleteScheduleSeries(ctx context.Context, seriesIds []uuid.UUID, trainerId uuid.UUID) error {
+	const op = "psql.DeleteScheduleSeries"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `DELETE FROM schedule_series WHERE id = ANY($1) AND trainer_id = $2`
+
+	tag, err := tx.Exec(ctx, query, seriesIds, trainerId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() != int64(len(seriesIds)) {
+		return storage.ErrSeriesNotFound
+	}
+
+	query = `DELETE FROM schedules WHERE series_id = ANY($1)`
+
+	if _, err := tx.Exec(ctx, query, seriesIds); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func seriesIds(series []*models.ScheduleSeries) []uuid.UUID {
+	ids := make([]uuid.UUID, len(series))
+	for i, ser := range series {
+		ids[i] = ser.Id
+	}
+
+	return ids
+}
+
+type executor interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+func updateSeries(ctx context.Context, db executor, series *models.ScheduleSeries) error {
+	query := `UPDATE schedule_series
+	SET title = $3, student_id = $4, start_date = $5, end_date = $6, frequency = $7, interval = $8, by_day = $9, count = $10, until = $11, exdates = $12, time_zone = $13
+	WHERE id = $1 AND trainer_id = $2`
+
+	rule := series.Recurrence
+	tag, err := db.Exec(ctx, query, series.Id, series.TrainerId, series.Title, series.StudentId, series.Start, series.End,
+		rule.Frequency, rule.Interval, weekdaysToInts(rule.ByDay), rule.Count, nullTime(rule.Until), exceptionsOrEmpty(rule.Exceptions), timeZoneOrUTC(series.TimeZone))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrSeriesNotFound
+	}
+
+	return nil
+}
+
+func addSeriesException(ctx context.Context, db executor, seriesId, trainerId uuid.UUID, at time.Time) error {
+	query := `UPDATE schedule_series SET exdates = array_append(exdates, $3)
+	WHERE id = $1 AND trainer_id = $2`
+
+	tag, err := db.Exec(ctx, query, seriesId, trainerId, at)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrSeriesNotFound
+	}
+
+	return nil
+}
+
+func scanSeries(row pgx.Row) (*models.ScheduleSeries, error) {
+	var series models.ScheduleSeries
+	var groupId, studentId, trainerId uuid.NullUUID
+	var byDay []int16
+	var until *time.Time
+
+	if err := row.Scan(
+		&series.Id, &series.GroupName, &groupId, &series.Title, &studentId, &trainerId, &series.Start, &series.End,
+		&series.Recurrence.Frequency, &series.Recurrence.Interval, &byDay, &series.Recurrence.Count, &until, &series.Recurrence.Exceptions,
+		&series.TimeZone, &series.LessonId,
+	); err != nil {
+		return nil, err
+	}
+
+	if !groupId.Valid || !studentId.Valid || !trainerId.Valid {
+		return nil, storage.ErrInvalidUUID
+	}
+	series.GroupId = groupId.UUID
+	series.StudentId = studentId.UUID
+	series.TrainerId = trainerId.UUID
+
+	series.Recurrence.ByDay = make([]time.Weekday, len(byDay))
+	for i := range byDay {
+		series.Recurrence.ByDay[i] = time.Weekday(byDay[i])
+	}
+	if until != nil {
+		series.Recurrence.Until = *until
+	}
+
+	return &series, nil
+}
+
+func weekdaysToInts(days []time.Weekday) []int16 {
+	res := make([]int16, len(days))
+	for i := range days {
+		res[i] = int16(days[i])
+	}
+
+	return res
+}
+
+// seriesLocation returns the time zone whose wall clock time the occurrences of series keep.
+func seriesLocation(series *models.ScheduleSeries) *time.Location {
+	loc, err := time.LoadLocation(timeZoneOrUTC(series.TimeZone))
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+func timeZoneOrUTC(timeZone string) string {
+	if timeZone == "" {
+		return "UTC"
+	}
+
+	return timeZone
+}
+
+func exceptionsOrEmpty(exceptions []time.Time) []time.Time {
+	if exceptions == nil {
+		return []time.Time{}
+	}
+
+	return exceptions
+}
+
+func uuidsOrEmpty(ids []uuid.UUID) []uuid.UUID {
+	if ids == nil {
+		return []uuid.UUID{}
+	}
+
+	return ids
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
@@ -0,0 +1,148 @@
+package psql
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hesoyamTM/apphelper-schedule/internal/models"
+	"github.com/hesoyamTM/apphelper-schedule/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLessonSeries(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	noMessages := func() ([]*models.OutboxMessage, error) { return nil, nil }
+
+	trainerId := uuid.New()
+	invitation := newTestInvitation(uuid.Nil, trainerId)
+	require.NoError(t, s.CreateGroup(ctx, "test", trainerId, invitation))
+
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	lessonId := uuid.New()
+
+	// a group lesson of two students
+	lesson := make([]*models.ScheduleSeries, 2)
+	for i := range lesson {
+		lesson[i] = &models.ScheduleSeries{
+			Title:      "group",
+			Start:      start,
+			End:        start.Add(time.Hour),
+			GroupId:    invitation.GroupId,
+			TrainerId:  trainerId,
+			StudentId:  uuid.New(),
+			LessonId:   lessonId,
+			Recurrence: models.Recurrence{Frequency: models.FrequencyWeekly, Interval: 1},
+		}
+	}
+	require.NoError(t, s.CreateScheduleSeries(ctx, lesson, nil, noMessages))
+
+	stored, err := s.ProvideLessonSeries(ctx, lesson[1].Id)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	require.Equal(t, lesson[1].Id, stored[0].Id)
+	require.Equal(t, lesson[0].Id, stored[1].Id)
+
+	// the detached occurrences of both students overlap each other and the lesson, but nothing else
+	at := start.AddDate(0, 0, 7)
+	occurrences := make([]*models.Schedule, len(lesson))
+	for i, ser := range lesson {
+		occurrences[i] = &models.Schedule{
+			Title:        "moved",
+			Start:        at.Add(30 * time.Minute),
+			End:          at.Add(90 * time.Minute),
+			GroupId:      ser.GroupId,
+			TrainerId:    ser.TrainerId,
+			StudentId:    ser.StudentId,
+			SeriesId:     ser.Id,
+			RecurrenceId: at,
+		}
+	}
+	require.NoError(t, s.DetachOccurrences(ctx, occurrences))
+
+	for _, ser := range stored {
+		ser.Title = "renamed"
+	}
+	require.NoError(t, s.UpdateScheduleSeries(ctx, stored, occurrences))
+
+	stored, err = s.ProvideLessonSeries(ctx, lesson[0].Id)
+	require.NoError(t, err)
+	for _, ser := range stored {
+		require.Equal(t, "renamed", ser.Title)
+		require.Len(t, ser.Recurrence.Exceptions, 1)
+		require.True(t, ser.Recurrence.Exceptions[0].Equal(at))
+	}
+
+	require.NoError(t, s.DeleteScheduleSeries(ctx, seriesIds(stored), trainerId))
+
+	_, err = s.ProvideLessonSeries(ctx, lesson[0].Id)
+	require.ErrorIs(t, err, storage.ErrSeriesNotFound)
+}
+
+func TestCreateScheduleSeriesConcurrently(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	noMessages := func() ([]*models.OutboxMessage, error) { return nil, nil }
+	noChanges := func() ([]*models.CalendarJob, []*models.OutboxMessage, error) { return nil, nil, nil }
+
+	trainerId := uuid.New()
+	invitation := newTestInvitation(uuid.Nil, trainerId)
+	require.NoError(t, s.CreateGroup(ctx, "test", trainerId, invitation))
+
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	// weekly series of the trainer with different students, overlapping in their third week
+	newSeries := func(start time.Time) (*models.ScheduleSeries, []*models.Schedule) {
+		ser := &models.ScheduleSeries{
+			Title:      "weekly",
+			Start:      start,
+			End:        start.Add(time.Hour),
+			GroupId:    invitation.GroupId,
+			TrainerId:  trainerId,
+			StudentId:  uuid.New(),
+			Recurrence: models.Recurrence{Frequency: models.FrequencyWeekly, Interval: 1, Count: 3},
+		}
+
+		occurrences := make([]*models.Schedule, ser.Recurrence.Count)
+		for i := range occurrences {
+			at := start.AddDate(0, 0, 7*i)
+			occurrences[i] = &models.Schedule{Start: at, End: at.Add(time.Hour), TrainerId: trainerId, StudentId: ser.StudentId}
+		}
+
+		return ser, occurrences
+	}
+
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i, at := range []time.Time{start, start.AddDate(0, 0, 14).Add(30 * time.Minute)} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ser, occurrences := newSeries(at)
+			errs[i] = s.CreateScheduleSeries(ctx, []*models.ScheduleSeries{ser}, occurrences, noMessages)
+		}()
+	}
+	wg.Wait()
+
+	// whichever commits first, the other one sees its series under the lock of the trainer
+	if errs[0] == nil {
+		require.ErrorIs(t, errs[1], storage.ErrScheduleConflict)
+	} else {
+		require.ErrorIs(t, errs[0], storage.ErrScheduleConflict)
+		require.NoError(t, errs[1])
+	}
+
+	// a single lesson at the time of an occurrence is rejected as well
+	at := start.AddDate(0, 0, 7)
+	if errs[0] != nil {
+		at = start.AddDate(0, 0, 21).Add(30 * time.Minute)
+	}
+	err := s.CreateSchedule(ctx, &models.Schedule{Title: "single", TrainerId: trainerId, Start: at, End: at.Add(time.Hour)}, noChanges)
+	require.ErrorIs(t, err, storage.ErrScheduleConflict)
+}
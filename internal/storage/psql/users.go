@@ -0,0 +1,41 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Storage) SetUserTimeZone(ctx context.Context, userId uuid.UUID, timeZone string) error {
+	const op = "psql.SetUserTimeZone"
+
+	query := `INSERT INTO user_settings (user_id, time_zone) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET time_zone = EXCLUDED.time_zone`
+
+	if _, err := s.db.Exec(ctx, query, userId, timeZone); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ProvideUserTimeZone returns the IANA time zone of the user, or an empty string if the user has not set one.
+func (s *Storage) ProvideUserTimeZone(ctx context.Context, userId uuid.UUID) (string, error) {
+	const op = "psql.ProvideUserTimeZone"
+
+	query := `SELECT time_zone FROM user_settings WHERE user_id = $1`
+
+	var timeZone string
+	if err := s.db.QueryRow(ctx, query, userId).Scan(&timeZone); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return timeZone, nil
+}
@@ -15,8 +15,11 @@ func (s *Storage) SetSession(ctx context.Context, userId uuid.UUID, tok models.T
 	const op = "redis.SetSession"
 
 	data := map[string]string{
+		"provider":      string(tok.Provider),
 		"access_token":  tok.AccessToken,
 		"refresh_token": tok.RefreshToken,
+		"endpoint":      tok.Endpoint,
+		"username":      tok.Username,
 	}
 	if err := s.client.HSet(ctx, userId.String(), data).Err(); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
@@ -36,10 +39,23 @@ func (s *Storage) ProvideSession(ctx context.Context, userId uuid.UUID) (models.
 
 		return models.Token{}, fmt.Errorf("%s: %w", op, err)
 	}
+	// HGETALL replies with an empty hash for a missing key
+	if len(res) == 0 {
+		return models.Token{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+
+	provider := models.CalendarProvider(res["provider"])
+	if provider == "" {
+		// sessions stored before providers were introduced
+		provider = models.ProviderGoogle
+	}
 
 	return models.Token{
+		Provider:     provider,
 		AccessToken:  res["access_token"],
 		RefreshToken: res["refresh_token"],
+		Endpoint:     res["endpoint"],
+		Username:     res["username"],
 	}, nil
 }
 
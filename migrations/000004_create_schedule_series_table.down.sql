@@ -0,0 +1,4 @@
+ALTER TABLE schedules DROP COLUMN IF EXISTS recurrence_id;
+ALTER TABLE schedules DROP COLUMN IF EXISTS series_id;
+
+DROP TABLE IF EXISTS schedule_series;
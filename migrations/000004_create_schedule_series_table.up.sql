@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS schedule_series (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    title VARCHAR(50) NOT NULL,
+    group_id uuid NOT NULL,
+    student_id uuid NOT NULL,
+    trainer_id uuid NOT NULL,
+    start_date timestamp NOT NULL,
+    end_date timestamp NOT NULL,
+    frequency VARCHAR(10) NOT NULL,
+    interval INTEGER NOT NULL DEFAULT 1,
+    by_day SMALLINT[] NOT NULL DEFAULT array[]::smallint[],
+    count INTEGER NOT NULL DEFAULT 0,
+    until timestamp,
+    exdates timestamp[] NOT NULL DEFAULT array[]::timestamp[]
+);
+
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS series_id uuid;
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS recurrence_id timestamp;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS schedules_student_period_idx;
+DROP INDEX IF EXISTS schedules_trainer_period_idx;
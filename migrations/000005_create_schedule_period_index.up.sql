@@ -0,0 +1,4 @@
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE INDEX IF NOT EXISTS schedules_trainer_period_idx ON schedules USING gist (trainer_id, tsrange(start_date, end_date));
+CREATE INDEX IF NOT EXISTS schedules_student_period_idx ON schedules USING gist (student_id, tsrange(start_date, end_date));
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS schedules_start_date_idx;
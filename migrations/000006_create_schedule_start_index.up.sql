@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS schedules_start_date_idx ON schedules (start_date, id);
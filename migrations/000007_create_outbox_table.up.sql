@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(100) NOT NULL,
+    key VARCHAR(100) NOT NULL DEFAULT '',
+    payload jsonb NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at timestamp NOT NULL DEFAULT now(),
+    next_attempt_at timestamp NOT NULL DEFAULT now(),
+    delivered_at timestamp
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at) WHERE delivered_at IS NULL;
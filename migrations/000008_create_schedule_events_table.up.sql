@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS schedule_events (
+    schedule_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    calendar_id VARCHAR(255) NOT NULL,
+    event_id VARCHAR(1024) NOT NULL,
+    PRIMARY KEY (schedule_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS schedule_events_event_idx ON schedule_events (calendar_id, event_id);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS calendar_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id uuid NOT NULL,
+    group_id uuid NOT NULL,
+    schedule_ids uuid[] NOT NULL,
+    operation VARCHAR(10) NOT NULL,
+    title VARCHAR(100) NOT NULL DEFAULT '',
+    start_date timestamp,
+    end_date timestamp,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL DEFAULT now(),
+    next_attempt_at timestamp NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS calendar_jobs_pending_idx ON calendar_jobs (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS calendar_jobs_user_idx ON calendar_jobs (user_id, id);
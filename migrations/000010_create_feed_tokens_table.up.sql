@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS feed_tokens (
+    user_id uuid PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at timestamp NOT NULL DEFAULT now()
+);
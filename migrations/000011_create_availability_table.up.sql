@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS availability (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    trainer_id uuid NOT NULL,
+    group_id uuid NOT NULL,
+    title VARCHAR(50) NOT NULL,
+    start_date timestamp NOT NULL,
+    end_date timestamp NOT NULL,
+    slot_length INTEGER NOT NULL,
+    buffer_before INTEGER NOT NULL DEFAULT 0,
+    buffer_after INTEGER NOT NULL DEFAULT 0,
+    frequency VARCHAR(10) NOT NULL DEFAULT '',
+    interval INTEGER NOT NULL DEFAULT 1,
+    by_day SMALLINT[] NOT NULL DEFAULT array[]::smallint[],
+    count INTEGER NOT NULL DEFAULT 0,
+    until timestamp,
+    exdates timestamp[] NOT NULL DEFAULT array[]::timestamp[]
+);
+
+CREATE INDEX IF NOT EXISTS availability_trainer_idx ON availability (trainer_id);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS attendance (
+    schedule_id uuid NOT NULL,
+    student_id uuid NOT NULL,
+    trainer_id uuid NOT NULL,
+    group_id uuid NOT NULL,
+    title VARCHAR(50) NOT NULL,
+    start_date timestamp NOT NULL,
+    end_date timestamp NOT NULL,
+    status VARCHAR(10) NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    marked_at timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY (schedule_id, student_id)
+);
+
+CREATE INDEX IF NOT EXISTS attendance_student_idx ON attendance (student_id, start_date);
+CREATE INDEX IF NOT EXISTS attendance_group_idx ON attendance (group_id, start_date);
@@ -0,0 +1,3 @@
+ALTER TABLE schedules DROP COLUMN IF EXISTS cancel_reason;
+ALTER TABLE schedules DROP COLUMN IF EXISTS cancelled_by;
+ALTER TABLE schedules DROP COLUMN IF EXISTS cancelled_at;
@@ -0,0 +1,3 @@
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS cancelled_at timestamp;
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS cancelled_by uuid;
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS cancel_reason TEXT;
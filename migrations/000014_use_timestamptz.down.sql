@@ -0,0 +1,50 @@
+SET TIME ZONE 'UTC';
+
+DROP TABLE IF EXISTS user_settings;
+
+ALTER TABLE feed_tokens ALTER COLUMN created_at TYPE timestamp;
+
+ALTER TABLE calendar_jobs
+    ALTER COLUMN start_date TYPE timestamp,
+    ALTER COLUMN end_date TYPE timestamp,
+    ALTER COLUMN created_at TYPE timestamp,
+    ALTER COLUMN next_attempt_at TYPE timestamp;
+
+ALTER TABLE outbox
+    ALTER COLUMN created_at TYPE timestamp,
+    ALTER COLUMN next_attempt_at TYPE timestamp,
+    ALTER COLUMN delivered_at TYPE timestamp;
+
+ALTER TABLE attendance
+    ALTER COLUMN start_date TYPE timestamp,
+    ALTER COLUMN end_date TYPE timestamp,
+    ALTER COLUMN marked_at TYPE timestamp;
+
+ALTER TABLE availability
+    ALTER COLUMN start_date TYPE timestamp,
+    ALTER COLUMN end_date TYPE timestamp,
+    ALTER COLUMN until TYPE timestamp,
+    ALTER COLUMN exdates DROP DEFAULT,
+    ALTER COLUMN exdates TYPE timestamp[],
+    ALTER COLUMN exdates SET DEFAULT array[]::timestamp[];
+
+ALTER TABLE schedule_series
+    DROP COLUMN IF EXISTS time_zone,
+    ALTER COLUMN start_date TYPE timestamp,
+    ALTER COLUMN end_date TYPE timestamp,
+    ALTER COLUMN until TYPE timestamp,
+    ALTER COLUMN exdates DROP DEFAULT,
+    ALTER COLUMN exdates TYPE timestamp[],
+    ALTER COLUMN exdates SET DEFAULT array[]::timestamp[];
+
+DROP INDEX IF EXISTS schedules_student_period_idx;
+DROP INDEX IF EXISTS schedules_trainer_period_idx;
+
+ALTER TABLE schedules
+    ALTER COLUMN start_date TYPE timestamp,
+    ALTER COLUMN end_date TYPE timestamp,
+    ALTER COLUMN recurrence_id TYPE timestamp,
+    ALTER COLUMN cancelled_at TYPE timestamp;
+
+CREATE INDEX IF NOT EXISTS schedules_trainer_period_idx ON schedules USING gist (trainer_id, tsrange(start_date, end_date));
+CREATE INDEX IF NOT EXISTS schedules_student_period_idx ON schedules USING gist (student_id, tsrange(start_date, end_date));
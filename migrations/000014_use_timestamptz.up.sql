@@ -0,0 +1,54 @@
+-- existing values were written in UTC
+SET TIME ZONE 'UTC';
+
+DROP INDEX IF EXISTS schedules_student_period_idx;
+DROP INDEX IF EXISTS schedules_trainer_period_idx;
+
+ALTER TABLE schedules
+    ALTER COLUMN start_date TYPE timestamptz,
+    ALTER COLUMN end_date TYPE timestamptz,
+    ALTER COLUMN recurrence_id TYPE timestamptz,
+    ALTER COLUMN cancelled_at TYPE timestamptz;
+
+CREATE INDEX IF NOT EXISTS schedules_trainer_period_idx ON schedules USING gist (trainer_id, tstzrange(start_date, end_date));
+CREATE INDEX IF NOT EXISTS schedules_student_period_idx ON schedules USING gist (student_id, tstzrange(start_date, end_date));
+
+ALTER TABLE schedule_series
+    ALTER COLUMN start_date TYPE timestamptz,
+    ALTER COLUMN end_date TYPE timestamptz,
+    ALTER COLUMN until TYPE timestamptz,
+    ALTER COLUMN exdates DROP DEFAULT,
+    ALTER COLUMN exdates TYPE timestamptz[],
+    ALTER COLUMN exdates SET DEFAULT array[]::timestamptz[],
+    ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE availability
+    ALTER COLUMN start_date TYPE timestamptz,
+    ALTER COLUMN end_date TYPE timestamptz,
+    ALTER COLUMN until TYPE timestamptz,
+    ALTER COLUMN exdates DROP DEFAULT,
+    ALTER COLUMN exdates TYPE timestamptz[],
+    ALTER COLUMN exdates SET DEFAULT array[]::timestamptz[];
+
+ALTER TABLE attendance
+    ALTER COLUMN start_date TYPE timestamptz,
+    ALTER COLUMN end_date TYPE timestamptz,
+    ALTER COLUMN marked_at TYPE timestamptz;
+
+ALTER TABLE outbox
+    ALTER COLUMN created_at TYPE timestamptz,
+    ALTER COLUMN next_attempt_at TYPE timestamptz,
+    ALTER COLUMN delivered_at TYPE timestamptz;
+
+ALTER TABLE calendar_jobs
+    ALTER COLUMN start_date TYPE timestamptz,
+    ALTER COLUMN end_date TYPE timestamptz,
+    ALTER COLUMN created_at TYPE timestamptz,
+    ALTER COLUMN next_attempt_at TYPE timestamptz;
+
+ALTER TABLE feed_tokens ALTER COLUMN created_at TYPE timestamptz;
+
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id uuid PRIMARY KEY,
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC'
+);
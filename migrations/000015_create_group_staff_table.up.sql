@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS group_staff (
+    group_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    added_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS group_staff_user_idx ON group_staff (user_id);
@@ -0,0 +1,9 @@
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS student_ids uuid[] NOT NULL DEFAULT array[]::uuid[];
+
+UPDATE groups SET student_ids = ARRAY(
+    SELECT user_id FROM group_members
+    WHERE group_members.group_id = groups.id AND status = 'active'
+    ORDER BY joined_at
+);
+
+DROP TABLE IF EXISTS group_members;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS group_members (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'active',
+    joined_at timestamptz NOT NULL DEFAULT now(),
+    left_at timestamptz
+);
+
+-- a member can leave and rejoin, every stay is kept but only one can be active
+CREATE UNIQUE INDEX IF NOT EXISTS group_members_active_idx ON group_members (group_id, user_id) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS group_members_user_idx ON group_members (user_id) WHERE status = 'active';
+
+INSERT INTO group_members (group_id, user_id)
+SELECT DISTINCT groups.id, student_id
+FROM groups, unnest(groups.student_ids) AS student_id
+WHERE student_id IS NOT NULL;
+
+ALTER TABLE groups DROP COLUMN IF EXISTS student_ids;
@@ -0,0 +1,3 @@
+ALTER TABLE group_members DROP COLUMN IF EXISTS invitation_id;
+
+DROP TABLE IF EXISTS group_invitations;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS group_invitations (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id uuid NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_by uuid NOT NULL,
+    student_id uuid,
+    max_uses INT NOT NULL DEFAULT 0,
+    uses INT NOT NULL DEFAULT 0,
+    expires_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS group_invitations_group_idx ON group_invitations (group_id);
+
+ALTER TABLE groups ALTER COLUMN invitation_link TYPE VARCHAR(64);
+
+-- the permanent links become unlimited invitations
+INSERT INTO group_invitations (group_id, token_hash, created_by)
+SELECT id, encode(sha256(convert_to(invitation_link, 'UTF8')), 'hex'), trainer_id
+FROM groups;
+
+ALTER TABLE group_members ADD COLUMN IF NOT EXISTS invitation_id uuid;
@@ -0,0 +1,14 @@
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS requires_approval BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS group_join_requests (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id uuid NOT NULL,
+    student_id uuid NOT NULL,
+    invitation_id uuid,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+    requested_at timestamptz NOT NULL DEFAULT now(),
+    decided_at timestamptz,
+    decided_by uuid
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS group_join_requests_pending_idx ON group_join_requests (group_id, student_id) WHERE status = 'pending';
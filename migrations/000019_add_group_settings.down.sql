@@ -0,0 +1,6 @@
+ALTER TABLE groups
+    DROP COLUMN IF EXISTS description,
+    DROP COLUMN IF EXISTS capacity,
+    DROP COLUMN IF EXISTS default_duration,
+    DROP COLUMN IF EXISTS location,
+    DROP COLUMN IF EXISTS archived_at;
@@ -0,0 +1,6 @@
+ALTER TABLE groups
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS capacity INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS default_duration INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS location VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS archived_at timestamptz;
@@ -0,0 +1 @@
+ALTER TABLE calendar_jobs DROP COLUMN IF EXISTS calendar_id;
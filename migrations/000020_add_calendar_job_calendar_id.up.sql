@@ -0,0 +1 @@
+ALTER TABLE calendar_jobs ADD COLUMN IF NOT EXISTS calendar_id VARCHAR(100) NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS calendars_group_idx;
+
+DELETE FROM calendars WHERE user_id NOT IN (SELECT trainer_id FROM groups WHERE groups.id = calendars.group_id);
+
+ALTER TABLE calendars
+    DROP CONSTRAINT IF EXISTS calendars_pkey,
+    DROP COLUMN IF EXISTS user_id,
+    ADD PRIMARY KEY (group_id);
@@ -0,0 +1,13 @@
+ALTER TABLE calendars ADD COLUMN IF NOT EXISTS user_id uuid;
+
+-- calendars were created in the account of the first member whose event was synced,
+-- which is the trainer for lessons created by them
+UPDATE calendars SET user_id = groups.trainer_id FROM groups WHERE groups.id = calendars.group_id;
+DELETE FROM calendars WHERE user_id IS NULL;
+
+ALTER TABLE calendars
+    DROP CONSTRAINT IF EXISTS calendars_pkey,
+    ALTER COLUMN user_id SET NOT NULL,
+    ADD PRIMARY KEY (user_id, group_id);
+
+CREATE INDEX IF NOT EXISTS calendars_group_idx ON calendars (group_id);
@@ -0,0 +1 @@
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS calendar_mode VARCHAR(10) NOT NULL DEFAULT 'copy';
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS calendars_channel_idx;
+DROP INDEX IF EXISTS calendars_next_sync_idx;
+
+ALTER TABLE calendars
+    DROP COLUMN IF EXISTS sync_token,
+    DROP COLUMN IF EXISTS next_sync_at,
+    DROP COLUMN IF EXISTS channel_id,
+    DROP COLUMN IF EXISTS channel_resource_id,
+    DROP COLUMN IF EXISTS channel_expires_at;
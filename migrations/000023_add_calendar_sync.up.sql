@@ -0,0 +1,9 @@
+ALTER TABLE calendars
+    ADD COLUMN IF NOT EXISTS sync_token TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS next_sync_at timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS channel_id uuid,
+    ADD COLUMN IF NOT EXISTS channel_resource_id VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS channel_expires_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS calendars_next_sync_idx ON calendars (next_sync_at);
+CREATE UNIQUE INDEX IF NOT EXISTS calendars_channel_idx ON calendars (channel_id) WHERE channel_id IS NOT NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS invitation_id uuid;
+
+-- the default invitation is the one whose token is the link of the group
+UPDATE groups SET invitation_id = group_invitations.id
+FROM group_invitations
+WHERE group_invitations.group_id = groups.id
+AND group_invitations.token_hash = encode(sha256(convert_to(groups.invitation_link, 'UTF8')), 'hex');
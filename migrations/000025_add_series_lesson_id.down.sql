@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS schedule_series_lesson_idx;
+
+ALTER TABLE schedule_series DROP COLUMN IF EXISTS lesson_id;
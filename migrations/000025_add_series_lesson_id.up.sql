@@ -0,0 +1,13 @@
+ALTER TABLE schedule_series ADD COLUMN IF NOT EXISTS lesson_id uuid;
+
+-- a group lesson is stored as one series per student with the same trainer and times
+UPDATE schedule_series SET lesson_id = lessons.lesson_id
+FROM (
+    SELECT id, first_value(id) OVER (PARTITION BY group_id, trainer_id, start_date, end_date, frequency, interval, by_day ORDER BY id) AS lesson_id
+    FROM schedule_series
+) AS lessons
+WHERE lessons.id = schedule_series.id;
+
+ALTER TABLE schedule_series ALTER COLUMN lesson_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS schedule_series_lesson_idx ON schedule_series (lesson_id);
@@ -0,0 +1,11 @@
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS lesson_id uuid;
+
+-- a group lesson is stored as one schedule per student with the same trainer and times
+UPDATE schedules SET lesson_id = lessons.lesson_id
+FROM (
+    SELECT id, first_value(id) OVER (PARTITION BY group_id, trainer_id, start_date, end_date ORDER BY id) AS lesson_id
+    FROM schedules
+) AS lessons
+WHERE lessons.id = schedules.id;
+
+ALTER TABLE schedules ALTER COLUMN lesson_id SET NOT NULL;